@@ -784,6 +784,87 @@ public:
 
     
 
+        return grpc::Status::OK;
+    }
+
+    grpc::Status EmbeddingBatch(ServerContext* context, const backend::PredictOptions* request, backend::EmbeddingBatchResult* batchResult) {
+        // the texts and the tokens are embedded in a single call, in this order
+        json prompt = json::array();
+        for (const auto & text : request->embeddingsbatch()) {
+            prompt.push_back(text);
+        }
+        for (const auto & tokens : request->embeddingtokensbatch()) {
+            prompt.push_back(json(std::vector<int32_t>(tokens.tokens().begin(), tokens.tokens().end())));
+        }
+        if (prompt.empty()) {
+            return grpc::Status(grpc::StatusCode::INVALID_ARGUMENT, "No inputs to embed");
+        }
+
+        auto tokenized_prompts = tokenize_input_prompts(ctx_server.vocab, ctx_server.mctx, prompt, true, true);
+        for (const auto & tokens : tokenized_prompts) {
+            // this check is necessary for models that do not add BOS token to the input
+            if (tokens.empty()) {
+                return grpc::Status(grpc::StatusCode::INVALID_ARGUMENT, "Input content cannot be empty");
+            }
+        }
+
+        int embd_normalize = 2; // default to Euclidean/L2 norm
+        std::vector<json> responses(tokenized_prompts.size());
+        bool error = false;
+        std::unordered_set<int> task_ids;
+        {
+            std::vector<server_task> tasks;
+            for (size_t i = 0; i < tokenized_prompts.size(); i++) {
+                server_task task = server_task(SERVER_TASK_TYPE_EMBEDDING);
+
+                task.id            = ctx_server.queue_tasks.get_new_id();
+                task.index         = i;
+                task.prompt_tokens = std::move(tokenized_prompts[i]);
+
+                task.params.oaicompat = OAICOMPAT_TYPE_NONE;
+                task.params.embd_normalize = embd_normalize;
+                tasks.push_back(std::move(task));
+            }
+
+            task_ids = server_task::get_list_id(tasks);
+            ctx_server.queue_results.add_waiting_tasks(tasks);
+            ctx_server.queue_tasks.post(std::move(tasks));
+        }
+
+        // the results may arrive in any order, they are put back in the order of the inputs
+        ctx_server.receive_multi_results(task_ids, [&](std::vector<server_task_result_ptr> & results) {
+            for (auto & res : results) {
+                GGML_ASSERT(dynamic_cast<server_task_result_embd*>(res.get()) != nullptr);
+                json response = res->to_json();
+                size_t index = response.value("index", 0);
+                if (index < responses.size()) {
+                    responses[index] = response;
+                }
+            }
+        }, [&](const json & error_data) {
+            error = true;
+        }, [&]() {
+            return false;
+        });
+
+        ctx_server.queue_results.remove_waiting_task_ids(task_ids);
+
+        if (error) {
+            return grpc::Status(grpc::StatusCode::INTERNAL, "Error in receiving results");
+        }
+
+        for (const auto & response_elem : responses) {
+            backend::EmbeddingResult* embeddingResult = batchResult->add_embeddings();
+            json embedding_data = json_value(response_elem, "embedding", json::array());
+            for (const auto & embedding_vector : embedding_data) {
+                if (embedding_vector.is_array()) {
+                    for (const auto & embedding_value : embedding_vector) {
+                        embeddingResult->add_embeddings(embedding_value.get<float>());
+                    }
+                }
+            }
+        }
+
         return grpc::Status::OK;
     }
 
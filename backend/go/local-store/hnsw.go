@@ -0,0 +1,387 @@
+package main
+
+// The HNSW index is a Hierarchical Navigable Small World graph over the keys of a store (Malkov & Yashunin,
+// 2016). Searches walk the graph from its top layer down, visiting a small part of the keys instead of all
+// of them, at the cost of sometimes missing some of the most similar ones. ef bounds the keys considered at
+// each step, higher values trade speed for recall.
+//
+// Keys are compared with the metric of the store, like the linear search. Deleted keys stay in the graph as
+// their links are needed to reach the other keys, the graph is rebuilt once they are the majority.
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 64
+)
+
+type hnswNode struct {
+	key      []float32
+	value    []byte
+	metadata map[string]any
+	invNorm  float32
+	// The neighbours of the node on each layer it is in, from the bottom one
+	links   [][]int32
+	deleted bool
+}
+
+type hnswParams struct {
+	// The number of neighbours of the nodes, twice as many on the bottom layer
+	m              int
+	efConstruction int
+	efSearch       int
+}
+
+type hnswIndex struct {
+	hnswParams
+	metric    metric
+	levelMult float64
+
+	nodes    []*hnswNode
+	ids      map[string]int32
+	entry    int32
+	maxLevel int
+	deleted  int
+
+	rng *rand.Rand
+	// The nodes visited by a search are marked with its generation
+	visited    []uint32
+	generation uint32
+}
+
+func newHNSWIndex(params hnswParams, metric metric) *hnswIndex {
+	return &hnswIndex{
+		hnswParams: params,
+		metric:     metric,
+		levelMult:  1 / math.Log(float64(params.m)),
+		ids:        make(map[string]int32),
+		entry:      -1,
+		// The graph is deterministic for the same changes
+		rng: rand.New(rand.NewSource(1)),
+	}
+}
+
+func keyID(k []float32) string {
+	b := make([]byte, 4*len(k))
+	for i, f := range k {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return string(b)
+}
+
+func invNorm(k []float32) float32 {
+	var sum float64
+	for _, v := range k {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return 0
+	}
+	return float32(1 / math.Sqrt(sum))
+}
+
+func dot(k1, k2 []float32) float32 {
+	assert(len(k1) == len(k2), fmt.Sprintf("dot: len(k1) = %d, len(k2) = %d", len(k1), len(k2)))
+
+	var d float32
+	for i := range k1 {
+		d += k1[i] * k2[i]
+	}
+	return d
+}
+
+func (h *hnswIndex) similarity(q []float32, qInvNorm float32, id int32) float32 {
+	n := h.nodes[id]
+	switch h.metric {
+	case metricDot:
+		return dot(q, n.key)
+	case metricL2:
+		return -l2Distance(q, n.key)
+	}
+	return dot(q, n.key) * qInvNorm * n.invNorm
+}
+
+type hnswCandidate struct {
+	id         int32
+	similarity float32
+}
+
+// hnswQueue is a heap of candidates, the least similar first unless mostSimilarFirst is set
+type hnswQueue struct {
+	items            []hnswCandidate
+	mostSimilarFirst bool
+}
+
+func (q *hnswQueue) Len() int { return len(q.items) }
+
+func (q *hnswQueue) Less(i, j int) bool {
+	if q.mostSimilarFirst {
+		return q.items[i].similarity > q.items[j].similarity
+	}
+	return q.items[i].similarity < q.items[j].similarity
+}
+
+func (q *hnswQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *hnswQueue) Push(x any) { q.items = append(q.items, x.(hnswCandidate)) }
+
+func (q *hnswQueue) Pop() any {
+	item := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return item
+}
+
+func sortCandidates(candidates []hnswCandidate) {
+	slices.SortFunc(candidates, func(a, b hnswCandidate) int {
+		if a.similarity > b.similarity {
+			return -1
+		} else if a.similarity < b.similarity {
+			return 1
+		}
+		return 0
+	})
+}
+
+// visit marks a node as visited by the current search, returning false if it already was
+func (h *hnswIndex) visit(id int32) bool {
+	if h.visited[id] == h.generation {
+		return false
+	}
+	h.visited[id] = h.generation
+	return true
+}
+
+// searchLayer returns the ef nodes of the layer most similar to q found from the entries, the most similar
+// first. The nodes which are not accepted are walked through but not returned, unless accept is nil.
+func (h *hnswIndex) searchLayer(q []float32, qInvNorm float32, entries []hnswCandidate, ef, level int, accept func(*hnswNode) bool) []hnswCandidate {
+	h.generation++
+	if len(h.visited) < len(h.nodes) || h.generation == 0 {
+		h.visited = make([]uint32, cap(h.nodes))
+		h.generation = 1
+	}
+
+	candidates := &hnswQueue{mostSimilarFirst: true}
+	results := &hnswQueue{}
+	for _, e := range entries {
+		h.visit(e.id)
+		heap.Push(candidates, e)
+		if accept == nil || accept(h.nodes[e.id]) {
+			heap.Push(results, e)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && c.similarity < results.items[0].similarity {
+			break
+		}
+
+		for _, id := range h.nodes[c.id].links[level] {
+			if !h.visit(id) {
+				continue
+			}
+
+			sim := h.similarity(q, qInvNorm, id)
+			if results.Len() < ef || sim > results.items[0].similarity {
+				heap.Push(candidates, hnswCandidate{id: id, similarity: sim})
+				if accept != nil && !accept(h.nodes[id]) {
+					continue
+				}
+				heap.Push(results, hnswCandidate{id: id, similarity: sim})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sortCandidates(results.items)
+	return results.items
+}
+
+// selectNeighbours keeps the m candidates, sorted by similarity, which are more similar to the node than to
+// the already selected ones, so that the links go in different directions. The closest of the pruned ones
+// fill the remaining links.
+func (h *hnswIndex) selectNeighbours(candidates []hnswCandidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var pruned []int32
+
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+
+		n := h.nodes[c.id]
+		diverse := true
+		for _, s := range selected {
+			if h.similarity(n.key, n.invNorm, s) > c.similarity {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.id)
+		} else {
+			pruned = append(pruned, c.id)
+		}
+	}
+
+	for _, id := range pruned {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, id)
+	}
+
+	return selected
+}
+
+func (h *hnswIndex) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * h.m
+	}
+	return h.m
+}
+
+// link adds a link from a node to another, pruning its links if it has too many
+func (h *hnswIndex) link(from, to int32, level int) {
+	n := h.nodes[from]
+	n.links[level] = append(n.links[level], to)
+	if len(n.links[level]) <= h.maxLinks(level) {
+		return
+	}
+
+	candidates := make([]hnswCandidate, len(n.links[level]))
+	for i, id := range n.links[level] {
+		candidates[i] = hnswCandidate{id: id, similarity: h.similarity(n.key, n.invNorm, id)}
+	}
+	sortCandidates(candidates)
+	n.links[level] = h.selectNeighbours(candidates, h.maxLinks(level))
+}
+
+// insert adds a key to the graph, or updates its value if it is already there
+func (h *hnswIndex) insert(key []float32, value []byte, metadata map[string]any) {
+	kid := keyID(key)
+	if id, ok := h.ids[kid]; ok {
+		n := h.nodes[id]
+		n.value = value
+		n.metadata = metadata
+		if n.deleted {
+			n.deleted = false
+			h.deleted--
+		}
+		return
+	}
+
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	n := &hnswNode{
+		key:      key,
+		value:    value,
+		metadata: metadata,
+		invNorm:  invNorm(key),
+		links:    make([][]int32, level+1),
+	}
+	id := int32(len(h.nodes))
+	h.nodes = append(h.nodes, n)
+	h.ids[kid] = id
+
+	if h.entry == -1 {
+		h.entry = id
+		h.maxLevel = level
+		return
+	}
+
+	entries := []hnswCandidate{{id: h.entry, similarity: h.similarity(key, n.invNorm, h.entry)}}
+	for l := h.maxLevel; l > level; l-- {
+		entries = h.searchLayer(key, n.invNorm, entries, 1, l, nil)[:1]
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(key, n.invNorm, entries, h.efConstruction, l, nil)
+		n.links[l] = h.selectNeighbours(found, h.m)
+		for _, neighbour := range n.links[l] {
+			h.link(neighbour, id, l)
+		}
+		entries = found
+	}
+
+	if level > h.maxLevel {
+		h.entry = id
+		h.maxLevel = level
+	}
+}
+
+// delete marks a key as deleted, rebuilding the graph once most of the keys are
+func (h *hnswIndex) delete(key []float32) {
+	id, ok := h.ids[keyID(key)]
+	if !ok || h.nodes[id].deleted {
+		return
+	}
+	h.nodes[id].deleted = true
+	h.nodes[id].value = nil
+	h.nodes[id].metadata = nil
+	h.deleted++
+
+	if h.deleted > len(h.nodes)/2 {
+		h.rebuild()
+	}
+}
+
+func (h *hnswIndex) rebuild() {
+	log.Debug().Int("keys", len(h.nodes)-h.deleted).Int("deleted", h.deleted).Msg("rebuilding the HNSW index")
+
+	nodes := h.nodes
+	h.nodes = make([]*hnswNode, 0, len(nodes)-h.deleted)
+	h.ids = make(map[string]int32, len(nodes)-h.deleted)
+	h.entry = -1
+	h.maxLevel = 0
+	h.deleted = 0
+	h.visited = nil
+	for _, n := range nodes {
+		if !n.deleted {
+			h.insert(n.key, n.value, n.metadata)
+		}
+	}
+}
+
+// search returns the topK keys most similar to q which are accepted, the most similar first. The search only
+// stops once it found ef accepted keys, so it finds less than topK keys after visiting all the keys reachable in
+// the graph: exhaustive then tells whether these were all the keys of the index, and so all the accepted ones.
+func (h *hnswIndex) search(q []float32, topK int, accept func(*hnswNode) bool) (found []hnswCandidate, exhaustive bool) {
+	if h.entry == -1 {
+		return nil, true
+	}
+
+	qInvNorm := invNorm(q)
+	entries := []hnswCandidate{{id: h.entry, similarity: h.similarity(q, qInvNorm, h.entry)}}
+	for l := h.maxLevel; l > 0; l-- {
+		entries = h.searchLayer(q, qInvNorm, entries, 1, l, nil)[:1]
+	}
+
+	found = h.searchLayer(q, qInvNorm, entries, max(h.efSearch, topK), 0, func(n *hnswNode) bool {
+		return !n.deleted && accept(n)
+	})
+	if len(found) > topK {
+		return found[:topK], false
+	}
+	if len(found) == topK {
+		return found, false
+	}
+
+	visited := 0
+	for id, n := range h.nodes {
+		if !n.deleted && h.visited[id] == h.generation {
+			visited++
+		}
+	}
+	return found, visited == len(h.nodes)-h.deleted
+}
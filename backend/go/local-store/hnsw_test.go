@@ -0,0 +1,233 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HNSW index", func() {
+	const (
+		count = 2000
+		dim   = 32
+		topK  = 10
+	)
+
+	var rng *rand.Rand
+
+	BeforeEach(func() {
+		rng = rand.New(rand.NewSource(42))
+	})
+
+	randomKeys := func(n int, normalized bool) [][]float32 {
+		keys := make([][]float32, n)
+		for i := range keys {
+			keys[i] = make([]float32, dim)
+			for j := range keys[i] {
+				keys[i][j] = float32(rng.NormFloat64())
+			}
+			if normalized {
+				inv := invNorm(keys[i])
+				for j := range keys[i] {
+					keys[i][j] *= inv
+				}
+			}
+		}
+		return keys
+	}
+
+	newStore := func(options ...string) *Store {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Options: append([]string{"index:hnsw"}, options...)})).To(Succeed())
+		Expect(s.hnsw).ToNot(BeNil())
+		return s
+	}
+
+	set := func(s *Store, keys [][]float32) {
+		opts := &pb.StoresSetOptions{}
+		for i, k := range keys {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+			opts.Values = append(opts.Values, &pb.StoresValue{Bytes: []byte{byte(i), byte(i >> 8)}})
+		}
+		Expect(s.StoresSet(opts)).To(Succeed())
+	}
+
+	find := func(s *Store, q []float32) pb.StoresFindResult {
+		res, err := s.StoresFind(&pb.StoresFindOptions{Key: &pb.StoresKey{Floats: q}, TopK: topK})
+		Expect(err).ToNot(HaveOccurred())
+		return res
+	}
+
+	// recall is the share of the keys found by the linear search which the index finds as well
+	recall := func(s *Store, queries [][]float32, linear func(*pb.StoresFindOptions, *store.Filter) (pb.StoresFindResult, error)) float64 {
+		var found, expected int
+		for _, q := range queries {
+			approx := find(s, q)
+			exact, err := linear(&pb.StoresFindOptions{Key: &pb.StoresKey{Floats: q}, TopK: topK}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			ids := map[string]bool{}
+			for _, k := range approx.Keys {
+				ids[keyID(k.Floats)] = true
+			}
+			for i, k := range exact.Keys {
+				if ids[keyID(k.Floats)] {
+					found++
+				}
+				expected++
+				if i < len(approx.Similarities) {
+					Expect(approx.Similarities[i]).To(BeNumerically("<=", exact.Similarities[0]+1e-4))
+				}
+			}
+		}
+		return float64(found) / float64(expected)
+	}
+
+	It("finds the keys of the linear search with normalized keys", func() {
+		s := newStore()
+		set(s, randomKeys(count, true))
+
+		Expect(recall(s, randomKeys(50, true), s.collections[""].findNormalized)).To(BeNumerically(">=", 0.95))
+	})
+
+	It("finds the keys of the linear search with keys which are not normalized", func() {
+		s := newStore("hnsw_m:8", "hnsw_ef_search:100")
+		Expect(s.hnsw.m).To(Equal(8))
+		Expect(s.hnsw.efSearch).To(Equal(100))
+		set(s, randomKeys(count, false))
+
+		Expect(recall(s, randomKeys(50, false), s.collections[""].findFallback)).To(BeNumerically(">=", 0.95))
+	})
+
+	It("finds the keys inserted after the first ones", func() {
+		s := newStore()
+		keys := randomKeys(count, true)
+		for i := 0; i < count; i += 100 {
+			set(s, keys[i:i+100])
+		}
+
+		Expect(recall(s, randomKeys(50, true), s.collections[""].findNormalized)).To(BeNumerically(">=", 0.95))
+	})
+
+	It("does not return deleted keys and rebuilds the graph once most keys are deleted", func() {
+		s := newStore()
+		keys := randomKeys(count, true)
+		set(s, keys)
+
+		deleted := map[string]bool{}
+		opts := &pb.StoresDeleteOptions{}
+		for _, k := range keys[:count/3] {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+			deleted[keyID(k)] = true
+		}
+		Expect(s.StoresDelete(opts)).To(Succeed())
+		Expect(s.collections[""].index.deleted).To(Equal(count / 3))
+
+		queries := append(randomKeys(20, true), keys[:20]...)
+		for _, q := range queries {
+			for _, k := range find(s, q).Keys {
+				Expect(deleted[keyID(k.Floats)]).To(BeFalse())
+			}
+		}
+		Expect(recall(s, queries, s.collections[""].findNormalized)).To(BeNumerically(">=", 0.95))
+
+		opts = &pb.StoresDeleteOptions{}
+		for _, k := range keys[count/3 : 2*(count/3)] {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+		}
+		Expect(s.StoresDelete(opts)).To(Succeed())
+		Expect(len(s.collections[""].index.nodes)).To(BeNumerically("<", count))
+		Expect(len(s.collections[""].index.nodes) - s.collections[""].index.deleted).To(Equal(count - 2*(count/3)))
+		Expect(recall(s, queries, s.collections[""].findNormalized)).To(BeNumerically(">=", 0.95))
+	})
+
+	It("updates the values of keys which are set again", func() {
+		s := newStore()
+		keys := randomKeys(100, true)
+		set(s, keys)
+
+		Expect(s.StoresSet(&pb.StoresSetOptions{
+			Keys:   []*pb.StoresKey{{Floats: keys[7]}},
+			Values: []*pb.StoresValue{{Bytes: []byte("updated")}},
+		})).To(Succeed())
+
+		res := find(s, keys[7])
+		Expect(res.Keys[0].Floats).To(Equal(keys[7]))
+		Expect(res.Values[0].Bytes).To(Equal([]byte("updated")))
+		Expect(res.Similarities[0]).To(BeNumerically("~", 1, 1e-5))
+		Expect(s.collections[""].index.nodes).To(HaveLen(100))
+	})
+
+	It("builds the index of a persisted store on load", func() {
+		modelPath := GinkgoT().TempDir()
+		options := &pb.ModelOptions{Model: "test", ModelPath: modelPath, Options: []string{"index:hnsw"}}
+
+		s := NewStore()
+		Expect(s.Load(options)).To(Succeed())
+		keys := randomKeys(500, true)
+		set(s, keys)
+		Expect(s.Unload()).To(Succeed())
+
+		s = NewStore()
+		Expect(s.Load(options)).To(Succeed())
+		Expect(s.collections[""].index.nodes).To(HaveLen(500))
+		Expect(find(s, keys[3]).Keys[0].Floats).To(Equal(keys[3]))
+	})
+
+	It("searches linearly only the keys matching the filter which the graph cannot reach", func() {
+		s := newStore()
+		keys := randomKeys(count, true)
+		opts := &pb.StoresSetOptions{}
+		for i, k := range keys {
+			v := &pb.StoresValue{Bytes: []byte{byte(i), byte(i >> 8)}}
+			if i%700 == 1 {
+				v.Metadata = []byte(`{"rare": true}`)
+			}
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+			opts.Values = append(opts.Values, v)
+		}
+		Expect(s.StoresSet(opts)).To(Succeed())
+
+		c := s.collections[""]
+		filter, err := store.ParseFilter([]byte(`{"rare": true}`))
+		Expect(err).ToNot(HaveOccurred())
+		findOpts := &pb.StoresFindOptions{Key: &pb.StoresKey{Floats: keys[0]}, TopK: topK, Filter: []byte(`{"rare": true}`)}
+
+		// the search walks all the graph to find the few matching keys, which are then all of them
+		res, complete := c.findIndexed(findOpts, filter)
+		Expect(complete).To(BeTrue())
+		Expect(res.Keys).To(HaveLen(3))
+
+		// a key no other key links to is only found linearly
+		unreachable := c.index.ids[keyID(keys[701])]
+		Expect(unreachable).ToNot(Equal(c.index.entry))
+		for _, n := range c.index.nodes {
+			for l := range n.links {
+				n.links[l] = slices.DeleteFunc(n.links[l], func(id int32) bool { return id == unreachable })
+			}
+		}
+
+		res, complete = c.findIndexed(findOpts, filter)
+		Expect(complete).To(BeFalse())
+		Expect(res.Keys).To(HaveLen(2))
+
+		res, err = s.StoresFind(findOpts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Keys).To(HaveLen(3))
+		Expect(res.Keys).To(ContainElement(HaveField("Floats", keys[701])))
+	})
+
+	It("rejects invalid options", func() {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Options: []string{"index:ivf"}})).ToNot(Succeed())
+		Expect(s.Load(&pb.ModelOptions{Options: []string{"index:hnsw", "hnsw_m:1"}})).ToNot(Succeed())
+		Expect(s.Load(&pb.ModelOptions{Options: []string{"index:hnsw", "hnsw_ef_search:many"}})).ToNot(Succeed())
+
+		Expect(s.Load(&pb.ModelOptions{Options: []string{"index:flat"}})).To(Succeed())
+		Expect(s.hnsw).To(BeNil())
+	})
+})
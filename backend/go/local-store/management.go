@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The sizes of the headers of the slices, strings and maps entries kept for each entry, in the memory estimates
+const (
+	sliceHeaderSize  = 24
+	stringHeaderSize = 16
+	mapEntrySize     = 48
+)
+
+func (s *Store) StoresStats(opts *pb.StoresStatsOptions) (pb.StoresStatsResult, error) {
+	res := pb.StoresStatsResult{
+		Metric:       string(s.metric),
+		Quantization: string(s.quantization),
+		Index:        "flat",
+		Persisted:    s.dir != "",
+	}
+	if s.hnsw != nil {
+		res.Index = "hnsw"
+	}
+
+	for _, namespace := range slices.Sorted(maps.Keys(s.collections)) {
+		c := s.collections[namespace]
+		res.Namespaces = append(res.Namespaces, &pb.StoresNamespaceStats{
+			Namespace:   namespace,
+			Entries:     int64(len(c.values)),
+			KeyLength:   int32(c.keyLen),
+			MemoryBytes: c.memoryBytes(),
+		})
+	}
+
+	return res, nil
+}
+
+// memoryBytes estimates the memory used by the entries of the collection and its index
+func (c *collection) memoryBytes() int64 {
+	var size int64
+
+	if c.quantized != nil {
+		// each entry has a code, a scale and a hash, which is also a key of the rows
+		for _, code := range c.quantized.codes {
+			size += int64(len(code)) + stringHeaderSize + 4 + 8 + mapEntrySize
+		}
+	} else {
+		size += int64(len(c.keys)) * (4*int64(max(c.keyLen, 0)) + sliceHeaderSize)
+	}
+
+	for i, v := range c.values {
+		size += int64(len(v)) + sliceHeaderSize
+		if c.metadata[i] != nil {
+			size += int64(len(marshalMetadata(c.metadata[i])))
+		}
+	}
+
+	// the nodes share their keys, values and metadata with the collection
+	if c.index != nil {
+		for _, n := range c.index.nodes {
+			size += 4*int64(len(n.key)) + stringHeaderSize + mapEntrySize
+			for _, links := range n.links {
+				size += 4*int64(len(links)) + sliceHeaderSize
+			}
+		}
+	}
+
+	return size
+}
+
+// StoresDrop deletes the entries of all the namespaces and the files of the store, which are created again
+// once entries are set
+func (s *Store) StoresDrop(opts *pb.StoresDropOptions) error {
+	if s.persistence != nil {
+		if err := s.persistence.Close(); err != nil {
+			log.Warn().Err(err).Msg("failed to close the write-ahead log")
+		}
+		s.persistence = nil
+	}
+	s.reset()
+
+	if s.dir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(s.dir); err != nil {
+		return fmt.Errorf("failed to delete the store files: %w", err)
+	}
+
+	return nil
+}
+
+// StoresExport returns the entries of a namespace from the offset-th one, in the order they are stored in,
+// which only changes with the entries
+func (s *Store) StoresExport(opts *pb.StoresExportOptions) (pb.StoresExportResult, error) {
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return pb.StoresExportResult{}, fmt.Errorf("the offset and the limit must not be negative")
+	}
+
+	c := s.collection(opts.Namespace)
+	total := int64(len(c.values))
+	start, end := min(opts.Offset, total), total
+	if opts.Limit > 0 {
+		end = min(start+int64(opts.Limit), total)
+	}
+
+	res := pb.StoresExportResult{
+		Keys:   make([]*pb.StoresKey, 0, end-start),
+		Values: make([]*pb.StoresValue, 0, end-start),
+		Total:  total,
+	}
+	for i := start; i < end; i++ {
+		var k []float32
+		if c.quantized != nil {
+			k = c.quantized.key(int(i), c.keyLen)
+		} else {
+			k = c.keys[i]
+		}
+		res.Keys = append(res.Keys, &pb.StoresKey{Floats: k})
+		res.Values = append(res.Values, &pb.StoresValue{
+			Bytes:    c.values[i],
+			Metadata: marshalMetadata(c.metadata[i]),
+		})
+	}
+
+	return res, nil
+}
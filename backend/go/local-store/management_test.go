@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store management", func() {
+	var modelPath string
+
+	BeforeEach(func() {
+		modelPath = GinkgoT().TempDir()
+	})
+
+	load := func(options ...string) *Store {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Model: "test", ModelPath: modelPath, Options: options})).To(Succeed())
+		return s
+	}
+
+	// newStore returns a store kept in memory only
+	newStore := func(options ...string) *Store {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Options: options})).To(Succeed())
+		return s
+	}
+
+	set := func(s *Store, namespace string, n int) {
+		opts := &pb.StoresSetOptions{Namespace: namespace}
+		for i := 0; i < n; i++ {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: []float32{float32(i), 100, -1}})
+			opts.Values = append(opts.Values, &pb.StoresValue{Bytes: []byte{byte(i)}, Metadata: []byte(`{"i":1}`)})
+		}
+		Expect(s.StoresSet(opts)).To(Succeed())
+	}
+
+	export := func(s *Store, namespace string, limit int32) pb.StoresExportResult {
+		var all pb.StoresExportResult
+		for {
+			res, err := s.StoresExport(&pb.StoresExportOptions{Namespace: namespace, Offset: int64(len(all.Keys)), Limit: limit})
+			Expect(err).ToNot(HaveOccurred())
+			all.Total = res.Total
+			if len(res.Keys) == 0 {
+				return all
+			}
+			all.Keys = append(all.Keys, res.Keys...)
+			all.Values = append(all.Values, res.Values...)
+		}
+	}
+
+	It("gives the entries, key length and memory use of each namespace", func() {
+		s := load("index:hnsw")
+		set(s, "", 10)
+		set(s, "docs", 3)
+
+		res, err := s.StoresStats(&pb.StoresStatsOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Metric).To(Equal("cosine"))
+		Expect(res.Quantization).To(Equal("none"))
+		Expect(res.Index).To(Equal("hnsw"))
+		Expect(res.Persisted).To(BeTrue())
+		Expect(res.Namespaces).To(HaveLen(2))
+		Expect(res.Namespaces[0].Namespace).To(Equal(""))
+		Expect(res.Namespaces[0].Entries).To(Equal(int64(10)))
+		Expect(res.Namespaces[0].KeyLength).To(Equal(int32(3)))
+		Expect(res.Namespaces[1].Namespace).To(Equal("docs"))
+		Expect(res.Namespaces[1].Entries).To(Equal(int64(3)))
+		Expect(res.Namespaces[0].MemoryBytes).To(BeNumerically(">", res.Namespaces[1].MemoryBytes))
+	})
+
+	It("estimates less memory for quantized keys", func() {
+		keys := make([]*pb.StoresKey, 100)
+		values := make([]*pb.StoresValue, 100)
+		for i := range keys {
+			keys[i] = &pb.StoresKey{Floats: make([]float32, 256)}
+			keys[i].Floats[i] = 1
+			values[i] = &pb.StoresValue{Bytes: []byte{byte(i)}}
+		}
+
+		memory := func(s *Store) int64 {
+			Expect(s.StoresSet(&pb.StoresSetOptions{Keys: keys, Values: values})).To(Succeed())
+			res, err := s.StoresStats(&pb.StoresStatsOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			return res.Namespaces[0].MemoryBytes
+		}
+
+		Expect(memory(newStore("quantization:binary"))).To(BeNumerically("<", memory(newStore("quantization:int8"))))
+		Expect(memory(newStore("quantization:int8"))).To(BeNumerically("<", memory(newStore())/2))
+	})
+
+	It("exports the entries of a namespace in pages", func() {
+		for _, options := range [][]string{nil, {"quantization:int8"}} {
+			s := newStore(options...)
+			set(s, "docs", 25)
+
+			all := export(s, "docs", 10)
+			Expect(all.Total).To(Equal(int64(25)))
+			Expect(all.Keys).To(HaveLen(25))
+
+			// the exported entries set in another store give the same store
+			other := NewStore()
+			Expect(other.StoresSet(&pb.StoresSetOptions{Keys: all.Keys, Values: all.Values})).To(Succeed())
+			Expect(export(other, "", 0).Values).To(Equal(all.Values))
+			Expect(string(all.Values[0].Metadata)).To(Equal(`{"i":1}`))
+
+			res, err := s.StoresExport(&pb.StoresExportOptions{Namespace: "docs"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Keys).To(HaveLen(25))
+
+			res, err = s.StoresExport(&pb.StoresExportOptions{Namespace: "missing"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Total).To(BeZero())
+			Expect(res.Keys).To(BeEmpty())
+
+			_, err = s.StoresExport(&pb.StoresExportOptions{Offset: -1})
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("drops the entries and the files of the store", func() {
+		s := load()
+		set(s, "", 5)
+		set(s, "docs", 5)
+		Expect(s.persistence.snapshot(s)).To(Succeed())
+
+		Expect(s.StoresDrop(&pb.StoresDropOptions{})).To(Succeed())
+		Expect(s.collections).To(BeEmpty())
+		_, err := os.Stat(filepath.Join(modelPath, "stores", "test"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		// the store is persisted again once entries are set
+		set(s, "docs", 2)
+		Expect(s.Unload()).To(Succeed())
+		s = load()
+		res, err := s.StoresStats(&pb.StoresStatsOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Namespaces).To(HaveLen(1))
+		Expect(res.Namespaces[0].Entries).To(Equal(int64(2)))
+	})
+})
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// A metric compares the keys of a store. The similarities it gives are higher for more similar keys:
+//   - cosine is the cosine of the angle between the keys, from -1 to 1
+//   - dot is the dot product of the keys, which is the cosine similarity for normalized keys
+//   - l2 is the negated Euclidean distance between the keys
+type metric string
+
+const (
+	metricCosine metric = "cosine"
+	metricDot    metric = "dot"
+	metricL2     metric = "l2"
+)
+
+func l2Distance(k1, k2 []float32) float32 {
+	assert(len(k1) == len(k2), fmt.Sprintf("l2Distance: len(k1) = %d, len(k2) = %d", len(k1), len(k2)))
+
+	var d float32
+	for i := range k1 {
+		diff := k1[i] - k2[i]
+		d += diff * diff
+	}
+	return float32(math.Sqrt(float64(d)))
+}
+
+// similarity returns the function comparing the keys to q with the metric
+func (m metric) similarity(q []float32) func(k []float32) float32 {
+	switch m {
+	case metricDot:
+		return func(k []float32) float32 {
+			return dot(q, k)
+		}
+	case metricL2:
+		return func(k []float32) float32 {
+			return -l2Distance(q, k)
+		}
+	}
+
+	qInvNorm := invNorm(q)
+	return func(k []float32) float32 {
+		return dot(q, k) * qInvNorm * invNorm(k)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Namespaces and metadata", func() {
+	set := func(s *Store, namespace string, keys [][]float32, values []string, metadata []string) error {
+		opts := &pb.StoresSetOptions{Namespace: namespace}
+		for i, k := range keys {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+			v := &pb.StoresValue{Bytes: []byte(values[i])}
+			if metadata != nil {
+				v.Metadata = []byte(metadata[i])
+			}
+			opts.Values = append(opts.Values, v)
+		}
+		return s.StoresSet(opts)
+	}
+
+	find := func(s *Store, namespace string, q []float32, topK int, filter string) ([]string, []string) {
+		res, err := s.StoresFind(&pb.StoresFindOptions{
+			Key:       &pb.StoresKey{Floats: q},
+			TopK:      int32(topK),
+			Namespace: namespace,
+			Filter:    []byte(filter),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		values, metadata := []string{}, []string{}
+		for _, v := range res.Values {
+			values = append(values, string(v.Bytes))
+			metadata = append(metadata, string(v.Metadata))
+		}
+		return values, metadata
+	}
+
+	It("keeps the entries of each namespace apart", func() {
+		s := NewStore()
+		Expect(set(s, "", [][]float32{{1, 0}, {0, 1}}, []string{"a", "b"}, nil)).To(Succeed())
+		Expect(set(s, "docs", [][]float32{{1, 0, 0}}, []string{"c"}, nil)).To(Succeed())
+
+		values, _ := find(s, "", []float32{1, 0}, 5, "")
+		Expect(values).To(Equal([]string{"a", "b"}))
+		values, _ = find(s, "docs", []float32{1, 0, 0}, 5, "")
+		Expect(values).To(Equal([]string{"c"}))
+
+		res, err := s.StoresGet(&pb.StoresGetOptions{Namespace: "docs", Keys: []*pb.StoresKey{{Floats: []float32{1, 0, 0}}}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Values).To(HaveLen(1))
+
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Namespace: "docs", Keys: []*pb.StoresKey{{Floats: []float32{1, 0, 0}}}})).To(Succeed())
+		Expect(s.collections).ToNot(HaveKey("docs"))
+		values, _ = find(s, "", []float32{1, 0}, 5, "")
+		Expect(values).To(HaveLen(2))
+	})
+
+	It("returns the metadata of the entries", func() {
+		s := NewStore()
+		Expect(set(s, "", [][]float32{{1, 0}, {0, 1}}, []string{"a", "b"}, []string{`{"page": 1}`, ""})).To(Succeed())
+
+		values, metadata := find(s, "", []float32{1, 0}, 2, "")
+		Expect(values).To(Equal([]string{"a", "b"}))
+		Expect(metadata).To(Equal([]string{`{"page":1}`, ""}))
+
+		res, err := s.StoresGet(&pb.StoresGetOptions{Keys: []*pb.StoresKey{{Floats: []float32{1, 0}}}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(res.Values[0].Metadata)).To(Equal(`{"page":1}`))
+
+		Expect(set(s, "", [][]float32{{1, 0}}, []string{"a"}, []string{`{"page": 2}`})).To(Succeed())
+		_, metadata = find(s, "", []float32{1, 0}, 1, "")
+		Expect(metadata).To(Equal([]string{`{"page":2}`}))
+	})
+
+	It("rejects metadata which is not a JSON object and invalid filters", func() {
+		s := NewStore()
+		Expect(set(s, "", [][]float32{{1, 0}}, []string{"a"}, []string{`[1]`})).ToNot(Succeed())
+		Expect(s.collections).To(BeEmpty())
+
+		Expect(set(s, "", [][]float32{{1, 0}}, []string{"a"}, nil)).To(Succeed())
+		_, err := s.StoresFind(&pb.StoresFindOptions{Key: &pb.StoresKey{Floats: []float32{1, 0}}, TopK: 1, Filter: []byte(`{"page": {"$near": 1}}`)})
+		Expect(err).To(HaveOccurred())
+	})
+
+	for _, options := range [][]string{nil, {"index:hnsw"}} {
+		It(fmt.Sprintf("finds the entries matching the filter with the options %v", options), func() {
+			s := NewStore()
+			Expect(s.Load(&pb.ModelOptions{Options: options})).To(Succeed())
+
+			keys := [][]float32{}
+			values := []string{}
+			metadata := []string{}
+			for i := 0; i < 200; i++ {
+				keys = append(keys, []float32{float32(i), 1})
+				values = append(values, fmt.Sprint(i))
+				metadata = append(metadata, fmt.Sprintf(`{"n": %d, "even": %t}`, i, i%2 == 0))
+			}
+			Expect(set(s, "", keys, values, metadata)).To(Succeed())
+
+			found, _ := find(s, "", []float32{1, 0}, 3, `{"even": false, "n": {"$gte": 100}}`)
+			Expect(found).To(Equal([]string{"199", "197", "195"}))
+
+			// less keys match than requested
+			found, _ = find(s, "", []float32{0, 1}, 10, `{"n": {"$in": [3, 4]}}`)
+			Expect(found).To(Equal([]string{"3", "4"}))
+
+			found, _ = find(s, "", []float32{1, 0}, 2, `{"n": -1}`)
+			Expect(found).To(BeEmpty())
+		})
+	}
+
+	for _, options := range [][]string{nil, {"index:hnsw"}, {"quantization:int8"}} {
+		It(fmt.Sprintf("finds no entries in an empty namespace with the options %v", options), func() {
+			s := NewStore()
+			Expect(s.Load(&pb.ModelOptions{Options: options})).To(Succeed())
+
+			found, _ := find(s, "", []float32{1, 0, 0}, 5, "")
+			Expect(found).To(BeEmpty())
+
+			Expect(set(s, "docs", [][]float32{{1, 0}}, []string{"a"}, nil)).To(Succeed())
+			Expect(s.StoresDelete(&pb.StoresDeleteOptions{Namespace: "docs", Keys: []*pb.StoresKey{{Floats: []float32{1, 0}}}})).To(Succeed())
+			found, _ = find(s, "docs", []float32{1, 0, 0}, 5, `{"lang": "en"}`)
+			Expect(found).To(BeEmpty())
+		})
+	}
+
+	It("deletes the entries matching a filter", func() {
+		s := NewStore()
+		Expect(set(s, "docs", [][]float32{{1, 0}, {0, 1}, {1, 1}}, []string{"a", "b", "c"},
+			[]string{`{"document_id": "x"}`, `{"document_id": "y"}`, `{"document_id": "x"}`})).To(Succeed())
+
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Namespace: "docs", Filter: []byte(`{"document_id": "x"}`)})).To(Succeed())
+		values, _ := find(s, "docs", []float32{1, 0}, 5, "")
+		Expect(values).To(Equal([]string{"b"}))
+
+		// nothing matches
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Namespace: "docs", Filter: []byte(`{"document_id": "x"}`)})).To(Succeed())
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Namespace: "other", Filter: []byte(`{"document_id": "y"}`)})).To(Succeed())
+
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Namespace: "docs", Filter: []byte(`{"$near": 1}`)})).ToNot(Succeed())
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{
+			Namespace: "docs",
+			Keys:      []*pb.StoresKey{{Floats: []float32{0, 1}}},
+			Filter:    []byte(`{"document_id": "y"}`),
+		})).ToNot(Succeed())
+	})
+
+	It("persists the namespaces and the metadata", func() {
+		modelPath := GinkgoT().TempDir()
+		options := &pb.ModelOptions{Model: "test", ModelPath: modelPath}
+
+		s := NewStore()
+		Expect(s.Load(options)).To(Succeed())
+		Expect(set(s, "", [][]float32{{1, 0}}, []string{"a"}, []string{`{"page": 1}`})).To(Succeed())
+		Expect(set(s, "docs", [][]float32{{0, 1, 0}}, []string{"b"}, []string{`{"page": 2}`})).To(Succeed())
+		Expect(s.Unload()).To(Succeed())
+
+		check := func() {
+			s = NewStore()
+			Expect(s.Load(options)).To(Succeed())
+			values, metadata := find(s, "", []float32{1, 0}, 5, `{"page": 1}`)
+			Expect(values).To(Equal([]string{"a"}))
+			Expect(metadata).To(Equal([]string{`{"page":1}`}))
+			values, metadata = find(s, "docs", []float32{0, 1, 0}, 5, "")
+			Expect(values).To(Equal([]string{"b"}))
+			Expect(metadata).To(Equal([]string{`{"page":2}`}))
+		}
+		check()
+
+		Expect(s.persistence.snapshot(s)).To(Succeed())
+		Expect(s.Unload()).To(Succeed())
+		check()
+	})
+})
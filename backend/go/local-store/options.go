@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultRescore = 4
+
+// storeOptions are the options the store is loaded with, from the options of its model configuration
+type storeOptions struct {
+	// The metric comparing the keys
+	metric metric
+	// The quantization of the stored keys, none to store them as they are set
+	quantization quantization
+	// The number of candidates found with the quantized keys for each key returned, which are rescored with
+	// the full precision query
+	rescore int
+	// The parameters of the approximate index of the collections, nil when they are searched linearly
+	hnsw *hnswParams
+}
+
+func defaultOptions() storeOptions {
+	return storeOptions{metric: metricCosine, quantization: quantizationNone, rescore: defaultRescore}
+}
+
+// parseOptions parses the options of the store, such as index:hnsw or metric:l2
+func parseOptions(options []string) (storeOptions, error) {
+	o := defaultOptions()
+	kind := "flat"
+	m, efConstruction, efSearch := defaultHNSWM, defaultHNSWEfConstruction, defaultHNSWEfSearch
+
+	for _, opt := range options {
+		k, v, _ := strings.Cut(opt, ":")
+		var err error
+		switch k {
+		case "index":
+			kind = v
+		case "hnsw_m":
+			m, err = strconv.Atoi(v)
+		case "hnsw_ef_construction":
+			efConstruction, err = strconv.Atoi(v)
+		case "hnsw_ef_search":
+			efSearch, err = strconv.Atoi(v)
+		case "metric":
+			o.metric = metric(v)
+		case "quantization":
+			o.quantization = quantization(v)
+		case "rescore":
+			o.rescore, err = strconv.Atoi(v)
+		default:
+			log.Warn().Str("option", opt).Msg("unrecognized store option")
+		}
+		if err != nil {
+			return storeOptions{}, fmt.Errorf("invalid store option %q: %w", opt, err)
+		}
+	}
+
+	switch o.metric {
+	case metricCosine, metricDot, metricL2:
+	default:
+		return storeOptions{}, fmt.Errorf("unknown metric %q, must be cosine, dot or l2", o.metric)
+	}
+	switch o.quantization {
+	case quantizationNone, quantizationInt8, quantizationBinary:
+	default:
+		return storeOptions{}, fmt.Errorf("unknown quantization %q, must be none, int8 or binary", o.quantization)
+	}
+	if o.rescore < 1 {
+		return storeOptions{}, fmt.Errorf("rescore must be at least 1")
+	}
+
+	switch kind {
+	case "flat":
+		return o, nil
+	case "hnsw":
+		if m < 2 || efConstruction < 1 || efSearch < 1 {
+			return storeOptions{}, fmt.Errorf("hnsw_m must be at least 2, hnsw_ef_construction and hnsw_ef_search at least 1")
+		}
+		if o.quantization != quantizationNone {
+			return storeOptions{}, fmt.Errorf("quantized keys are only supported by the flat index")
+		}
+		o.hnsw = &hnswParams{m: m, efConstruction: efConstruction, efSearch: efSearch}
+		return o, nil
+	}
+	return storeOptions{}, fmt.Errorf("unknown index %q, must be flat or hnsw", kind)
+}
+
+// persisted returns the options chosen when the store is created, which a persisted store keeps
+func (o storeOptions) persisted() string {
+	return fmt.Sprintf("metric:%s\nquantization:%s\n", o.metric, o.quantization)
+}
@@ -0,0 +1,440 @@
+package main
+
+// Stores are persisted under the models path, in stores/<name>: a snapshot of the entries of each namespace,
+// a write-ahead log of the changes made since the snapshot, and the options the store was created with. Each change is synced to the log before it is
+// acknowledged, and the log is replayed on load up to its first incomplete record, which a crash can leave.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	snapshotFile  = "snapshot"
+	walFile       = "wal"
+	optionsFile   = "options"
+	snapshotMagic = "LAISTOR1"
+
+	opSet    byte = 1
+	opDelete byte = 2
+	// opSetHashed and opDeleteHashed set and delete the approximations of quantized keys, each followed by the
+	// hash of the key it approximates
+	opSetHashed    byte = 3
+	opDeleteHashed byte = 4
+
+	// defaultSnapshotSize is the size of the log beyond which a snapshot is taken
+	defaultSnapshotSize = 64 << 20
+	// maxRecordSize bounds the records read, so that a corrupted length is not allocated
+	maxRecordSize = 1 << 30
+)
+
+var errTornRecord = errors.New("incomplete record")
+
+// A record is a change of the entries of a namespace
+type record struct {
+	op        byte
+	namespace string
+	keys      [][]float32
+	values    [][]byte
+	metadata  [][]byte
+	// The hashes of the keys approximated by the keys of opSetHashed and opDeleteHashed records
+	hashes []uint64
+}
+
+func (rec record) hashed() bool {
+	return rec.op == opSetHashed || rec.op == opDeleteHashed
+}
+
+type persistence struct {
+	dir          string
+	wal          *os.File
+	walSize      int64
+	snapshotSize int64
+}
+
+// openPersistence loads the store persisted in dir, creating it if needed
+func openPersistence(dir string, s *Store) (*persistence, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create the store directory: %w", err)
+	}
+
+	if err := checkOptions(filepath.Join(dir, optionsFile), s.storeOptions); err != nil {
+		return nil, err
+	}
+
+	if err := loadSnapshot(filepath.Join(dir, snapshotFile), s); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the write-ahead log: %w", err)
+	}
+	size, err := replay(wal, s)
+	if err != nil {
+		wal.Close()
+		return nil, err
+	}
+	// the records following a torn one are dropped, the next ones are appended after the last complete one
+	if err := wal.Truncate(size); err != nil {
+		wal.Close()
+		return nil, fmt.Errorf("failed to truncate the write-ahead log: %w", err)
+	}
+	if _, err := wal.Seek(size, io.SeekStart); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	log.Debug().Str("dir", dir).Int("namespaces", len(s.collections)).Int64("wal", size).Msg("store loaded")
+
+	return &persistence{
+		dir:          dir,
+		wal:          wal,
+		walSize:      size,
+		snapshotSize: defaultSnapshotSize,
+	}, nil
+}
+
+// checkOptions checks that the store is loaded with the metric and the quantization it was created with
+func checkOptions(path string, o storeOptions) error {
+	persisted, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(path, []byte(o.persisted()), 0600); err != nil {
+			return fmt.Errorf("failed to write the store options: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the store options: %w", err)
+	}
+
+	if string(persisted) != o.persisted() {
+		return fmt.Errorf("the store was created with %s, it cannot be loaded with %s",
+			strings.Join(strings.Fields(string(persisted)), " "), strings.Join(strings.Fields(o.persisted()), " "))
+	}
+	return nil
+}
+
+func loadSnapshot(path string, s *Store) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open the snapshot: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return fmt.Errorf("%s is not a store snapshot", path)
+	}
+	// snapshots are renamed once complete, they cannot be torn
+	for {
+		rec, _, err := readRecord(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil || (rec.op != opSet && rec.op != opSetHashed) {
+			return fmt.Errorf("the snapshot %s is corrupted: %v", path, err)
+		}
+		if err := apply(s, rec); err != nil {
+			return err
+		}
+	}
+}
+
+// replay applies the complete records of the log, returning their size
+func replay(wal *os.File, s *Store) (int64, error) {
+	r := bufio.NewReader(wal)
+	var size int64
+	for {
+		rec, n, err := readRecord(r)
+		if errors.Is(err, io.EOF) {
+			return size, nil
+		}
+		if errors.Is(err, errTornRecord) {
+			log.Warn().Err(err).Int64("offset", size).Msg("dropping the end of the write-ahead log")
+			return size, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the write-ahead log: %w", err)
+		}
+		if err := apply(s, rec); err != nil {
+			return 0, fmt.Errorf("failed to replay the write-ahead log: %w", err)
+		}
+		size += n
+	}
+}
+
+func apply(s *Store, rec record) error {
+	if len(rec.keys) == 0 {
+		return nil
+	}
+	pbKeys := make([]*pb.StoresKey, len(rec.keys))
+	for i, k := range rec.keys {
+		pbKeys[i] = &pb.StoresKey{Floats: k}
+	}
+
+	c := s.collection(rec.namespace)
+	if rec.hashed() && c.quantized == nil {
+		return fmt.Errorf("the keys are quantized but the store is not")
+	}
+	switch rec.op {
+	case opSet, opSetHashed:
+		pbValues := make([]*pb.StoresValue, len(rec.values))
+		for i, v := range rec.values {
+			pbValues[i] = &pb.StoresValue{Bytes: v, Metadata: rec.metadata[i]}
+		}
+		opts := &pb.StoresSetOptions{Keys: pbKeys, Values: pbValues}
+		metadata, err := c.checkSet(opts)
+		if err != nil {
+			return err
+		}
+		if rec.op == opSetHashed {
+			c.setHashed(opts, metadata, rec.hashes)
+		} else {
+			c.set(opts, metadata)
+		}
+	case opDelete, opDeleteHashed:
+		opts := &pb.StoresDeleteOptions{Keys: pbKeys}
+		if err := c.checkDelete(opts); err != nil {
+			return err
+		}
+		if rec.op == opDeleteHashed {
+			c.deleteQuantized(rec.hashes)
+		} else {
+			c.delete(opts)
+		}
+	default:
+		return fmt.Errorf("unknown operation %d", rec.op)
+	}
+
+	if len(c.values) > 0 {
+		s.collections[rec.namespace] = c
+	} else {
+		delete(s.collections, rec.namespace)
+	}
+	return nil
+}
+
+// Records are made of the length and the checksum of their payload, followed by the payload: the operation,
+// the namespace, the number of keys, and each key with its value and metadata, followed by its hash in hashed
+// records
+func encodeRecord(rec record) []byte {
+	size := 1 + 4 + len(rec.namespace) + 4
+	for i, k := range rec.keys {
+		size += 4 + 4*len(k) + 4 + 4
+		if rec.hashed() {
+			size += 8
+		}
+		if rec.values != nil {
+			size += len(rec.values[i]) + len(rec.metadata[i])
+		}
+	}
+
+	buf := make([]byte, 8, 8+size)
+	buf = append(buf, rec.op)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(rec.namespace)))
+	buf = append(buf, rec.namespace...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(rec.keys)))
+	for i, k := range rec.keys {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(k)))
+		for _, f := range k {
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(f))
+		}
+		var v, m []byte
+		if rec.values != nil {
+			v, m = rec.values[i], rec.metadata[i]
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(v)))
+		buf = append(buf, v...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(m)))
+		buf = append(buf, m...)
+		if rec.hashed() {
+			buf = binary.LittleEndian.AppendUint64(buf, rec.hashes[i])
+		}
+	}
+
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(buf)-8))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(buf[8:]))
+	return buf
+}
+
+// readRecord reads a record, returning io.EOF at the end of the log and errTornRecord for a record which
+// is incomplete or does not match its checksum
+func readRecord(r io.Reader) (record, int64, error) {
+	header := make([]byte, 8)
+	if n, err := io.ReadFull(r, header); err != nil {
+		if n == 0 && errors.Is(err, io.EOF) {
+			return record{}, 0, io.EOF
+		}
+		return record{}, 0, errTornRecord
+	}
+	length := binary.LittleEndian.Uint32(header[0:4])
+	if length < 9 || length > maxRecordSize {
+		return record{}, 0, errTornRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return record{}, 0, errTornRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		return record{}, 0, errTornRecord
+	}
+
+	rec := record{op: payload[0]}
+	p := payload[1:]
+	next := func(n int) ([]byte, error) {
+		if n < 0 || n > len(p) {
+			return nil, fmt.Errorf("malformed record")
+		}
+		b := p[:n]
+		p = p[n:]
+		return b, nil
+	}
+	// nextBytes reads a length followed by as many bytes
+	nextBytes := func() ([]byte, error) {
+		b, err := next(4)
+		if err != nil {
+			return nil, err
+		}
+		return next(int(binary.LittleEndian.Uint32(b)))
+	}
+
+	namespace, err := nextBytes()
+	if err != nil {
+		return record{}, 0, err
+	}
+	rec.namespace = string(namespace)
+	b, err := next(4)
+	if err != nil {
+		return record{}, 0, err
+	}
+	count := binary.LittleEndian.Uint32(b)
+	for i := uint32(0); i < count; i++ {
+		b, err := next(4)
+		if err != nil {
+			return record{}, 0, err
+		}
+		keyLen := int(binary.LittleEndian.Uint32(b))
+		if b, err = next(4 * keyLen); err != nil {
+			return record{}, 0, err
+		}
+		k := make([]float32, keyLen)
+		for j := range k {
+			k[j] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*j:]))
+		}
+		v, err := nextBytes()
+		if err != nil {
+			return record{}, 0, err
+		}
+		m, err := nextBytes()
+		if err != nil {
+			return record{}, 0, err
+		}
+		if len(m) == 0 {
+			m = nil
+		}
+		if rec.hashed() {
+			if b, err = next(8); err != nil {
+				return record{}, 0, err
+			}
+			rec.hashes = append(rec.hashes, binary.LittleEndian.Uint64(b))
+		}
+		rec.keys = append(rec.keys, k)
+		rec.values = append(rec.values, v)
+		rec.metadata = append(rec.metadata, m)
+	}
+	return rec, int64(8 + length), nil
+}
+
+// append syncs a change to the log. A record which fails to be written is removed, so that the next
+// ones are not appended after it.
+func (p *persistence) append(rec record) error {
+	encoded := encodeRecord(rec)
+	if _, err := p.wal.Write(encoded); err != nil {
+		p.rewind()
+		return fmt.Errorf("failed to write to the write-ahead log: %w", err)
+	}
+	if err := p.wal.Sync(); err != nil {
+		p.rewind()
+		return fmt.Errorf("failed to sync the write-ahead log: %w", err)
+	}
+	p.walSize += int64(len(encoded))
+	return nil
+}
+
+func (p *persistence) rewind() {
+	p.wal.Truncate(p.walSize)
+	p.wal.Seek(p.walSize, io.SeekStart)
+}
+
+// snapshot writes the entries of the store, and empties the log. If interrupted before the log is
+// emptied, the log is replayed on top of the snapshot, which gives the same keys and values.
+func (p *persistence) snapshot(s *Store) error {
+	tmp := filepath.Join(p.dir, snapshotFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create the snapshot: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(snapshotMagic)
+	for _, namespace := range slices.Sorted(maps.Keys(s.collections)) {
+		c := s.collections[namespace]
+		rec := record{op: opSet, namespace: namespace, keys: c.storedKeys(), values: c.values}
+		if c.quantized != nil {
+			rec.op, rec.hashes = opSetHashed, c.quantized.hashes
+		}
+		for _, m := range c.metadata {
+			rec.metadata = append(rec.metadata, marshalMetadata(m))
+		}
+		w.Write(encodeRecord(rec))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write the snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync the snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(p.dir, snapshotFile)); err != nil {
+		return fmt.Errorf("failed to replace the snapshot: %w", err)
+	}
+	if dir, err := os.Open(p.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	if err := p.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to empty the write-ahead log: %w", err)
+	}
+	if _, err := p.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	p.walSize = 0
+	return p.wal.Sync()
+}
+
+func (p *persistence) Close() error {
+	return p.wal.Close()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store persistence", func() {
+	var modelPath string
+
+	BeforeEach(func() {
+		modelPath = GinkgoT().TempDir()
+	})
+
+	load := func() *Store {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Model: "test", ModelPath: modelPath})).To(Succeed())
+		return s
+	}
+
+	set := func(s *Store, keys [][]float32, values ...string) {
+		opts := &pb.StoresSetOptions{}
+		for i, k := range keys {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+			opts.Values = append(opts.Values, &pb.StoresValue{Bytes: []byte(values[i])})
+		}
+		Expect(s.StoresSet(opts)).To(Succeed())
+	}
+
+	del := func(s *Store, keys ...[]float32) {
+		opts := &pb.StoresDeleteOptions{}
+		for _, k := range keys {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+		}
+		Expect(s.StoresDelete(opts)).To(Succeed())
+	}
+
+	get := func(s *Store, keys ...[]float32) []string {
+		opts := &pb.StoresGetOptions{}
+		for _, k := range keys {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+		}
+		res, err := s.StoresGet(opts)
+		Expect(err).ToNot(HaveOccurred())
+		values := []string{}
+		for _, v := range res.Values {
+			values = append(values, string(v.Bytes))
+		}
+		return values
+	}
+
+	storeDir := func() string {
+		return filepath.Join(modelPath, "stores", "test")
+	}
+
+	It("keeps the keys across restarts", func() {
+		s := load()
+		set(s, [][]float32{{0.1, 0.2}, {0.3, 0.4}, {0.5, 0.6}}, "a", "b", "c")
+		del(s, []float32{0.3, 0.4})
+		set(s, [][]float32{{0.1, 0.2}}, "d")
+		Expect(s.Unload()).To(Succeed())
+
+		s = load()
+		Expect(get(s, []float32{0.1, 0.2}, []float32{0.3, 0.4}, []float32{0.5, 0.6})).To(Equal([]string{"d", "c"}))
+		Expect(s.collections[""].keyLen).To(Equal(2))
+	})
+
+	It("recovers the acknowledged changes of a killed store", func() {
+		s := load()
+		set(s, [][]float32{{0.1, 0.2}}, "a")
+		set(s, [][]float32{{0.3, 0.4}}, "b")
+
+		// the files are left open, as when the process is killed
+		s = load()
+		Expect(get(s, []float32{0.1, 0.2}, []float32{0.3, 0.4})).To(Equal([]string{"a", "b"}))
+	})
+
+	It("drops a record torn by a crash and appends after the last complete one", func() {
+		s := load()
+		set(s, [][]float32{{0.1, 0.2}}, "a")
+		set(s, [][]float32{{0.3, 0.4}}, "b")
+		Expect(s.Unload()).To(Succeed())
+
+		wal := filepath.Join(storeDir(), walFile)
+		data, err := os.ReadFile(wal)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(wal, data[:len(data)-3], 0600)).To(Succeed())
+
+		s = load()
+		Expect(get(s, []float32{0.1, 0.2}, []float32{0.3, 0.4})).To(Equal([]string{"a"}))
+		set(s, [][]float32{{0.5, 0.6}}, "c")
+		Expect(s.Unload()).To(Succeed())
+
+		s = load()
+		Expect(get(s, []float32{0.1, 0.2}, []float32{0.3, 0.4}, []float32{0.5, 0.6})).To(Equal([]string{"a", "c"}))
+	})
+
+	It("drops a record which does not match its checksum", func() {
+		s := load()
+		set(s, [][]float32{{0.1, 0.2}}, "a")
+		set(s, [][]float32{{0.3, 0.4}}, "b")
+		Expect(s.Unload()).To(Succeed())
+
+		wal := filepath.Join(storeDir(), walFile)
+		data, err := os.ReadFile(wal)
+		Expect(err).ToNot(HaveOccurred())
+		data[len(data)-1] ^= 0xff
+		Expect(os.WriteFile(wal, data, 0600)).To(Succeed())
+
+		s = load()
+		Expect(get(s, []float32{0.1, 0.2}, []float32{0.3, 0.4})).To(Equal([]string{"a"}))
+	})
+
+	It("snapshots the store once the log grows and empties the log", func() {
+		s := load()
+		s.persistence.snapshotSize = 0
+		set(s, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, "a", "b")
+		del(s, []float32{0.1, 0.2})
+		Expect(s.Unload()).To(Succeed())
+
+		Expect(filepath.Join(storeDir(), snapshotFile)).To(BeAnExistingFile())
+		info, err := os.Stat(filepath.Join(storeDir(), walFile))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Size()).To(BeZero())
+
+		s = load()
+		Expect(get(s, []float32{0.1, 0.2}, []float32{0.3, 0.4})).To(Equal([]string{"b"}))
+	})
+
+	It("replays the log over a snapshot taken before a crash emptied it", func() {
+		s := load()
+		set(s, [][]float32{{0.1, 0.2}}, "a")
+		del(s, []float32{0.1, 0.2})
+		set(s, [][]float32{{0.3, 0.4}}, "b")
+
+		wal := filepath.Join(storeDir(), walFile)
+		data, err := os.ReadFile(wal)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.persistence.snapshot(s)).To(Succeed())
+		Expect(s.Unload()).To(Succeed())
+		Expect(os.WriteFile(wal, data, 0600)).To(Succeed())
+
+		s = load()
+		Expect(get(s, []float32{0.1, 0.2}, []float32{0.3, 0.4})).To(Equal([]string{"b"}))
+	})
+
+	It("does not persist rejected changes", func() {
+		s := load()
+		set(s, [][]float32{{0.1, 0.2}}, "a")
+		Expect(s.StoresSet(&pb.StoresSetOptions{
+			Keys:   []*pb.StoresKey{{Floats: []float32{0.1, 0.2, 0.3}}},
+			Values: []*pb.StoresValue{{Bytes: []byte("b")}},
+		})).ToNot(Succeed())
+		Expect(s.Unload()).To(Succeed())
+
+		s = load()
+		Expect(get(s, []float32{0.1, 0.2})).To(Equal([]string{"a"}))
+	})
+
+	It("rejects store names which are not a single path element", func() {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Model: "../test", ModelPath: modelPath})).ToNot(Succeed())
+		Expect(s.Load(&pb.ModelOptions{Model: "..", ModelPath: modelPath})).ToNot(Succeed())
+	})
+
+	It("keeps the store in memory without a models path", func() {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Model: "test"})).To(Succeed())
+		Expect(s.persistence).To(BeNil())
+		set(s, [][]float32{{0.1, 0.2}}, "a")
+		Expect(get(s, []float32{0.1, 0.2})).To(Equal([]string{"a"}))
+	})
+})
@@ -0,0 +1,326 @@
+package main
+
+// Quantized stores keep a compact code of each key in place of its floats: int8 keeps a byte per dimension,
+// 4 times less than the floats, and binary keeps a bit per dimension, 32 times less. Each code comes with a
+// scale, the key being approximated by the code times the scale.
+//
+// Searches first compare the codes of the keys with the code of the query, which is fast but approximate,
+// then rescore the rescore*topK most similar ones by comparing the query itself with their approximations.
+// The floats of the keys are not kept, so the similarities returned are the ones of the approximations, and
+// keys whose approximations are ranked differently from them can be missed.
+//
+// Keys are identified by a hash of their floats, so that different keys with the same code are different
+// entries. The keys returned by searches are the approximations of the keys set, and are persisted as such in
+// snapshots, with the hashes of the keys they approximate, as quantizing an approximation again gives the
+// same code.
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strings"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/store"
+)
+
+type quantization string
+
+const (
+	quantizationNone   quantization = "none"
+	quantizationInt8   quantization = "int8"
+	quantizationBinary quantization = "binary"
+)
+
+// quantizedKeys are the keys of a collection stored as codes, in the order of the values of the collection
+type quantizedKeys struct {
+	quantization quantization
+	codes        []string
+	scales       []float32
+	// The hashes of the keys, identifying them as different keys can have the same code
+	hashes []uint64
+	// The index of the entry of each hash
+	rows map[uint64]int32
+}
+
+func newQuantizedKeys(q quantization) *quantizedKeys {
+	return &quantizedKeys{
+		quantization: q,
+		rows:         make(map[uint64]int32),
+	}
+}
+
+// keyHash returns the FNV-1a hash of the floats of a key, 0 and -0 being the same as when comparing keys
+func keyHash(k []float32) uint64 {
+	h := fnv.New64a()
+	b := make([]byte, 4*len(k))
+	for i, v := range k {
+		if v == 0 {
+			v = 0
+		}
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	h.Write(b)
+	return h.Sum64()
+}
+
+func keyHashes(keys []*pb.StoresKey) []uint64 {
+	hashes := make([]uint64, len(keys))
+	for i, k := range keys {
+		hashes[i] = keyHash(k.Floats)
+	}
+	return hashes
+}
+
+// encode returns the code of a key with its scale. int8 codes round the dimensions to multiples of the
+// largest one divided by 127, binary codes keep the sign of the dimensions, with their mean magnitude.
+func (q quantization) encode(k []float32) (string, float32) {
+	var sb strings.Builder
+
+	switch q {
+	case quantizationInt8:
+		var maxAbs float64
+		for _, v := range k {
+			maxAbs = max(maxAbs, math.Abs(float64(v)))
+		}
+		scale := float32(maxAbs / 127)
+
+		sb.Grow(len(k))
+		for _, v := range k {
+			var c float64
+			if scale > 0 {
+				c = max(-127, min(127, math.Round(float64(v)/float64(scale))))
+			}
+			sb.WriteByte(byte(int8(c)))
+		}
+		return sb.String(), scale
+	case quantizationBinary:
+		var sum float64
+		for _, v := range k {
+			sum += math.Abs(float64(v))
+		}
+
+		sb.Grow((len(k) + 7) / 8)
+		for i := 0; i < len(k); i += 8 {
+			var b byte
+			for j := i; j < min(i+8, len(k)); j++ {
+				if k[j] > 0 {
+					b |= 1 << (j - i)
+				}
+			}
+			sb.WriteByte(b)
+		}
+		return sb.String(), float32(sum / float64(len(k)))
+	}
+
+	panic(fmt.Sprintf("encode: unknown quantization %q", q))
+}
+
+// decode returns the approximation of the key of a code
+func (q quantization) decode(code string, scale float32, keyLen int) []float32 {
+	k := make([]float32, keyLen)
+	for i := range k {
+		switch q {
+		case quantizationInt8:
+			k[i] = float32(int8(code[i])) * scale
+		case quantizationBinary:
+			if code[i/8]&(1<<(i%8)) != 0 {
+				k[i] = scale
+			} else {
+				k[i] = -scale
+			}
+		}
+	}
+	return k
+}
+
+// approximateSimilarity returns the function comparing the code of the keys to the code of q, which
+// approximates their similarity with the metric
+func (qk *quantizedKeys) approximateSimilarity(m metric, q []float32) func(row int) float32 {
+	qCode, qScale := qk.quantization.encode(q)
+
+	if qk.quantization == quantizationBinary {
+		qNorm := math.Sqrt(float64(dot(q, q)))
+		return func(row int) float32 {
+			// the share of the dimensions with different signs is the angle between the keys over pi
+			code := qk.codes[row]
+			var differences int
+			for i := 0; i < len(code); i++ {
+				differences += bits.OnesCount8(code[i] ^ qCode[i])
+			}
+			cos := math.Cos(math.Pi * float64(differences) / float64(len(q)))
+			norm := float64(qk.scales[row]) * math.Sqrt(float64(len(q)))
+
+			switch m {
+			case metricDot:
+				return float32(qNorm * norm * cos)
+			case metricL2:
+				return -float32(math.Sqrt(max(0, qNorm*qNorm+norm*norm-2*qNorm*norm*cos)))
+			}
+			return float32(cos)
+		}
+	}
+
+	var qSquares int32
+	for i := 0; i < len(qCode); i++ {
+		qSquares += int32(int8(qCode[i])) * int32(int8(qCode[i]))
+	}
+	return func(row int) float32 {
+		code := qk.codes[row]
+		var products, squares int32
+		for i := 0; i < len(code); i++ {
+			c := int32(int8(code[i]))
+			products += int32(int8(qCode[i])) * c
+			squares += c * c
+		}
+		scale := float64(qk.scales[row])
+
+		switch m {
+		case metricDot:
+			return float32(float64(qScale) * scale * float64(products))
+		case metricL2:
+			qs := float64(qScale)
+			return -float32(math.Sqrt(max(0, qs*qs*float64(qSquares)+scale*scale*float64(squares)-2*qs*scale*float64(products))))
+		}
+		if qSquares == 0 || squares == 0 {
+			return 0
+		}
+		return float32(float64(products) / math.Sqrt(float64(qSquares)*float64(squares)))
+	}
+}
+
+func (qk *quantizedKeys) key(row int, keyLen int) []float32 {
+	return qk.quantization.decode(qk.codes[row], qk.scales[row], keyLen)
+}
+
+// setQuantized sets the entries of the keys identified by the hashes, replacing the ones with the same hash
+func (c *collection) setQuantized(opts *pb.StoresSetOptions, metadata []map[string]any, hashes []uint64) {
+	qk := c.quantized
+	for i, k := range opts.Keys {
+		code, scale := qk.quantization.encode(k.Floats)
+		if row, ok := qk.rows[hashes[i]]; ok {
+			qk.codes[row], qk.scales[row] = code, scale
+			c.values[row] = opts.Values[i].Bytes
+			c.metadata[row] = metadata[i]
+			continue
+		}
+
+		qk.rows[hashes[i]] = int32(len(qk.codes))
+		qk.codes = append(qk.codes, code)
+		qk.scales = append(qk.scales, scale)
+		qk.hashes = append(qk.hashes, hashes[i])
+		c.values = append(c.values, opts.Values[i].Bytes)
+		c.metadata = append(c.metadata, metadata[i])
+	}
+}
+
+// setHashed sets the approximations of quantized keys persisted in a snapshot, identified by the hashes of the
+// keys they approximate
+func (c *collection) setHashed(opts *pb.StoresSetOptions, metadata []map[string]any, hashes []uint64) {
+	if c.keyLen == -1 {
+		c.keyLen = len(opts.Keys[0].Floats)
+	}
+	c.setQuantized(opts, metadata, hashes)
+}
+
+// deleteQuantized deletes the entries of the keys with the hashes, moving the last entry in place of each
+// deleted one
+func (c *collection) deleteQuantized(hashes []uint64) {
+	qk := c.quantized
+	for _, hash := range hashes {
+		row, ok := qk.rows[hash]
+		if !ok {
+			continue
+		}
+
+		last := int32(len(qk.codes) - 1)
+		if row != last {
+			qk.codes[row], qk.scales[row], qk.hashes[row] = qk.codes[last], qk.scales[last], qk.hashes[last]
+			c.values[row], c.metadata[row] = c.values[last], c.metadata[last]
+			qk.rows[qk.hashes[row]] = row
+		}
+		delete(qk.rows, hash)
+		c.values[last], c.metadata[last] = nil, nil
+		qk.codes, qk.scales, qk.hashes = qk.codes[:last], qk.scales[:last], qk.hashes[:last]
+		c.values, c.metadata = c.values[:last], c.metadata[:last]
+	}
+
+	assert(len(qk.codes) == len(c.values) && len(qk.hashes) == len(c.values) && len(qk.rows) == len(c.values), fmt.Sprintf("len(codes) = %d, len(hashes) = %d, len(rows) = %d, len(values) = %d", len(qk.codes), len(qk.hashes), len(qk.rows), len(c.values)))
+}
+
+func (c *collection) getQuantized(opts *pb.StoresGetOptions) pb.StoresGetResult {
+	qk := c.quantized
+	var res pb.StoresGetResult
+	for _, k := range opts.Keys {
+		if row, ok := qk.rows[keyHash(k.Floats)]; ok {
+			res.Keys = append(res.Keys, &pb.StoresKey{Floats: k.Floats})
+			res.Values = append(res.Values, &pb.StoresValue{
+				Bytes:    c.values[row],
+				Metadata: marshalMetadata(c.metadata[row]),
+			})
+		}
+	}
+	return res
+}
+
+// topCandidates keeps the n most similar candidates pushed
+type topCandidates struct {
+	hnswQueue
+	n int
+}
+
+func (t *topCandidates) push(row int, similarity float32) {
+	heap.Push(&t.hnswQueue, hnswCandidate{id: int32(row), similarity: similarity})
+	if t.Len() > t.n {
+		heap.Pop(&t.hnswQueue)
+	}
+}
+
+// sorted returns the candidates, the most similar first
+func (t *topCandidates) sorted() []hnswCandidate {
+	sortCandidates(t.items)
+	return t.items
+}
+
+// findQuantized finds the rescore*TopK entries whose codes are the most similar to the code of the query, and
+// returns the TopK ones whose approximations are the most similar to the query, with their similarities to it
+func (c *collection) findQuantized(opts *pb.StoresFindOptions, filter *store.Filter) pb.StoresFindResult {
+	tk := opts.Key.Floats
+	qk := c.quantized
+
+	approximate := qk.approximateSimilarity(c.metric, tk)
+	candidates := &topCandidates{n: int(opts.TopK) * c.rescore}
+	for row := range qk.codes {
+		if filter.Match(c.metadata[row]) {
+			candidates.push(row, approximate(row))
+		}
+	}
+
+	similarity := c.metric.similarity(tk)
+	top := &topCandidates{n: int(opts.TopK)}
+	keys := make(map[int32][]float32, candidates.Len())
+	for _, candidate := range candidates.items {
+		k := qk.key(int(candidate.id), c.keyLen)
+		keys[candidate.id] = k
+		top.push(int(candidate.id), similarity(k))
+	}
+
+	found := top.sorted()
+	res := pb.StoresFindResult{
+		Keys:         make([]*pb.StoresKey, len(found)),
+		Values:       make([]*pb.StoresValue, len(found)),
+		Similarities: make([]float32, len(found)),
+	}
+	for i, f := range found {
+		res.Similarities[i] = f.similarity
+		res.Keys[i] = &pb.StoresKey{Floats: keys[f.id]}
+		res.Values[i] = &pb.StoresValue{
+			Bytes:    c.values[f.id],
+			Metadata: marshalMetadata(c.metadata[f.id]),
+		}
+	}
+	return res
+}
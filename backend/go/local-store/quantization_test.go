@@ -0,0 +1,331 @@
+package main
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics and quantization", func() {
+	const (
+		count = 2000
+		dim   = 128
+		topK  = 10
+	)
+
+	var (
+		rng     *rand.Rand
+		centers [][]float32
+	)
+
+	BeforeEach(func() {
+		rng = rand.New(rand.NewSource(42))
+		centers = make([][]float32, 8)
+		for i := range centers {
+			centers[i] = make([]float32, dim)
+			for j := range centers[i] {
+				centers[i][j] = float32(rng.NormFloat64())
+			}
+		}
+	})
+
+	// randomKeys returns keys around a few centers, like the embeddings of texts on a few topics
+	randomKeys := func(n int) [][]float32 {
+		keys := make([][]float32, n)
+		for i := range keys {
+			c := centers[rng.Intn(len(centers))]
+			keys[i] = make([]float32, dim)
+			for j := range keys[i] {
+				keys[i][j] = c[j] + float32(rng.NormFloat64())
+			}
+		}
+		return keys
+	}
+
+	newStore := func(options ...string) *Store {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Options: options})).To(Succeed())
+		return s
+	}
+
+	set := func(s *Store, keys [][]float32) {
+		opts := &pb.StoresSetOptions{}
+		for i, k := range keys {
+			opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+			opts.Values = append(opts.Values, &pb.StoresValue{Bytes: []byte{byte(i), byte(i >> 8)}})
+		}
+		Expect(s.StoresSet(opts)).To(Succeed())
+	}
+
+	find := func(s *Store, q []float32) pb.StoresFindResult {
+		res, err := s.StoresFind(&pb.StoresFindOptions{Key: &pb.StoresKey{Floats: q}, TopK: topK})
+		Expect(err).ToNot(HaveOccurred())
+		return res
+	}
+
+	// compare returns the share of the values found by the exact store which the other one finds as well,
+	// and the largest difference between the similarities they give to the same values
+	compare := func(exact, other *Store, queries [][]float32) (float64, float64) {
+		var found, expected int
+		var maxDiff float64
+		for _, q := range queries {
+			want, got := find(exact, q), find(other, q)
+			similarities := map[string]float32{}
+			for i, v := range want.Values {
+				similarities[string(v.Bytes)] = want.Similarities[i]
+			}
+			for i, v := range got.Values {
+				if sim, ok := similarities[string(v.Bytes)]; ok {
+					found++
+					maxDiff = max(maxDiff, float64(abs(sim-got.Similarities[i])))
+				}
+			}
+			expected += len(want.Values)
+		}
+		return float64(found) / float64(expected), maxDiff
+	}
+
+	// accuracy returns the share of the topK keys the most similar to the queries with the floats of the keys
+	// which the store finds, and the largest difference between the similarities it gives to the keys found
+	// and the similarities of their floats
+	accuracy := func(s *Store, m metric, keys, queries [][]float32) (float64, float64) {
+		var found int
+		var maxDiff float64
+		for _, q := range queries {
+			similarity := m.similarity(q)
+			rows := make([]int, len(keys))
+			for i := range rows {
+				rows[i] = i
+			}
+			slices.SortFunc(rows, func(a, b int) int {
+				return cmp.Compare(similarity(keys[b]), similarity(keys[a]))
+			})
+			top := rows[:topK]
+
+			res := find(s, q)
+			for i, v := range res.Values {
+				row := int(v.Bytes[0]) | int(v.Bytes[1])<<8
+				if slices.Contains(top, row) {
+					found++
+				}
+				maxDiff = max(maxDiff, float64(abs(similarity(keys[row])-res.Similarities[i])))
+			}
+		}
+		return float64(found) / float64(len(queries)*topK), maxDiff
+	}
+
+	It("ranks the keys with the dot product and the Euclidean distance", func() {
+		for _, metric := range []string{"dot", "l2"} {
+			s := newStore("metric:" + metric)
+			set(s, [][]float32{{1, 0}, {3, 0}, {0, 1}, {-1, 0}})
+
+			res := find(s, []float32{1, 0})
+			values := []byte{}
+			for _, v := range res.Values {
+				values = append(values, v.Bytes[0])
+			}
+			if metric == "dot" {
+				Expect(values).To(Equal([]byte{1, 0, 2, 3}))
+				Expect(res.Similarities).To(Equal([]float32{3, 1, 0, -1}))
+			} else {
+				Expect(values).To(Equal([]byte{0, 2, 1, 3}))
+				Expect(res.Similarities[0]).To(BeNumerically("~", 0, 1e-6))
+				Expect(res.Similarities[1]).To(BeNumerically("~", -1.41421, 1e-4))
+				Expect(res.Similarities[3]).To(BeNumerically("~", -2, 1e-6))
+			}
+		}
+	})
+
+	It("finds the keys of the linear search in the index with other metrics", func() {
+		keys, queries := randomKeys(count), randomKeys(50)
+		for _, metric := range []string{"dot", "l2"} {
+			exact, indexed := newStore("metric:"+metric), newStore("metric:"+metric, "index:hnsw")
+			set(exact, keys)
+			set(indexed, keys)
+
+			recall, maxDiff := compare(exact, indexed, queries)
+			Expect(recall).To(BeNumerically(">=", 0.95), metric)
+			Expect(maxDiff).To(BeNumerically("<", 1e-3), metric)
+		}
+	})
+
+	It("finds the most similar keys of the floats in int8 stores", func() {
+		keys, queries := randomKeys(count), randomKeys(50)
+		for _, m := range []metric{metricCosine, metricDot, metricL2} {
+			quantized := newStore("metric:"+string(m), "quantization:int8")
+			set(quantized, keys)
+
+			recall, _ := accuracy(quantized, m, keys, queries)
+			Expect(recall).To(BeNumerically(">=", 0.95), string(m))
+		}
+	})
+
+	It("finds the keys close to the queries in binary stores", func() {
+		keys := randomKeys(count)
+		// the queries are close to some of the keys, which are then the most similar ones
+		queries := make([][]float32, 50)
+		for i := range queries {
+			queries[i] = make([]float32, dim)
+			for j := range queries[i] {
+				queries[i][j] = keys[i][j] + float32(0.5*rng.NormFloat64())
+			}
+		}
+
+		for _, metric := range []string{"cosine", "dot", "l2"} {
+			s := newStore("metric:"+metric, "quantization:binary")
+			set(s, keys)
+
+			for i, q := range queries {
+				Expect(find(s, q).Values[0].Bytes).To(Equal([]byte{byte(i), 0}), metric)
+			}
+		}
+
+		// the signs of the dimensions only tell apart the keys which are not too similar, rescoring more
+		// candidates finds more of the other most similar keys
+		quantized, rescored := newStore("quantization:binary", "rescore:1"), newStore("quantization:binary", "rescore:10")
+		set(quantized, keys)
+		set(rescored, keys)
+		recall, _ := accuracy(quantized, metricCosine, keys, queries)
+		rescoredRecall, _ := accuracy(rescored, metricCosine, keys, queries)
+		Expect(rescoredRecall).To(BeNumerically(">", recall+0.05))
+	})
+
+	It("approximates the similarities of the floats of the keys", func() {
+		keys, queries := randomKeys(count), randomKeys(50)
+		int8Store, binaryStore := newStore("quantization:int8"), newStore("quantization:binary")
+		set(int8Store, keys)
+		set(binaryStore, keys)
+
+		// the similarities are the ones of the approximations of the keys, the floats are not kept
+		_, maxDiff := accuracy(int8Store, metricCosine, keys, queries)
+		Expect(maxDiff).To(BeNumerically("<", 0.01))
+		Expect(maxDiff).To(BeNumerically(">", 0))
+		_, maxDiff = accuracy(binaryStore, metricCosine, keys, queries)
+		Expect(maxDiff).To(BeNumerically("<", 0.2))
+	})
+
+	It("stores the keys in less memory", func() {
+		keys := randomKeys(100)
+		int8Store, binaryStore := newStore("quantization:int8"), newStore("quantization:binary")
+		set(int8Store, keys)
+		set(binaryStore, keys)
+
+		Expect(int8Store.collections[""].keys).To(BeEmpty())
+		Expect(int8Store.collections[""].quantized.codes[0]).To(HaveLen(dim))
+		Expect(binaryStore.collections[""].quantized.codes[0]).To(HaveLen(dim / 8))
+	})
+
+	It("gets, updates and deletes the keys set in quantized stores", func() {
+		for _, quantization := range []string{"int8", "binary"} {
+			s := newStore("quantization:" + quantization)
+			keys := randomKeys(100)
+			set(s, keys)
+
+			res, err := s.StoresGet(&pb.StoresGetOptions{Keys: []*pb.StoresKey{{Floats: keys[5]}, {Floats: keys[9]}}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Keys[0].Floats).To(Equal(keys[5]))
+			Expect(res.Values[1].Bytes).To(Equal([]byte{9, 0}))
+
+			Expect(s.StoresSet(&pb.StoresSetOptions{
+				Keys:   []*pb.StoresKey{{Floats: keys[5]}},
+				Values: []*pb.StoresValue{{Bytes: []byte("updated")}},
+			})).To(Succeed())
+			Expect(find(s, keys[5]).Values[0].Bytes).To(Equal([]byte("updated")))
+			Expect(s.collections[""].values).To(HaveLen(100))
+
+			Expect(s.StoresDelete(&pb.StoresDeleteOptions{Keys: []*pb.StoresKey{{Floats: keys[5]}, {Floats: keys[99]}}})).To(Succeed())
+			Expect(s.collections[""].values).To(HaveLen(98))
+			res, err = s.StoresGet(&pb.StoresGetOptions{Keys: []*pb.StoresKey{{Floats: keys[5]}, {Floats: keys[98]}}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Values).To(HaveLen(1))
+			Expect(res.Values[0].Bytes).To(Equal([]byte{98, 0}))
+		}
+	})
+
+	It("keeps apart the keys with the same code", func() {
+		modelPath := GinkgoT().TempDir()
+		options := &pb.ModelOptions{Model: "test", ModelPath: modelPath, Options: []string{"quantization:binary"}}
+		// the keys have the same signs, so the same binary code
+		a, b, c := []float32{1, 2, -1, 0.5}, []float32{2, 1, -3, 1}, []float32{1, 1, -1, 1}
+		values := func(s *Store, keys ...[]float32) []string {
+			opts := &pb.StoresGetOptions{}
+			for _, k := range keys {
+				opts.Keys = append(opts.Keys, &pb.StoresKey{Floats: k})
+			}
+			res, err := s.StoresGet(opts)
+			Expect(err).ToNot(HaveOccurred())
+			found := []string{}
+			for _, v := range res.Values {
+				found = append(found, string(v.Bytes))
+			}
+			return found
+		}
+
+		s := NewStore()
+		Expect(s.Load(options)).To(Succeed())
+		Expect(s.StoresSet(&pb.StoresSetOptions{
+			Keys:   []*pb.StoresKey{{Floats: a}, {Floats: b}, {Floats: c}},
+			Values: []*pb.StoresValue{{Bytes: []byte("a")}, {Bytes: []byte("b")}, {Bytes: []byte("c"), Metadata: []byte(`{"drop": true}`)}},
+		})).To(Succeed())
+		Expect(values(s, a, b, c)).To(Equal([]string{"a", "b", "c"}))
+
+		Expect(s.StoresSet(&pb.StoresSetOptions{Keys: []*pb.StoresKey{{Floats: a}}, Values: []*pb.StoresValue{{Bytes: []byte("updated")}}})).To(Succeed())
+		Expect(values(s, a, b)).To(Equal([]string{"updated", "b"}))
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Keys: []*pb.StoresKey{{Floats: a}}})).To(Succeed())
+		Expect(values(s, a, b, c)).To(Equal([]string{"b", "c"}))
+		// a key with the same code which was not set is not found
+		Expect(values(s, []float32{3, 3, -3, 3})).To(BeEmpty())
+
+		// the snapshot keeps the hashes of the keys, and the log the ones of the keys deleted with a filter
+		Expect(s.persistence.snapshot(s)).To(Succeed())
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Filter: []byte(`{"drop": true}`)})).To(Succeed())
+		Expect(s.Unload()).To(Succeed())
+
+		s = NewStore()
+		Expect(s.Load(options)).To(Succeed())
+		Expect(values(s, a, b, c)).To(Equal([]string{"b"}))
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Keys: []*pb.StoresKey{{Floats: b}}})).To(Succeed())
+		Expect(s.collections).To(BeEmpty())
+	})
+
+	It("keeps the keys of quantized stores across snapshots", func() {
+		modelPath := GinkgoT().TempDir()
+		options := &pb.ModelOptions{Model: "test", ModelPath: modelPath, Options: []string{"quantization:int8", "metric:l2"}}
+		keys := randomKeys(100)
+
+		s := NewStore()
+		Expect(s.Load(options)).To(Succeed())
+		set(s, keys)
+		Expect(s.persistence.snapshot(s)).To(Succeed())
+		Expect(s.Unload()).To(Succeed())
+
+		s = NewStore()
+		Expect(s.Load(options)).To(Succeed())
+		Expect(s.StoresDelete(&pb.StoresDeleteOptions{Keys: []*pb.StoresKey{{Floats: keys[3]}}})).To(Succeed())
+		Expect(s.collections[""].values).To(HaveLen(99))
+		Expect(find(s, keys[4]).Values[0].Bytes).To(Equal([]byte{4, 0}))
+		Expect(s.Unload()).To(Succeed())
+
+		// the keys of the store are quantized, it cannot be loaded with other options
+		Expect(NewStore().Load(&pb.ModelOptions{Model: "test", ModelPath: modelPath})).To(MatchError(ContainSubstring("quantization:int8")))
+	})
+
+	It("rejects invalid options", func() {
+		s := NewStore()
+		Expect(s.Load(&pb.ModelOptions{Options: []string{"metric:manhattan"}})).ToNot(Succeed())
+		Expect(s.Load(&pb.ModelOptions{Options: []string{"quantization:int4"}})).ToNot(Succeed())
+		Expect(s.Load(&pb.ModelOptions{Options: []string{"quantization:int8", "rescore:0"}})).ToNot(Succeed())
+		Expect(s.Load(&pb.ModelOptions{Options: []string{"quantization:int8", "index:hnsw"}})).ToNot(Succeed())
+	})
+})
+
+func abs(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
@@ -4,13 +4,15 @@ package main
 // It is meant to be used by the main executable that is the server for the specific backend type (falcon, gpt3, etc)
 import (
 	"container/heap"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"math"
+	"path/filepath"
 	"slices"
 
 	"github.com/mudler/LocalAI/pkg/grpc/base"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/store"
 
 	"github.com/rs/zerolog/log"
 )
@@ -18,32 +20,89 @@ import (
 type Store struct {
 	base.SingleThread
 
+	// The collections of the store by namespace, the default namespace being empty
+	collections map[string]*collection
+
+	storeOptions
+	// The files the store is persisted to, nil when the store is only in memory
+	persistence *persistence
+	// The directory the store is persisted to, empty when the store is only in memory. A dropped store has no
+	// files until entries are set again.
+	dir string
+}
+
+// A collection holds the entries of a namespace of the store
+type collection struct {
 	// The sorted keys
 	keys [][]float32
 	// The sorted values
 	values [][]byte
+	// The metadata of the sorted values, nil for the values without
+	metadata []map[string]any
 
 	// If for every K it holds that ||k||^2 = 1, then we can use the normalized distance functions
 	// TODO: Should we normalize incoming keys if they are not instead?
 	keysAreNormalized bool
 	// The first key decides the length of the keys
 	keyLen int
+
+	// The approximate index of the keys, nil when they are searched linearly
+	index *hnswIndex
+
+	metric metric
+	// The number of candidates rescored for each key found in quantized keys
+	rescore int
+	// The quantized keys, in place of the keys and in the order of the values, nil when the keys are not
+	// quantized
+	quantized *quantizedKeys
 }
 
 // TODO: Only used for sorting using Go's builtin implementation. The interfaces are columnar because
 // that's theoretically best for memory layout and cache locality, but this isn't optimized yet.
 type Pair struct {
-	Key   []float32
-	Value []byte
+	Key      []float32
+	Value    []byte
+	Metadata map[string]any
 }
 
 func NewStore() *Store {
 	return &Store{
+		collections:  make(map[string]*collection),
+		storeOptions: defaultOptions(),
+	}
+}
+
+func newCollection(o storeOptions) *collection {
+	c := &collection{
 		keys:              make([][]float32, 0),
 		values:            make([][]byte, 0),
+		metadata:          make([]map[string]any, 0),
 		keysAreNormalized: true,
 		keyLen:            -1,
+		metric:            o.metric,
+		rescore:           o.rescore,
+	}
+	if o.hnsw != nil {
+		c.index = newHNSWIndex(*o.hnsw, o.metric)
+	}
+	if o.quantization != quantizationNone {
+		c.quantized = newQuantizedKeys(o.quantization)
 	}
+	return c
+}
+
+// reset empties the store, leaving its lock untouched as it is held while loading
+func (s *Store) reset() {
+	s.collections = make(map[string]*collection)
+}
+
+// collection returns the collection of a namespace, which is empty and not yet part of the store if the
+// namespace has no entries
+func (s *Store) collection(namespace string) *collection {
+	if c, ok := s.collections[namespace]; ok {
+		return c
+	}
+	return newCollection(s.storeOptions)
 }
 
 func compareSlices(k1, k2 []float32) int {
@@ -99,38 +158,238 @@ func sortIntoKeySlicese(keys []*pb.StoresKey) [][]float32 {
 	return ks
 }
 
+// Load opens the store named by the model under the models path. Without a models path the store is kept
+// in memory only. The options select the index, the metric and the quantization of the store.
 func (s *Store) Load(opts *pb.ModelOptions) error {
-	if opts.Model != "" {
-		return errors.New("not implemented")
+	o, err := parseOptions(opts.Options)
+	if err != nil {
+		return err
+	}
+
+	name := opts.Model
+	if name == "" {
+		name = "default"
+	}
+	if opts.ModelPath != "" && (name != filepath.Base(name) || name == "." || name == "..") {
+		return fmt.Errorf("invalid store name %q", opts.Model)
+	}
+
+	if s.persistence != nil {
+		s.persistence.Close()
+		s.persistence = nil
 	}
+	s.reset()
+	s.storeOptions = o
+	s.dir = ""
+
+	if opts.ModelPath == "" {
+		return nil
+	}
+
+	// The indexes are not persisted, they are built again while the changes are replayed
+	dir := filepath.Join(opts.ModelPath, "stores", name)
+	p, err := openPersistence(dir, s)
+	if err != nil {
+		s.reset()
+		return err
+	}
+	s.persistence = p
+	s.dir = dir
+
 	return nil
 }
 
-// Sort the incoming kvs and merge them with the existing sorted kvs
+// Unload closes the files of the store, the changes are already persisted
+func (s *Store) Unload() error {
+	if s.persistence == nil {
+		return nil
+	}
+	err := s.persistence.Close()
+	s.persistence = nil
+	return err
+}
+
+// Changes are checked, then written to the log, then applied in memory, so that only the changes which are
+// persisted are applied.
+
 func (s *Store) StoresSet(opts *pb.StoresSetOptions) error {
+	c := s.collection(opts.Namespace)
+	metadata, err := c.checkSet(opts)
+	if err != nil {
+		return err
+	}
+
+	if s.persistence == nil && s.dir != "" {
+		p, err := openPersistence(s.dir, s)
+		if err != nil {
+			return err
+		}
+		s.persistence = p
+	}
+
+	if s.persistence != nil {
+		r := record{op: opSet, namespace: opts.Namespace}
+		for i, k := range opts.Keys {
+			r.keys = append(r.keys, k.Floats)
+			r.values = append(r.values, opts.Values[i].Bytes)
+			r.metadata = append(r.metadata, opts.Values[i].Metadata)
+		}
+		if err := s.persistence.append(r); err != nil {
+			return err
+		}
+	}
+
+	c.set(opts, metadata)
+	s.collections[opts.Namespace] = c
+	s.snapshotIfNeeded()
+
+	return nil
+}
+
+func (s *Store) StoresDelete(opts *pb.StoresDeleteOptions) error {
+	c := s.collection(opts.Namespace)
+
+	// the keys matching a filter are deleted like keys given explicitly, which the log records. The keys of
+	// quantized stores are approximations, recorded with the hashes of the keys they approximate.
+	var hashes []uint64
+	if len(opts.Filter) > 0 {
+		if len(opts.Keys) > 0 {
+			return fmt.Errorf("either keys or a filter must be deleted, not both")
+		}
+		filter, err := store.ParseFilter(opts.Filter)
+		if err != nil {
+			return err
+		}
+		var keys []*pb.StoresKey
+		keys, hashes = c.matching(filter)
+		if len(keys) == 0 {
+			return nil
+		}
+		opts = &pb.StoresDeleteOptions{Keys: keys, Namespace: opts.Namespace}
+	}
+
+	if err := c.checkDelete(opts); err != nil {
+		return err
+	}
+
+	if s.persistence != nil {
+		r := record{op: opDelete, namespace: opts.Namespace, hashes: hashes}
+		if hashes != nil {
+			r.op = opDeleteHashed
+		}
+		for _, k := range opts.Keys {
+			r.keys = append(r.keys, k.Floats)
+		}
+		if err := s.persistence.append(r); err != nil {
+			return err
+		}
+	}
+
+	if hashes != nil {
+		c.deleteQuantized(hashes)
+	} else {
+		c.delete(opts)
+	}
+	if len(c.values) > 0 {
+		s.collections[opts.Namespace] = c
+	} else {
+		delete(s.collections, opts.Namespace)
+	}
+	s.snapshotIfNeeded()
+
+	return nil
+}
+
+func (s *Store) snapshotIfNeeded() {
+	if s.persistence == nil || s.persistence.walSize <= s.persistence.snapshotSize {
+		return
+	}
+	if err := s.persistence.snapshot(s); err != nil {
+		// the log still holds the changes, the snapshot is attempted again on the next change
+		log.Warn().Err(err).Msg("failed to snapshot the store")
+	}
+}
+
+func (s *Store) StoresGet(opts *pb.StoresGetOptions) (pb.StoresGetResult, error) {
+	return s.collection(opts.Namespace).get(opts)
+}
+
+func (s *Store) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResult, error) {
+	filter, err := store.ParseFilter(opts.Filter)
+	if err != nil {
+		return pb.StoresFindResult{}, err
+	}
+
+	return s.collection(opts.Namespace).find(opts, filter)
+}
+
+// parseMetadata parses the metadata of an entry, which must be a JSON object
+func parseMetadata(metadata []byte) (map[string]any, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(metadata, &m); err != nil {
+		return nil, fmt.Errorf("the metadata must be a JSON object: %w", err)
+	}
+	return m, nil
+}
+
+func marshalMetadata(metadata map[string]any) []byte {
+	if metadata == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(metadata)
+	assert(err == nil, fmt.Sprintf("failed to marshal the metadata: %v", err))
+	return b
+}
+
+// checkSet checks the keys to set, returning the parsed metadata of their values
+func (c *collection) checkSet(opts *pb.StoresSetOptions) ([]map[string]any, error) {
 	if len(opts.Keys) == 0 {
-		return fmt.Errorf("no keys to add")
+		return nil, fmt.Errorf("no keys to add")
 	}
 
 	if len(opts.Keys) != len(opts.Values) {
-		return fmt.Errorf("len(keys) = %d, len(values) = %d", len(opts.Keys), len(opts.Values))
+		return nil, fmt.Errorf("len(keys) = %d, len(values) = %d", len(opts.Keys), len(opts.Values))
 	}
 
-	if s.keyLen == -1 {
-		s.keyLen = len(opts.Keys[0].Floats)
-	} else {
-		if len(opts.Keys[0].Floats) != s.keyLen {
-			return fmt.Errorf("Try to add key with length %d when existing length is %d", len(opts.Keys[0].Floats), s.keyLen)
+	if c.keyLen != -1 && len(opts.Keys[0].Floats) != c.keyLen {
+		return nil, fmt.Errorf("Try to add key with length %d when existing length is %d", len(opts.Keys[0].Floats), c.keyLen)
+	}
+
+	metadata := make([]map[string]any, len(opts.Values))
+	for i, v := range opts.Values {
+		m, err := parseMetadata(v.Metadata)
+		if err != nil {
+			return nil, err
 		}
+		metadata[i] = m
+	}
+
+	return metadata, nil
+}
+
+// Sort the incoming kvs and merge them with the existing sorted kvs
+func (c *collection) set(opts *pb.StoresSetOptions, metadata []map[string]any) {
+	if c.keyLen == -1 {
+		c.keyLen = len(opts.Keys[0].Floats)
+	}
+
+	if c.quantized != nil {
+		c.setQuantized(opts, metadata, keyHashes(opts.Keys))
+		return
 	}
 
 	kvs := make([]Pair, len(opts.Keys))
 
 	for i, k := range opts.Keys {
-		if s.keysAreNormalized && !isNormalized(k.Floats) {
-			s.keysAreNormalized = false
+		if c.keysAreNormalized && !isNormalized(k.Floats) {
+			c.keysAreNormalized = false
 			var sample []float32
-			if len(s.keys) > 5 {
+			if len(c.keys) > 5 {
 				sample = k.Floats[:5]
 			} else {
 				sample = k.Floats
@@ -139,8 +398,9 @@ func (s *Store) StoresSet(opts *pb.StoresSetOptions) error {
 		}
 
 		kvs[i] = Pair{
-			Key:   k.Floats,
-			Value: opts.Values[i].Bytes,
+			Key:      k.Floats,
+			Value:    opts.Values[i].Bytes,
+			Metadata: metadata[i],
 		}
 	}
 
@@ -151,9 +411,10 @@ func (s *Store) StoresSet(opts *pb.StoresSetOptions) error {
 	assert(len(kvs) == len(opts.Keys), fmt.Sprintf("len(kvs) = %d, len(opts.Keys) = %d", len(kvs), len(opts.Keys)))
 	assert(isSortedPairs(kvs), "keys are not sorted")
 
-	l := len(kvs) + len(s.keys)
+	l := len(kvs) + len(c.keys)
 	merge_ks := make([][]float32, 0, l)
 	merge_vs := make([][]byte, 0, l)
+	merge_ms := make([]map[string]any, 0, l)
 
 	i, j := 0, 0
 	for {
@@ -162,80 +423,129 @@ func (s *Store) StoresSet(opts *pb.StoresSetOptions) error {
 		}
 
 		if i >= len(kvs) {
-			merge_ks = append(merge_ks, s.keys[j])
-			merge_vs = append(merge_vs, s.values[j])
+			merge_ks = append(merge_ks, c.keys[j])
+			merge_vs = append(merge_vs, c.values[j])
+			merge_ms = append(merge_ms, c.metadata[j])
 			j++
 			continue
 		}
 
-		if j >= len(s.keys) {
+		if j >= len(c.keys) {
 			merge_ks = append(merge_ks, kvs[i].Key)
 			merge_vs = append(merge_vs, kvs[i].Value)
+			merge_ms = append(merge_ms, kvs[i].Metadata)
 			i++
 			continue
 		}
 
-		c := compareSlices(kvs[i].Key, s.keys[j])
-		if c < 0 {
+		cmp := compareSlices(kvs[i].Key, c.keys[j])
+		if cmp < 0 {
 			merge_ks = append(merge_ks, kvs[i].Key)
 			merge_vs = append(merge_vs, kvs[i].Value)
+			merge_ms = append(merge_ms, kvs[i].Metadata)
 			i++
-		} else if c > 0 {
-			merge_ks = append(merge_ks, s.keys[j])
-			merge_vs = append(merge_vs, s.values[j])
+		} else if cmp > 0 {
+			merge_ks = append(merge_ks, c.keys[j])
+			merge_vs = append(merge_vs, c.values[j])
+			merge_ms = append(merge_ms, c.metadata[j])
 			j++
 		} else {
 			merge_ks = append(merge_ks, kvs[i].Key)
 			merge_vs = append(merge_vs, kvs[i].Value)
+			merge_ms = append(merge_ms, kvs[i].Metadata)
 			i++
 			j++
 		}
 	}
 
-	assert(len(merge_ks) == l, fmt.Sprintf("len(merge_ks) = %d, l = %d", len(merge_ks), l))
+	// keys which are set again are merged, as when the log is replayed over a snapshot
+	assert(len(merge_ks) <= l, fmt.Sprintf("len(merge_ks) = %d, l = %d", len(merge_ks), l))
 	assert(isSortedKeys(merge_ks), "merge keys are not sorted")
 
-	s.keys = merge_ks
-	s.values = merge_vs
+	c.keys = merge_ks
+	c.values = merge_vs
+	c.metadata = merge_ms
 
-	return nil
+	if c.index != nil {
+		for i, k := range opts.Keys {
+			c.index.insert(k.Floats, opts.Values[i].Bytes, metadata[i])
+		}
+	}
 }
 
-func (s *Store) StoresDelete(opts *pb.StoresDeleteOptions) error {
+// matching returns the keys whose metadata matches the filter, with the hashes of the keys they approximate
+// when they are quantized
+func (c *collection) matching(filter *store.Filter) ([]*pb.StoresKey, []uint64) {
+	var keys []*pb.StoresKey
+	var hashes []uint64
+	for i, k := range c.storedKeys() {
+		if filter.Match(c.metadata[i]) {
+			keys = append(keys, &pb.StoresKey{Floats: k})
+			if c.quantized != nil {
+				hashes = append(hashes, c.quantized.hashes[i])
+			}
+		}
+	}
+	return keys, hashes
+}
+
+// storedKeys returns the keys of the entries, which are the approximations of the keys set when they are quantized
+func (c *collection) storedKeys() [][]float32 {
+	if c.quantized == nil {
+		return c.keys
+	}
+
+	keys := make([][]float32, len(c.quantized.codes))
+	for i := range keys {
+		keys[i] = c.quantized.key(i, c.keyLen)
+	}
+	return keys
+}
+
+func (c *collection) checkDelete(opts *pb.StoresDeleteOptions) error {
 	if len(opts.Keys) == 0 {
 		return fmt.Errorf("no keys to delete")
 	}
 
-	if len(opts.Keys) == 0 {
-		return fmt.Errorf("no keys to add")
+	if c.keyLen != -1 && len(opts.Keys[0].Floats) != c.keyLen {
+		return fmt.Errorf("Trying to delete key with length %d when existing length is %d", len(opts.Keys[0].Floats), c.keyLen)
 	}
 
-	if s.keyLen == -1 {
-		s.keyLen = len(opts.Keys[0].Floats)
-	} else {
-		if len(opts.Keys[0].Floats) != s.keyLen {
-			return fmt.Errorf("Trying to delete key with length %d when existing length is %d", len(opts.Keys[0].Floats), s.keyLen)
-		}
+	return nil
+}
+
+func (c *collection) delete(opts *pb.StoresDeleteOptions) {
+	if c.keyLen == -1 {
+		c.keyLen = len(opts.Keys[0].Floats)
+	}
+
+	if c.quantized != nil {
+		c.deleteQuantized(keyHashes(opts.Keys))
+		return
 	}
 
 	ks := sortIntoKeySlicese(opts.Keys)
 
-	l := len(s.keys) - len(ks)
+	l := len(c.keys) - len(ks)
 	merge_ks := make([][]float32, 0, l)
 	merge_vs := make([][]byte, 0, l)
+	merge_ms := make([]map[string]any, 0, l)
 
-	tail_ks := s.keys
-	tail_vs := s.values
+	tail_ks := c.keys
+	tail_vs := c.values
+	tail_ms := c.metadata
 	for _, k := range ks {
 		j, found := findInSortedSlice(tail_ks, k)
 
 		if found {
 			merge_ks = append(merge_ks, tail_ks[:j]...)
 			merge_vs = append(merge_vs, tail_vs[:j]...)
+			merge_ms = append(merge_ms, tail_ms[:j]...)
 			tail_ks = tail_ks[j+1:]
 			tail_vs = tail_vs[j+1:]
+			tail_ms = tail_ms[j+1:]
 		} else {
-			assert(!hasKey(s.keys, k), fmt.Sprintf("Key exists, but was not found: t=%d, %v", len(tail_ks), k))
+			assert(!hasKey(c.keys, k), fmt.Sprintf("Key exists, but was not found: t=%d, %v", len(tail_ks), k))
 		}
 
 		log.Debug().Msgf("Delete: found = %v, t = %d, j = %d, len(merge_ks) = %d, len(merge_vs) = %d", found, len(tail_ks), j, len(merge_ks), len(merge_vs))
@@ -243,49 +553,60 @@ func (s *Store) StoresDelete(opts *pb.StoresDeleteOptions) error {
 
 	merge_ks = append(merge_ks, tail_ks...)
 	merge_vs = append(merge_vs, tail_vs...)
+	merge_ms = append(merge_ms, tail_ms...)
 
-	assert(len(merge_ks) <= len(s.keys), fmt.Sprintf("len(merge_ks) = %d, len(s.keys) = %d", len(merge_ks), len(s.keys)))
+	assert(len(merge_ks) <= len(c.keys), fmt.Sprintf("len(merge_ks) = %d, len(c.keys) = %d", len(merge_ks), len(c.keys)))
 
-	s.keys = merge_ks
-	s.values = merge_vs
+	c.keys = merge_ks
+	c.values = merge_vs
+	c.metadata = merge_ms
 
-	assert(len(s.keys) >= l, fmt.Sprintf("len(s.keys) = %d, l = %d", len(s.keys), l))
-	assert(isSortedKeys(s.keys), "keys are not sorted")
+	assert(len(c.keys) >= l, fmt.Sprintf("len(c.keys) = %d, l = %d", len(c.keys), l))
+	assert(isSortedKeys(c.keys), "keys are not sorted")
 	assert(func() bool {
 		for _, k := range ks {
-			if _, found := findInSortedSlice(s.keys, k); found {
+			if _, found := findInSortedSlice(c.keys, k); found {
 				return false
 			}
 		}
 		return true
 	}(), "Keys to delete still present")
 
-	if len(s.keys) != l {
-		log.Debug().Msgf("Delete: Some keys not found: len(s.keys) = %d, l = %d", len(s.keys), l)
+	if len(c.keys) != l {
+		log.Debug().Msgf("Delete: Some keys not found: len(c.keys) = %d, l = %d", len(c.keys), l)
 	}
 
-	return nil
+	if c.index != nil {
+		for _, k := range opts.Keys {
+			c.index.delete(k.Floats)
+		}
+	}
 }
 
-func (s *Store) StoresGet(opts *pb.StoresGetOptions) (pb.StoresGetResult, error) {
+func (c *collection) get(opts *pb.StoresGetOptions) (pb.StoresGetResult, error) {
 	pbKeys := make([]*pb.StoresKey, 0, len(opts.Keys))
 	pbValues := make([]*pb.StoresValue, 0, len(opts.Keys))
 	ks := sortIntoKeySlicese(opts.Keys)
 
-	if len(s.keys) == 0 {
+	if len(c.keys) == 0 {
 		log.Debug().Msgf("Get: No keys in store")
 	}
 
-	if s.keyLen == -1 {
-		s.keyLen = len(opts.Keys[0].Floats)
+	if c.keyLen == -1 {
+		c.keyLen = len(opts.Keys[0].Floats)
 	} else {
-		if len(opts.Keys[0].Floats) != s.keyLen {
-			return pb.StoresGetResult{}, fmt.Errorf("Try to get a key with length %d when existing length is %d", len(opts.Keys[0].Floats), s.keyLen)
+		if len(opts.Keys[0].Floats) != c.keyLen {
+			return pb.StoresGetResult{}, fmt.Errorf("Try to get a key with length %d when existing length is %d", len(opts.Keys[0].Floats), c.keyLen)
 		}
 	}
 
-	tail_k := s.keys
-	tail_v := s.values
+	if c.quantized != nil {
+		return c.getQuantized(opts), nil
+	}
+
+	tail_k := c.keys
+	tail_v := c.values
+	tail_m := c.metadata
 	for i, k := range ks {
 		j, found := findInSortedSlice(tail_k, k)
 
@@ -294,18 +615,20 @@ func (s *Store) StoresGet(opts *pb.StoresGetOptions) (pb.StoresGetResult, error)
 				Floats: k,
 			})
 			pbValues = append(pbValues, &pb.StoresValue{
-				Bytes: tail_v[j],
+				Bytes:    tail_v[j],
+				Metadata: marshalMetadata(tail_m[j]),
 			})
 
 			tail_k = tail_k[j+1:]
 			tail_v = tail_v[j+1:]
+			tail_m = tail_m[j+1:]
 		} else {
-			assert(!hasKey(s.keys, k), fmt.Sprintf("Key exists, but was not found: i=%d, %v", i, k))
+			assert(!hasKey(c.keys, k), fmt.Sprintf("Key exists, but was not found: i=%d, %v", i, k))
 		}
 	}
 
 	if len(pbKeys) != len(opts.Keys) {
-		log.Debug().Msgf("Get: Some keys not found: len(pbKeys) = %d, len(opts.Keys) = %d, len(s.Keys) = %d", len(pbKeys), len(opts.Keys), len(s.keys))
+		log.Debug().Msgf("Get: Some keys not found: len(pbKeys) = %d, len(opts.Keys) = %d, len(s.Keys) = %d", len(pbKeys), len(opts.Keys), len(c.keys))
 	}
 
 	return pb.StoresGetResult{
@@ -346,6 +669,7 @@ type PriorityItem struct {
 	Similarity float32
 	Key        []float32
 	Value      []byte
+	Metadata   map[string]any
 }
 
 type PriorityQueue []*PriorityItem
@@ -374,17 +698,21 @@ func (pq *PriorityQueue) Pop() any {
 	return item
 }
 
-func (s *Store) StoresFindNormalized(opts *pb.StoresFindOptions) (pb.StoresFindResult, error) {
-	tk := opts.Key.Floats
+// findLinear compares the query to all the keys matching the filter, returning the TopK most similar ones
+func (c *collection) findLinear(opts *pb.StoresFindOptions, filter *store.Filter, similarity func(k []float32) float32) pb.StoresFindResult {
 	top_ks := make(PriorityQueue, 0, int(opts.TopK))
 	heap.Init(&top_ks)
 
-	for i, k := range s.keys {
-		sim := normalizedCosineSimilarity(tk, k)
+	for i, k := range c.keys {
+		if !filter.Match(c.metadata[i]) {
+			continue
+		}
+
 		heap.Push(&top_ks, &PriorityItem{
-			Similarity: sim,
+			Similarity: similarity(k),
 			Key:        k,
-			Value:      s.values[i],
+			Value:      c.values[i],
+			Metadata:   c.metadata[i],
 		})
 
 		if top_ks.Len() > int(opts.TopK) {
@@ -404,7 +732,8 @@ func (s *Store) StoresFindNormalized(opts *pb.StoresFindOptions) (pb.StoresFindR
 			Floats: item.Key,
 		}
 		pbValues[i] = &pb.StoresValue{
-			Bytes: item.Value,
+			Bytes:    item.Value,
+			Metadata: marshalMetadata(item.Metadata),
 		}
 	}
 
@@ -412,7 +741,15 @@ func (s *Store) StoresFindNormalized(opts *pb.StoresFindOptions) (pb.StoresFindR
 		Keys:         pbKeys,
 		Values:       pbValues,
 		Similarities: similarities,
-	}, nil
+	}
+}
+
+func (c *collection) findNormalized(opts *pb.StoresFindOptions, filter *store.Filter) (pb.StoresFindResult, error) {
+	tk := opts.Key.Floats
+
+	return c.findLinear(opts, filter, func(k []float32) float32 {
+		return normalizedCosineSimilarity(tk, k)
+	}), nil
 }
 
 func cosineSimilarity(k1, k2 []float32, mag1 float64) float32 {
@@ -431,10 +768,8 @@ func cosineSimilarity(k1, k2 []float32, mag1 float64) float32 {
 	return sim
 }
 
-func (s *Store) StoresFindFallback(opts *pb.StoresFindOptions) (pb.StoresFindResult, error) {
+func (c *collection) findFallback(opts *pb.StoresFindOptions, filter *store.Filter) (pb.StoresFindResult, error) {
 	tk := opts.Key.Floats
-	top_ks := make(PriorityQueue, 0, int(opts.TopK))
-	heap.Init(&top_ks)
 
 	var mag1 float64
 	for _, v := range tk {
@@ -442,32 +777,32 @@ func (s *Store) StoresFindFallback(opts *pb.StoresFindOptions) (pb.StoresFindRes
 	}
 	mag1 = math.Sqrt(mag1)
 
-	for i, k := range s.keys {
-		dist := cosineSimilarity(tk, k, mag1)
-		heap.Push(&top_ks, &PriorityItem{
-			Similarity: dist,
-			Key:        k,
-			Value:      s.values[i],
-		})
+	return c.findLinear(opts, filter, func(k []float32) float32 {
+		return cosineSimilarity(tk, k, mag1)
+	}), nil
+}
 
-		if top_ks.Len() > int(opts.TopK) {
-			heap.Pop(&top_ks)
-		}
-	}
+// findIndexed searches the index, which may find less than TopK keys matching the filter. complete tells
+// whether there are no other keys matching it, as the search visited all of them.
+func (c *collection) findIndexed(opts *pb.StoresFindOptions, filter *store.Filter) (res pb.StoresFindResult, complete bool) {
+	found, exhaustive := c.index.search(opts.Key.Floats, int(opts.TopK), func(n *hnswNode) bool {
+		return filter.Match(n.metadata)
+	})
 
-	similarities := make([]float32, top_ks.Len())
-	pbKeys := make([]*pb.StoresKey, top_ks.Len())
-	pbValues := make([]*pb.StoresValue, top_ks.Len())
+	similarities := make([]float32, len(found))
+	pbKeys := make([]*pb.StoresKey, len(found))
+	pbValues := make([]*pb.StoresValue, len(found))
 
-	for i := top_ks.Len() - 1; i >= 0; i-- {
-		item := heap.Pop(&top_ks).(*PriorityItem)
+	for i, f := range found {
+		n := c.index.nodes[f.id]
 
-		similarities[i] = item.Similarity
+		similarities[i] = f.similarity
 		pbKeys[i] = &pb.StoresKey{
-			Floats: item.Key,
+			Floats: n.key,
 		}
 		pbValues[i] = &pb.StoresValue{
-			Bytes: item.Value,
+			Bytes:    n.value,
+			Metadata: marshalMetadata(n.metadata),
 		}
 	}
 
@@ -475,34 +810,46 @@ func (s *Store) StoresFindFallback(opts *pb.StoresFindOptions) (pb.StoresFindRes
 		Keys:         pbKeys,
 		Values:       pbValues,
 		Similarities: similarities,
-	}, nil
+	}, len(found) == int(opts.TopK) || exhaustive
 }
 
-func (s *Store) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResult, error) {
+func (c *collection) find(opts *pb.StoresFindOptions, filter *store.Filter) (pb.StoresFindResult, error) {
 	tk := opts.Key.Floats
 
-	if len(tk) != s.keyLen {
-		return pb.StoresFindResult{}, fmt.Errorf("Try to find key with length %d when existing length is %d", len(tk), s.keyLen)
+	// An empty collection has no key length yet, so nothing is found whatever the length of the key
+	if len(c.values) == 0 {
+		return pb.StoresFindResult{}, nil
+	}
+
+	if len(tk) != c.keyLen {
+		return pb.StoresFindResult{}, fmt.Errorf("Try to find key with length %d when existing length is %d", len(tk), c.keyLen)
 	}
 
 	if opts.TopK < 1 {
 		return pb.StoresFindResult{}, fmt.Errorf("opts.TopK = %d, must be >= 1", opts.TopK)
 	}
 
-	if s.keyLen == -1 {
-		s.keyLen = len(opts.Key.Floats)
-	} else {
-		if len(opts.Key.Floats) != s.keyLen {
-			return pb.StoresFindResult{}, fmt.Errorf("Try to add key with length %d when existing length is %d", len(opts.Key.Floats), s.keyLen)
+	if c.quantized != nil {
+		return c.findQuantized(opts, filter), nil
+	}
+
+	if c.index != nil {
+		// The keys matching the filter which the graph cannot reach are searched linearly
+		if res, complete := c.findIndexed(opts, filter); filter == nil || complete {
+			return res, nil
 		}
 	}
 
-	if s.keysAreNormalized && isNormalized(tk) {
-		return s.StoresFindNormalized(opts)
+	if c.metric != metricCosine {
+		return c.findLinear(opts, filter, c.metric.similarity(tk)), nil
+	}
+
+	if c.keysAreNormalized && isNormalized(tk) {
+		return c.findNormalized(opts, filter)
 	} else {
-		if s.keysAreNormalized {
+		if c.keysAreNormalized {
 			var sample []float32
-			if len(s.keys) > 5 {
+			if len(c.keys) > 5 {
 				sample = tk[:5]
 			} else {
 				sample = tk
@@ -510,6 +857,6 @@ func (s *Store) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResult, err
 			log.Debug().Msgf("Trying to compare non-normalized key with normalized keys: %v", sample)
 		}
 
-		return s.StoresFindFallback(opts)
+		return c.findFallback(opts, filter)
 	}
 }
@@ -5,6 +5,7 @@ Extra gRPC server for HuggingFace AutoModel models.
 from concurrent import futures
 
 import argparse
+import gc
 import signal
 import sys
 import os
@@ -320,6 +321,18 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
         # Replace this with your desired response
         return backend_pb2.Result(message="Model loaded successfully", success=True)
 
+    def Unload(self, request, context):
+        """
+        Frees the memory used by the model weights, keeping the backend process running.
+        The model is loaded again with LoadModel when it is used next.
+        """
+        if hasattr(self, "model"):
+            del self.model
+        gc.collect()
+        if torch.cuda.is_available():
+            torch.cuda.empty_cache()
+        return backend_pb2.Result(message="Model unloaded", success=True)
+
     def Embedding(self, request, context):
         """
         A gRPC method that calculates embeddings for a given sentence.
@@ -358,6 +371,46 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
             embeds = sentence_embeddings[0]
         return backend_pb2.EmbeddingResult(embeddings=embeds)
 
+    def EmbeddingBatch(self, request, context):
+        """
+        A gRPC method that calculates the embeddings of a batch of sentences in a single pass.
+
+        Args:
+            request: A PredictOptions object with the sentences in EmbeddingsBatch.
+            context: A grpc.ServicerContext object that provides information about the RPC.
+
+        Returns:
+            An EmbeddingBatchResult object with the embeddings of the sentences, in order.
+        """
+        if len(request.EmbeddingTokensBatch) > 0:
+            # embedding tokens is not supported, the client embeds them one at a time instead
+            context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+            context.set_details("embedding tokens is not supported")
+            return backend_pb2.EmbeddingBatchResult()
+
+        set_seed(request.Seed)
+        max_length = 512
+        if request.Tokens != 0:
+            max_length = request.Tokens
+
+        sentences = list(request.EmbeddingsBatch)
+        if self.SentenceTransformer:
+            embeds = self.model.encode(sentences)
+        else:
+            encoded_input = self.tokenizer(sentences, padding=True, truncation=True, max_length=max_length, return_tensors="pt")
+
+            if self.CUDA:
+                encoded_input = encoded_input.to("cuda")
+
+            with torch.no_grad():
+                model_output = self.model(**encoded_input)
+
+            embeds = mean_pooling(model_output, encoded_input['attention_mask']).cpu()
+
+        return backend_pb2.EmbeddingBatchResult(
+            embeddings=[backend_pb2.EmbeddingResult(embeddings=e.tolist()) for e in embeds]
+        )
+
     async def _predict(self, request, context, streaming=False): 
         set_seed(request.Seed)
         if request.TopP < 0 or request.TopP > 1:
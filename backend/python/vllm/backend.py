@@ -14,7 +14,7 @@ import backend_pb2_grpc
 import grpc
 from vllm.engine.arg_utils import AsyncEngineArgs
 from vllm.engine.async_llm_engine import AsyncLLMEngine
-from vllm.sampling_params import SamplingParams
+from vllm.sampling_params import SamplingParams, GuidedDecodingParams
 from vllm.utils import random_uuid
 from vllm.transformers_utils.tokenizer import get_tokenizer
 from vllm.multimodal.utils import fetch_image
@@ -229,6 +229,14 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
                 if value not in (None, 0, [], False):
                     setattr(sampling_params, param_field, value)
 
+        # Constrain the output natively, rather than with the grammar compiled by LocalAI
+        if request.GuidedRegex:
+            sampling_params.guided_decoding = GuidedDecodingParams(regex=request.GuidedRegex)
+        elif request.GuidedChoice:
+            sampling_params.guided_decoding = GuidedDecodingParams(choice=list(request.GuidedChoice))
+        elif request.GuidedGrammar:
+            sampling_params.guided_decoding = GuidedDecodingParams(grammar=request.GuidedGrammar)
+
         # Extract image paths and process images
         prompt = request.Prompt
 
@@ -1,6 +1,7 @@
 package application
 
 import (
+	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/core/templates"
@@ -8,19 +9,25 @@ import (
 )
 
 type Application struct {
-	backendLoader      *config.ModelConfigLoader
-	modelLoader        *model.ModelLoader
-	applicationConfig  *config.ApplicationConfig
-	templatesEvaluator *templates.Evaluator
-	galleryService     *services.GalleryService
+	backendLoader       *config.ModelConfigLoader
+	modelLoader         *model.ModelLoader
+	applicationConfig   *config.ApplicationConfig
+	templatesEvaluator  *templates.Evaluator
+	galleryService      *services.GalleryService
+	documentsService    *services.DocumentsService
+	filesService        *services.FilesService
+	vectorStoresService *services.VectorStoresService
+	healthService       *services.HealthService
 }
 
 func newApplication(appConfig *config.ApplicationConfig) *Application {
+	ml := model.NewModelLoader(appConfig.SystemState, appConfig.SingleBackend)
 	return &Application{
 		backendLoader:      config.NewModelConfigLoader(appConfig.SystemState.Model.ModelsPath),
-		modelLoader:        model.NewModelLoader(appConfig.SystemState, appConfig.SingleBackend),
+		modelLoader:        ml,
 		applicationConfig:  appConfig,
 		templatesEvaluator: templates.NewEvaluator(appConfig.SystemState.Model.ModelsPath),
+		healthService:      services.NewHealthService(ml, appConfig.HealthCheckInterval),
 	}
 }
 
@@ -44,6 +51,22 @@ func (a *Application) GalleryService() *services.GalleryService {
 	return a.galleryService
 }
 
+func (a *Application) DocumentsService() *services.DocumentsService {
+	return a.documentsService
+}
+
+func (a *Application) FilesService() *services.FilesService {
+	return a.filesService
+}
+
+func (a *Application) VectorStoresService() *services.VectorStoresService {
+	return a.vectorStoresService
+}
+
+func (a *Application) HealthService() *services.HealthService {
+	return a.healthService
+}
+
 func (a *Application) start() error {
 	galleryService := services.NewGalleryService(a.ApplicationConfig(), a.ModelLoader())
 	err := galleryService.Start(a.ApplicationConfig().Context, a.ModelConfigLoader(), a.ApplicationConfig().SystemState)
@@ -53,5 +76,27 @@ func (a *Application) start() error {
 
 	a.galleryService = galleryService
 
+	documentsService := services.NewDocumentsService(a.ApplicationConfig())
+	err = documentsService.Start(a.ApplicationConfig().Context, backend.DocumentIngester(a.ModelLoader(), a.ModelConfigLoader(), a.ApplicationConfig()))
+	if err != nil {
+		return err
+	}
+
+	a.documentsService = documentsService
+
+	filesService, err := services.NewFilesService(a.ApplicationConfig().UploadDir)
+	if err != nil {
+		return err
+	}
+
+	a.filesService = filesService
+
+	vectorStoresService, err := services.NewVectorStoresService(a.ApplicationConfig(), documentsService)
+	if err != nil {
+		return err
+	}
+
+	a.vectorStoresService = vectorStoresService
+
 	return nil
 }
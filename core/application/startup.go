@@ -82,19 +82,9 @@ func New(opts ...config.AppOption) (*Application, error) {
 		}
 	}
 
-	if err := application.ModelConfigLoader().Preload(options.SystemState.Model.ModelsPath); err != nil {
-		log.Error().Err(err).Msg("error downloading models")
-	}
-
-	if options.PreloadJSONModels != "" {
-		if err := services.ApplyGalleryFromString(options.SystemState, application.ModelLoader(), options.EnforcePredownloadScans, options.AutoloadBackendGalleries, options.Galleries, options.BackendGalleries, options.PreloadJSONModels); err != nil {
-			return nil, err
-		}
-	}
-
-	if options.PreloadModelsFromPath != "" {
-		if err := services.ApplyGalleryFromFile(options.SystemState, application.ModelLoader(), options.EnforcePredownloadScans, options.AutoloadBackendGalleries, options.Galleries, options.BackendGalleries, options.PreloadModelsFromPath); err != nil {
-			return nil, err
+	if options.ModelRoutesFile != "" {
+		if err := application.ModelConfigLoader().LoadModelRoutesFromFile(options.ModelRoutesFile); err != nil {
+			log.Error().Err(err).Msg("error loading model routes file")
 		}
 	}
 
@@ -120,7 +110,8 @@ func New(opts ...config.AppOption) (*Application, error) {
 			options.WatchDogBusyTimeout,
 			options.WatchDogIdleTimeout,
 			options.WatchDogBusy,
-			options.WatchDogIdle)
+			options.WatchDogIdle,
+			options.WatchDogIdleSuspend)
 		application.ModelLoader().SetWatchDog(wd)
 		go wd.Run()
 		go func() {
@@ -130,25 +121,6 @@ func New(opts ...config.AppOption) (*Application, error) {
 		}()
 	}
 
-	if options.LoadToMemory != nil && !options.SingleBackend {
-		for _, m := range options.LoadToMemory {
-			cfg, err := application.ModelConfigLoader().LoadModelConfigFileByNameDefaultOptions(m, options)
-			if err != nil {
-				return nil, err
-			}
-
-			log.Debug().Msgf("Auto loading model %s into memory from file: %s", m, cfg.Model)
-
-			o := backend.ModelOptions(*cfg, options)
-
-			var backendErr error
-			_, backendErr = application.ModelLoader().Load(o...)
-			if backendErr != nil {
-				return nil, err
-			}
-		}
-	}
-
 	// Watch the configuration directory
 	startWatcher(options)
 
@@ -156,10 +128,88 @@ func New(opts ...config.AppOption) (*Application, error) {
 		return nil, err
 	}
 
+	application.HealthService().Start(options.Context)
+	startPreloadJobs(application, options)
+
 	log.Info().Msg("core/startup process completed!")
 	return application, nil
 }
 
+// startPreloadJobs downloads and loads the models requested at startup in the background,
+// so that the API can report the progress in /readyz and /healthz/details.
+// The instance cannot start if a required job fails (see HealthService.WaitForJobs).
+func startPreloadJobs(application *Application, options *config.ApplicationConfig) {
+	type job struct {
+		name     string
+		required bool
+		fn       func() error
+	}
+	jobs := []job{{
+		// the files which cannot be downloaded are only logged, as before the jobs
+		name: "preload model files",
+		fn: func() error {
+			return application.ModelConfigLoader().Preload(options.SystemState.Model.ModelsPath)
+		},
+	}}
+
+	if options.PreloadJSONModels != "" {
+		jobs = append(jobs, job{
+			name:     "preload gallery models",
+			required: true,
+			fn: func() error {
+				return services.ApplyGalleryFromString(options.SystemState, application.ModelLoader(), options.EnforcePredownloadScans, options.AutoloadBackendGalleries, options.Galleries, options.BackendGalleries, options.PreloadJSONModels)
+			},
+		})
+	}
+
+	if options.PreloadModelsFromPath != "" {
+		jobs = append(jobs, job{
+			name:     "preload gallery models from " + options.PreloadModelsFromPath,
+			required: true,
+			fn: func() error {
+				return services.ApplyGalleryFromFile(options.SystemState, application.ModelLoader(), options.EnforcePredownloadScans, options.AutoloadBackendGalleries, options.Galleries, options.BackendGalleries, options.PreloadModelsFromPath)
+			},
+		})
+	}
+
+	if options.LoadToMemory != nil && !options.SingleBackend {
+		for _, m := range options.LoadToMemory {
+			jobs = append(jobs, job{
+				name:     "load model " + m,
+				required: true,
+				fn: func() error {
+					cfg, err := application.ModelConfigLoader().LoadModelConfigFileByNameDefaultOptions(m, options)
+					if err != nil {
+						return err
+					}
+
+					log.Debug().Msgf("Auto loading model %s into memory from file: %s", m, cfg.Model)
+
+					// Models are identified by the name of their config, as in backend.ModelOptions
+					name := cfg.Name
+					if name == "" {
+						name = cfg.Model
+					}
+					application.HealthService().RequireModel(name)
+
+					_, err = application.ModelLoader().Load(backend.ModelOptions(*cfg, options)...)
+					return err
+				},
+			})
+		}
+	}
+
+	for _, j := range jobs {
+		application.HealthService().AddJob(j.name, j.required)
+	}
+
+	go func() {
+		for _, j := range jobs {
+			application.HealthService().RunJob(j.name, j.fn)
+		}
+	}()
+}
+
 func startWatcher(options *config.ApplicationConfig) {
 	if options.DynamicConfigsDir == "" {
 		// No need to start the watcher if the directory is not set
@@ -26,7 +26,10 @@ func Detection(
 		return nil, fmt.Errorf("could not load detection model")
 	}
 
-	res, err := detectionModel.Detect(context.Background(), &proto.DetectOptions{
+	ctx, cancel := withTimeout(context.Background(), modelConfig)
+	defer cancel()
+
+	res, err := detectionModel.Detect(ctx, &proto.DetectOptions{
 		Src: sourceFile,
 	})
 
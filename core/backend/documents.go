@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/document"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/store"
+)
+
+const (
+	// the chunking of the documents set in stores which keep texts whole
+	defaultDocumentChunkSize    = 1000
+	defaultDocumentChunkOverlap = 100
+
+	// documentSetBatchSize bounds the number of chunks set in a single call, to keep the messages small
+	documentSetBatchSize = 256
+)
+
+// DocumentIngester returns the function ingesting the documents of the DocumentsService
+func DocumentIngester(sl *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig) services.DocumentIngester {
+	return func(op *services.DocumentOp, progress func(*services.DocumentOpStatus)) error {
+		return IngestDocument(appConfig.Context, op, sl, cl, appConfig, progress)
+	}
+}
+
+// IngestDocument extracts the text of the document, splits it into chunks, embeds them with the embedding model of
+// the operation or else of the store and sets them in the store, replacing the chunks of a previous version of the
+// document. Each chunk has the metadata of the document along with its document_id, file_name and chunk index.
+func IngestDocument(ctx context.Context, op *services.DocumentOp, sl *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig, progress func(*services.DocumentOpStatus)) error {
+	status := func(message string, p float64, chunks int, processed bool) {
+		progress(&services.DocumentOpStatus{
+			Store:      op.Store,
+			DocumentID: op.DocumentID,
+			FileName:   op.FileName,
+			Message:    message,
+			Progress:   p,
+			Chunks:     chunks,
+			Processed:  processed,
+		})
+	}
+
+	var embedder *StoreEmbedder
+	var err error
+	if op.EmbeddingModel != "" {
+		embedder, err = NewStoreEmbedderWithConfig(sl, cl, appConfig, config.StoreConfig{EmbeddingModel: op.EmbeddingModel})
+	} else {
+		embedder, err = NewStoreEmbedder(sl, cl, appConfig, op.Store)
+	}
+	if err != nil {
+		return err
+	}
+
+	status("extracting text", 0, 0, false)
+	text, err := document.Extract(op.FileName, op.Data)
+	if err != nil {
+		return fmt.Errorf("failed to extract the text of %s: %w", op.FileName, err)
+	}
+
+	size, overlap := embedder.ChunkSize, embedder.ChunkOverlap
+	if size <= 0 {
+		size, overlap = defaultDocumentChunkSize, defaultDocumentChunkOverlap
+	}
+	if op.ChunkSize > 0 {
+		size, overlap = op.ChunkSize, op.ChunkOverlap
+	}
+	chunks := store.SplitText(text, size, overlap)
+	if len(chunks) == 0 {
+		return fmt.Errorf("%s has no text", op.FileName)
+	}
+
+	values := make([][]byte, len(chunks))
+	metadata := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		values[i] = []byte(chunk)
+
+		m := maps.Clone(op.Metadata)
+		if m == nil {
+			m = map[string]interface{}{}
+		}
+		m["document_id"], m["file_name"], m["chunk"] = op.DocumentID, op.FileName, i
+		if metadata[i], err = json.Marshal(m); err != nil {
+			return err
+		}
+	}
+
+	// the embeddings are computed before the store is loaded, as the model loader may only hold one backend at a time
+	keys := make([][]float32, 0, len(chunks))
+	for start := 0; start < len(chunks); start += embedder.batchSize() {
+		status("embedding chunks", float64(start)/float64(len(chunks))*100, len(chunks), false)
+
+		embeddings, err := embedder.Embed(chunks[start:min(start+embedder.batchSize(), len(chunks))])
+		if err != nil {
+			return err
+		}
+		keys = append(keys, embeddings...)
+	}
+
+	status("indexing chunks", 100, len(chunks), false)
+	sb, err := StoreBackend(sl, cl, appConfig, op.Store, "")
+	if err != nil {
+		return err
+	}
+	defer sl.Close()
+
+	filter, err := json.Marshal(map[string]interface{}{"document_id": op.DocumentID})
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteFiltered(ctx, sb, filter, store.WithNamespace(op.Namespace)); err != nil {
+		return fmt.Errorf("failed to delete the previous chunks of the document: %w", err)
+	}
+
+	for start := 0; start < len(keys); start += documentSetBatchSize {
+		end := min(start+documentSetBatchSize, len(keys))
+		err := store.SetCols(ctx, sb, keys[start:end], values[start:end],
+			store.WithNamespace(op.Namespace), store.WithMetadata(metadata[start:end]))
+		if err != nil {
+			return err
+		}
+	}
+
+	status("completed", 100, len(chunks), true)
+	return nil
+}
@@ -1,14 +1,27 @@
 package backend
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"sync"
 
 	"github.com/mudler/LocalAI/core/config"
 
 	"github.com/mudler/LocalAI/pkg/grpc"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const defaultEmbeddingsBatchSize = 32
+
+// ErrEmbeddingDimensions is returned when the requested dimensions exceed the ones of the embeddings
+var ErrEmbeddingDimensions = errors.New("invalid dimensions")
+
 func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, modelConfig config.ModelConfig, appConfig *config.ApplicationConfig) (func() ([]float32, error), error) {
 
 	opts := ModelOptions(modelConfig, appConfig)
@@ -23,6 +36,9 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, modelConf
 	switch model := inferenceModel.(type) {
 	case grpc.Backend:
 		fn = func() ([]float32, error) {
+			ctx, cancel := withTimeout(appConfig.Context, modelConfig)
+			defer cancel()
+
 			predictOptions := gRPCPredictOpts(modelConfig, loader.ModelPath)
 			if len(tokens) > 0 {
 				embeds := []int32{}
@@ -32,7 +48,7 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, modelConf
 				}
 				predictOptions.EmbeddingTokens = embeds
 
-				res, err := model.Embeddings(appConfig.Context, predictOptions)
+				res, err := model.Embeddings(ctx, predictOptions)
 				if err != nil {
 					return nil, err
 				}
@@ -41,7 +57,7 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, modelConf
 			}
 			predictOptions.Embeddings = s
 
-			res, err := model.Embeddings(appConfig.Context, predictOptions)
+			res, err := model.Embeddings(ctx, predictOptions)
 			if err != nil {
 				return nil, err
 			}
@@ -59,14 +75,168 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, modelConf
 		if err != nil {
 			return embeds, err
 		}
-		// Remove trailing 0s
-		for i := len(embeds) - 1; i >= 0; i-- {
-			if embeds[i] == 0.0 {
-				embeds = embeds[:i]
+		return trimEmbedding(embeds), nil
+	}, nil
+}
+
+// ModelEmbeddings computes the embeddings of the texts followed by the ones of the tokens, sending up to
+// EmbeddingsBatchSize inputs to the backend in a single call. With the backends not implementing
+// EmbeddingsBatch, the inputs of each batch are embedded concurrently, one per call.
+// When dimensions is positive, the embeddings are truncated to their first dimensions and normalized instead
+// of being trimmed of their trailing 0s.
+func ModelEmbeddings(texts []string, tokens [][]int, dimensions int, loader *model.ModelLoader, modelConfig config.ModelConfig, appConfig *config.ApplicationConfig) ([][]float32, error) {
+	opts := ModelOptions(modelConfig, appConfig)
+
+	inferenceModel, err := loader.Load(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer loader.Close()
+
+	ctx, cancel := withTimeout(appConfig.Context, modelConfig)
+	defer cancel()
+
+	batchSize := modelConfig.EmbeddingsBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultEmbeddingsBatchSize
+	}
+
+	newPredictOptions := func() *pb.PredictOptions {
+		return gRPCPredictOpts(modelConfig, loader.ModelPath)
+	}
+
+	embeddings := make([][]float32, 0, len(texts)+len(tokens))
+	batched := true
+	embed := func(texts []string, tokens []*pb.EmbeddingTokens) error {
+		var (
+			batch [][]float32
+			err   error
+		)
+		if batched {
+			predictOptions := newPredictOptions()
+			predictOptions.EmbeddingsBatch = texts
+			predictOptions.EmbeddingTokensBatch = tokens
+
+			var res *pb.EmbeddingBatchResult
+			res, err = inferenceModel.EmbeddingsBatch(ctx, predictOptions)
+			if status.Code(err) == codes.Unimplemented {
+				// the next batches go straight to the fallback
+				batched = false
+			} else if err != nil {
+				return err
+			} else if len(res.Embeddings) != len(texts)+len(tokens) {
+				return fmt.Errorf("the backend returned %d embeddings for %d inputs", len(res.Embeddings), len(texts)+len(tokens))
+			} else {
+				for _, e := range res.Embeddings {
+					batch = append(batch, e.Embeddings)
+				}
+			}
+		}
+		if !batched {
+			if batch, err = embedEach(ctx, inferenceModel, newPredictOptions, texts, tokens); err != nil {
+				return err
+			}
+		}
+
+		for _, e := range batch {
+			if dimensions > 0 {
+				if e, err = truncateEmbedding(e, dimensions); err != nil {
+					return err
+				}
 			} else {
-				break
+				e = trimEmbedding(e)
 			}
+			embeddings = append(embeddings, e)
 		}
-		return embeds, nil
-	}, nil
+		return nil
+	}
+
+	for start := 0; start < len(texts); start += batchSize {
+		if err := embed(texts[start:min(start+batchSize, len(texts))], nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for start := 0; start < len(tokens); start += batchSize {
+		batch := []*pb.EmbeddingTokens{}
+		for _, t := range tokens[start:min(start+batchSize, len(tokens))] {
+			embeds := make([]int32, len(t))
+			for i, token := range t {
+				embeds[i] = int32(token)
+			}
+			batch = append(batch, &pb.EmbeddingTokens{Tokens: embeds})
+		}
+		if err := embed(nil, batch); err != nil {
+			return nil, err
+		}
+	}
+
+	return embeddings, nil
+}
+
+// embedEach embeds the texts and the tokens concurrently, one per call, for the backends not implementing
+// EmbeddingsBatch
+func embedEach(ctx context.Context, backend grpc.Backend, newPredictOptions func() *pb.PredictOptions, texts []string, tokens []*pb.EmbeddingTokens) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts)+len(tokens))
+	errs := make([]error, len(embeddings))
+
+	var wg sync.WaitGroup
+	for i := range embeddings {
+		predictOptions := newPredictOptions()
+		if i < len(texts) {
+			predictOptions.Embeddings = texts[i]
+		} else {
+			predictOptions.EmbeddingTokens = tokens[i-len(texts)].Tokens
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := backend.Embeddings(ctx, predictOptions)
+			if err == nil {
+				embeddings[i] = res.Embeddings
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// truncateEmbedding keeps the first dimensions of the embedding, as for models trained with Matryoshka
+// representation learning, and normalizes them to unit length
+func truncateEmbedding(embedding []float32, dimensions int) ([]float32, error) {
+	if dimensions > len(embedding) {
+		return nil, fmt.Errorf("%w: they must be at most %d for this model", ErrEmbeddingDimensions, len(embedding))
+	}
+
+	truncated := slices.Clone(embedding[:dimensions])
+	var norm float64
+	for _, v := range truncated {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return truncated, nil
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range truncated {
+		truncated[i] = float32(float64(v) / norm)
+	}
+	return truncated, nil
+}
+
+// trimEmbedding removes the trailing 0s of the embedding
+func trimEmbedding(embeds []float32) []float32 {
+	for i := len(embeds) - 1; i >= 0; i-- {
+		if embeds[i] == 0.0 {
+			embeds = embeds[:i]
+		} else {
+			break
+		}
+	}
+	return embeds
 }
@@ -0,0 +1,128 @@
+package backend_test
+
+import (
+	"math"
+
+	. "github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/grpc/base"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/system"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Embeddings", func() {
+	var (
+		ml        *model.ModelLoader
+		appConfig *config.ApplicationConfig
+		cfg       config.ModelConfig
+	)
+
+	BeforeEach(func() {
+		systemState, err := system.GetSystemState(system.WithModelPath(GinkgoT().TempDir()))
+		Expect(err).ToNot(HaveOccurred())
+		appConfig = config.NewApplicationConfig(
+			config.WithSystemState(systemState),
+			config.WithExternalBackend("fake-embeddings", "backend-embedder"),
+			config.WithExternalBackend("fake-batch-embeddings", "backend-batch-embedder"),
+		)
+		ml = model.NewModelLoader(systemState, false)
+		cfg = config.ModelConfig{
+			PredictionOptions:   schema.PredictionOptions{BasicModelRequest: schema.BasicModelRequest{Model: "embedder"}},
+			EmbeddingsBatchSize: 2,
+		}
+		cfg.SetDefaults()
+	})
+
+	It("embeds up to EmbeddingsBatchSize inputs in a single call", func() {
+		embedder := &fakeBatchEmbedder{}
+		grpc.Provide("backend-batch-embedder", embedder)
+		cfg.Backend = "fake-batch-embeddings"
+
+		embeddings, err := ModelEmbeddings([]string{"a", "bb", "ccc"}, [][]int{{1, 2, 3, 4}}, 0, ml, cfg, appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(embeddings).To(Equal([][]float32{{1, 1}, {2, 1}, {3, 1}, {4, 1}}))
+		Expect(embedder.batches).To(Equal([]int{2, 1, 1}))
+	})
+
+	It("embeds the inputs one per call with the backends not supporting batches", func() {
+		embedder := &fakeEmbedder{}
+		grpc.Provide("backend-embedder", embedder)
+		cfg.Backend = "fake-embeddings"
+
+		embeddings, err := ModelEmbeddings([]string{"a", "bb", "ccc"}, [][]int{{1, 2, 3, 4}}, 0, ml, cfg, appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(embeddings).To(Equal([][]float32{{1, 1}, {2, 1}, {3, 1}, {4, 1}}))
+		// only the first batch is tried in a single call
+		Expect(embedder.batchCalls).To(Equal(1))
+	})
+
+	It("truncates the embeddings to the dimensions and normalizes them", func() {
+		grpc.Provide("backend-embedder", &fakeEmbedder{})
+		cfg.Backend = "fake-embeddings"
+
+		embeddings, err := ModelEmbeddings([]string{"abc", "abcd"}, nil, 2, ml, cfg, appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(embeddings).To(HaveLen(2))
+		Expect(embeddings[0][0]).To(BeNumerically("~", 3/math.Sqrt(10), 1e-6))
+		Expect(embeddings[0][1]).To(BeNumerically("~", 1/math.Sqrt(10), 1e-6))
+		Expect(embeddings[1][0]).To(BeNumerically("~", 4/math.Sqrt(17), 1e-6))
+
+		// the trailing 0 is one of the dimensions of the model
+		embeddings, err = ModelEmbeddings([]string{"abc"}, nil, 3, ml, cfg, appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(embeddings[0]).To(HaveLen(3))
+		Expect(embeddings[0][2]).To(BeZero())
+
+		_, err = ModelEmbeddings([]string{"abc"}, nil, 4, ml, cfg, appConfig)
+		Expect(err).To(MatchError(ErrEmbeddingDimensions))
+		Expect(err).To(MatchError(ContainSubstring("at most 3")))
+	})
+})
+
+// fakeEmbedder returns the length of the input and 1 as embeddings, followed by a 0 which is trimmed, and
+// counts the calls to EmbeddingsBatch, which it does not implement
+type fakeEmbedder struct {
+	base.SingleThread
+	batchCalls int
+}
+
+func (f *fakeEmbedder) Load(*pb.ModelOptions) error {
+	return nil
+}
+
+func (f *fakeEmbedder) EmbeddingsBatch(opts *pb.PredictOptions) ([][]float32, error) {
+	f.batchCalls++
+	return f.SingleThread.EmbeddingsBatch(opts)
+}
+
+func (f *fakeEmbedder) Embeddings(opts *pb.PredictOptions) ([]float32, error) {
+	if len(opts.EmbeddingTokens) > 0 {
+		return []float32{float32(len(opts.EmbeddingTokens)), 1, 0}, nil
+	}
+	return []float32{float32(len(opts.Embeddings)), 1, 0}, nil
+}
+
+// fakeBatchEmbedder embeds like fakeEmbedder, recording the size of the batches
+type fakeBatchEmbedder struct {
+	fakeEmbedder
+	batches []int
+}
+
+func (f *fakeBatchEmbedder) EmbeddingsBatch(opts *pb.PredictOptions) ([][]float32, error) {
+	f.batches = append(f.batches, len(opts.EmbeddingsBatch)+len(opts.EmbeddingTokensBatch))
+
+	embeddings := [][]float32{}
+	for _, text := range opts.EmbeddingsBatch {
+		embeddings = append(embeddings, []float32{float32(len(text)), 1, 0})
+	}
+	for _, tokens := range opts.EmbeddingTokensBatch {
+		embeddings = append(embeddings, []float32{float32(len(tokens.Tokens)), 1, 0})
+	}
+	return embeddings, nil
+}
@@ -19,8 +19,11 @@ func ImageGeneration(height, width, mode, step, seed int, positive_prompt, negat
 	defer loader.Close()
 
 	fn := func() error {
+		ctx, cancel := withTimeout(appConfig.Context, modelConfig)
+		defer cancel()
+
 		_, err := inferenceModel.GenerateImage(
-			appConfig.Context,
+			ctx,
 			&proto.GenerateImageRequest{
 				Height:           int32(height),
 				Width:            int32(width),
@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/rs/zerolog/log"
@@ -26,6 +27,9 @@ type LLMResponse struct {
 	Response    string // should this be []byte?
 	Usage       TokenUsage
 	AudioOutput string
+	// TimedOut is set when generation was stopped by the request timeout:
+	// Response then holds the output generated until then
+	TimedOut bool
 }
 
 type TokenUsage struct {
@@ -90,8 +94,27 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 		}
 	}
 
+	// The timeout covers all the predictions of the request, but not loading the model.
+	// With a timeout, always stream the reply so that the output generated until
+	// the deadline can be returned
+	var deadline time.Time
+	prefixResponse := false
+	if timeout := c.GetTimeout(); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+		if tokenCallback == nil {
+			tokenCallback = func(string, TokenUsage) bool { return true }
+			prefixResponse = true
+		}
+	}
+
 	// in GRPC, the backend is supposed to answer to 1 single token if stream is not supported
 	fn := func() (LLMResponse, error) {
+		predictCtx, cancel := ctx, context.CancelFunc(func() {})
+		if !deadline.IsZero() {
+			predictCtx, cancel = context.WithDeadline(ctx, deadline)
+		}
+		defer cancel()
+
 		opts := gRPCPredictOpts(*c, loader.ModelPath)
 		opts.Prompt = s
 		opts.Messages = protoMessages
@@ -112,7 +135,7 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 				}
 			}
 
-			promptInfo, pErr := inferenceModel.TokenizeString(ctx, opts)
+			promptInfo, pErr := inferenceModel.TokenizeString(predictCtx, opts)
 			if pErr == nil && promptInfo.Length > 0 {
 				tokenUsage.Prompt = int(promptInfo.Length)
 			}
@@ -132,7 +155,7 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 			ss := ""
 
 			var partialRune []byte
-			err := inferenceModel.PredictStream(ctx, opts, func(reply *proto.Reply) {
+			err := inferenceModel.PredictStream(predictCtx, opts, func(reply *proto.Reply) {
 				msg := reply.Message
 				partialRune = append(partialRune, msg...)
 
@@ -163,13 +186,23 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 					tokenCallback("", tokenUsage)
 				}
 			})
-			return LLMResponse{
+			if prefixResponse {
+				ss = c.TemplateConfig.ReplyPrefix + ss
+			}
+
+			resp := LLMResponse{
 				Response: ss,
 				Usage:    tokenUsage,
-			}, err
+			}
+			if err != nil && timedOut(ctx, predictCtx) {
+				log.Debug().Str("model", c.Name).Msg("request timed out, returning partial output")
+				resp.TimedOut = true
+				err = nil
+			}
+			return resp, err
 		} else {
 			// TODO: Is the chicken bit the only way to get here? is that acceptable?
-			reply, err := inferenceModel.Predict(ctx, opts)
+			reply, err := inferenceModel.Predict(predictCtx, opts)
 			if err != nil {
 				return LLMResponse{}, err
 			}
@@ -226,6 +226,9 @@ func gRPCPredictOpts(c config.ModelConfig, modelPath string) *pb.PredictOptions
 		F16KV:               *c.F16,
 		DebugMode:           *c.Debug,
 		Grammar:             c.Grammar,
+		GuidedRegex:         c.GuidedRegex,
+		GuidedChoice:        c.GuidedChoice,
+		GuidedGrammar:       c.GuidedGrammar,
 		NegativePromptScale: c.NegativePromptScale,
 		RopeFreqBase:        c.RopeFreqBase,
 		RopeFreqScale:       c.RopeFreqScale,
@@ -21,7 +21,10 @@ func Rerank(request *proto.RerankRequest, loader *model.ModelLoader, appConfig *
 		return nil, fmt.Errorf("could not load rerank model")
 	}
 
-	res, err := rerankModel.Rerank(context.Background(), request)
+	ctx, cancel := withTimeout(context.Background(), modelConfig)
+	defer cancel()
+
+	res, err := rerankModel.Rerank(ctx, request)
 
 	return res, err
 }
@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/store"
+)
+
+const (
+	defaultRAGTopK = 3
+	// the number of values reranked for each document kept, by default
+	ragCandidatesPerDocument = 4
+)
+
+// RetrieveDocuments finds the values of the store of the RAG closest to the query, ordered by the reranker if any
+func RetrieveDocuments(ctx context.Context, query string, rag schema.RAG, sl *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig) ([]schema.Document, error) {
+	topK := rag.TopK
+	if topK <= 0 {
+		topK = defaultRAGTopK
+	}
+	candidates := topK
+	if rag.Reranker != "" {
+		candidates = rag.Candidates
+		if candidates < topK {
+			candidates = ragCandidatesPerDocument * topK
+		}
+	}
+
+	// the query is embedded before loading the store, see StoreEmbedder.Embed
+	embedder, err := NewStoreEmbedder(sl, cl, appConfig, rag.Store)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := embedder.Embed([]string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []store.Option{store.WithNamespace(rag.Namespace)}
+	if rag.Filter != nil {
+		filter, err := json.Marshal(rag.Filter)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, store.WithFilter(filter))
+	}
+
+	sb, err := StoreBackend(sl, cl, appConfig, rag.Store, "")
+	if err != nil {
+		return nil, err
+	}
+	_, values, metadata, similarities, err := store.FindWithMetadata(ctx, sb, keys[0], candidates, opts...)
+	sl.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search the store %q: %w", rag.Store, err)
+	}
+
+	documents := make([]schema.Document, 0, len(values))
+	for i, v := range values {
+		if similarities[i] < rag.MinSimilarity {
+			continue
+		}
+		d := schema.Document{Content: string(v), Similarity: similarities[i]}
+		if i < len(metadata) && len(metadata[i]) > 0 {
+			if err := json.Unmarshal(metadata[i], &d.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		documents = append(documents, d)
+	}
+
+	if rag.Reranker != "" && len(documents) > 0 {
+		if documents, err = rerankDocuments(query, documents, rag.Reranker, sl, cl, appConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	return documents[:min(topK, len(documents))], nil
+}
+
+// rerankDocuments orders the documents by their relevance to the query
+func rerankDocuments(query string, documents []schema.Document, reranker string, sl *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig) ([]schema.Document, error) {
+	rerankConfig, err := cl.LoadModelConfigFileByNameDefaultOptions(reranker, appConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the rerank model: %w", err)
+	}
+
+	texts := make([]string, len(documents))
+	for i, d := range documents {
+		texts[i] = d.Content
+	}
+	res, err := Rerank(&proto.RerankRequest{Query: query, Documents: texts, TopN: int32(len(texts))}, sl, appConfig, *rerankConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	reranked := make([]schema.Document, 0, len(res.Results))
+	for _, r := range res.Results {
+		if r.Index < 0 || int(r.Index) >= len(documents) {
+			return nil, fmt.Errorf("the rerank model returned the document %d of %d", r.Index, len(documents))
+		}
+		d := documents[r.Index]
+		score := r.RelevanceScore
+		d.RelevanceScore = &score
+		reranked = append(reranked, d)
+	}
+	slices.SortStableFunc(reranked, func(a, b schema.Document) int {
+		return cmp.Compare(*b.RelevanceScore, *a.RelevanceScore)
+	})
+
+	return reranked, nil
+}
@@ -47,7 +47,10 @@ func SoundGeneration(
 	fileName := utils.GenerateUniqueFileName(audioDir, "sound_generation", ".wav")
 	filePath := filepath.Join(audioDir, fileName)
 
-	res, err := soundGenModel.SoundGeneration(context.Background(), &proto.SoundGenerationRequest{
+	ctx, cancel := withTimeout(context.Background(), modelConfig)
+	defer cancel()
+
+	res, err := soundGenModel.SoundGeneration(ctx, &proto.SoundGenerationRequest{
 		Text:        text,
 		Model:       modelConfig.Model,
 		Dst:         filePath,
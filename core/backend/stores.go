@@ -1,13 +1,25 @@
 package backend
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/mudler/LocalAI/core/config"
 
 	"github.com/mudler/LocalAI/pkg/grpc"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/store"
 )
 
-func StoreBackend(sl *model.ModelLoader, appConfig *config.ApplicationConfig, storeName string, backend string) (grpc.Backend, error) {
+const defaultStoreBatchSize = 8
+
+// ErrNoEmbeddingModel is returned for texts given to a store which is not bound to an embedding model
+var ErrNoEmbeddingModel = errors.New("the store is not bound to an embedding model, set store.embedding_model in the model configuration named after it")
+
+// StoreBackend loads the store, with the options of the model configuration named after it, if any, for
+// the same backend
+func StoreBackend(sl *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig, storeName string, backend string) (grpc.Backend, error) {
 	if backend == "" {
 		backend = model.LocalStoreBackend
 	}
@@ -16,5 +28,73 @@ func StoreBackend(sl *model.ModelLoader, appConfig *config.ApplicationConfig, st
 		model.WithModel(storeName),
 	}
 
+	if cfg, exists := cl.GetModelConfig(storeName); exists && cfg.Backend == backend {
+		sc = append(sc, model.WithLoadGRPCLoadModelOpts(&pb.ModelOptions{Options: cfg.Options}))
+	}
+
 	return sl.Load(sc...)
 }
+
+// StoreEmbedder computes the keys of the texts set in and queried from a store, with the embedding model the
+// store is bound to
+type StoreEmbedder struct {
+	config.StoreConfig
+
+	modelConfig *config.ModelConfig
+	loader      *model.ModelLoader
+	appConfig   *config.ApplicationConfig
+}
+
+// NewStoreEmbedder returns the embedder of the store, or ErrNoEmbeddingModel if it is not bound to an embedding model
+func NewStoreEmbedder(sl *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig, storeName string) (*StoreEmbedder, error) {
+	cfg, exists := cl.GetModelConfig(storeName)
+	if !exists || cfg.Store.EmbeddingModel == "" {
+		return nil, ErrNoEmbeddingModel
+	}
+
+	return NewStoreEmbedderWithConfig(sl, cl, appConfig, cfg.Store)
+}
+
+// NewStoreEmbedderWithConfig returns the embedder of a store bound to the embedding model of storeConfig
+func NewStoreEmbedderWithConfig(sl *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig, storeConfig config.StoreConfig) (*StoreEmbedder, error) {
+	modelConfig, err := cl.LoadModelConfigFileByNameDefaultOptions(storeConfig.EmbeddingModel, appConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the embedding model: %w", err)
+	}
+
+	return &StoreEmbedder{
+		StoreConfig: storeConfig,
+		modelConfig: modelConfig,
+		loader:      sl,
+		appConfig:   appConfig,
+	}, nil
+}
+
+// Chunks splits the text into the chunks stored as separate values
+func (e *StoreEmbedder) Chunks(text string) []string {
+	return store.SplitText(text, e.ChunkSize, e.ChunkOverlap)
+}
+
+// Embed computes the embeddings of the texts, BatchSize of them at a time. It must not be called while
+// holding the store backend, as the model loader may only hold one backend at a time.
+func (e *StoreEmbedder) Embed(texts []string) ([][]float32, error) {
+	batchSize := e.batchSize()
+
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch, err := ModelEmbeddings(texts[start:min(start+batchSize, len(texts))], nil, 0, e.loader, *e.modelConfig, e.appConfig)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batch...)
+	}
+
+	return embeddings, nil
+}
+
+func (e *StoreEmbedder) batchSize() int {
+	if e.BatchSize <= 0 {
+		return defaultStoreBatchSize
+	}
+	return e.BatchSize
+}
@@ -0,0 +1,23 @@
+package backend
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// withTimeout returns a context that expires after the timeout configured for
+// the model (or the request), if any
+func withTimeout(ctx context.Context, c config.ModelConfig) (context.Context, context.CancelFunc) {
+	if timeout := c.GetTimeout(); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// timedOut reports whether the request was interrupted by its own timeout,
+// rather than by the caller going away
+func timedOut(parent, ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+}
@@ -23,7 +23,10 @@ func ModelTokenize(s string, loader *model.ModelLoader, modelConfig config.Model
 	predictOptions.Prompt = s
 
 	// tokenize the string
-	resp, err := inferenceModel.TokenizeString(appConfig.Context, predictOptions)
+	ctx, cancel := withTimeout(appConfig.Context, modelConfig)
+	defer cancel()
+
+	resp, err := inferenceModel.TokenizeString(ctx, predictOptions)
 	if err != nil {
 		return schema.TokenizeResponse{}, err
 	}
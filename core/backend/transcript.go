@@ -30,7 +30,10 @@ func ModelTranscription(audio, language string, translate bool, diarize bool, ml
 		return nil, fmt.Errorf("could not load transcription model")
 	}
 
-	r, err := transcriptionModel.AudioTranscription(context.Background(), &proto.TranscriptRequest{
+	ctx, cancel := withTimeout(context.Background(), modelConfig)
+	defer cancel()
+
+	r, err := transcriptionModel.AudioTranscription(ctx, &proto.TranscriptRequest{
 		Dst:       audio,
 		Language:  language,
 		Translate: translate,
@@ -57,7 +57,10 @@ func ModelTTS(
 		modelPath = modelConfig.Model // skip this step if it fails?????
 	}
 
-	res, err := ttsModel.TTS(context.Background(), &proto.TTSRequest{
+	ctx, cancel := withTimeout(context.Background(), modelConfig)
+	defer cancel()
+
+	res, err := ttsModel.TTS(ctx, &proto.TTSRequest{
 		Text:     text,
 		Model:    modelPath,
 		Voice:    voice,
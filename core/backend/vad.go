@@ -24,6 +24,9 @@ func VAD(request *schema.VADRequest,
 	req := proto.VADRequest{
 		Audio: request.Audio,
 	}
+	ctx, cancel := withTimeout(ctx, modelConfig)
+	defer cancel()
+
 	resp, err := vadModel.VAD(ctx, &req)
 	if err != nil {
 		return nil, err
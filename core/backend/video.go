@@ -19,8 +19,11 @@ func VideoGeneration(height, width int32, prompt, negativePrompt, startImage, en
 	defer loader.Close()
 
 	fn := func() error {
+		ctx, cancel := withTimeout(appConfig.Context, modelConfig)
+		defer cancel()
+
 		_, err := inferenceModel.GenerateVideo(
-			appConfig.Context,
+			ctx,
 			&proto.GenerateVideoRequest{
 				Height:         height,
 				Width:          width,
@@ -33,6 +33,7 @@ type RunCMD struct {
 	LocalaiConfigDirPollInterval time.Duration `env:"LOCALAI_CONFIG_DIR_POLL_INTERVAL" help:"Typically the config path picks up changes automatically, but if your system has broken fsnotify events, set this to an interval to poll the LocalAI Config Dir (example: 1m)" group:"storage"`
 	// The alias on this option is there to preserve functionality with the old `--config-file` parameter
 	ModelsConfigFile         string   `env:"LOCALAI_MODELS_CONFIG_FILE,CONFIG_FILE" aliases:"config-file" help:"YAML file containing a list of model backend configs" group:"storage"`
+	ModelRoutesFile          string   `env:"LOCALAI_MODEL_ROUTES_FILE,MODEL_ROUTES_FILE" help:"YAML file containing a list of model aliases and routing groups" group:"storage"`
 	BackendGalleries         string   `env:"LOCALAI_BACKEND_GALLERIES,BACKEND_GALLERIES" help:"JSON list of backend galleries" group:"backends" default:"${backends}"`
 	Galleries                string   `env:"LOCALAI_GALLERIES,GALLERIES" help:"JSON list of galleries" group:"models" default:"${galleries}"`
 	AutoloadGalleries        bool     `env:"LOCALAI_AUTOLOAD_GALLERIES,AUTOLOAD_GALLERIES" group:"models" default:"true"`
@@ -69,8 +70,10 @@ type RunCMD struct {
 	ExternalGRPCBackends               []string `env:"LOCALAI_EXTERNAL_GRPC_BACKENDS,EXTERNAL_GRPC_BACKENDS" help:"A list of external grpc backends" group:"backends"`
 	EnableWatchdogIdle                 bool     `env:"LOCALAI_WATCHDOG_IDLE,WATCHDOG_IDLE" default:"false" help:"Enable watchdog for stopping backends that are idle longer than the watchdog-idle-timeout" group:"backends"`
 	WatchdogIdleTimeout                string   `env:"LOCALAI_WATCHDOG_IDLE_TIMEOUT,WATCHDOG_IDLE_TIMEOUT" default:"15m" help:"Threshold beyond which an idle backend should be stopped" group:"backends"`
+	WatchdogIdleSuspend                bool     `env:"LOCALAI_WATCHDOG_IDLE_SUSPEND,WATCHDOG_IDLE_SUSPEND" default:"false" help:"Suspend idle backends instead of stopping them. Suspended backends free the model memory but keep running, and are resumed faster on the next request. Only the transformers backend supports it: the other backends are stopped as usual" group:"backends"`
 	EnableWatchdogBusy                 bool     `env:"LOCALAI_WATCHDOG_BUSY,WATCHDOG_BUSY" default:"false" help:"Enable watchdog for stopping backends that are busy longer than the watchdog-busy-timeout" group:"backends"`
 	WatchdogBusyTimeout                string   `env:"LOCALAI_WATCHDOG_BUSY_TIMEOUT,WATCHDOG_BUSY_TIMEOUT" default:"5m" help:"Threshold beyond which a busy backend should be stopped" group:"backends"`
+	HealthCheckInterval                string   `env:"LOCALAI_HEALTH_CHECK_INTERVAL,HEALTH_CHECK_INTERVAL" default:"30s" help:"How often loaded backends are health checked to compute the readiness reported by /readyz (0 disables the checks)" group:"backends"`
 	Federated                          bool     `env:"LOCALAI_FEDERATED,FEDERATED" help:"Enable federated instance" group:"federated"`
 	DisableGalleryEndpoint             bool     `env:"LOCALAI_DISABLE_GALLERY_ENDPOINT,DISABLE_GALLERY_ENDPOINT" help:"Disable the gallery endpoints" group:"api"`
 	MachineTag                         string   `env:"LOCALAI_MACHINE_TAG,MACHINE_TAG" help:"Add Machine-Tag header to each response which is useful to track the machine in the P2P network" group:"api"`
@@ -99,6 +102,7 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 
 	opts := []config.AppOption{
 		config.WithConfigFile(r.ModelsConfigFile),
+		config.WithModelRoutesFile(r.ModelRoutesFile),
 		config.WithJSONStringPreload(r.PreloadModels),
 		config.WithYAMLConfigPreload(r.PreloadModelsConfig),
 		config.WithSystemState(systemState),
@@ -173,6 +177,9 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 				return err
 			}
 			opts = append(opts, config.SetWatchDogIdleTimeout(dur))
+			if r.WatchdogIdleSuspend {
+				opts = append(opts, config.EnableWatchDogIdleSuspend)
+			}
 		}
 		if busyWatchDog {
 			opts = append(opts, config.EnableWatchDogBusyCheck)
@@ -183,6 +190,13 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 			opts = append(opts, config.SetWatchDogBusyTimeout(dur))
 		}
 	}
+	if r.HealthCheckInterval != "" {
+		dur, err := time.ParseDuration(r.HealthCheckInterval)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.SetHealthCheckInterval(dur))
+	}
 	if r.ParallelRequests {
 		opts = append(opts, config.EnableParallelBackendRequests)
 	}
@@ -206,8 +220,11 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 	}
 
 	if r.PreloadBackendOnly {
-		_, err := application.New(opts...)
-		return err
+		app, err := application.New(opts...)
+		if err != nil {
+			return err
+		}
+		return app.HealthService().WaitForJobs()
 	}
 
 	app, err := application.New(opts...)
@@ -228,5 +245,29 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 	// Catch signals from the OS requesting us to exit, and stop all backends
 	signals.Handler(app.ModelLoader())
 
-	return appHTTP.Listen(r.Address)
+	// The models are preloaded while the API is up: stop if a required model could not be installed or loaded
+	jobsErr := make(chan error, 1)
+	go func() {
+		if err := app.HealthService().WaitForJobs(); err != nil {
+			jobsErr <- err
+		}
+	}()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- appHTTP.Listen(r.Address)
+	}()
+
+	select {
+	case err := <-listenErr:
+		return err
+	case err := <-jobsErr:
+		if err := appHTTP.Shutdown(); err != nil {
+			log.Error().Err(err).Msg("error shutting down the HTTP server")
+		}
+		if err := app.ModelLoader().StopAllGRPC(); err != nil {
+			log.Error().Err(err).Msg("error while stopping all grpc backends")
+		}
+		return fmt.Errorf("failed startup jobs with error %w", err)
+	}
 }
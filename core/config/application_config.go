@@ -14,6 +14,7 @@ import (
 type ApplicationConfig struct {
 	Context                             context.Context
 	ConfigFile                          string
+	ModelRoutesFile                     string
 	SystemState                         *system.SystemState
 	ExternalBackends                    []string
 	UploadLimitMB, Threads, ContextSize int
@@ -58,10 +59,15 @@ type ApplicationConfig struct {
 	WatchDogBusy bool
 	WatchDog     bool
 
+	WatchDogIdleSuspend bool
+
 	ModelsURL []string
 
 	WatchDogBusyTimeout, WatchDogIdleTimeout time.Duration
 
+	// HealthCheckInterval is how often loaded backends are probed to compute readiness (0 disables probing)
+	HealthCheckInterval time.Duration
+
 	MachineTag string
 }
 
@@ -137,6 +143,10 @@ var EnableWatchDogIdleCheck = func(o *ApplicationConfig) {
 	o.WatchDogIdle = true
 }
 
+var EnableWatchDogIdleSuspend = func(o *ApplicationConfig) {
+	o.WatchDogIdleSuspend = true
+}
+
 var DisableGalleryEndpoint = func(o *ApplicationConfig) {
 	o.DisableGalleryEndpoint = true
 }
@@ -162,6 +172,12 @@ func SetWatchDogIdleTimeout(t time.Duration) AppOption {
 	}
 }
 
+func SetHealthCheckInterval(t time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.HealthCheckInterval = t
+	}
+}
+
 var EnableSingleBackend = func(o *ApplicationConfig) {
 	o.SingleBackend = true
 }
@@ -250,6 +266,12 @@ func WithConfigFile(configFile string) AppOption {
 	}
 }
 
+func WithModelRoutesFile(routesFile string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ModelRoutesFile = routesFile
+	}
+}
+
 func WithUploadLimitMB(limit int) AppOption {
 	return func(o *ApplicationConfig) {
 		o.UploadLimitMB = limit
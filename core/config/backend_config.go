@@ -1,19 +1,25 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/downloader"
 	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
 )
 
 const (
 	RAND_SEED = -1
+
+	defaultFallbackThreshold = 5 * time.Second
 )
 
 type TTSConfig struct {
@@ -40,11 +46,40 @@ type ModelConfig struct {
 	KnownUsecases       *ModelConfigUsecases `yaml:"-" json:"-"`
 	Pipeline            Pipeline             `yaml:"pipeline" json:"pipeline"`
 
+	// EmbeddingsBatchSize is the maximum number of inputs embedded in a single call to the backend. Defaults to 32
+	EmbeddingsBatchSize int `yaml:"embeddings_batch_size" json:"embeddings_batch_size"`
+
+	// FallbackModel is served instead of this model while it is being loaded,
+	// if loading takes longer than FallbackThreshold (e.g. "5s")
+	FallbackModel     string `yaml:"fallback_model" json:"fallback_model"`
+	FallbackThreshold string `yaml:"fallback_threshold" json:"fallback_threshold"`
+
+	// Timeout is the maximum time spent serving a request (e.g. "30s").
+	// Requests can shorten it with the X-Request-Timeout header, which sets it when it is empty
+	Timeout string `yaml:"timeout" json:"timeout"`
+
+	// StructuredOutput controls how outputs not matching a strict json_schema response format are handled
+	StructuredOutput StructuredOutput `yaml:"structured_output" json:"structured_output"`
+
+	// Reasoning configures how the reasoning of the model is returned
+	Reasoning ReasoningConfig `yaml:"reasoning" json:"reasoning"`
+
+	// ServerTools are tools run by LocalAI during chat completions
+	ServerTools ServerToolsConfig `yaml:"server_tools" json:"server_tools"`
+
+	// Store binds a store named after the model configuration to an embedding model
+	Store StoreConfig `yaml:"store" json:"store"`
+
+	// RAG gives the model the values of a store closest to the last user message
+	RAG schema.RAG `yaml:"rag" json:"rag"`
+
 	PromptStrings, InputStrings                []string               `yaml:"-" json:"-"`
 	InputToken                                 [][]int                `yaml:"-" json:"-"`
 	functionCallString, functionCallNameString string                 `yaml:"-" json:"-"`
 	ResponseFormat                             string                 `yaml:"-" json:"-"`
 	ResponseFormatMap                          map[string]interface{} `yaml:"-" json:"-"`
+	GuidedRegex, GuidedGrammar                 string                 `yaml:"-" json:"-"`
+	GuidedChoice                               []string               `yaml:"-" json:"-"`
 
 	FunctionsConfig functions.FunctionsConfig `yaml:"function" json:"function"`
 
@@ -75,6 +110,64 @@ type ModelConfig struct {
 	Overrides []string `yaml:"overrides" json:"overrides"`
 }
 
+// StructuredOutput defines how outputs are validated against strict json_schema response formats
+type StructuredOutput struct {
+	// Retries is how many times the model is asked to fix an output not matching the schema
+	Retries int `yaml:"retries" json:"retries"`
+	// OnFailure is either "refusal" (the default) to reply with a refusal, or "error" to fail the request
+	OnFailure string `yaml:"on_failure" json:"on_failure"`
+}
+
+// ReasoningConfig defines the delimiters of the reasoning in the model output, returned as reasoning_content
+type ReasoningConfig struct {
+	// Disable returns the reasoning as part of the content
+	Disable bool `yaml:"disable" json:"disable"`
+	// Auto guesses StartTag and EndTag from the chat template of GGUF models, if they are not set
+	Auto bool `yaml:"auto" json:"auto"`
+	// StartTag and EndTag delimit the reasoning, e.g. <think> and </think>
+	StartTag string `yaml:"start_tag" json:"start_tag"`
+	EndTag   string `yaml:"end_tag" json:"end_tag"`
+	// Budgets maps the reasoning_effort of requests to the maximum number of tokens spent reasoning.
+	// Once exhausted the reasoning is closed and the model replies. A budget of 0 skips the reasoning.
+	Budgets map[string]int `yaml:"budgets" json:"budgets"`
+}
+
+// Enabled reports whether the reasoning is extracted from the output
+func (r ReasoningConfig) Enabled() bool {
+	return !r.Disable && r.StartTag != "" && r.EndTag != ""
+}
+
+// Budget returns the reasoning token budget for a reasoning effort, if any
+func (r ReasoningConfig) Budget(effort string) (int, bool) {
+	if !r.Enabled() || effort == "" {
+		return 0, false
+	}
+	budget, ok := r.Budgets[effort]
+	return budget, ok
+}
+
+// ServerToolsConfig defines the tools run by LocalAI. The model is called again with their results
+// until it replies without calling them, up to MaxIterations times.
+type ServerToolsConfig struct {
+	Tools []schema.ServerTool `yaml:"tools" json:"tools"`
+	// MCPServers provide their tools along with Tools
+	MCPServers []schema.MCPServer `yaml:"mcp_servers" json:"mcp_servers"`
+	// MaxIterations is the maximum number of generations for a request. Defaults to 5
+	MaxIterations int `yaml:"max_iterations" json:"max_iterations"`
+}
+
+// StoreConfig defines the embedding model computing the keys of the texts set in and queried from a store.
+// Texts longer than ChunkSize characters are split into chunks, stored as separate values.
+type StoreConfig struct {
+	EmbeddingModel string `yaml:"embedding_model" json:"embedding_model"`
+	// ChunkSize is the maximum number of characters of the chunks, 0 to keep the texts whole
+	ChunkSize int `yaml:"chunk_size" json:"chunk_size"`
+	// ChunkOverlap is the number of characters shared by consecutive chunks
+	ChunkOverlap int `yaml:"chunk_overlap" json:"chunk_overlap"`
+	// BatchSize is the number of texts embedded concurrently. Defaults to 8
+	BatchSize int `yaml:"batch_size" json:"batch_size"`
+}
+
 // Pipeline defines other models to use for audio-to-audio
 type Pipeline struct {
 	TTS           string `yaml:"tts" json:"tts"`
@@ -217,6 +310,11 @@ type TemplateConfig struct {
 
 	JinjaTemplate bool `yaml:"jinja_template" json:"jinja_template"`
 
+	// BOSToken and EOSToken are the bos_token and eos_token variables of jinja templates. The backends usually
+	// add the BOS token to the prompt already.
+	BOSToken string `yaml:"bos_token" json:"bos_token"`
+	EOSToken string `yaml:"eos_token" json:"eos_token"`
+
 	ReplyPrefix string `yaml:"reply_prefix" json:"reply_prefix"`
 }
 
@@ -289,6 +387,57 @@ func (c *ModelConfig) ModelFileName() string {
 	return c.Model
 }
 
+// GetFallbackThreshold returns how long a request should wait for the model to
+// load before being served by the fallback model
+func (c *ModelConfig) GetFallbackThreshold() time.Duration {
+	if c.FallbackThreshold == "" {
+		return defaultFallbackThreshold
+	}
+
+	d, err := time.ParseDuration(c.FallbackThreshold)
+	if err != nil {
+		log.Warn().Err(err).Str("model", c.Name).Msgf("invalid fallback_threshold %q, using default", c.FallbackThreshold)
+		return defaultFallbackThreshold
+	}
+
+	return d
+}
+
+// GetTimeout returns the maximum time spent serving a request, or 0 if there is
+// no limit. Plain numbers are interpreted as seconds.
+func (c *ModelConfig) GetTimeout() time.Duration {
+	if c.Timeout == "" {
+		return 0
+	}
+
+	d, err := ParseTimeout(c.Timeout)
+	if err != nil {
+		log.Warn().Err(err).Str("model", c.Name).Msgf("invalid timeout %q, ignoring it", c.Timeout)
+		return 0
+	}
+
+	return d
+}
+
+// ParseTimeout parses a duration (e.g. "1m30s") or a number of seconds (e.g. "90")
+func ParseTimeout(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		if secs < 0 {
+			return 0, fmt.Errorf("timeout cannot be negative")
+		}
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("timeout cannot be negative")
+	}
+	return d, nil
+}
+
 func (c *ModelConfig) FunctionToCall() string {
 	if c.functionCallNameString != "" &&
 		c.functionCallNameString != "none" && c.functionCallNameString != "auto" {
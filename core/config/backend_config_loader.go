@@ -20,6 +20,7 @@ import (
 
 type ModelConfigLoader struct {
 	configs   map[string]ModelConfig
+	routes    map[string]ModelRoute
 	modelPath string
 	sync.Mutex
 }
@@ -27,6 +28,7 @@ type ModelConfigLoader struct {
 func NewModelConfigLoader(modelPath string) *ModelConfigLoader {
 	return &ModelConfigLoader{
 		configs:   make(map[string]ModelConfig),
+		routes:    make(map[string]ModelRoute),
 		modelPath: modelPath,
 	}
 }
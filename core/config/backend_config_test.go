@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -160,4 +161,14 @@ parameters:
 		Expect(i.HasUsecases(FLAG_COMPLETION)).To(BeTrue())
 		Expect(i.HasUsecases(FLAG_CHAT)).To(BeTrue())
 	})
+	It("Parses request timeouts", func() {
+		Expect((&ModelConfig{}).GetTimeout()).To(BeZero())
+		Expect((&ModelConfig{Timeout: "1m30s"}).GetTimeout()).To(Equal(90 * time.Second))
+		Expect((&ModelConfig{Timeout: "90"}).GetTimeout()).To(Equal(90 * time.Second))
+		Expect((&ModelConfig{Timeout: "0.5"}).GetTimeout()).To(Equal(500 * time.Millisecond))
+		Expect((&ModelConfig{Timeout: "soon"}).GetTimeout()).To(BeZero())
+
+		_, err := ParseTimeout("-5s")
+		Expect(err).To(HaveOccurred())
+	})
 })
@@ -3,6 +3,8 @@ package config
 import (
 	"strings"
 
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/functions/grammars"
 	"github.com/mudler/LocalAI/pkg/xsysinfo"
 	"github.com/rs/zerolog/log"
 
@@ -140,12 +142,6 @@ Function response:
 	},
 }
 
-// this maps well known template used in HF to model families defined above
-var knownTemplates = map[string]familyType{
-	`{% if messages[0]['role'] == 'system' %}{% set system_message = messages[0]['content'] %}{% endif %}{% if system_message is defined %}{{ system_message }}{% endif %}{% for message in messages %}{% set content = message['content'] %}{% if message['role'] == 'user' %}{{ '<|im_start|>user\n' + content + '<|im_end|>\n<|im_start|>assistant\n' }}{% elif message['role'] == 'assistant' %}{{ content + '<|im_end|>' + '\n' }}{% endif %}{% endfor %}`:                              ChatML,
-	`{{ bos_token }}{% for message in messages %}{% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}{{ raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') }}{% endif %}{% if message['role'] == 'user' %}{{ '[INST] ' + message['content'] + ' [/INST]' }}{% elif message['role'] == 'assistant' %}{{ message['content'] + eos_token}}{% else %}{{ raise_exception('Only user and assistant roles are supported!') }}{% endif %}{% endfor %}`: Mistral03,
-}
-
 func guessGGUFFromFile(cfg *ModelConfig, f *gguf.GGUFFile, defaultCtx int) {
 
 	if defaultCtx == 0 && cfg.ContextSize == nil {
@@ -198,6 +194,14 @@ func guessGGUFFromFile(cfg *ModelConfig, f *gguf.GGUFFile, defaultCtx int) {
 
 	log.Debug().Any("NGPULayers", cfg.NGPULayers).Msgf("guessDefaultsFromFile: %s", "NGPULayers set")
 
+	if chatTemplate, found := f.Header.MetadataKV.Get("tokenizer.chat_template"); found {
+		// tool call format
+		resolveAutoParser(cfg, chatTemplate.ValueString(), f.Architecture().Architecture)
+
+		// reasoning delimiters
+		resolveAutoReasoningTags(cfg, chatTemplate.ValueString())
+	}
+
 	// template estimations
 	if cfg.HasTemplate() {
 		// nothing to guess here
@@ -216,6 +220,12 @@ func guessGGUFFromFile(cfg *ModelConfig, f *gguf.GGUFFile, defaultCtx int) {
 		cfg.Name = f.Metadata().Name
 	}
 
+	// the chat template of the model is used as is, the model families are a fallback for the files without one
+	if chatTemplate, found := f.Header.MetadataKV.Get("tokenizer.chat_template"); found && chatTemplate.ValueString() != "" {
+		useChatTemplate(cfg, ggufChatTemplate(f, chatTemplate.ValueString()))
+		return
+	}
+
 	family := identifyFamily(f)
 
 	if family == Unknown {
@@ -237,32 +247,108 @@ func guessGGUFFromFile(cfg *ModelConfig, f *gguf.GGUFFile, defaultCtx int) {
 	} else {
 		log.Debug().Any("family", family).Msgf("guessDefaultsFromFile: no template found for family")
 	}
+}
 
-	if cfg.HasTemplate() {
+// ggufChatTemplate reads the special tokens used by the chat template from the tokenizer of the file
+func ggufChatTemplate(f *gguf.GGUFFile, template string) chatTemplate {
+	t := f.Tokenizer()
+	res := chatTemplate{
+		Template:     template,
+		Architecture: f.Architecture().Architecture,
+		// llama.cpp adds the BOS token unless told otherwise
+		AddBOS: true,
+	}
+	if v, found := f.Header.MetadataKV.Get("tokenizer.ggml.add_bos_token"); found && v.ValueType == gguf.GGUFMetadataValueTypeBool {
+		res.AddBOS = v.ValueBool()
+	}
+
+	tokens, found := f.Header.MetadataKV.Get("tokenizer.ggml.tokens")
+	if !found || tokens.ValueType != gguf.GGUFMetadataValueTypeArray || tokens.ValueArray().Type != gguf.GGUFMetadataValueTypeString {
+		return res
+	}
+	vocab := tokens.ValueArray().ValuesString()
+	token := func(id int64) string {
+		if id < 0 || id >= int64(len(vocab)) {
+			return ""
+		}
+		return vocab[id]
+	}
+	res.BOSToken = token(t.BOSTokenID)
+	res.EOSToken = token(t.EOSTokenID)
+	for _, id := range []int64{t.EOSTokenID, t.EOTTokenID, t.EOMTokenID} {
+		if tok := token(id); tok != "" {
+			res.EndTokens = append(res.EndTokens, tok)
+		}
+	}
+	return res
+}
+
+// resolveAutoParser replaces the "auto" tool call parser with the one matching the chat template.
+// The parser is guessed only if requested, as it changes how the calls are parsed.
+func resolveAutoParser(cfg *ModelConfig, chatTemplate, arch string) {
+	if cfg.FunctionsConfig.Parser != functions.AutoParser {
 		return
 	}
+	cfg.FunctionsConfig.Parser = guessToolCallParser(chatTemplate, arch)
+	log.Debug().Any("parser", cfg.FunctionsConfig.Parser).Msgf("guessDefaultsFromFile: %s", "tool call parser guessed")
+}
 
-	// identify from well known templates first, otherwise use the raw jinja template
-	chatTemplate, found := f.Header.MetadataKV.Get("tokenizer.chat_template")
-	if found {
-		// try to use the jinja template
-		cfg.TemplateConfig.JinjaTemplate = true
-		cfg.TemplateConfig.ChatMessage = chatTemplate.ValueString()
+// guessToolCallParser identifies the tool call format from the tokens used by the chat template.
+// It returns an empty string if the format is unknown.
+func guessToolCallParser(chatTemplate, arch string) string {
+	switch {
+	case chatTemplate == "":
+		return ""
+	case strings.Contains(chatTemplate, "<｜tool▁calls▁begin｜>"):
+		return grammars.DeepSeekType
+	case strings.Contains(chatTemplate, "[TOOL_CALLS]"):
+		return grammars.MistralType
+	case strings.Contains(chatTemplate, "<tool_call>"):
+		if strings.HasPrefix(arch, "qwen") {
+			return grammars.QwenType
+		}
+		return grammars.HermesType
+	// Llama 3.2+ and 4 templates ask for calls like [func_name1(params_name1=params_value1, ...)]
+	case strings.Contains(chatTemplate, "[func_name1(") || strings.Contains(chatTemplate, "[func_name("):
+		return grammars.PythonicType
+	case strings.Contains(chatTemplate, "<|python_tag|>") || strings.Contains(chatTemplate, "ipython"):
+		return grammars.Llama3JSONType
 	}
+	return ""
+}
 
+// reasoningTags are the delimiters of the reasoning used by known model families
+var reasoningTags = [][2]string{
+	{"<think>", "</think>"},
+	{"<seed:think>", "</seed:think>"},
+	{"<|START_THINKING|>", "<|END_THINKING|>"},
+	{"[THINK]", "[/THINK]"},
+	{"◁think▷", "◁/think▷"},
 }
 
-func identifyFamily(f *gguf.GGUFFile) familyType {
+// resolveAutoReasoningTags sets the reasoning delimiters referenced by the chat template if requested,
+// as extracting the reasoning changes the content of the replies
+func resolveAutoReasoningTags(cfg *ModelConfig, chatTemplate string) {
+	if !cfg.Reasoning.Auto || cfg.Reasoning.StartTag != "" || cfg.Reasoning.EndTag != "" {
+		return
+	}
+	cfg.Reasoning.StartTag, cfg.Reasoning.EndTag = guessReasoningTags(chatTemplate)
+	log.Debug().Str("start", cfg.Reasoning.StartTag).Str("end", cfg.Reasoning.EndTag).Msgf("guessDefaultsFromFile: %s", "reasoning tags guessed")
+}
 
-	// identify from well known templates first
-	chatTemplate, found := f.Header.MetadataKV.Get("tokenizer.chat_template")
-	if found && chatTemplate.ValueString() != "" {
-		if family, ok := knownTemplates[chatTemplate.ValueString()]; ok {
-			return family
+// guessReasoningTags returns the delimiters of the reasoning referenced by the chat template, if any
+func guessReasoningTags(chatTemplate string) (string, string) {
+	for _, tags := range reasoningTags {
+		if strings.Contains(chatTemplate, tags[1]) {
+			return tags[0], tags[1]
 		}
 	}
+	return "", ""
+}
+
+func identifyFamily(f *gguf.GGUFFile) familyType {
 
-	// otherwise try to identify from the model properties
+	// identify from the model properties
 	arch := f.Architecture().Architecture
 	eosTokenID := f.Tokenizer().EOSTokenID
 	bosTokenID := f.Tokenizer().BOSTokenID
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GGUF defaults", func() {
+	DescribeTable("guesses the tool call parser from the chat template",
+		func(chatTemplate, arch, expected string) {
+			Expect(guessToolCallParser(chatTemplate, arch)).To(Equal(expected))
+		},
+		Entry("hermes", `{{- '<tool_call>\n' -}}{{ tool_call | tojson }}{{- '\n</tool_call>' -}}`, "llama", "hermes"),
+		Entry("qwen", `{{- '<tool_call>\n' -}}{{ tool_call | tojson }}{{- '\n</tool_call>' -}}`, "qwen2", "qwen"),
+		Entry("mistral", `{{- "[TOOL_CALLS] [" }}{% for tool_call in message.tool_calls %}`, "llama", "mistral"),
+		Entry("deepseek", `{{'<｜Assistant｜><｜tool▁calls▁begin｜><｜tool▁call▁begin｜>'}}`, "deepseek2", "deepseek"),
+		Entry("pythonic", `You SHOULD NOT include any other text in the response if you call a function\n[func_name1(params_name1=params_value1, params_name2=params_value2...)]`, "llama", "pythonic"),
+		Entry("llama3_json", `{%- if builtin_tools is defined %}{{- "<|python_tag|>" }}{%- endif %}{{- "<|start_header_id|>ipython<|end_header_id|>\n\n" }}`, "llama", "llama3_json"),
+		Entry("without tool calls", `{% for message in messages %}{{ '<|im_start|>' + message['role'] }}{% endfor %}`, "qwen2", ""),
+	)
+
+	DescribeTable("guesses the reasoning delimiters from the chat template",
+		func(chatTemplate, start, end string) {
+			s, e := guessReasoningTags(chatTemplate)
+			Expect(s).To(Equal(start))
+			Expect(e).To(Equal(end))
+		},
+		Entry("qwen3", `{%- if '</think>' in content %}{%- set content = content.split('</think>')[-1].lstrip('\n') %}{%- endif %}`, "<think>", "</think>"),
+		Entry("seed", `{{ '<seed:think>' + reasoning_content + '</seed:think>' }}`, "<seed:think>", "</seed:think>"),
+		Entry("magistral", `[THINK]{{ thinking }}[/THINK]`, "[THINK]", "[/THINK]"),
+		Entry("without reasoning", `{% for message in messages %}{{ message['content'] }}{% endfor %}`, "", ""),
+	)
+
+	It("guesses the tool call parser only if requested", func() {
+		template := `{{- '<tool_call>\n' -}}{{ tool_call | tojson }}{{- '\n</tool_call>' -}}`
+
+		cfg := &ModelConfig{}
+		resolveAutoParser(cfg, template, "qwen2")
+		Expect(cfg.FunctionsConfig.Parser).To(BeEmpty())
+
+		cfg = &ModelConfig{FunctionsConfig: functions.FunctionsConfig{Parser: functions.AutoParser}}
+		resolveAutoParser(cfg, template, "qwen2")
+		Expect(cfg.FunctionsConfig.Parser).To(Equal("qwen"))
+
+		cfg = &ModelConfig{FunctionsConfig: functions.FunctionsConfig{Parser: functions.AutoParser}}
+		resolveAutoParser(cfg, `{{ messages }}`, "qwen2")
+		Expect(cfg.FunctionsConfig.Parser).To(BeEmpty())
+	})
+
+	It("guesses the reasoning delimiters only if requested", func() {
+		template := `{%- if '</think>' in content %}{%- set content = content.split('</think>')[-1] %}{%- endif %}`
+
+		cfg := &ModelConfig{}
+		resolveAutoReasoningTags(cfg, template)
+		Expect(cfg.Reasoning.Enabled()).To(BeFalse())
+
+		cfg = &ModelConfig{Reasoning: ReasoningConfig{Auto: true}}
+		resolveAutoReasoningTags(cfg, template)
+		Expect(cfg.Reasoning.StartTag).To(Equal("<think>"))
+		Expect(cfg.Reasoning.EndTag).To(Equal("</think>"))
+
+		cfg = &ModelConfig{Reasoning: ReasoningConfig{Auto: true, StartTag: "[THINK]", EndTag: "[/THINK]"}}
+		resolveAutoReasoningTags(cfg, template)
+		Expect(cfg.Reasoning.StartTag).To(Equal("[THINK]"))
+	})
+
+	Context("chat templates", func() {
+		template := `{{ bos_token }}{% for message in messages %}{{ '<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>\n' }}{% if message.tool_calls %}<tool_call>{% endif %}{% endfor %}`
+
+		It("renders the prompts with the chat template of the model", func() {
+			cfg := &ModelConfig{}
+			useChatTemplate(cfg, chatTemplate{
+				Template:     template,
+				Architecture: "qwen2",
+				BOSToken:     "<s>",
+				EOSToken:     "<|endoftext|>",
+				EndTokens:    []string{"<|endoftext|>", "<|im_end|>"},
+			})
+			Expect(cfg.TemplateConfig.JinjaTemplate).To(BeTrue())
+			Expect(cfg.TemplateConfig.ChatMessage).To(Equal(template))
+			Expect(cfg.TemplateConfig.BOSToken).To(Equal("<s>"))
+			Expect(cfg.TemplateConfig.EOSToken).To(Equal("<|endoftext|>"))
+			Expect(cfg.StopWords).To(Equal([]string{"<|endoftext|>", "<|im_end|>"}))
+			Expect(cfg.FunctionsConfig.Parser).To(Equal("qwen"))
+		})
+
+		It("does not repeat the BOS token added by the tokenizer", func() {
+			cfg := &ModelConfig{}
+			useChatTemplate(cfg, chatTemplate{Template: template, BOSToken: "<s>", AddBOS: true})
+			Expect(cfg.TemplateConfig.BOSToken).To(BeEmpty())
+			Expect(cfg.StopWords).To(Equal([]string{"<|im_end|>"}))
+		})
+
+		It("keeps the configured stop words and parser", func() {
+			cfg := &ModelConfig{LLMConfig: LLMConfig{StopWords: []string{"###"}}, FunctionsConfig: functions.FunctionsConfig{Parser: "hermes"}}
+			useChatTemplate(cfg, chatTemplate{Template: template, Architecture: "qwen2", EndTokens: []string{"<|im_end|>"}})
+			Expect(cfg.StopWords).To(Equal([]string{"###"}))
+			Expect(cfg.FunctionsConfig.Parser).To(Equal("hermes"))
+		})
+
+		It("does not guess the parser of configurations extracting the calls with regular expressions", func() {
+			cfg := &ModelConfig{FunctionsConfig: functions.FunctionsConfig{ResponseRegex: []string{`<tool_call>(?P<name>\w+)(?P<arguments>.*)</tool_call>`}}}
+			useChatTemplate(cfg, chatTemplate{Template: template, Architecture: "qwen2", EndTokens: []string{"<|im_end|>"}})
+			Expect(cfg.FunctionsConfig.Parser).To(BeEmpty())
+
+			cfg = &ModelConfig{FunctionsConfig: functions.FunctionsConfig{ReplaceFunctionResults: []functions.ReplaceResult{{Key: "<tool_call>", Value: ""}}}}
+			useChatTemplate(cfg, chatTemplate{Template: template, Architecture: "qwen2", EndTokens: []string{"<|im_end|>"}})
+			Expect(cfg.FunctionsConfig.Parser).To(BeEmpty())
+		})
+
+		It("reads the chat template of the tokenizer of model directories", func() {
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "tokenizer_config.json"), []byte(`{
+				"add_bos_token": false,
+				"bos_token": {"content": "<s>", "lstrip": false},
+				"eos_token": "<|im_end|>",
+				"chat_template": [{"name": "tool_use", "template": "tools"}, {"name": "default", "template": "{{ messages }}"}]
+			}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"model_type": "qwen2"}`), 0600)).To(Succeed())
+
+			cfg := &ModelConfig{}
+			guessHFFromDirectory(cfg, dir)
+			Expect(cfg.TemplateConfig.JinjaTemplate).To(BeTrue())
+			Expect(cfg.TemplateConfig.ChatMessage).To(Equal("{{ messages }}"))
+			Expect(cfg.TemplateConfig.BOSToken).To(Equal("<s>"))
+			Expect(cfg.TemplateConfig.EOSToken).To(Equal("<|im_end|>"))
+			Expect(cfg.StopWords).To(Equal([]string{"<|im_end|>"}))
+		})
+
+		It("reads chat_template.jinja and keeps the configured templates", func() {
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "tokenizer_config.json"), []byte(`{"eos_token": "</s>"}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "chat_template.jinja"), []byte(template), 0600)).To(Succeed())
+
+			cfg := &ModelConfig{}
+			guessHFFromDirectory(cfg, dir)
+			Expect(cfg.TemplateConfig.ChatMessage).To(Equal(template))
+			Expect(cfg.FunctionsConfig.Parser).To(Equal("hermes"))
+
+			cfg = &ModelConfig{TemplateConfig: TemplateConfig{UseTokenizerTemplate: true}}
+			guessHFFromDirectory(cfg, dir)
+			Expect(cfg.TemplateConfig.JinjaTemplate).To(BeFalse())
+		})
+	})
+})
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/functions"
 
 	gguf "github.com/gpustack/gguf-parser-go"
 	"github.com/rs/zerolog/log"
@@ -43,4 +48,160 @@ func guessDefaultsFromFile(cfg *ModelConfig, modelPath string, defaultCtx int) {
 		guessGGUFFromFile(cfg, f, defaultCtx)
 		return
 	}
+
+	// or the tokenizer of a model directory, as used by transformers
+	if info, err := os.Stat(guessPath); err == nil && info.IsDir() {
+		guessHFFromDirectory(cfg, guessPath)
+	}
+}
+
+// chatTemplate is the chat template of a model, along with the special tokens it uses
+type chatTemplate struct {
+	Template     string
+	Architecture string
+	BOSToken     string
+	EOSToken     string
+	// AddBOS is true when the tokenizer adds the BOS token to the prompts
+	AddBOS bool
+	// EndTokens are the tokens ending the turns of the model
+	EndTokens []string
+}
+
+// endOfTurnTokens are the tokens ending the turns of known model families
+var endOfTurnTokens = []string{
+	"<|im_end|>", "<|eot_id|>", "<|eom_id|>", "<end_of_turn>", "<|end|>", "<|END_OF_TURN_TOKEN|>",
+	"<｜end▁of▁sentence｜>", "<|endoftext|>", "<|return|>", "<|call|>", "[/TOOL_CALLS]",
+}
+
+// useChatTemplate renders the prompts of the model with its chat template. The stop words and the tool
+// call format are set from the tokens of the template, unless configured.
+func useChatTemplate(cfg *ModelConfig, t chatTemplate) {
+	cfg.TemplateConfig.JinjaTemplate = true
+	cfg.TemplateConfig.ChatMessage = t.Template
+	cfg.TemplateConfig.EOSToken = t.EOSToken
+	// the template would repeat the BOS token added by the tokenizer
+	if !t.AddBOS {
+		cfg.TemplateConfig.BOSToken = t.BOSToken
+	}
+
+	if len(cfg.StopWords) == 0 {
+		for _, token := range t.EndTokens {
+			if token != "" && !slices.Contains(cfg.StopWords, token) {
+				cfg.StopWords = append(cfg.StopWords, token)
+			}
+		}
+		for _, token := range endOfTurnTokens {
+			if strings.Contains(t.Template, token) && !slices.Contains(cfg.StopWords, token) {
+				cfg.StopWords = append(cfg.StopWords, token)
+			}
+		}
+	}
+
+	// the template was not chosen by the configuration, neither is the format of the calls, unless the calls are
+	// extracted with regular expressions, which a named parser would take precedence over
+	if cfg.FunctionsConfig.Parser == "" && !extractsCallsWithRegex(cfg.FunctionsConfig) {
+		cfg.FunctionsConfig.Parser = functions.AutoParser
+	}
+	resolveAutoParser(cfg, t.Template, t.Architecture)
+	resolveAutoReasoningTags(cfg, t.Template)
+
+	log.Debug().Any("name", cfg.Name).Strs("stopwords", cfg.StopWords).Str("parser", cfg.FunctionsConfig.Parser).Msgf("guessDefaultsFromFile: %s", "using the chat template of the model")
+}
+
+// extractsCallsWithRegex returns true if the configuration extracts or rewrites the tool calls of the model with
+// regular expressions
+func extractsCallsWithRegex(c functions.FunctionsConfig) bool {
+	return len(c.ResponseRegex) > 0 || len(c.JSONRegexMatch) > 0 || len(c.ReplaceFunctionResults) > 0 ||
+		len(c.CaptureLLMResult) > 0 || len(c.ReplaceLLMResult) > 0
+}
+
+// hfTokenizerConfig is the tokenizer_config.json file of the models of the Hugging Face hub
+type hfTokenizerConfig struct {
+	ChatTemplate json.RawMessage `json:"chat_template"`
+	BOSToken     json.RawMessage `json:"bos_token"`
+	EOSToken     json.RawMessage `json:"eos_token"`
+	AddBOSToken  *bool           `json:"add_bos_token"`
+}
+
+// hfToken returns the content of a special token, which is either a string or an added token
+func hfToken(raw json.RawMessage) string {
+	var token string
+	if json.Unmarshal(raw, &token) == nil {
+		return token
+	}
+	var added struct {
+		Content string `json:"content"`
+	}
+	json.Unmarshal(raw, &added)
+	return added.Content
+}
+
+// hfChatTemplate returns the default template of the tokenizer, which is either a string or a list of
+// named templates
+func hfChatTemplate(raw json.RawMessage) string {
+	var template string
+	if json.Unmarshal(raw, &template) == nil {
+		return template
+	}
+	var templates []struct {
+		Name     string `json:"name"`
+		Template string `json:"template"`
+	}
+	json.Unmarshal(raw, &templates)
+	for _, t := range templates {
+		if t.Name == "default" {
+			return t.Template
+		}
+	}
+	if len(templates) > 0 {
+		return templates[0].Template
+	}
+	return ""
+}
+
+// guessHFFromDirectory uses the chat template of the tokenizer of a model directory, which is either in
+// tokenizer_config.json or in chat_template.jinja
+func guessHFFromDirectory(cfg *ModelConfig, dir string) {
+	if cfg.HasTemplate() || cfg.TemplateConfig.UseTokenizerTemplate {
+		log.Debug().Any("name", cfg.Name).Msgf("guessDefaultsFromFile: %s", "template already set")
+		return
+	}
+
+	dat, err := os.ReadFile(filepath.Join(dir, "tokenizer_config.json"))
+	if err != nil {
+		log.Debug().Err(err).Msgf("guessDefaultsFromFile: %s", "no tokenizer configuration")
+		return
+	}
+	var tc hfTokenizerConfig
+	if err := json.Unmarshal(dat, &tc); err != nil {
+		log.Warn().Err(err).Str("dir", dir).Msgf("guessDefaultsFromFile: %s", "invalid tokenizer configuration")
+		return
+	}
+
+	t := chatTemplate{
+		Template: hfChatTemplate(tc.ChatTemplate),
+		BOSToken: hfToken(tc.BOSToken),
+		EOSToken: hfToken(tc.EOSToken),
+		AddBOS:   tc.AddBOSToken != nil && *tc.AddBOSToken,
+	}
+	if t.Template == "" {
+		template, err := os.ReadFile(filepath.Join(dir, "chat_template.jinja"))
+		if err != nil {
+			log.Debug().Str("dir", dir).Msgf("guessDefaultsFromFile: %s", "no chat template")
+			return
+		}
+		t.Template = string(template)
+	}
+	if t.EOSToken != "" {
+		t.EndTokens = []string{t.EOSToken}
+	}
+	if dat, err := os.ReadFile(filepath.Join(dir, "config.json")); err == nil {
+		var modelConfig struct {
+			ModelType string `json:"model_type"`
+		}
+		if json.Unmarshal(dat, &modelConfig) == nil {
+			t.Architecture = modelConfig.ModelType
+		}
+	}
+	useChatTemplate(cfg, t)
 }
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// RouteStrategyWeighted picks a target randomly, proportionally to its weight
+	RouteStrategyWeighted = "weighted"
+	// RouteStrategyFailover picks the first healthy target, in order
+	RouteStrategyFailover = "failover"
+)
+
+// RouteTarget is a real model configuration a virtual model can be routed to
+type RouteTarget struct {
+	Model  string `yaml:"model" json:"model"`
+	Weight int    `yaml:"weight" json:"weight"`
+}
+
+// ModelRoute maps a virtual model name (e.g. gpt-4o) to one or more real model configurations.
+// A route with a single target is a plain alias.
+type ModelRoute struct {
+	Name     string        `yaml:"name" json:"name"`
+	Strategy string        `yaml:"strategy" json:"strategy"`
+	Targets  []RouteTarget `yaml:"targets" json:"targets"`
+
+	// StickyHeader is a request header (e.g. X-User-ID) used to route requests
+	// of the same user always to the same target
+	StickyHeader string `yaml:"sticky_header" json:"sticky_header"`
+}
+
+// Resolve returns the model the route points to. stickyKey, if not empty, makes the choice
+// deterministic for weighted routes. Targets for which healthy returns false are skipped.
+func (r *ModelRoute) Resolve(stickyKey string, healthy func(model string) bool) (string, error) {
+	candidates := slices.Clone(r.Targets)
+	for len(candidates) > 0 {
+		i := r.pick(candidates, stickyKey)
+		if healthy == nil || healthy(candidates[i].Model) {
+			return candidates[i].Model, nil
+		}
+		candidates = slices.Delete(candidates, i, i+1)
+	}
+
+	return "", fmt.Errorf("no healthy target available for model %q", r.Name)
+}
+
+func (r *ModelRoute) pick(candidates []RouteTarget, stickyKey string) int {
+	if r.Strategy == RouteStrategyFailover {
+		return 0
+	}
+
+	total := 0
+	for _, t := range candidates {
+		total += t.weight()
+	}
+
+	var n int
+	if stickyKey != "" {
+		h := fnv.New32a()
+		h.Write([]byte(stickyKey))
+		n = int(h.Sum32() % uint32(total))
+	} else {
+		n = rand.Intn(total)
+	}
+
+	for i, t := range candidates {
+		n -= t.weight()
+		if n < 0 {
+			return i
+		}
+	}
+
+	return len(candidates) - 1
+}
+
+func (t RouteTarget) weight() int {
+	if t.Weight <= 0 {
+		return 1
+	}
+	return t.Weight
+}
+
+func (r *ModelRoute) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("route name cannot be empty")
+	}
+	if len(r.Targets) == 0 {
+		return fmt.Errorf("route %q has no targets", r.Name)
+	}
+	switch r.Strategy {
+	case "", RouteStrategyWeighted, RouteStrategyFailover:
+	default:
+		return fmt.Errorf("route %q has unknown strategy %q", r.Name, r.Strategy)
+	}
+	for _, t := range r.Targets {
+		if t.Model == "" {
+			return fmt.Errorf("route %q has a target without model", r.Name)
+		}
+		if t.Model == r.Name {
+			return fmt.Errorf("route %q cannot target itself", r.Name)
+		}
+	}
+	return nil
+}
+
+func readModelRoutesFromFile(file string) ([]ModelRoute, error) {
+	f, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("readModelRoutesFromFile cannot read routes file %q: %w", file, err)
+	}
+
+	routes := []ModelRoute{}
+	if err := yaml.Unmarshal(f, &routes); err != nil {
+		return nil, fmt.Errorf("readModelRoutesFromFile cannot unmarshal routes file %q: %w", file, err)
+	}
+
+	for i := range routes {
+		if err := routes[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return routes, nil
+}
+
+// LoadModelRoutesFromFile reads a YAML file containing a list of model routes, replacing the ones
+// currently loaded
+func (bcl *ModelConfigLoader) LoadModelRoutesFromFile(file string) error {
+	routes, err := readModelRoutesFromFile(file)
+	if err != nil {
+		return err
+	}
+
+	bcl.Lock()
+	defer bcl.Unlock()
+	bcl.routes = make(map[string]ModelRoute, len(routes))
+	for _, r := range routes {
+		bcl.routes[r.Name] = r
+	}
+
+	return nil
+}
+
+func (bcl *ModelConfigLoader) GetModelRoute(name string) (ModelRoute, bool) {
+	bcl.Lock()
+	defer bcl.Unlock()
+	r, exists := bcl.routes[name]
+	return r, exists
+}
+
+func (bcl *ModelConfigLoader) GetAllModelRoutes() []ModelRoute {
+	bcl.Lock()
+	defer bcl.Unlock()
+	var res []ModelRoute
+	for _, r := range bcl.routes {
+		res = append(res, r)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Model routes", func() {
+	Context("Resolve", func() {
+		It("routes aliases to their only target", func() {
+			r := ModelRoute{Name: "gpt-4o", Targets: []RouteTarget{{Model: "llama3"}}}
+			m, err := r.Resolve("", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m).To(Equal("llama3"))
+		})
+
+		It("distributes requests according to weights", func() {
+			r := ModelRoute{Name: "gpt-4o", Targets: []RouteTarget{
+				{Model: "a", Weight: 9},
+				{Model: "b", Weight: 1},
+			}}
+			counts := map[string]int{}
+			for i := 0; i < 2000; i++ {
+				m, err := r.Resolve("", nil)
+				Expect(err).ToNot(HaveOccurred())
+				counts[m]++
+			}
+			Expect(counts["a"]).To(BeNumerically(">", 1600))
+			Expect(counts["b"]).To(BeNumerically(">", 100))
+		})
+
+		It("is sticky for the same key", func() {
+			r := ModelRoute{Name: "gpt-4o", Targets: []RouteTarget{{Model: "a"}, {Model: "b"}, {Model: "c"}}}
+			first, err := r.Resolve("user-1", nil)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 50; i++ {
+				m, err := r.Resolve("user-1", nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(m).To(Equal(first))
+			}
+		})
+
+		It("fails over to the next healthy target", func() {
+			r := ModelRoute{Name: "gpt-4o", Strategy: RouteStrategyFailover, Targets: []RouteTarget{{Model: "a"}, {Model: "b"}, {Model: "c"}}}
+			m, err := r.Resolve("", func(model string) bool { return model != "a" })
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m).To(Equal("b"))
+
+			_, err = r.Resolve("", func(model string) bool { return false })
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("skips unhealthy targets of weighted routes", func() {
+			r := ModelRoute{Name: "gpt-4o", Targets: []RouteTarget{{Model: "a"}, {Model: "b"}}}
+			for i := 0; i < 50; i++ {
+				m, err := r.Resolve("", func(model string) bool { return model == "b" })
+				Expect(err).ToNot(HaveOccurred())
+				Expect(m).To(Equal("b"))
+			}
+		})
+	})
+
+	Context("LoadModelRoutesFromFile", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "routes")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("loads routes", func() {
+			file := filepath.Join(dir, "routes.yaml")
+			Expect(os.WriteFile(file, []byte(`
+- name: gpt-4o
+  sticky_header: X-User-ID
+  targets:
+  - model: llama3
+    weight: 80
+  - model: qwen
+    weight: 20
+- name: embeddings
+  strategy: failover
+  targets:
+  - model: bert
+`), 0600)).To(Succeed())
+
+			bcl := NewModelConfigLoader(dir)
+			Expect(bcl.LoadModelRoutesFromFile(file)).To(Succeed())
+
+			routes := bcl.GetAllModelRoutes()
+			Expect(routes).To(HaveLen(2))
+			Expect(routes[0].Name).To(Equal("embeddings"))
+
+			r, exists := bcl.GetModelRoute("gpt-4o")
+			Expect(exists).To(BeTrue())
+			Expect(r.StickyHeader).To(Equal("X-User-ID"))
+			Expect(r.Targets).To(HaveLen(2))
+		})
+
+		It("rejects invalid routes", func() {
+			file := filepath.Join(dir, "routes.yaml")
+			Expect(os.WriteFile(file, []byte(`
+- name: gpt-4o
+  strategy: random
+  targets:
+  - model: llama3
+`), 0600)).To(Succeed())
+
+			bcl := NewModelConfigLoader(dir)
+			Expect(bcl.LoadModelRoutesFromFile(file)).ToNot(Succeed())
+		})
+	})
+})
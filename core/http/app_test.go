@@ -979,6 +979,52 @@ var _ = Describe("API test", func() {
 					Expect(findRespBody.Similarities[i]).To(BeNumerically("<=", 1))
 				}
 			})
+
+			It("filters the entries of a namespace on their metadata", func() {
+				url := "http://127.0.0.1:9090/stores/"
+				setBody := schema.StoresSet{
+					Keys:   [][]float32{{0.1, 0.2}, {0.3, 0.4}, {0.5, 0.6}},
+					Values: []string{"intro", "refunds", "shipping"},
+					Metadata: []map[string]interface{}{
+						{"source": "faq.md", "page": 1},
+						{"source": "faq.md", "page": 2},
+						{"source": "terms.pdf", "page": 7},
+					},
+					StoreCommon: schema.StoreCommon{Namespace: "docs"},
+				}
+				Expect(postRequestJSON(url+"set", &setBody)).To(Succeed())
+
+				findBody := schema.StoresFind{
+					Key:         []float32{0.5, 0.6},
+					Topk:        10,
+					Filter:      map[string]interface{}{"source": "faq.md", "page": map[string]interface{}{"$gte": 2}},
+					StoreCommon: schema.StoreCommon{Namespace: "docs"},
+				}
+				var findRespBody schema.StoresFindResponse
+				Expect(postRequestResponseJSON(url+"find", &findBody, &findRespBody)).To(Succeed())
+				Expect(findRespBody.Values).To(Equal([]string{"refunds"}))
+				Expect(findRespBody.Metadata).To(Equal([]map[string]interface{}{{"source": "faq.md", "page": float64(2)}}))
+
+				findBody.Filter = nil
+				findRespBody = schema.StoresFindResponse{}
+				Expect(postRequestResponseJSON(url+"find", &findBody, &findRespBody)).To(Succeed())
+				Expect(findRespBody.Values).To(HaveLen(3))
+
+				findBody.Filter = map[string]interface{}{"page": map[string]interface{}{"$near": 2}}
+				Expect(postRequestResponseJSON(url+"find", &findBody, &findRespBody)).ToNot(Succeed())
+			})
+
+			It("rejects texts for stores without an embedding model", func() {
+				url := "http://127.0.0.1:9090/stores/"
+				setBody := schema.StoresSet{Texts: []string{"the quick brown fox"}}
+				err := postRequestJSON(url+"set", &setBody)
+				Expect(err).To(MatchError(ContainSubstring("400")))
+
+				findBody := schema.StoresFind{Query: "fox", Topk: 1}
+				var findRespBody schema.StoresFindResponse
+				err = postRequestResponseJSON(url+"find", &findBody, &findRespBody)
+				Expect(err).To(MatchError(ContainSubstring("400")))
+			})
 		})
 	})
 
@@ -0,0 +1,107 @@
+package localai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/utils"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// StoresDocumentsEndpoint ingests a document into a store in the background
+// @Summary Ingests a text, Markdown, HTML or PDF document into a store bound to an embedding model, replacing the previous version of the document
+// @Param name path string true "Store name"
+// @Param file formData file true "document"
+// @Param id formData string false "document ID, defaults to the file name"
+// @Param namespace formData string false "namespace"
+// @Param metadata formData string false "JSON object set in the metadata of the chunks"
+// @Param chunk_size formData int false "maximum number of characters of the chunks"
+// @Param chunk_overlap formData int false "number of characters shared by consecutive chunks"
+// @Success 200 {object} schema.GalleryResponse "Response"
+// @Router /stores/{name}/documents [post]
+func StoresDocumentsEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig, documentsService *services.DocumentsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		storeName := c.Params("name")
+
+		// fail early for stores which cannot embed the chunks
+		if _, err := storeEmbedder(cl, sl, appConfig, storeName); err != nil {
+			return err
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "a document must be sent in the file field")
+		}
+		f, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+
+		op := services.DocumentOp{
+			Store:      storeName,
+			Namespace:  c.FormValue("namespace"),
+			DocumentID: c.FormValue("id", path.Base(file.Filename)),
+			FileName:   path.Base(file.Filename),
+			Data:       data,
+		}
+		if m := c.FormValue("metadata"); m != "" {
+			if err := json.Unmarshal([]byte(m), &op.Metadata); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "the metadata must be a JSON object: "+err.Error())
+			}
+		}
+		for field, value := range map[string]*int{"chunk_size": &op.ChunkSize, "chunk_overlap": &op.ChunkOverlap} {
+			if v := c.FormValue(field); v != "" {
+				if *value, err = strconv.Atoi(v); err != nil || *value < 0 {
+					return fiber.NewError(fiber.StatusBadRequest, field+" must be a positive integer")
+				}
+			}
+		}
+
+		uuid, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+		op.ID = uuid.String()
+		documentsService.UpdateStatus(op.ID, &services.DocumentOpStatus{Store: op.Store, DocumentID: op.DocumentID, FileName: op.FileName, Message: "queued"})
+		documentsService.DocumentsChannel <- op
+
+		return c.JSON(schema.GalleryResponse{ID: op.ID, StatusURL: fmt.Sprintf("%sstores/jobs/%s", utils.BaseURL(c), op.ID)})
+	}
+}
+
+// StoresJobStatusEndpoint returns the status of a document ingestion job
+// @Summary Returns the status of a document ingestion job
+// @Success 200 {object} services.DocumentOpStatus "Response"
+// @Router /stores/jobs/{uuid} [get]
+func StoresJobStatusEndpoint(documentsService *services.DocumentsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		status := documentsService.GetStatus(c.Params("uuid"))
+		if status == nil {
+			return fiber.NewError(fiber.StatusNotFound, "could not find any status for ID")
+		}
+		return c.JSON(status)
+	}
+}
+
+// StoresJobsEndpoint returns the status of all the document ingestion jobs
+// @Summary Returns the status of all the document ingestion jobs
+// @Success 200 {object} map[string]services.DocumentOpStatus "Response"
+// @Router /stores/jobs [get]
+func StoresJobsEndpoint(documentsService *services.DocumentsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(documentsService.GetAllStatus())
+	}
+}
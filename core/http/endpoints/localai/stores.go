@@ -1,6 +1,9 @@
 package localai
 
 import (
+	"encoding/json"
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
@@ -9,7 +12,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/store"
 )
 
-func StoresSetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+func StoresSetEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		input := new(schema.StoresSet)
 
@@ -17,7 +20,13 @@ func StoresSetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfi
 			return err
 		}
 
-		sb, err := backend.StoreBackend(sl, appConfig, input.Store, input.Backend)
+		if len(input.Texts) > 0 {
+			if err := embedStoreTexts(cl, sl, appConfig, input); err != nil {
+				return err
+			}
+		}
+
+		sb, err := backend.StoreBackend(sl, cl, appConfig, input.Store, input.Backend)
 		if err != nil {
 			return err
 		}
@@ -28,7 +37,21 @@ func StoresSetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfi
 			vals[i] = []byte(v)
 		}
 
-		err = store.SetCols(c.Context(), sb, input.Keys, vals)
+		opts := []store.Option{store.WithNamespace(input.Namespace)}
+		if input.Metadata != nil {
+			metadata := make([][]byte, len(input.Metadata))
+			for i, m := range input.Metadata {
+				if m == nil {
+					continue
+				}
+				if metadata[i], err = json.Marshal(m); err != nil {
+					return err
+				}
+			}
+			opts = append(opts, store.WithMetadata(metadata))
+		}
+
+		err = store.SetCols(c.Context(), sb, input.Keys, vals, opts...)
 		if err != nil {
 			return err
 		}
@@ -37,7 +60,7 @@ func StoresSetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfi
 	}
 }
 
-func StoresDeleteEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+func StoresDeleteEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		input := new(schema.StoresDelete)
 
@@ -45,13 +68,13 @@ func StoresDeleteEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationCo
 			return err
 		}
 
-		sb, err := backend.StoreBackend(sl, appConfig, input.Store, input.Backend)
+		sb, err := backend.StoreBackend(sl, cl, appConfig, input.Store, input.Backend)
 		if err != nil {
 			return err
 		}
 		defer sl.Close()
 
-		if err := store.DeleteCols(c.Context(), sb, input.Keys); err != nil {
+		if err := store.DeleteCols(c.Context(), sb, input.Keys, store.WithNamespace(input.Namespace)); err != nil {
 			return err
 		}
 
@@ -59,7 +82,7 @@ func StoresDeleteEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationCo
 	}
 }
 
-func StoresGetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+func StoresGetEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		input := new(schema.StoresGet)
 
@@ -67,13 +90,13 @@ func StoresGetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfi
 			return err
 		}
 
-		sb, err := backend.StoreBackend(sl, appConfig, input.Store, input.Backend)
+		sb, err := backend.StoreBackend(sl, cl, appConfig, input.Store, input.Backend)
 		if err != nil {
 			return err
 		}
 		defer sl.Close()
 
-		keys, vals, err := store.GetCols(c.Context(), sb, input.Keys)
+		keys, vals, metadata, err := store.GetColsWithMetadata(c.Context(), sb, input.Keys, store.WithNamespace(input.Namespace))
 		if err != nil {
 			return err
 		}
@@ -87,11 +110,15 @@ func StoresGetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfi
 			res.Values[i] = string(v)
 		}
 
+		if res.Metadata, err = decodeMetadata(metadata); err != nil {
+			return err
+		}
+
 		return c.JSON(res)
 	}
 }
 
-func StoresFindEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+func StoresFindEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		input := new(schema.StoresFind)
 
@@ -99,13 +126,40 @@ func StoresFindEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConf
 			return err
 		}
 
-		sb, err := backend.StoreBackend(sl, appConfig, input.Store, input.Backend)
+		if input.Query != "" {
+			if input.Key != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "either a key or a query must be found, not both")
+			}
+			embedder, err := storeEmbedder(cl, sl, appConfig, input.Store)
+			if err != nil {
+				return err
+			}
+			keys, err := embedder.Embed([]string{input.Query})
+			if err != nil {
+				return err
+			}
+			input.Key = keys[0]
+		}
+
+		sb, err := backend.StoreBackend(sl, cl, appConfig, input.Store, input.Backend)
 		if err != nil {
 			return err
 		}
 		defer sl.Close()
 
-		keys, vals, similarities, err := store.Find(c.Context(), sb, input.Key, input.Topk)
+		opts := []store.Option{store.WithNamespace(input.Namespace)}
+		if input.Filter != nil {
+			filter, err := json.Marshal(input.Filter)
+			if err != nil {
+				return err
+			}
+			if _, err := store.ParseFilter(filter); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			opts = append(opts, store.WithFilter(filter))
+		}
+
+		keys, vals, metadata, similarities, err := store.FindWithMetadata(c.Context(), sb, input.Key, input.Topk, opts...)
 		if err != nil {
 			return err
 		}
@@ -120,6 +174,69 @@ func StoresFindEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConf
 			res.Values[i] = string(v)
 		}
 
+		if res.Metadata, err = decodeMetadata(metadata); err != nil {
+			return err
+		}
+
 		return c.JSON(res)
 	}
 }
+
+// storeEmbedder returns the embedder of the store, failing the request if it is not bound to an embedding model
+func storeEmbedder(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig, storeName string) (*backend.StoreEmbedder, error) {
+	embedder, err := backend.NewStoreEmbedder(sl, cl, appConfig, storeName)
+	if errors.Is(err, backend.ErrNoEmbeddingModel) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return embedder, err
+}
+
+// embedStoreTexts replaces the texts of the input with the keys and the values of their chunks. The texts are
+// embedded before the store is loaded, as the model loader may only hold one backend at a time.
+func embedStoreTexts(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig, input *schema.StoresSet) error {
+	if len(input.Keys) > 0 || len(input.Values) > 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "either keys and values or texts must be set, not both")
+	}
+	if input.Metadata != nil && len(input.Metadata) != len(input.Texts) {
+		return fiber.NewError(fiber.StatusBadRequest, "the metadata must have the same length as the texts")
+	}
+
+	embedder, err := storeEmbedder(cl, sl, appConfig, input.Store)
+	if err != nil {
+		return err
+	}
+
+	var metadata []map[string]interface{}
+	for i, t := range input.Texts {
+		for _, chunk := range embedder.Chunks(t) {
+			input.Values = append(input.Values, chunk)
+			if input.Metadata != nil {
+				metadata = append(metadata, input.Metadata[i])
+			}
+		}
+	}
+	if len(input.Values) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "the texts are empty")
+	}
+	input.Metadata = metadata
+
+	input.Keys, err = embedder.Embed(input.Values)
+	return err
+}
+
+// decodeMetadata decodes the metadata of the entries, which is nil when none of them has any
+func decodeMetadata(metadata [][]byte) ([]map[string]interface{}, error) {
+	var decoded []map[string]interface{}
+	for i, m := range metadata {
+		if len(m) == 0 {
+			continue
+		}
+		if decoded == nil {
+			decoded = make([]map[string]interface{}, len(metadata))
+		}
+		if err := json.Unmarshal(m, &decoded[i]); err != nil {
+			return nil, err
+		}
+	}
+	return decoded, nil
+}
@@ -0,0 +1,285 @@
+package localai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/store"
+)
+
+const (
+	// storeExportPageSize is the number of entries exported from the store at a time
+	storeExportPageSize = 1000
+	// storeImportBatchSize is the number of entries imported into the store at a time
+	storeImportBatchSize = 256
+	// maxStoreEntrySize bounds the lines of the imported files
+	maxStoreEntrySize = 64 << 20
+)
+
+// StoresListEndpoint lists the stores
+// @Summary Lists the stores persisted under the models path and the ones configured with the local-store backend
+// @Success 200 {object} schema.StoresListResponse "Response"
+// @Router /stores [get]
+func StoresListEndpoint(cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		stores := map[string]*schema.StoreInfo{}
+
+		entries, err := os.ReadDir(filepath.Join(appConfig.SystemState.Model.ModelsPath, "stores"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				stores[e.Name()] = &schema.StoreInfo{Name: e.Name(), Persisted: true}
+			}
+		}
+
+		for _, cfg := range cl.GetAllModelsConfigs() {
+			if cfg.Backend != model.LocalStoreBackend {
+				continue
+			}
+			info, ok := stores[cfg.Name]
+			if !ok {
+				info = &schema.StoreInfo{Name: cfg.Name}
+				stores[cfg.Name] = info
+			}
+			info.Configured = true
+			info.EmbeddingModel = cfg.Store.EmbeddingModel
+		}
+
+		res := schema.StoresListResponse{Stores: []schema.StoreInfo{}}
+		for _, info := range stores {
+			res.Stores = append(res.Stores, *info)
+		}
+		slices.SortFunc(res.Stores, func(a, b schema.StoreInfo) int {
+			if a.Name < b.Name {
+				return -1
+			} else if a.Name > b.Name {
+				return 1
+			}
+			return 0
+		})
+
+		return c.JSON(res)
+	}
+}
+
+// StoresStatsEndpoint returns the statistics of a store
+// @Summary Returns the entries, key length and memory use of each namespace of a store, with its metric, quantization and index
+// @Param name path string true "Store name"
+// @Param backend query string false "store backend, local-store by default"
+// @Success 200 {object} schema.StoresStatsResponse "Response"
+// @Router /stores/{name} [get]
+func StoresStatsEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		storeName := c.Params("name")
+
+		sb, err := backend.StoreBackend(sl, cl, appConfig, storeName, c.Query("backend"))
+		if err != nil {
+			return err
+		}
+		defer sl.Close()
+
+		stats, err := store.Stats(c.Context(), sb)
+		if err != nil {
+			return err
+		}
+
+		res := schema.StoresStatsResponse{
+			Name:         storeName,
+			Metric:       stats.Metric,
+			Quantization: stats.Quantization,
+			Index:        stats.Index,
+			Persisted:    stats.Persisted,
+			Namespaces:   []schema.StoreNamespaceStats{},
+		}
+		for _, ns := range stats.Namespaces {
+			res.Entries += ns.Entries
+			res.MemoryBytes += ns.MemoryBytes
+			res.Namespaces = append(res.Namespaces, schema.StoreNamespaceStats{
+				Namespace:   ns.Namespace,
+				Entries:     ns.Entries,
+				KeyLength:   int(ns.KeyLength),
+				MemoryBytes: ns.MemoryBytes,
+			})
+		}
+
+		return c.JSON(res)
+	}
+}
+
+// StoresDropEndpoint drops a store
+// @Summary Deletes all the entries of a store, in all its namespaces, along with its files
+// @Param name path string true "Store name"
+// @Param backend query string false "store backend, local-store by default"
+// @Router /stores/{name} [delete]
+func StoresDropEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sb, err := backend.StoreBackend(sl, cl, appConfig, c.Params("name"), c.Query("backend"))
+		if err != nil {
+			return err
+		}
+		defer sl.Close()
+
+		if err := store.Drop(c.Context(), sb); err != nil {
+			return err
+		}
+
+		return c.Send(nil)
+	}
+}
+
+// StoresExportEndpoint exports the entries of a store
+// @Summary Exports the entries of all the namespaces of a store as JSON lines, which the import endpoint reads back
+// @Param name path string true "Store name"
+// @Param backend query string false "store backend, local-store by default"
+// @Success 200 {object} schema.StoreEntry "One entry per line"
+// @Router /stores/{name}/export [get]
+func StoresExportEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		storeName := c.Params("name")
+
+		sb, err := backend.StoreBackend(sl, cl, appConfig, storeName, c.Query("backend"))
+		if err != nil {
+			return err
+		}
+		defer sl.Close()
+
+		stats, err := store.Stats(c.Context(), sb)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, ns := range stats.Namespaces {
+			for offset := int64(0); ; {
+				keys, vals, metadata, total, err := store.Export(c.Context(), sb, offset, storeExportPageSize, store.WithNamespace(ns.Namespace))
+				if err != nil {
+					return err
+				}
+				decoded, err := decodeMetadata(metadata)
+				if err != nil {
+					return err
+				}
+
+				for i, k := range keys {
+					entry := schema.StoreEntry{Namespace: ns.Namespace, Key: k, Value: string(vals[i])}
+					if decoded != nil {
+						entry.Metadata = decoded[i]
+					}
+					if err := enc.Encode(entry); err != nil {
+						return err
+					}
+				}
+
+				offset += int64(len(keys))
+				if len(keys) == 0 || offset >= total {
+					break
+				}
+			}
+		}
+
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", storeName+".jsonl"))
+		return c.Send(buf.Bytes())
+	}
+}
+
+// StoresImportEndpoint imports entries into a store
+// @Summary Sets the entries of a file exported from a store, one JSON object per line, sent as the body or in the file field of a form
+// @Param name path string true "Store name"
+// @Param backend query string false "store backend, local-store by default"
+// @Success 200 {object} schema.StoresImportResponse "Response"
+// @Router /stores/{name}/import [post]
+func StoresImportEndpoint(cl *config.ModelConfigLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var r io.Reader = bytes.NewReader(c.Body())
+		if file, err := c.FormFile("file"); err == nil {
+			f, err := file.Open()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		sb, err := backend.StoreBackend(sl, cl, appConfig, c.Params("name"), c.Query("backend"))
+		if err != nil {
+			return err
+		}
+		defer sl.Close()
+
+		// the consecutive entries of the same namespace are set together
+		var (
+			namespace string
+			keys      [][]float32
+			vals      [][]byte
+			metadata  [][]byte
+			imported  int
+		)
+		flush := func() error {
+			if len(keys) == 0 {
+				return nil
+			}
+			if err := store.SetCols(c.Context(), sb, keys, vals, store.WithNamespace(namespace), store.WithMetadata(metadata)); err != nil {
+				return fmt.Errorf("failed to import the entries after the first %d: %w", imported, err)
+			}
+			imported += len(keys)
+			keys, vals, metadata = nil, nil, nil
+			return nil
+		}
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxStoreEntrySize)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+
+			var entry schema.StoreEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid entry on line %d: %s", line, err))
+			}
+			if len(entry.Key) == 0 {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the entry on line %d has no key", line))
+			}
+
+			if entry.Namespace != namespace || len(keys) >= storeImportBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+				namespace = entry.Namespace
+			}
+
+			var m []byte
+			if entry.Metadata != nil {
+				if m, err = json.Marshal(entry.Metadata); err != nil {
+					return err
+				}
+			}
+			keys = append(keys, entry.Key)
+			vals = append(vals, []byte(entry.Value))
+			metadata = append(metadata, m)
+		}
+		if err := scanner.Err(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("failed to read the entries: %s", err))
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		return c.JSON(schema.StoresImportResponse{Entries: imported})
+	}
+}
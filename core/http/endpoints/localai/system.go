@@ -24,7 +24,7 @@ func SystemInformations(ml *model.ModelLoader, appConfig *config.ApplicationConf
 
 		sysmodels := []schema.SysInfoModel{}
 		for _, m := range loadedModels {
-			sysmodels = append(sysmodels, schema.SysInfoModel{ID: m.ID})
+			sysmodels = append(sysmodels, schema.SysInfoModel{ID: m.ID, Suspended: m.IsSuspended()})
 		}
 		return c.JSON(
 			schema.SystemInformationResponse{
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,6 +15,7 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http/middleware"
 	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/tools"
 	"github.com/mudler/LocalAI/pkg/functions"
 
 	"github.com/mudler/LocalAI/core/templates"
@@ -27,10 +30,70 @@ import (
 // @Param request body schema.OpenAIRequest true "query params"
 // @Success 200 {object} schema.OpenAIResponse "Response"
 // @Router /v1/chat/completions [post]
+// The prompt is returned without generating by /v1/chat/completions/render, or with ?dry_run=true
 func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, startupOptions *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	var id, textContentToReturn string
 	var created int
 
+	executor := tools.NewExecutor(ml, cl, startupOptions)
+
+	// retryStructuredOutput asks the model to fix an output not matching the json_schema response format
+	retryStructuredOutput := func(req *schema.OpenAIRequest, config *config.ModelConfig) func(previous, feedback string) (string, backend.TokenUsage, error) {
+		return func(previous, feedback string) (string, backend.TokenUsage, error) {
+			retryReq := *req
+			retryReq.N = 1
+			retryReq.Messages = append(slices.Clone(req.Messages),
+				schema.Message{Role: "assistant", Content: previous, StringContent: previous},
+				schema.Message{Role: "user", Content: feedback, StringContent: feedback},
+			)
+
+			prompt := ""
+			if !config.TemplateConfig.UseTokenizerTemplate {
+				var err error
+				prompt, err = evaluator.TemplateMessages(retryReq, retryReq.Messages, config, nil, false)
+				if err != nil {
+					return "", backend.TokenUsage{}, err
+				}
+			}
+
+			output := ""
+			_, usage, err := ComputeChoices(&retryReq, prompt, config, cl, startupOptions, ml, func(s string, c *[]schema.Choice) {
+				_, output = extractReasoning(config, prompt, s)
+			}, nil)
+			return output, usage, err
+		}
+	}
+
+	// enforceChoicesSchema validates the content of the choices against a strict json_schema response format,
+	// replacing the outputs which still do not match after the retries with a refusal
+	enforceChoicesSchema := func(req *schema.OpenAIRequest, config *config.ModelConfig, jsonSchema map[string]interface{}, choices []schema.Choice) (backend.TokenUsage, error) {
+		usage := backend.TokenUsage{}
+		for i := range choices {
+			c := &choices[i]
+			if c.Message == nil || c.FinishReason == finishReasonTimeout {
+				continue
+			}
+			content, ok := c.Message.Content.(*string)
+			if !ok || content == nil {
+				continue
+			}
+
+			output, retryUsage, err := enforceJSONSchema(config, jsonSchema, *content, retryStructuredOutput(req, config))
+			addTokenUsage(&usage, retryUsage)
+			if err != nil {
+				refusal, err := structuredOutputRefusal(config, err)
+				if err != nil {
+					return usage, err
+				}
+				c.Message.Content = nil
+				c.Message.Refusal = refusal
+				continue
+			}
+			c.Message.Content = &output
+		}
+		return usage, nil
+	}
+
 	process := func(s string, req *schema.OpenAIRequest, config *config.ModelConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) error {
 		initialMessage := schema.OpenAIResponse{
 			ID:      id,
@@ -41,7 +104,23 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 		}
 		responses <- initialMessage
 
-		_, _, err := ComputeChoices(req, s, config, cl, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, tokenUsage backend.TokenUsage) bool {
+		if jsonSchema := strictJSONSchema(config); jsonSchema != nil {
+			// The output can be sent only once it is validated against the schema: the whole
+			// completion is buffered, and streamed in a single chunk
+			choices, tokenUsage, err := ComputeChoices(req, s, config, cl, startupOptions, loader, func(output string, c *[]schema.Choice) {
+				reasoningContent, content := extractReasoning(config, s, output)
+				*c = append(*c, schema.Choice{Message: &schema.Message{Role: "assistant", Content: &content, ReasoningContent: reasoningContent}})
+			}, nil)
+			if err == nil {
+				var retryUsage backend.TokenUsage
+				retryUsage, err = enforceChoicesSchema(req, config, jsonSchema, choices)
+				addTokenUsage(&tokenUsage, retryUsage)
+			}
+			if err != nil {
+				close(responses)
+				return err
+			}
+
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
 				CompletionTokens: tokenUsage.Completion,
@@ -51,25 +130,76 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 				usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
 				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
 			}
+			for _, choice := range choices {
+				if choice.Message == nil {
+					continue
+				}
+				responses <- schema.OpenAIResponse{
+					ID:      id,
+					Created: created,
+					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+					Choices: []schema.Choice{{Delta: &schema.Message{Content: choice.Message.Content, Refusal: choice.Message.Refusal, ReasoningContent: choice.Message.ReasoningContent}, Index: 0}},
+					Object:  "chat.completion.chunk",
+					Usage:   usage,
+				}
+			}
+			if hasTimedOut(choices) {
+				responses <- timeoutChunk(req)
+			}
+			close(responses)
+			return nil
+		}
 
-			resp := schema.OpenAIResponse{
+		// the reasoning is sent separately from the content, as reasoning_content
+		parser := newReasoningParser(config, s)
+		var lastUsage schema.OpenAIUsage
+		sendDelta := func(reasoningContent, content string, usage schema.OpenAIUsage) {
+			delta := &schema.Message{ReasoningContent: reasoningContent}
+			if content != "" || reasoningContent == "" {
+				delta.Content = &content
+			}
+			responses <- schema.OpenAIResponse{
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: []schema.Choice{{Delta: &schema.Message{Content: &s}, Index: 0}},
+				Choices: []schema.Choice{{Delta: delta, Index: 0}},
 				Object:  "chat.completion.chunk",
 				Usage:   usage,
 			}
+		}
 
-			responses <- resp
+		choices, _, err := ComputeChoices(req, s, config, cl, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, tokenUsage backend.TokenUsage) bool {
+			usage := schema.OpenAIUsage{
+				PromptTokens:     tokenUsage.Prompt,
+				CompletionTokens: tokenUsage.Completion,
+				TotalTokens:      tokenUsage.Prompt + tokenUsage.Completion,
+			}
+			if extraUsage {
+				usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
+				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
+			}
+			lastUsage = usage
+
+			reasoningContent, content := parser.Feed(s)
+			if reasoningContent == "" && content == "" && s != "" {
+				// held back until the parser can tell whether it is a reasoning delimiter
+				return true
+			}
+			sendDelta(reasoningContent, content, usage)
 			return true
 		})
+		if reasoningContent, content := parser.Flush(); reasoningContent != "" || content != "" {
+			sendDelta(reasoningContent, content, lastUsage)
+		}
+		if err == nil && hasTimedOut(choices) {
+			responses <- timeoutChunk(req)
+		}
 		close(responses)
 		return err
 	}
-	processTools := func(noAction string, prompt string, req *schema.OpenAIRequest, config *config.ModelConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) error {
+	processTools := func(noAction string, prompt string, req *schema.OpenAIRequest, config *config.ModelConfig, loader *model.ModelLoader, loop serverToolsLoop, responses chan schema.OpenAIResponse, extraUsage bool) error {
 		result := ""
-		_, tokenUsage, err := ComputeChoices(req, prompt, config, cl, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
+		choices, tokenUsage, err := ComputeChoices(req, prompt, config, cl, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
 			result += s
 			// TODO: Change generated BNF grammar to be compliant with the schema so we can
 			// stream the result token by token here.
@@ -78,6 +208,39 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 		if err != nil {
 			return err
 		}
+		timedOut := hasTimedOut(choices)
+		if !timedOut {
+			// the steps of the server tools are streamed as the results of the tools
+			loop.onStep = func(step serverToolStep) {
+				responses <- schema.OpenAIResponse{
+					ID:      id,
+					Created: created,
+					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+					Choices: []schema.Choice{{Delta: &schema.Message{Role: "tool", Name: step.call.FunctionCall.Name, Content: &step.result}}},
+					Object:  "chat.completion.chunk",
+				}
+			}
+			var loopUsage backend.TokenUsage
+			prompt, result, loopUsage, timedOut, err = loop.run(req, config, prompt, result)
+			addTokenUsage(&tokenUsage, loopUsage)
+			if err != nil {
+				return err
+			}
+		}
+		if timedOut {
+			// The function call is likely truncated, return what was generated as is
+			responses <- schema.OpenAIResponse{
+				ID:      id,
+				Created: created,
+				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+				Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &result}}},
+				Object:  "chat.completion.chunk",
+			}
+			responses <- timeoutChunk(req)
+			close(responses)
+			return nil
+		}
+		reasoningContent, result := extractReasoning(config, prompt, result)
 		textContentToReturn = functions.ParseTextContent(result, config.FunctionsConfig)
 		result = functions.CleanupLLMResult(result, config.FunctionsConfig)
 		functionResults := functions.ParseFunctionCall(result, config.FunctionsConfig)
@@ -90,7 +253,7 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &textContentToReturn}}},
+				Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &textContentToReturn, ReasoningContent: reasoningContent}}},
 				Object:  "chat.completion.chunk",
 			}
 			responses <- initialMessage
@@ -122,6 +285,15 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 			responses <- resp
 
 		default:
+			if reasoningContent != "" {
+				responses <- schema.OpenAIResponse{
+					ID:      id,
+					Created: created,
+					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+					Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", ReasoningContent: reasoningContent}}},
+					Object:  "chat.completion.chunk",
+				}
+			}
 			for i, ss := range functionResults {
 				name, args := ss.Name, ss.Arguments
 
@@ -194,6 +366,34 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 
 		log.Debug().Msgf("Chat endpoint configuration read: %+v", config)
 
+		// Rendering the prompt has no side effects: the MCP servers are not started, and no documents are retrieved
+		render := isRenderRequest(c)
+
+		// The server tools are given to the model along with the functions of the request
+		serverTools, skippedTools, err := serverToolsOf(input.Context, executor, config, input, !render)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		for _, t := range serverTools {
+			f, err := tools.Function(t)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			input.Functions = append(input.Functions, f)
+		}
+
+		// The documents retrieved for the request are given to the templates, and returned as citations
+		if !render {
+			input.Documents, err = retrieveDocuments(cl, ml, startupOptions, config, input)
+			if err != nil {
+				return err
+			}
+			// the models whose templates ignore the documents get them in the system message
+			if len(input.Documents) > 0 && !evaluator.ConsumesDocuments(config) {
+				input.Messages = withDocuments(input.Messages, input.Documents)
+			}
+		}
+
 		funcs := input.Functions
 		shouldUseFn := len(input.Functions) > 0 && config.ShouldUseFunctions()
 		strictMode := false
@@ -232,21 +432,17 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 			case "json_object":
 				input.Grammar = functions.JSONBNF
 			case "json_schema":
-				d := schema.JsonSchemaRequest{}
-				dat, err := json.Marshal(config.ResponseFormatMap)
-				if err != nil {
-					return err
-				}
-				err = json.Unmarshal(dat, &d)
+				js, err := jsonSchemaResponseFormat(config)
 				if err != nil {
 					return err
 				}
-				fs := &functions.JSONFunctionStructure{
-					AnyOf: []functions.Item{d.JsonSchema.Schema},
-				}
-				g, err := fs.Grammar(config.FunctionsConfig.GrammarOptions()...)
-				if err == nil {
-					input.Grammar = g
+				if js != nil {
+					g, err := functions.JSONSchemaGrammar(js.Schema, config.FunctionsConfig.GrammarOptions()...)
+					if err != nil {
+						log.Error().Err(err).Msg("failed to convert the JSON schema to a grammar")
+					} else {
+						input.Grammar = g
+					}
 				}
 			}
 		}
@@ -299,6 +495,23 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 			}
 		}
 
+		loop := serverToolsLoop{
+			executor:    executor,
+			evaluator:   evaluator,
+			funcs:       funcs,
+			shouldUseFn: shouldUseFn,
+			generate: func(req *schema.OpenAIRequest, prompt string) (string, backend.TokenUsage, bool, error) {
+				output := ""
+				choices, usage, err := ComputeChoices(req, prompt, config, cl, startupOptions, ml, func(s string, c *[]schema.Choice) {
+					output = s
+				}, nil)
+				return output, usage, hasTimedOut(choices), err
+			},
+		}
+		if shouldUseFn {
+			loop.tools = serverTools
+		}
+
 		// process functions if we have any defined or if we have a function call string
 
 		// functions are not supported in stream mode (yet?)
@@ -311,7 +524,15 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 		// If we are using the tokenizer template, we don't need to process the messages
 		// unless we are processing functions
 		if !config.TemplateConfig.UseTokenizerTemplate || shouldUseFn {
-			predInput = evaluator.TemplateMessages(*input, input.Messages, config, funcs, shouldUseFn)
+			predInput, err = evaluator.TemplateMessages(*input, input.Messages, config, funcs, shouldUseFn)
+			if err != nil {
+				var templateErr *templates.TemplateError
+				if errors.As(err, &templateErr) {
+					return fiber.NewError(fiber.StatusBadRequest, err.Error())
+				}
+				return err
+			}
+			predInput = skipReasoning(config, input, predInput)
 
 			log.Debug().Msgf("Prompt (after templating): %s", predInput)
 			if config.Grammar != "" {
@@ -319,6 +540,12 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 			}
 		}
 
+		if render {
+			res := renderChat(input, config, ml, startupOptions, predInput)
+			res.Warnings = renderWarnings(config, input, skippedTools)
+			return c.JSON(res)
+		}
+
 		switch {
 		case toStream:
 
@@ -338,13 +565,14 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 				if !shouldUseFn {
 					ended <- process(predInput, input, config, ml, responses, extraUsage)
 				} else {
-					ended <- processTools(noActionName, predInput, input, config, ml, responses, extraUsage)
+					ended <- processTools(noActionName, predInput, input, config, ml, loop, responses, extraUsage)
 				}
 			}()
 
 			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
 				usage := &schema.OpenAIUsage{}
 				toolsCalled := false
+				timedOut := false
 
 			LOOP:
 				for {
@@ -354,6 +582,10 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 							log.Debug().Msgf("No choices in the response, skipping")
 							continue
 						}
+						if ev.Choices[0].FinishReason == finishReasonTimeout {
+							timedOut = true
+							continue
+						}
 						usage = &ev.Usage // Copy a pointer to the latest usage chunk so that the stop message can reference it
 						if len(ev.Choices[0].Delta.ToolCalls) > 0 {
 							toolsCalled = true
@@ -403,6 +635,9 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 				} else if toolsCalled {
 					finishReason = "function_call"
 				}
+				if timedOut {
+					finishReason = finishReasonTimeout
+				}
 
 				resp := &schema.OpenAIResponse{
 					ID:      id,
@@ -414,8 +649,9 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 							Index:        0,
 							Delta:        &schema.Message{Content: &textContentToReturn},
 						}},
-					Object: "chat.completion.chunk",
-					Usage:  *usage,
+					Object:    "chat.completion.chunk",
+					Citations: input.Documents,
+					Usage:     *usage,
 				}
 				respData, _ := json.Marshal(resp)
 
@@ -430,10 +666,30 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 		// no streaming mode
 		default:
 
+			var serverToolsUsage backend.TokenUsage
+			var serverToolsErr error
 			tokenCallback := func(s string, c *[]schema.Choice) {
+				prompt := predInput
+				if shouldUseFn {
+					var loopUsage backend.TokenUsage
+					var timedOut bool
+					var err error
+					prompt, s, loopUsage, timedOut, err = loop.run(input, config, predInput, s)
+					addTokenUsage(&serverToolsUsage, loopUsage)
+					if err != nil {
+						serverToolsErr = err
+						return
+					}
+					if timedOut {
+						*c = append(*c, schema.Choice{FinishReason: finishReasonTimeout, Message: &schema.Message{Role: "assistant", Content: &s}})
+						return
+					}
+				}
+
+				reasoningContent, s := extractReasoning(config, prompt, s)
 				if !shouldUseFn {
 					// no function is called, just reply and use stop as finish reason
-					*c = append(*c, schema.Choice{FinishReason: "stop", Index: 0, Message: &schema.Message{Role: "assistant", Content: &s}})
+					*c = append(*c, schema.Choice{FinishReason: "stop", Index: 0, Message: &schema.Message{Role: "assistant", Content: &s, ReasoningContent: reasoningContent}})
 					return
 				}
 
@@ -445,7 +701,7 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 
 				switch {
 				case noActionsToRun:
-					result, err := handleQuestion(config, cl, input, ml, startupOptions, results, s, predInput)
+					result, err := handleQuestion(config, cl, input, ml, startupOptions, results, s, prompt)
 					if err != nil {
 						log.Error().Err(err).Msg("error handling question")
 						return
@@ -453,12 +709,13 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 
 					*c = append(*c, schema.Choice{
 						FinishReason: "stop",
-						Message:      &schema.Message{Role: "assistant", Content: &result}})
+						Message:      &schema.Message{Role: "assistant", Content: &result, ReasoningContent: reasoningContent}})
 				default:
 					toolChoice := schema.Choice{
 						FinishReason: "tool_calls",
 						Message: &schema.Message{
-							Role: "assistant",
+							Role:             "assistant",
+							ReasoningContent: reasoningContent,
 						},
 					}
 
@@ -483,8 +740,9 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 							*c = append(*c, schema.Choice{
 								FinishReason: "function_call",
 								Message: &schema.Message{
-									Role:    "assistant",
-									Content: &textContentToReturn,
+									Role:             "assistant",
+									Content:          &textContentToReturn,
+									ReasoningContent: reasoningContent,
 									FunctionCall: map[string]interface{}{
 										"name":      name,
 										"arguments": args,
@@ -512,9 +770,20 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 				tokenCallback,
 				nil,
 			)
+			if err == nil {
+				err = serverToolsErr
+			}
 			if err != nil {
 				return err
 			}
+			addTokenUsage(&tokenUsage, serverToolsUsage)
+			if jsonSchema := strictJSONSchema(config); jsonSchema != nil && !shouldUseFn {
+				retryUsage, err := enforceChoicesSchema(input, config, jsonSchema, result)
+				if err != nil {
+					return err
+				}
+				addTokenUsage(&tokenUsage, retryUsage)
+			}
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
 				CompletionTokens: tokenUsage.Completion,
@@ -526,12 +795,13 @@ func ChatEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator
 			}
 
 			resp := &schema.OpenAIResponse{
-				ID:      id,
-				Created: created,
-				Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: result,
-				Object:  "chat.completion",
-				Usage:   usage,
+				ID:        id,
+				Created:   created,
+				Model:     input.Model, // we have to return what the user sent here, due to OpenAI spec.
+				Choices:   result,
+				Object:    "chat.completion",
+				Citations: input.Documents,
+				Usage:     usage,
 			}
 			respData, _ := json.Marshal(resp)
 			log.Debug().Msgf("Response: %s", respData)
@@ -30,7 +30,82 @@ import (
 func CompletionEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	created := int(time.Now().Unix())
 
+	// retryStructuredOutput asks the model to fix an output not matching the json_schema response format,
+	// by continuing the prompt with the output and the validation errors
+	retryStructuredOutput := func(req *schema.OpenAIRequest, config *config.ModelConfig, prompt string) func(previous, feedback string) (string, backend.TokenUsage, error) {
+		return func(previous, feedback string) (string, backend.TokenUsage, error) {
+			retryReq := *req
+			retryReq.N = 1
+
+			output := ""
+			_, usage, err := ComputeChoices(&retryReq, fmt.Sprintf("%s%s\n\n%s\n", prompt, previous, feedback), config, cl, appConfig, ml, func(s string, c *[]schema.Choice) {
+				output = s
+			}, nil)
+			return output, usage, err
+		}
+	}
+
+	// enforceChoicesSchema validates the choices against a strict json_schema response format.
+	// Completions have no refusals: outputs which still do not match after the retries fail the request.
+	enforceChoicesSchema := func(req *schema.OpenAIRequest, config *config.ModelConfig, prompt string, jsonSchema map[string]interface{}, choices []schema.Choice) (backend.TokenUsage, error) {
+		usage := backend.TokenUsage{}
+		for i := range choices {
+			if choices[i].FinishReason == finishReasonTimeout {
+				continue
+			}
+			output, retryUsage, err := enforceJSONSchema(config, jsonSchema, choices[i].Text, retryStructuredOutput(req, config, prompt))
+			addTokenUsage(&usage, retryUsage)
+			if err != nil {
+				return usage, structuredOutputError(err)
+			}
+			choices[i].Text = output
+		}
+		return usage, nil
+	}
+
 	process := func(id string, s string, req *schema.OpenAIRequest, config *config.ModelConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) error {
+		if jsonSchema := strictJSONSchema(config); jsonSchema != nil {
+			// The output can be sent only once it is validated against the schema: the whole
+			// completion is buffered, and streamed in a single chunk
+			choices, tokenUsage, err := ComputeChoices(req, s, config, cl, appConfig, loader, func(s string, c *[]schema.Choice) {
+				*c = append(*c, schema.Choice{Text: s})
+			}, nil)
+			if err == nil {
+				var retryUsage backend.TokenUsage
+				retryUsage, err = enforceChoicesSchema(req, config, s, jsonSchema, choices)
+				addTokenUsage(&tokenUsage, retryUsage)
+			}
+			if err != nil {
+				close(responses)
+				return err
+			}
+
+			usage := schema.OpenAIUsage{
+				PromptTokens:     tokenUsage.Prompt,
+				CompletionTokens: tokenUsage.Completion,
+				TotalTokens:      tokenUsage.Prompt + tokenUsage.Completion,
+			}
+			if extraUsage {
+				usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
+				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
+			}
+			for _, choice := range choices {
+				responses <- schema.OpenAIResponse{
+					ID:      id,
+					Created: created,
+					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+					Choices: []schema.Choice{{Index: 0, Text: choice.Text}},
+					Object:  "text_completion",
+					Usage:   usage,
+				}
+			}
+			if hasTimedOut(choices) {
+				responses <- timeoutChunk(req)
+			}
+			close(responses)
+			return nil
+		}
+
 		tokenCallback := func(s string, tokenUsage backend.TokenUsage) bool {
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
@@ -59,7 +134,10 @@ func CompletionEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eva
 			responses <- resp
 			return true
 		}
-		_, _, err := ComputeChoices(req, s, config, cl, appConfig, loader, func(s string, c *[]schema.Choice) {}, tokenCallback)
+		choices, _, err := ComputeChoices(req, s, config, cl, appConfig, loader, func(s string, c *[]schema.Choice) {}, tokenCallback)
+		if err == nil && hasTimedOut(choices) {
+			responses <- timeoutChunk(req)
+		}
 		close(responses)
 		return err
 	}
@@ -83,8 +161,22 @@ func CompletionEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eva
 			d := schema.ChatCompletionResponseFormat{}
 			dat, _ := json.Marshal(config.ResponseFormatMap)
 			_ = json.Unmarshal(dat, &d)
-			if d.Type == "json_object" {
+			switch d.Type {
+			case "json_object":
 				input.Grammar = functions.JSONBNF
+			case "json_schema":
+				js, err := jsonSchemaResponseFormat(config)
+				if err != nil {
+					return err
+				}
+				if js != nil {
+					g, err := functions.JSONSchemaGrammar(js.Schema, config.FunctionsConfig.GrammarOptions()...)
+					if err != nil {
+						log.Error().Err(err).Msg("failed to convert the JSON schema to a grammar")
+					} else {
+						input.Grammar = g
+					}
+				}
 			}
 		}
 
@@ -128,6 +220,7 @@ func CompletionEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eva
 			}()
 
 			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+				finishReason := "stop"
 
 			LOOP:
 				for {
@@ -137,6 +230,10 @@ func CompletionEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eva
 							log.Debug().Msgf("No choices in the response, skipping")
 							continue
 						}
+						if ev.Choices[0].FinishReason == finishReasonTimeout {
+							finishReason = finishReasonTimeout
+							continue
+						}
 						var buf bytes.Buffer
 						enc := json.NewEncoder(&buf)
 						enc.Encode(ev)
@@ -162,7 +259,7 @@ func CompletionEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eva
 					Choices: []schema.Choice{
 						{
 							Index:        0,
-							FinishReason: "stop",
+							FinishReason: finishReason,
 						},
 					},
 					Object: "text_completion",
@@ -199,6 +296,13 @@ func CompletionEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, eva
 			if err != nil {
 				return err
 			}
+			if jsonSchema := strictJSONSchema(config); jsonSchema != nil {
+				retryUsage, err := enforceChoicesSchema(input, config, i, jsonSchema, r)
+				if err != nil {
+					return err
+				}
+				addTokenUsage(&tokenUsage, retryUsage)
+			}
 
 			totalTokenUsage.TimingTokenGeneration += tokenUsage.TimingTokenGeneration
 			totalTokenUsage.TimingPromptProcessing += tokenUsage.TimingPromptProcessing
@@ -1,7 +1,12 @@
 package openai
 
 import (
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/mudler/LocalAI/core/backend"
@@ -34,34 +39,30 @@ func EmbeddingsEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, app
 		}
 
 		log.Debug().Msgf("Parameter Config: %+v", config)
-		items := []schema.Item{}
 
-		for i, s := range config.InputToken {
-			// get the model function to call for the result
-			embedFn, err := backend.ModelEmbedding("", s, ml, *config, appConfig)
-			if err != nil {
-				return err
-			}
-
-			embeddings, err := embedFn()
-			if err != nil {
-				return err
-			}
-			items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
+		if input.Dimensions < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "dimensions must be positive")
+		}
+		switch input.EncodingFormat {
+		case "", "float", "base64":
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unsupported encoding_format %q, it must be float or base64", input.EncodingFormat))
 		}
 
-		for i, s := range config.InputStrings {
-			// get the model function to call for the result
-			embedFn, err := backend.ModelEmbedding(s, []int{}, ml, *config, appConfig)
-			if err != nil {
-				return err
-			}
+		embeddings, err := backend.ModelEmbeddings(config.InputStrings, config.InputToken, input.Dimensions, ml, *config, appConfig)
+		if errors.Is(err, backend.ErrEmbeddingDimensions) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return err
+		}
 
-			embeddings, err := embedFn()
-			if err != nil {
-				return err
+		items := make([]schema.Item, len(embeddings))
+		for i, e := range embeddings {
+			items[i] = schema.Item{Embedding: e, Index: i, Object: "embedding"}
+			if input.EncodingFormat == "base64" {
+				items[i].Embedding = encodeEmbedding(e)
 			}
-			items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
 		}
 
 		id := uuid.New().String()
@@ -81,3 +82,12 @@ func EmbeddingsEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, app
 		return c.JSON(resp)
 	}
 }
+
+// encodeEmbedding returns the base64 encoding of the little-endian floats of the embedding
+func encodeEmbedding(embedding []float32) string {
+	b := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
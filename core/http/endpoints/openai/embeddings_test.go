@@ -0,0 +1,20 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Embeddings", func() {
+	It("encodes the embeddings in base64", func() {
+		b, err := base64.StdEncoding.DecodeString(encodeEmbedding([]float32{0.5, -2}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b).To(HaveLen(8))
+		Expect(math.Float32frombits(binary.LittleEndian.Uint32(b[0:]))).To(Equal(float32(0.5)))
+		Expect(math.Float32frombits(binary.LittleEndian.Uint32(b[4:]))).To(Equal(float32(-2)))
+	})
+})
@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// UploadFilesEndpoint https://platform.openai.com/docs/api-reference/files/create
+// @Summary Uploads a file, such as a document to attach to vector stores
+// @Param file formData file true "file"
+// @Param purpose formData string true "purpose of the file"
+// @Success 200 {object} schema.File "Response"
+// @Router /v1/files [post]
+func UploadFilesEndpoint(filesService *services.FilesService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "a file must be sent in the file field")
+		}
+		purpose := c.FormValue("purpose")
+		if purpose == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "the purpose of the file must be set")
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+
+		uploaded, err := filesService.Upload(file.Filename, purpose, data)
+		if err != nil {
+			return err
+		}
+		return c.JSON(uploaded)
+	}
+}
+
+// ListFilesEndpoint https://platform.openai.com/docs/api-reference/files/list
+// @Summary Lists the uploaded files
+// @Param purpose query string false "only lists the files with this purpose"
+// @Success 200 {object} schema.FileList "Response"
+// @Router /v1/files [get]
+func ListFilesEndpoint(filesService *services.FilesService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(schema.FileList{
+			Object: "list",
+			Data:   filesService.List(c.Query("purpose")),
+		})
+	}
+}
+
+// GetFilesEndpoint https://platform.openai.com/docs/api-reference/files/retrieve
+// @Summary Returns an uploaded file
+// @Param file_id path string true "File ID"
+// @Success 200 {object} schema.File "Response"
+// @Router /v1/files/{file_id} [get]
+func GetFilesEndpoint(filesService *services.FilesService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		file, ok := filesService.Get(c.Params("file_id"))
+		if !ok {
+			return fiber.NewError(fiber.StatusNotFound, "no such file")
+		}
+		return c.JSON(file)
+	}
+}
+
+// GetFilesContentsEndpoint https://platform.openai.com/docs/api-reference/files/retrieve-contents
+// @Summary Returns the content of an uploaded file
+// @Param file_id path string true "File ID"
+// @Router /v1/files/{file_id}/content [get]
+func GetFilesContentsEndpoint(filesService *services.FilesService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if _, ok := filesService.Get(c.Params("file_id")); !ok {
+			return fiber.NewError(fiber.StatusNotFound, "no such file")
+		}
+		data, err := filesService.Content(c.Params("file_id"))
+		if err != nil {
+			return err
+		}
+		return c.Send(data)
+	}
+}
+
+// DeleteFilesEndpoint https://platform.openai.com/docs/api-reference/files/delete
+// @Summary Deletes an uploaded file, which stays in the vector stores it is attached to
+// @Param file_id path string true "File ID"
+// @Success 200 {object} schema.DeletionStatus "Response"
+// @Router /v1/files/{file_id} [delete]
+func DeleteFilesEndpoint(filesService *services.FilesService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("file_id")
+		deleted, err := filesService.Delete(id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return fiber.NewError(fiber.StatusNotFound, "no such file")
+		}
+		return c.JSON(schema.DeletionStatus{ID: id, Object: "file", Deleted: true})
+	}
+}
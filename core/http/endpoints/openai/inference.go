@@ -1,13 +1,19 @@
 package openai
 
 import (
+	"time"
+
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 
 	"github.com/mudler/LocalAI/core/schema"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/reasoning"
 )
 
+// finishReasonTimeout is reported for choices cut short by the request timeout
+const finishReasonTimeout = "timeout"
+
 func ComputeChoices(
 	req *schema.OpenAIRequest,
 	predInput string,
@@ -37,8 +43,20 @@ func ComputeChoices(
 		audios = append(audios, m.StringAudios...)
 	}
 
+	// With a reasoning budget, the first prediction stops once the budget is spent
+	// and is then continued without reasoning (see continueAfterReasoningBudget)
+	inferenceConfig := config
+	budget, hasBudget := config.Reasoning.Budget(req.ReasoningEffort)
+	forceBudget := hasBudget && budget > 0 && predInput != "" &&
+		(config.Maxtokens == nil || *config.Maxtokens <= 0 || *config.Maxtokens > budget)
+	if forceBudget {
+		budgetConfig := *config
+		budgetConfig.Maxtokens = &budget
+		inferenceConfig = &budgetConfig
+	}
+
 	// get the model function to call for the result
-	predFunc, err := backend.ModelInference(req.Context, predInput, req.Messages, images, videos, audios, loader, config, bcl, o, tokenCallback)
+	predFunc, err := backend.ModelInference(req.Context, predInput, req.Messages, images, videos, audios, loader, inferenceConfig, bcl, o, tokenCallback)
 	if err != nil {
 		return result, backend.TokenUsage{}, err
 	}
@@ -46,10 +64,17 @@ func ComputeChoices(
 	tokenUsage := backend.TokenUsage{}
 
 	for i := 0; i < n; i++ {
+		start := time.Now()
 		prediction, err := predFunc()
 		if err != nil {
 			return result, backend.TokenUsage{}, err
 		}
+		if forceBudget && !prediction.TimedOut && prediction.Usage.Completion >= budget {
+			prediction, err = continueAfterReasoningBudget(req, predInput, prediction, time.Since(start), images, videos, audios, config, bcl, o, loader, tokenCallback)
+			if err != nil {
+				return result, backend.TokenUsage{}, err
+			}
+		}
 
 		tokenUsage.Prompt += prediction.Usage.Prompt
 		tokenUsage.Completion += prediction.Usage.Completion
@@ -57,10 +82,96 @@ func ComputeChoices(
 		tokenUsage.TimingTokenGeneration += prediction.Usage.TimingTokenGeneration
 
 		finetunedResponse := backend.Finetune(*config, predInput, prediction.Response)
+		generated := len(result)
 		cb(finetunedResponse, &result)
 
+		if prediction.TimedOut {
+			// Streaming callers do not collect choices: add one to report the timeout
+			if len(result) == generated {
+				result = append(result, schema.Choice{Index: i})
+			}
+			for j := generated; j < len(result); j++ {
+				result[j].FinishReason = finishReasonTimeout
+			}
+			break
+		}
+
 		//result = append(result, Choice{Text: prediction})
 
 	}
 	return result, tokenUsage, err
 }
+
+// hasTimedOut reports whether generation of the choices was cut short by the request timeout
+func hasTimedOut(choices []schema.Choice) bool {
+	for _, c := range choices {
+		if c.FinishReason == finishReasonTimeout {
+			return true
+		}
+	}
+	return false
+}
+
+// timeoutChunk signals the stream writer that generation was cut short by the request timeout
+func timeoutChunk(req *schema.OpenAIRequest) schema.OpenAIResponse {
+	return schema.OpenAIResponse{
+		Model:   req.Model,
+		Choices: []schema.Choice{{FinishReason: finishReasonTimeout}},
+	}
+}
+
+// continueAfterReasoningBudget continues a prediction stopped by the reasoning budget. If the model was
+// still reasoning, the reasoning is closed so that the model replies straight away. The continuation
+// gets what is left of the request timeout, elapsed being the time spent on the prediction.
+func continueAfterReasoningBudget(req *schema.OpenAIRequest, predInput string, prediction backend.LLMResponse, elapsed time.Duration,
+	images, videos, audios []string, config *config.ModelConfig, bcl *config.ModelConfigLoader, o *config.ApplicationConfig,
+	loader *model.ModelLoader, tokenCallback func(string, backend.TokenUsage) bool) (backend.LLMResponse, error) {
+	output := prediction.Response
+	if reasoning.IsOpen(output, config.Reasoning.StartTag, config.Reasoning.EndTag, reasoningOpen(config, predInput)) {
+		closing := "\n" + config.Reasoning.EndTag + "\n\n"
+		output += closing
+		if tokenCallback != nil {
+			tokenCallback(closing, prediction.Usage)
+		}
+	}
+
+	restConfig := *config
+	restConfig.TemplateConfig.ReplyPrefix = ""
+	if config.Maxtokens != nil && *config.Maxtokens > 0 {
+		rest := *config.Maxtokens - prediction.Usage.Completion
+		if rest <= 0 {
+			prediction.Response = output
+			return prediction, nil
+		}
+		restConfig.Maxtokens = &rest
+	}
+	if timeout := config.GetTimeout(); timeout > 0 {
+		remaining := timeout - elapsed
+		if remaining <= 0 {
+			prediction.Response = output
+			prediction.TimedOut = true
+			return prediction, nil
+		}
+		restConfig.Timeout = remaining.String()
+	}
+
+	predFunc, err := backend.ModelInference(req.Context, predInput+output, req.Messages, images, videos, audios, loader, &restConfig, bcl, o, tokenCallback)
+	if err != nil {
+		return prediction, err
+	}
+	next, err := predFunc()
+	if err != nil {
+		return prediction, err
+	}
+
+	return backend.LLMResponse{
+		Response: output + next.Response,
+		Usage: backend.TokenUsage{
+			Prompt:                 prediction.Usage.Prompt,
+			Completion:             prediction.Usage.Completion + next.Usage.Completion,
+			TimingPromptProcessing: prediction.Usage.TimingPromptProcessing + next.Usage.TimingPromptProcessing,
+			TimingTokenGeneration:  prediction.Usage.TimingTokenGeneration + next.Usage.TimingTokenGeneration,
+		},
+		TimedOut: next.TimedOut,
+	}, nil
+}
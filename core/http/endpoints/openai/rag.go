@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// retrieveDocuments retrieves the documents of the RAG of the model, overridden by the request, closest to the
+// last user message. There are none without a store or a user message.
+func retrieveDocuments(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, cfg *config.ModelConfig, input *schema.OpenAIRequest) ([]schema.Document, error) {
+	rag := cfg.RAG.Override(input.RAG)
+	if rag.Store == "" {
+		return nil, nil
+	}
+
+	query := lastUserMessage(input.Messages)
+	if query == "" {
+		return nil, nil
+	}
+
+	documents, err := backend.RetrieveDocuments(input.Context, query, rag, ml, cl, appConfig)
+	if errors.Is(err, backend.ErrNoEmbeddingModel) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return documents, err
+}
+
+// lastUserMessage returns the text of the last message of the user, if any
+func lastUserMessage(messages []schema.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].StringContent
+		}
+	}
+	return ""
+}
+
+// withDocuments gives the documents to the model in the system message, appended to the first message if it is
+// a system message with a text content, or in a new system message otherwise
+func withDocuments(messages []schema.Message, documents []schema.Document) []schema.Message {
+	var preamble strings.Builder
+	preamble.WriteString("Answer with the following documents when they are relevant:")
+	for i, doc := range documents {
+		fmt.Fprintf(&preamble, "\n\n[%d]", i+1)
+		if len(doc.Metadata) > 0 {
+			if metadata, err := json.Marshal(doc.Metadata); err == nil {
+				fmt.Fprintf(&preamble, " %s", metadata)
+			}
+		}
+		fmt.Fprintf(&preamble, "\n%s", doc.Content)
+	}
+
+	if len(messages) > 0 && messages[0].Role == "system" {
+		if content, ok := messages[0].Content.(string); ok {
+			res := append([]schema.Message{}, messages...)
+			res[0].Content = content + "\n\n" + preamble.String()
+			res[0].StringContent = res[0].Content.(string)
+			return res
+		}
+	}
+	system := preamble.String()
+	return append([]schema.Message{{Role: "system", Content: system, StringContent: system}}, messages...)
+}
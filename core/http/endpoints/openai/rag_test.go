@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RAG", func() {
+	It("queries the store with the last user message", func() {
+		Expect(lastUserMessage([]schema.Message{
+			{Role: "system", StringContent: "You are a helpful assistant."},
+			{Role: "user", StringContent: "Hello!"},
+			{Role: "assistant", StringContent: "Hi!"},
+			{Role: "user", StringContent: "What is the capital of France?"},
+			{Role: "tool", StringContent: "sunny"},
+		})).To(Equal("What is the capital of France?"))
+		Expect(lastUserMessage([]schema.Message{{Role: "system", StringContent: "You are a helpful assistant."}})).To(BeEmpty())
+	})
+
+	It("overrides the RAG of the model with the one of the request", func() {
+		cfg := &config.ModelConfig{RAG: schema.RAG{Store: "docs", TopK: 5, Reranker: "jina"}}
+		rag := cfg.RAG.Override(&schema.RAG{TopK: 2, Filter: map[string]interface{}{"lang": "en"}})
+		Expect(rag).To(Equal(schema.RAG{Store: "docs", TopK: 2, Reranker: "jina", Filter: map[string]interface{}{"lang": "en"}}))
+		Expect(cfg.RAG.Override(nil)).To(Equal(cfg.RAG))
+	})
+
+	It("retrieves no documents without a store", func() {
+		documents, err := retrieveDocuments(nil, nil, nil, &config.ModelConfig{}, &schema.OpenAIRequest{
+			Messages: []schema.Message{{Role: "user", StringContent: "Hello!"}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(documents).To(BeNil())
+	})
+
+	It("gives the documents in the system message", func() {
+		documents := []schema.Document{
+			{Content: "Paris is the capital of France.", Metadata: map[string]interface{}{"source": "atlas"}},
+			{Content: "Lyon is in France."},
+		}
+		preamble := "Answer with the following documents when they are relevant:\n\n[1] {\"source\":\"atlas\"}\nParis is the capital of France.\n\n[2]\nLyon is in France."
+
+		messages := withDocuments([]schema.Message{
+			{Role: "system", Content: "You are a helpful assistant.", StringContent: "You are a helpful assistant."},
+			{Role: "user", Content: "Hello!", StringContent: "Hello!"},
+		}, documents)
+		Expect(messages).To(HaveLen(2))
+		Expect(messages[0].StringContent).To(Equal("You are a helpful assistant.\n\n" + preamble))
+		Expect(messages[0].Content).To(Equal(messages[0].StringContent))
+
+		messages = withDocuments([]schema.Message{{Role: "user", Content: "Hello!", StringContent: "Hello!"}}, documents)
+		Expect(messages).To(HaveLen(2))
+		Expect(messages[0]).To(Equal(schema.Message{Role: "system", Content: preamble, StringContent: preamble}))
+		Expect(messages[1].StringContent).To(Equal("Hello!"))
+	})
+})
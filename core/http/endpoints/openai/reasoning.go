@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/reasoning"
+)
+
+// reasoningOpen reports whether the prompt starts the reasoning of the reply, as some
+// templates end with the reasoning start tag
+func reasoningOpen(config *config.ModelConfig, prompt string) bool {
+	return config.Reasoning.Enabled() && strings.HasSuffix(strings.TrimSpace(prompt), config.Reasoning.StartTag)
+}
+
+// skipReasoning starts the reply with an empty reasoning block if the reasoning_effort
+// of the request has a budget of 0, so that the model replies without reasoning
+func skipReasoning(config *config.ModelConfig, req *schema.OpenAIRequest, prompt string) string {
+	budget, ok := config.Reasoning.Budget(req.ReasoningEffort)
+	if !ok || budget > 0 || prompt == "" {
+		return prompt
+	}
+	if !reasoningOpen(config, prompt) {
+		prompt += config.Reasoning.StartTag
+	}
+	return prompt + "\n\n" + config.Reasoning.EndTag + "\n\n"
+}
+
+// extractReasoning splits the output of the model in its reasoning and the content of the reply
+func extractReasoning(config *config.ModelConfig, prompt, output string) (string, string) {
+	if !config.Reasoning.Enabled() {
+		return "", output
+	}
+	return reasoning.Extract(output, config.Reasoning.StartTag, config.Reasoning.EndTag, reasoningOpen(config, prompt))
+}
+
+// newReasoningParser returns a parser splitting the streamed tokens between reasoning and content
+func newReasoningParser(config *config.ModelConfig, prompt string) *reasoning.Parser {
+	if !config.Reasoning.Enabled() {
+		return reasoning.NewParser("", "", false)
+	}
+	return reasoning.NewParser(config.Reasoning.StartTag, config.Reasoning.EndTag, reasoningOpen(config, prompt))
+}
@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// isRenderRequest tells whether the request only asks for the prompt, either with the render endpoint
+// or with dry_run=true
+func isRenderRequest(c *fiber.Ctx) bool {
+	return strings.HasSuffix(c.Path(), "/render") || c.QueryBool("dry_run")
+}
+
+// renderChat returns the prompt, grammar and parameters the model would be given, and the number of
+// tokens of the prompt if the backend can tokenize it
+func renderChat(input *schema.OpenAIRequest, config *config.ModelConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig, prompt string) schema.ChatCompletionRenderResponse {
+	res := schema.ChatCompletionRenderResponse{
+		Model:      input.Model,
+		Prompt:     prompt,
+		Grammar:    config.Grammar,
+		StopWords:  config.StopWords,
+		Parameters: config.PredictionOptions,
+	}
+	if prompt == "" {
+		return res
+	}
+
+	tokens, err := backend.ModelTokenize(prompt, ml, *config, appConfig)
+	if err != nil {
+		log.Warn().Err(err).Str("model", config.Name).Msg("failed to count the tokens of the prompt")
+		return res
+	}
+	count := len(tokens.Tokens)
+	res.Tokens = &count
+	return res
+}
+
+// renderWarnings tells what the rendered prompt leaves out: the tools of the MCP servers, which are not started,
+// with the skipped tools selected by the request, and the documents of the RAG store, which is not queried
+func renderWarnings(config *config.ModelConfig, input *schema.OpenAIRequest, skippedTools []string) []string {
+	var warnings []string
+	if servers := config.ServerTools.MCPServers; len(servers) > 0 && (input.ServerTools == nil || len(skippedTools) > 0) {
+		names := make([]string, len(servers))
+		for i, s := range servers {
+			names[i] = s.Name
+		}
+		warning := fmt.Sprintf("the tools of the MCP servers %s are left out, as the servers are not started", strings.Join(names, ", "))
+		if len(skippedTools) > 0 {
+			warning += fmt.Sprintf(": the server tools %s selected by the request are not given to the model", strings.Join(skippedTools, ", "))
+		}
+		warnings = append(warnings, warning)
+	}
+	if rag := config.RAG.Override(input.RAG); rag.Store != "" && lastUserMessage(input.Messages) != "" {
+		warnings = append(warnings, fmt.Sprintf("the documents of the store %s are left out, as it is not queried", rag.Store))
+	}
+	return warnings
+}
@@ -0,0 +1,122 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/templates"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/grpc/base"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/system"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// tokenizerBackend counts the words of the prompts as tokens
+type tokenizerBackend struct {
+	base.SingleThread
+}
+
+func (b *tokenizerBackend) Load(*pb.ModelOptions) error {
+	return nil
+}
+
+func (b *tokenizerBackend) TokenizeString(opts *pb.PredictOptions) (pb.TokenizationResponse, error) {
+	words := strings.Fields(opts.Prompt)
+	res := pb.TokenizationResponse{Length: int32(len(words))}
+	for i := range words {
+		res.Tokens = append(res.Tokens, int32(i))
+	}
+	return res, nil
+}
+
+var _ = Describe("Chat render", func() {
+	var (
+		app *fiber.App
+		cfg *config.ModelConfig
+	)
+
+	BeforeEach(func() {
+		grpc.Provide("render-tokenizer", &tokenizerBackend{})
+		systemState, err := system.GetSystemState(system.WithModelPath(GinkgoT().TempDir()))
+		Expect(err).ToNot(HaveOccurred())
+		appConfig := &config.ApplicationConfig{
+			Context:              context.Background(),
+			SystemState:          systemState,
+			ExternalGRPCBackends: map[string]string{"fake": "render-tokenizer"},
+		}
+		cfg = &config.ModelConfig{
+			Name:    "render",
+			Backend: "fake",
+			TemplateConfig: config.TemplateConfig{
+				JinjaTemplate: true,
+				ChatMessage:   `{% for m in messages %}<{{ m.role }}> {{ m.content }} {% endfor %}<assistant>`,
+			},
+		}
+		cfg.StopWords = []string{"</s>"}
+		cfg.Model = "render"
+		cfg.SetDefaults()
+
+		app = fiber.New()
+		app.Post("/*", func(c *fiber.Ctx) error {
+			input := &schema.OpenAIRequest{
+				Context:  context.Background(),
+				Messages: []schema.Message{{Role: "user", Content: "Hello there!", StringContent: "Hello there!"}},
+			}
+			input.Model = "render"
+			c.Locals(middleware.CONTEXT_LOCALS_KEY_LOCALAI_REQUEST, input)
+			c.Locals(middleware.CONTEXT_LOCALS_KEY_MODEL_CONFIG, cfg)
+			return c.Next()
+		}, ChatEndpoint(nil, model.NewModelLoader(systemState, false), templates.NewEvaluator(""), appConfig))
+	})
+
+	render := func(path string) schema.ChatCompletionRenderResponse {
+		resp, err := app.Test(httptest.NewRequest("POST", path, nil))
+		Expect(err).ToNot(HaveOccurred())
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(200), string(body))
+
+		var res schema.ChatCompletionRenderResponse
+		Expect(json.Unmarshal(body, &res)).To(Succeed())
+		return res
+	}
+
+	It("returns the prompt without generating", func() {
+		res := render("/v1/chat/completions/render")
+		Expect(res.Model).To(Equal("render"))
+		Expect(res.Prompt).To(Equal("<user> Hello there! <assistant>"))
+		Expect(res.StopWords).To(Equal([]string{"</s>"}))
+		Expect(res.Tokens).ToNot(BeNil())
+		Expect(*res.Tokens).To(Equal(4))
+		Expect(res.Warnings).To(BeEmpty())
+	})
+
+	It("renders dry runs", func() {
+		Expect(render("/v1/chat/completions?dry_run=true").Prompt).To(Equal("<user> Hello there! <assistant>"))
+	})
+
+	It("returns the grammar of the response format", func() {
+		cfg.ResponseFormatMap = map[string]interface{}{"type": "json_object"}
+		Expect(render("/v1/chat/completions/render").Grammar).ToNot(BeEmpty())
+	})
+
+	It("warns about the tools and the documents left out", func() {
+		cfg.ServerTools.MCPServers = []schema.MCPServer{{Name: "github", Command: "github-mcp-server"}}
+		cfg.RAG.Store = "docs"
+		Expect(render("/v1/chat/completions/render").Warnings).To(Equal([]string{
+			"the tools of the MCP servers github are left out, as the servers are not started",
+			"the documents of the store docs are left out, as it is not queried",
+		}))
+	})
+})
@@ -0,0 +1,148 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/templates"
+	"github.com/mudler/LocalAI/core/tools"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/rs/zerolog/log"
+)
+
+// serverToolsOf returns the server tools available to the request: the tools of the model configuration
+// and of its MCP servers, or the ones the request selects by name. Requests cannot define tools, as they
+// could reach other stores. Unless withMCP is set, the MCP servers are not started nor queried, and the
+// tools selected by name which may be theirs are left out and returned as skipped.
+func serverToolsOf(ctx context.Context, executor *tools.Executor, config *config.ModelConfig, input *schema.OpenAIRequest, withMCP bool) (res []schema.ServerTool, skipped []string, err error) {
+	available := config.ServerTools.Tools
+	if withMCP && len(config.ServerTools.MCPServers) > 0 {
+		available = append(slices.Clone(available), executor.MCPTools(ctx, config.ServerTools.MCPServers)...)
+	}
+	if input.ServerTools == nil {
+		return available, nil, nil
+	}
+
+	res = []schema.ServerTool{}
+	for _, name := range input.ServerTools {
+		t, exists := tools.Find(available, name)
+		if !exists && !withMCP && len(config.ServerTools.MCPServers) > 0 {
+			skipped = append(skipped, name)
+			continue
+		}
+		if !exists {
+			return nil, nil, fmt.Errorf("unknown server tool %q", name)
+		}
+		res = append(res, t)
+	}
+	return res, skipped, nil
+}
+
+// serverToolStep is a call of a server tool and its result
+type serverToolStep struct {
+	call   schema.ToolCall
+	result string
+}
+
+// serverToolsLoop runs the server tools called by the model, and generates the reply again
+// with their results until the model answers without calling them
+type serverToolsLoop struct {
+	executor    *tools.Executor
+	evaluator   *templates.Evaluator
+	tools       []schema.ServerTool
+	funcs       functions.Functions
+	shouldUseFn bool
+
+	// generate returns the output of the model for the prompt, and whether it timed out
+	generate func(req *schema.OpenAIRequest, prompt string) (string, backend.TokenUsage, bool, error)
+	// onStep is called with every tool run, if set
+	onStep func(step serverToolStep)
+}
+
+// calls returns the calls of the output if they are all calls of server tools
+func (l *serverToolsLoop) calls(config *config.ModelConfig, prompt, output string) []functions.FuncCallResults {
+	_, content := extractReasoning(config, prompt, output)
+	results := functions.ParseFunctionCall(functions.CleanupLLMResult(content, config.FunctionsConfig), config.FunctionsConfig)
+	for _, r := range results {
+		if _, ok := tools.Find(l.tools, r.Name); !ok {
+			// the client has to run the other calls, which are returned as they are
+			return nil
+		}
+	}
+	return results
+}
+
+// run returns the prompt and the output of the last generation. The output of the first
+// generation is the one of the prompt of the request.
+func (l *serverToolsLoop) run(input *schema.OpenAIRequest, config *config.ModelConfig, prompt, output string) (string, string, backend.TokenUsage, bool, error) {
+	usage := backend.TokenUsage{}
+	if len(l.tools) == 0 {
+		return prompt, output, usage, false, nil
+	}
+
+	maxIterations := config.ServerTools.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = tools.DefaultMaxIterations
+	}
+
+	messages := slices.Clone(input.Messages)
+	for iteration := 1; iteration < maxIterations; iteration++ {
+		calls := l.calls(config, prompt, output)
+		if len(calls) == 0 {
+			break
+		}
+
+		assistant := schema.Message{Role: "assistant"}
+		var results []schema.Message
+		for i, c := range calls {
+			tool, _ := tools.Find(l.tools, c.Name)
+			log.Debug().Str("tool", c.Name).Str("arguments", c.Arguments).Int("iteration", iteration).Msg("running server tool")
+
+			step := serverToolStep{
+				call: schema.ToolCall{
+					Index:        i,
+					ID:           uuid.New().String(),
+					Type:         "function",
+					FunctionCall: schema.FunctionCall{Name: c.Name, Arguments: c.Arguments},
+				},
+				result: l.executor.Run(input.Context, tool, c.Arguments),
+			}
+			if l.onStep != nil {
+				l.onStep(step)
+			}
+
+			assistant.ToolCalls = append(assistant.ToolCalls, step.call)
+			results = append(results, schema.Message{Role: "tool", Name: c.Name, ToolCallID: step.call.ID, Content: step.result, StringContent: step.result})
+		}
+		messages = append(append(messages, assistant), results...)
+
+		req := *input
+		req.N = 1
+		req.Messages = messages
+
+		var (
+			stepUsage backend.TokenUsage
+			timedOut  bool
+			err       error
+		)
+		prompt, err = l.evaluator.TemplateMessages(req, messages, config, l.funcs, l.shouldUseFn)
+		if err != nil {
+			return prompt, output, usage, false, err
+		}
+		prompt = skipReasoning(config, &req, prompt)
+		log.Debug().Msgf("Prompt (after server tools): %s", prompt)
+
+		output, stepUsage, timedOut, err = l.generate(&req, prompt)
+		addTokenUsage(&usage, stepUsage)
+		if err != nil || timedOut {
+			return prompt, output, usage, timedOut, err
+		}
+	}
+
+	return prompt, output, usage, false, nil
+}
@@ -0,0 +1,162 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/templates"
+	"github.com/mudler/LocalAI/core/tools"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server tools", func() {
+	const calculatorCall = `{"name": "calculator", "arguments": {"expression": "6 * 7"}}`
+
+	var (
+		cfg   *config.ModelConfig
+		input *schema.OpenAIRequest
+	)
+
+	BeforeEach(func() {
+		cfg = &config.ModelConfig{ServerTools: config.ServerToolsConfig{
+			Tools: []schema.ServerTool{
+				{Type: tools.CalculatorTool},
+				{Type: tools.StoreTool, Name: "search_docs", Store: "docs", EmbeddingModel: "bert"},
+			},
+		}}
+		input = &schema.OpenAIRequest{
+			Context:  context.Background(),
+			Messages: []schema.Message{{Role: "user", StringContent: "What is 6 times 7?"}},
+		}
+	})
+
+	Context("serverToolsOf()", func() {
+		It("returns the tools of the model configuration", func() {
+			Expect(serverToolsOf(input.Context, tools.NewExecutor(nil, nil, nil), cfg, input, true)).To(Equal(cfg.ServerTools.Tools))
+		})
+
+		It("returns the tools selected by the request", func() {
+			input.ServerTools = []string{"search_docs"}
+			Expect(serverToolsOf(input.Context, tools.NewExecutor(nil, nil, nil), cfg, input, true)).To(Equal(cfg.ServerTools.Tools[1:]))
+
+			input.ServerTools = []string{}
+			Expect(serverToolsOf(input.Context, tools.NewExecutor(nil, nil, nil), cfg, input, true)).To(BeEmpty())
+		})
+
+		It("fails for tools which are not in the model configuration", func() {
+			input.ServerTools = []string{"calculator", "http"}
+			_, _, err := serverToolsOf(input.Context, tools.NewExecutor(nil, nil, nil), cfg, input, true)
+			Expect(err).To(MatchError(`unknown server tool "http"`))
+		})
+
+		It("leaves out the tools of the MCP servers without querying them", func() {
+			// querying the MCP servers would panic with a nil executor
+			cfg.ServerTools.MCPServers = []schema.MCPServer{{Name: "github", Command: "github-mcp-server"}}
+			Expect(serverToolsOf(input.Context, nil, cfg, input, false)).To(Equal(cfg.ServerTools.Tools))
+
+			input.ServerTools = []string{"calculator", "github_search"}
+			serverTools, skipped, err := serverToolsOf(input.Context, nil, cfg, input, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serverTools).To(Equal(cfg.ServerTools.Tools[:1]))
+			Expect(skipped).To(Equal([]string{"github_search"}))
+		})
+	})
+
+	Context("run()", func() {
+		var (
+			loop     *serverToolsLoop
+			requests []*schema.OpenAIRequest
+			outputs  []string
+			steps    []serverToolStep
+		)
+
+		BeforeEach(func() {
+			requests, outputs, steps = nil, nil, nil
+			loop = &serverToolsLoop{
+				executor:    tools.NewExecutor(nil, nil, nil),
+				evaluator:   templates.NewEvaluator(GinkgoT().TempDir()),
+				tools:       cfg.ServerTools.Tools,
+				shouldUseFn: true,
+				generate: func(req *schema.OpenAIRequest, prompt string) (string, backend.TokenUsage, bool, error) {
+					requests = append(requests, req)
+					output := outputs[0]
+					outputs = outputs[1:]
+					return output, backend.TokenUsage{Prompt: 10, Completion: 5}, false, nil
+				},
+				onStep: func(step serverToolStep) {
+					steps = append(steps, step)
+				},
+			}
+		})
+
+		It("generates again with the results of the tools", func() {
+			outputs = []string{"The answer is 42."}
+
+			_, output, usage, timedOut, err := loop.run(input, cfg, "prompt", calculatorCall)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(timedOut).To(BeFalse())
+			Expect(output).To(Equal("The answer is 42."))
+			Expect(usage.Completion).To(Equal(5))
+
+			Expect(steps).To(HaveLen(1))
+			Expect(steps[0].call.FunctionCall.Name).To(Equal("calculator"))
+			Expect(steps[0].result).To(Equal("42"))
+
+			Expect(requests).To(HaveLen(1))
+			messages := requests[0].Messages
+			Expect(messages).To(HaveLen(3))
+			Expect(messages[1].Role).To(Equal("assistant"))
+			Expect(messages[1].ToolCalls).To(HaveLen(1))
+			Expect(messages[2].Role).To(Equal("tool"))
+			Expect(messages[2].StringContent).To(Equal("42"))
+		})
+
+		It("passes the errors of the tools back to the model", func() {
+			outputs = []string{"Sorry, I could not compute it."}
+
+			_, output, _, _, err := loop.run(input, cfg, "prompt", `{"name": "calculator", "arguments": {"expression": "6 *"}}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal("Sorry, I could not compute it."))
+
+			Expect(requests).To(HaveLen(1))
+			result := requests[0].Messages[2]
+			Expect(result.Role).To(Equal("tool"))
+			Expect(result.StringContent).To(HavePrefix("Error: "))
+		})
+
+		It("stops after the maximum number of iterations", func() {
+			cfg.ServerTools.MaxIterations = 3
+			outputs = []string{calculatorCall, calculatorCall, calculatorCall}
+
+			_, output, usage, _, err := loop.run(input, cfg, "prompt", calculatorCall)
+			Expect(err).ToNot(HaveOccurred())
+			// the first generation is the one of the request
+			Expect(requests).To(HaveLen(2))
+			Expect(steps).To(HaveLen(2))
+			Expect(usage.Completion).To(Equal(10))
+			// the calls of the last generation are returned as they are
+			Expect(output).To(Equal(calculatorCall))
+			Expect(outputs).To(HaveLen(1))
+		})
+
+		It("returns the calls of client tools as they are", func() {
+			output := `{"name": "get_weather", "arguments": {"city": "Rome"}}`
+			_, res, _, _, err := loop.run(input, cfg, "prompt", output)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(output))
+			Expect(requests).To(BeEmpty())
+		})
+
+		It("does nothing without server tools", func() {
+			loop.tools = nil
+			_, res, _, _, err := loop.run(input, cfg, "prompt", calculatorCall)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(calculatorCall))
+			Expect(requests).To(BeEmpty())
+		})
+	})
+})
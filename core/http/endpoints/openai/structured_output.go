@@ -0,0 +1,102 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/rs/zerolog/log"
+)
+
+const structuredOutputFeedback = "The previous response does not match the required JSON schema: %s. Reply again with only a JSON document matching the schema."
+
+// jsonSchemaResponseFormat returns the json_schema response format of the request, if any
+func jsonSchemaResponseFormat(config *config.ModelConfig) (*schema.JsonSchema, error) {
+	if config.ResponseFormatMap == nil {
+		return nil, nil
+	}
+
+	d := schema.JsonSchemaRequest{}
+	dat, err := json.Marshal(config.ResponseFormatMap)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(dat, &d); err != nil {
+		return nil, err
+	}
+	if d.Type != "json_schema" || d.JsonSchema.Schema == nil {
+		return nil, nil
+	}
+	return &d.JsonSchema, nil
+}
+
+// strictJSONSchema returns the schema that outputs must be validated against, if the request
+// has a strict json_schema response format
+func strictJSONSchema(config *config.ModelConfig) map[string]interface{} {
+	js, err := jsonSchemaResponseFormat(config)
+	if err != nil || js == nil || !js.Strict {
+		return nil
+	}
+	return js.Schema
+}
+
+// enforceJSONSchema validates output against the schema. While it does not match, the model is asked
+// to fix it with retry, up to the configured number of times. If the output still does not match,
+// a *functions.SchemaValidationError is returned.
+func enforceJSONSchema(config *config.ModelConfig, jsonSchema map[string]interface{}, output string,
+	retry func(previous, feedback string) (string, backend.TokenUsage, error)) (string, backend.TokenUsage, error) {
+	usage := backend.TokenUsage{}
+
+	for attempt := 0; ; attempt++ {
+		err := functions.ValidateJSONSchema(jsonSchema, output)
+		if err == nil {
+			return output, usage, nil
+		}
+		log.Debug().Err(err).Int("attempt", attempt).Msg("output does not match the JSON schema")
+		if attempt >= config.StructuredOutput.Retries {
+			return output, usage, err
+		}
+
+		var retryUsage backend.TokenUsage
+		output, retryUsage, err = retry(output, fmt.Sprintf(structuredOutputFeedback, err.Error()))
+		addTokenUsage(&usage, retryUsage)
+		if err != nil {
+			return "", usage, err
+		}
+	}
+}
+
+func addTokenUsage(usage *backend.TokenUsage, u backend.TokenUsage) {
+	usage.Prompt += u.Prompt
+	usage.Completion += u.Completion
+	usage.TimingPromptProcessing += u.TimingPromptProcessing
+	usage.TimingTokenGeneration += u.TimingTokenGeneration
+}
+
+// structuredOutputRefusal returns the refusal to reply with when the output does not match the schema,
+// or the error failing the request if the model is configured to do so
+func structuredOutputRefusal(config *config.ModelConfig, err error) (string, error) {
+	var validationErr *functions.SchemaValidationError
+	if !errors.As(err, &validationErr) {
+		return "", err
+	}
+
+	if config.StructuredOutput.OnFailure == "error" {
+		return "", structuredOutputError(validationErr)
+	}
+	return fmt.Sprintf("The model output does not match the JSON schema: %s", validationErr.Error()), nil
+}
+
+// structuredOutputError returns the error failing requests whose output does not match the schema
+func structuredOutputError(err error) error {
+	var validationErr *functions.SchemaValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	return fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("The model output does not match the JSON schema: %s", validationErr.Error()))
+}
@@ -0,0 +1,108 @@
+package openai
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/functions"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Structured outputs", func() {
+	jsonSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"answer": map[string]interface{}{"type": "integer"},
+		},
+	}
+
+	// retryWith returns a retry function replying with the outputs in order, recording the feedback
+	retryWith := func(feedback *[]string, outputs ...string) func(previous, f string) (string, backend.TokenUsage, error) {
+		return func(previous, f string) (string, backend.TokenUsage, error) {
+			*feedback = append(*feedback, f)
+			output := outputs[0]
+			outputs = outputs[1:]
+			return output, backend.TokenUsage{Prompt: 10, Completion: 5}, nil
+		}
+	}
+
+	Context("enforceJSONSchema()", func() {
+		It("returns valid outputs as they are", func() {
+			var feedback []string
+			cfg := &config.ModelConfig{StructuredOutput: config.StructuredOutput{Retries: 2}}
+
+			output, usage, err := enforceJSONSchema(cfg, jsonSchema, `{"answer": 42}`, retryWith(&feedback))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal(`{"answer": 42}`))
+			Expect(usage).To(Equal(backend.TokenUsage{}))
+			Expect(feedback).To(BeEmpty())
+		})
+
+		It("asks the model to fix invalid outputs", func() {
+			var feedback []string
+			cfg := &config.ModelConfig{StructuredOutput: config.StructuredOutput{Retries: 2}}
+
+			output, usage, err := enforceJSONSchema(cfg, jsonSchema, `{"answer": "42"}`, retryWith(&feedback, `{"answer": 42}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal(`{"answer": 42}`))
+			Expect(usage.Prompt).To(Equal(10))
+			Expect(usage.Completion).To(Equal(5))
+			Expect(feedback).To(HaveLen(1))
+			Expect(feedback[0]).To(ContainSubstring("$.answer: must be of type integer, got string"))
+		})
+
+		It("fails once the retries are exhausted", func() {
+			var feedback []string
+			cfg := &config.ModelConfig{StructuredOutput: config.StructuredOutput{Retries: 2}}
+
+			output, usage, err := enforceJSONSchema(cfg, jsonSchema, `{}`, retryWith(&feedback, `{"answer": 1.5}`, `{"answer": 1, "extra": true}`))
+			Expect(err).To(BeAssignableToTypeOf(&functions.SchemaValidationError{}))
+			Expect(err.Error()).To(ContainSubstring(`unexpected property "extra"`))
+			Expect(output).To(Equal(`{"answer": 1, "extra": true}`))
+			Expect(usage.Completion).To(Equal(10))
+			Expect(feedback).To(HaveLen(2))
+		})
+
+		It("does not retry by default", func() {
+			var feedback []string
+			_, _, err := enforceJSONSchema(&config.ModelConfig{}, jsonSchema, `{}`, retryWith(&feedback))
+			Expect(err).To(BeAssignableToTypeOf(&functions.SchemaValidationError{}))
+			Expect(feedback).To(BeEmpty())
+		})
+
+		It("returns the errors of the retries", func() {
+			cfg := &config.ModelConfig{StructuredOutput: config.StructuredOutput{Retries: 1}}
+			_, _, err := enforceJSONSchema(cfg, jsonSchema, `{}`, func(previous, feedback string) (string, backend.TokenUsage, error) {
+				return "", backend.TokenUsage{}, errors.New("backend failure")
+			})
+			Expect(err).To(MatchError("backend failure"))
+		})
+	})
+
+	Context("structuredOutputRefusal()", func() {
+		validationErr := &functions.SchemaValidationError{Errors: []string{`$: missing required property "answer"`}}
+
+		It("replies with a refusal when the retries are exhausted", func() {
+			refusal, err := structuredOutputRefusal(&config.ModelConfig{}, validationErr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(refusal).To(Equal(`The model output does not match the JSON schema: $: missing required property "answer"`))
+		})
+
+		It("fails the request if configured to", func() {
+			cfg := &config.ModelConfig{StructuredOutput: config.StructuredOutput{OnFailure: "error"}}
+			_, err := structuredOutputRefusal(cfg, validationErr)
+			var fiberErr *fiber.Error
+			Expect(errors.As(err, &fiberErr)).To(BeTrue())
+			Expect(fiberErr.Code).To(Equal(fiber.StatusUnprocessableEntity))
+		})
+
+		It("returns other errors as they are", func() {
+			_, err := structuredOutputRefusal(&config.ModelConfig{}, errors.New("backend failure"))
+			Expect(err).To(MatchError("backend failure"))
+		})
+	})
+})
@@ -0,0 +1,474 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/store"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultListLimit     = 20
+	maxListLimit         = 100
+	defaultSearchResults = 10
+	maxSearchResults     = 50
+)
+
+// vectorStoreError fails the request with a 404 for the vector stores and files which do not exist
+func vectorStoreError(err error) error {
+	if errors.Is(err, services.ErrVectorStoreNotFound) || errors.Is(err, services.ErrVectorStoreFileNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return err
+}
+
+// paginate returns the page of the items, which are sorted from the most recent, asked by the limit, order, after
+// and before query parameters of the OpenAI list endpoints, and whether there are more items after it
+func paginate[T any](c *fiber.Ctx, items []T, id func(T) string) ([]T, bool, error) {
+	limit := defaultListLimit
+	if l := c.Query("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > maxListLimit {
+			return nil, false, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+		}
+	}
+
+	switch c.Query("order", "desc") {
+	case "desc":
+	case "asc":
+		items = slices.Clone(items)
+		slices.Reverse(items)
+	default:
+		return nil, false, fiber.NewError(fiber.StatusBadRequest, "order must be asc or desc")
+	}
+
+	if after := c.Query("after"); after != "" {
+		i := slices.IndexFunc(items, func(item T) bool { return id(item) == after })
+		items = items[i+1:]
+	}
+	if before := c.Query("before"); before != "" {
+		if i := slices.IndexFunc(items, func(item T) bool { return id(item) == before }); i >= 0 {
+			items = items[max(0, i-limit):i]
+		}
+	}
+
+	if len(items) > limit {
+		return items[:limit], true, nil
+	}
+	return items, false, nil
+}
+
+// defaultEmbeddingModel returns the first model configured for embeddings
+func defaultEmbeddingModel(cl *config.ModelConfigLoader) (string, error) {
+	models := cl.GetModelConfigsByFilter(config.BuildUsecaseFilterFn(config.FLAG_EMBEDDINGS))
+	if len(models) == 0 {
+		return "", fiber.NewError(fiber.StatusBadRequest, "no embedding model is configured, set embedding_model")
+	}
+	return models[0].Name, nil
+}
+
+// storeFilter converts the filters of the OpenAI vector stores, such as {"type": "eq", "key": "lang", "value": "en"}
+// or {"type": "and", "filters": [...]}, to the filters of the stores, see pkg/store.Filter
+func storeFilter(f map[string]interface{}) (map[string]interface{}, error) {
+	t, _ := f["type"].(string)
+	switch t {
+	case "and", "or":
+		filters, ok := f["filters"].([]interface{})
+		if !ok || len(filters) == 0 {
+			return nil, fmt.Errorf("%s filters must have a non-empty array of filters", t)
+		}
+		converted := make([]interface{}, len(filters))
+		for i, sub := range filters {
+			subFilter, ok := sub.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s filters must have a non-empty array of filters", t)
+			}
+			c, err := storeFilter(subFilter)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = c
+		}
+		return map[string]interface{}{"$" + t: converted}, nil
+	case "eq", "ne", "gt", "gte", "lt", "lte", "in", "nin":
+		key, ok := f["key"].(string)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s filters must have a key", t)
+		}
+		return map[string]interface{}{key: map[string]interface{}{"$" + t: f["value"]}}, nil
+	}
+	return nil, fmt.Errorf("unknown filter type %q", t)
+}
+
+// searchQueries returns the queries of a search, which is either a string or an array of strings
+func searchQueries(query interface{}) ([]string, error) {
+	switch q := query.(type) {
+	case string:
+		if q != "" {
+			return []string{q}, nil
+		}
+	case []interface{}:
+		queries := make([]string, 0, len(q))
+		for _, s := range q {
+			str, ok := s.(string)
+			if !ok {
+				return nil, fmt.Errorf("the query must be a string or an array of strings")
+			}
+			queries = append(queries, str)
+		}
+		if len(queries) > 0 {
+			return queries, nil
+		}
+	}
+	return nil, fmt.Errorf("the query must be a string or an array of strings")
+}
+
+// CreateVectorStoreEndpoint https://platform.openai.com/docs/api-reference/vector-stores/create
+// @Summary Creates a vector store, backed by a local store, embedding the chunks of its files with an embedding model
+// @Param request body schema.VectorStoreCreateRequest true "query params"
+// @Success 200 {object} schema.VectorStore "Response"
+// @Router /v1/vector_stores [post]
+func CreateVectorStoreEndpoint(cl *config.ModelConfigLoader, vectorStores *services.VectorStoresService, filesService *services.FilesService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.VectorStoreCreateRequest)
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+
+		if input.EmbeddingModel == "" {
+			var err error
+			if input.EmbeddingModel, err = defaultEmbeddingModel(cl); err != nil {
+				return err
+			}
+		} else if _, exists := cl.GetModelConfig(input.EmbeddingModel); !exists {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("no model named %s", input.EmbeddingModel))
+		}
+		if _, _, err := services.ChunkingCharacters(input.ChunkingStrategy); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		files := make([]schema.File, len(input.FileIDs))
+		for i, id := range input.FileIDs {
+			file, ok := filesService.Get(id)
+			if !ok {
+				return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("no file with ID %s", id))
+			}
+			files[i] = file
+		}
+
+		vs, err := vectorStores.Create(input.Name, input.Metadata, input.EmbeddingModel, input.ChunkingStrategy)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			data, err := filesService.Content(file.ID)
+			if err != nil {
+				return err
+			}
+			if _, err := vectorStores.AddFile(vs.ID, file, data, nil, nil); err != nil {
+				return err
+			}
+		}
+
+		if vs, err = vectorStores.Get(vs.ID); err != nil {
+			return vectorStoreError(err)
+		}
+		return c.JSON(vs)
+	}
+}
+
+// ListVectorStoresEndpoint https://platform.openai.com/docs/api-reference/vector-stores/list
+// @Summary Lists the vector stores
+// @Success 200 {object} schema.VectorStoreList "Response"
+// @Router /v1/vector_stores [get]
+func ListVectorStoresEndpoint(vectorStores *services.VectorStoresService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		stores, hasMore, err := paginate(c, vectorStores.List(), func(vs schema.VectorStore) string { return vs.ID })
+		if err != nil {
+			return err
+		}
+
+		res := schema.VectorStoreList{Object: "list", Data: stores, HasMore: hasMore}
+		if len(stores) > 0 {
+			res.FirstID, res.LastID = stores[0].ID, stores[len(stores)-1].ID
+		}
+		return c.JSON(res)
+	}
+}
+
+// GetVectorStoreEndpoint https://platform.openai.com/docs/api-reference/vector-stores/retrieve
+// @Summary Returns a vector store
+// @Param vector_store_id path string true "Vector store ID"
+// @Success 200 {object} schema.VectorStore "Response"
+// @Router /v1/vector_stores/{vector_store_id} [get]
+func GetVectorStoreEndpoint(vectorStores *services.VectorStoresService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		vs, err := vectorStores.Get(c.Params("vector_store_id"))
+		if err != nil {
+			return vectorStoreError(err)
+		}
+		return c.JSON(vs)
+	}
+}
+
+// DeleteVectorStoreEndpoint https://platform.openai.com/docs/api-reference/vector-stores/delete
+// @Summary Deletes a vector store along with its local store, the files attached to it are kept
+// @Param vector_store_id path string true "Vector store ID"
+// @Success 200 {object} schema.DeletionStatus "Response"
+// @Router /v1/vector_stores/{vector_store_id} [delete]
+func DeleteVectorStoreEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, vectorStores *services.VectorStoresService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("vector_store_id")
+		if _, err := vectorStores.Get(id); err != nil {
+			return vectorStoreError(err)
+		}
+
+		sb, err := backend.StoreBackend(ml, cl, appConfig, id, "")
+		if err != nil {
+			return err
+		}
+		err = store.Drop(c.Context(), sb)
+		ml.Close()
+		if err != nil {
+			return err
+		}
+		// each store has its own process, which is not needed anymore
+		if err := ml.ShutdownModel(id); err != nil {
+			log.Warn().Err(err).Str("store", id).Msg("failed to stop the store of the deleted vector store")
+		}
+
+		if err := vectorStores.Delete(id); err != nil {
+			return vectorStoreError(err)
+		}
+		return c.JSON(schema.DeletionStatus{ID: id, Object: "vector_store.deleted", Deleted: true})
+	}
+}
+
+// CreateVectorStoreFileEndpoint https://platform.openai.com/docs/api-reference/vector-stores-files/createFile
+// @Summary Attaches an uploaded file to a vector store, its chunks are embedded and set in the store in the background
+// @Param vector_store_id path string true "Vector store ID"
+// @Param request body schema.VectorStoreFileCreateRequest true "query params"
+// @Success 200 {object} schema.VectorStoreFile "Response"
+// @Router /v1/vector_stores/{vector_store_id}/files [post]
+func CreateVectorStoreFileEndpoint(vectorStores *services.VectorStoresService, filesService *services.FilesService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.VectorStoreFileCreateRequest)
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+
+		id := c.Params("vector_store_id")
+		if _, err := vectorStores.Get(id); err != nil {
+			return vectorStoreError(err)
+		}
+		if _, _, err := services.ChunkingCharacters(input.ChunkingStrategy); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		file, ok := filesService.Get(input.FileID)
+		if !ok {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("no file with ID %s", input.FileID))
+		}
+		data, err := filesService.Content(file.ID)
+		if err != nil {
+			return err
+		}
+
+		f, err := vectorStores.AddFile(id, file, data, input.Attributes, input.ChunkingStrategy)
+		if err != nil {
+			return vectorStoreError(err)
+		}
+		return c.JSON(f)
+	}
+}
+
+// ListVectorStoreFilesEndpoint https://platform.openai.com/docs/api-reference/vector-stores-files/listFiles
+// @Summary Lists the files attached to a vector store
+// @Param vector_store_id path string true "Vector store ID"
+// @Param filter query string false "only lists the files with this status"
+// @Success 200 {object} schema.VectorStoreFileList "Response"
+// @Router /v1/vector_stores/{vector_store_id}/files [get]
+func ListVectorStoreFilesEndpoint(vectorStores *services.VectorStoresService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		files, err := vectorStores.ListFiles(c.Params("vector_store_id"), c.Query("filter"))
+		if err != nil {
+			return vectorStoreError(err)
+		}
+		files, hasMore, err := paginate(c, files, func(f schema.VectorStoreFile) string { return f.ID })
+		if err != nil {
+			return err
+		}
+
+		res := schema.VectorStoreFileList{Object: "list", Data: files, HasMore: hasMore}
+		if len(files) > 0 {
+			res.FirstID, res.LastID = files[0].ID, files[len(files)-1].ID
+		}
+		return c.JSON(res)
+	}
+}
+
+// GetVectorStoreFileEndpoint https://platform.openai.com/docs/api-reference/vector-stores-files/getFile
+// @Summary Returns a file attached to a vector store, with the status of its ingestion
+// @Param vector_store_id path string true "Vector store ID"
+// @Param file_id path string true "File ID"
+// @Success 200 {object} schema.VectorStoreFile "Response"
+// @Router /v1/vector_stores/{vector_store_id}/files/{file_id} [get]
+func GetVectorStoreFileEndpoint(vectorStores *services.VectorStoresService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		f, err := vectorStores.GetFile(c.Params("vector_store_id"), c.Params("file_id"))
+		if err != nil {
+			return vectorStoreError(err)
+		}
+		return c.JSON(f)
+	}
+}
+
+// DeleteVectorStoreFileEndpoint https://platform.openai.com/docs/api-reference/vector-stores-files/deleteFile
+// @Summary Detaches a file from a vector store, deleting its chunks from the store
+// @Param vector_store_id path string true "Vector store ID"
+// @Param file_id path string true "File ID"
+// @Success 200 {object} schema.DeletionStatus "Response"
+// @Router /v1/vector_stores/{vector_store_id}/files/{file_id} [delete]
+func DeleteVectorStoreFileEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, vectorStores *services.VectorStoresService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id, fileID := c.Params("vector_store_id"), c.Params("file_id")
+		if err := vectorStores.DeleteFile(id, fileID); err != nil {
+			return vectorStoreError(err)
+		}
+
+		filter, err := json.Marshal(map[string]interface{}{"document_id": fileID})
+		if err != nil {
+			return err
+		}
+		sb, err := backend.StoreBackend(ml, cl, appConfig, id, "")
+		if err != nil {
+			return err
+		}
+		defer ml.Close()
+		if err := store.DeleteFiltered(c.Context(), sb, filter); err != nil {
+			return err
+		}
+
+		return c.JSON(schema.DeletionStatus{ID: fileID, Object: "vector_store.file.deleted", Deleted: true})
+	}
+}
+
+// SearchVectorStoreEndpoint https://platform.openai.com/docs/api-reference/vector-stores/search
+// @Summary Searches the chunks of the files of a vector store most similar to a query
+// @Param vector_store_id path string true "Vector store ID"
+// @Param request body schema.VectorStoreSearchRequest true "query params"
+// @Success 200 {object} schema.VectorStoreSearchResponse "Response"
+// @Router /v1/vector_stores/{vector_store_id}/search [post]
+func SearchVectorStoreEndpoint(cl *config.ModelConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, vectorStores *services.VectorStoresService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.VectorStoreSearchRequest)
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+
+		id := c.Params("vector_store_id")
+		vs, err := vectorStores.Get(id)
+		if err != nil {
+			return vectorStoreError(err)
+		}
+
+		queries, err := searchQueries(input.Query)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		maxResults := input.MaxNumResults
+		if maxResults == 0 {
+			maxResults = defaultSearchResults
+		}
+		if maxResults < 1 || maxResults > maxSearchResults {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("max_num_results must be between 1 and %d", maxSearchResults))
+		}
+
+		var opts []store.Option
+		if input.Filters != nil {
+			f, err := storeFilter(input.Filters)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			filter, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			if _, err := store.ParseFilter(filter); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			opts = append(opts, store.WithFilter(filter))
+		}
+
+		// the queries are embedded before the store is loaded, as the model loader may only hold one backend at a time
+		embedder, err := backend.NewStoreEmbedderWithConfig(ml, cl, appConfig, config.StoreConfig{EmbeddingModel: vs.EmbeddingModel})
+		if err != nil {
+			return err
+		}
+		keys, err := embedder.Embed([]string{strings.Join(queries, "\n")})
+		if err != nil {
+			return err
+		}
+
+		sb, err := backend.StoreBackend(ml, cl, appConfig, id, "")
+		if err != nil {
+			return err
+		}
+		_, vals, metadata, similarities, err := store.FindWithMetadata(c.Context(), sb, keys[0], maxResults, opts...)
+		ml.Close()
+		if err != nil {
+			return err
+		}
+
+		var threshold float32
+		if input.RankingOptions != nil {
+			threshold = input.RankingOptions.ScoreThreshold
+		}
+		// the chunks of the files which are detached while they are ingested may still be in the store
+		attached := vectorStores.FileNames(id)
+
+		res := schema.VectorStoreSearchResponse{
+			Object:      "vector_store.search_results.page",
+			SearchQuery: queries,
+			Data:        []schema.VectorStoreSearchResult{},
+		}
+		for i, v := range vals {
+			var attributes map[string]interface{}
+			if len(metadata[i]) > 0 {
+				if err := json.Unmarshal(metadata[i], &attributes); err != nil {
+					return err
+				}
+			}
+			fileID, _ := attributes["document_id"].(string)
+			filename, ok := attached[fileID]
+			if !ok || similarities[i] < threshold {
+				continue
+			}
+			delete(attributes, "document_id")
+			delete(attributes, "file_name")
+			delete(attributes, "chunk")
+
+			res.Data = append(res.Data, schema.VectorStoreSearchResult{
+				FileID:     fileID,
+				Filename:   filename,
+				Score:      similarities[i],
+				Attributes: attributes,
+				Content:    []schema.VectorStoreSearchContent{{Type: "text", Text: string(v)}},
+			})
+		}
+
+		vectorStores.Touch(id)
+		return c.JSON(res)
+	}
+}
@@ -0,0 +1,48 @@
+package openai
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Vector stores", func() {
+	It("converts the attribute filters to store filters", func() {
+		filter, err := storeFilter(map[string]interface{}{
+			"type": "and",
+			"filters": []interface{}{
+				map[string]interface{}{"type": "eq", "key": "lang", "value": "en"},
+				map[string]interface{}{"type": "or", "filters": []interface{}{
+					map[string]interface{}{"type": "gte", "key": "year", "value": 2020.0},
+					map[string]interface{}{"type": "in", "key": "team", "value": []interface{}{"infra", "ml"}},
+				}},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(filter).To(Equal(map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"lang": map[string]interface{}{"$eq": "en"}},
+			map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"year": map[string]interface{}{"$gte": 2020.0}},
+				map[string]interface{}{"team": map[string]interface{}{"$in": []interface{}{"infra", "ml"}}},
+			}},
+		}}))
+
+		_, err = storeFilter(map[string]interface{}{"type": "eq", "value": "en"})
+		Expect(err).To(HaveOccurred())
+		_, err = storeFilter(map[string]interface{}{"type": "or", "filters": []interface{}{}})
+		Expect(err).To(HaveOccurred())
+		_, err = storeFilter(map[string]interface{}{"type": "like", "key": "lang"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("accepts a query or an array of queries", func() {
+		Expect(searchQueries("capital of France")).To(Equal([]string{"capital of France"}))
+		Expect(searchQueries([]interface{}{"France", "capital"})).To(Equal([]string{"France", "capital"}))
+
+		_, err := searchQueries("")
+		Expect(err).To(HaveOccurred())
+		_, err = searchQueries([]interface{}{"France", 1.0})
+		Expect(err).To(HaveOccurred())
+		_, err = searchQueries(nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// FallbackModelHeader is set on responses served by a fallback model
+const FallbackModelHeader = "X-LocalAI-Fallback-Model"
+
+// resolveFallback returns the config of the fallback model if the requested
+// model is not resident yet and does not finish loading within its threshold.
+// The model of the request is then replaced by the fallback, so that the response
+// reports the model which served it.
+// Loading of the requested model continues in the background, so that
+// subsequent requests are served by it as soon as it is ready.
+func (re *RequestExtractor) resolveFallback(ctx *fiber.Ctx, input schema.LocalAIRequest, cfg *config.ModelConfig) *config.ModelConfig {
+	if cfg == nil || cfg.FallbackModel == "" || cfg.FallbackModel == cfg.Name {
+		return cfg
+	}
+
+	// With a single active backend, loading the fallback would stop the model
+	// we are waiting for
+	if re.applicationConfig.SingleBackend {
+		return cfg
+	}
+
+	if re.modelLoader.IsLoaded(cfg.Name) {
+		return cfg
+	}
+
+	// Concurrent requests join the load started by the first one
+	done := re.modelLoader.BackgroundLoad(backend.ModelOptions(*cfg, re.applicationConfig)...)
+
+	select {
+	case <-done:
+		return cfg
+	case <-time.After(cfg.GetFallbackThreshold()):
+	}
+
+	fallbackCfg, err := re.modelConfigLoader.LoadModelConfigFileByNameDefaultOptions(cfg.FallbackModel, re.applicationConfig)
+	if err != nil {
+		log.Warn().Err(err).Str("model", cfg.Name).Msgf("unable to load fallback model config %q, waiting for the model", cfg.FallbackModel)
+		<-done
+		return cfg
+	}
+	if fallbackCfg.Model == "" {
+		fallbackCfg.Model = cfg.FallbackModel
+	}
+
+	fallbackName := fallbackCfg.Name
+	if fallbackName == "" {
+		fallbackName = cfg.FallbackModel
+	}
+	log.Info().Str("model", cfg.Name).Str("fallback", fallbackName).Msg("model is still loading, serving request with the fallback model")
+	ctx.Set(FallbackModelHeader, fallbackName)
+	input.ModelName(&fallbackName)
+
+	return fallbackCfg
+}
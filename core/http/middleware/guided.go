@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions/grammars"
+)
+
+// applyGuidedDecoding compiles the guided_regex, guided_choice and guided_grammar
+// options of the request to a grammar, and keeps them for the backends supporting them natively.
+// They can't be combined with the options constraining the output with their own grammar,
+// a JSON response_format or tools, as one of them would be silently ignored.
+func applyGuidedDecoding(cfg *config.ModelConfig, input *schema.OpenAIRequest) error {
+	set := 0
+	for _, isSet := range []bool{input.GuidedRegex != "", len(input.GuidedChoice) > 0, input.GuidedGrammar != "", input.Grammar != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set > 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "only one of grammar, guided_regex, guided_choice and guided_grammar can be set")
+	}
+
+	guided := input.GuidedRegex != "" || len(input.GuidedChoice) > 0 || input.GuidedGrammar != ""
+	if guided && jsonResponseFormat(input.ResponseFormat) {
+		return fiber.NewError(fiber.StatusBadRequest, "guided_regex, guided_choice and guided_grammar can't be combined with a json response_format")
+	}
+	if guided && (len(input.Tools) > 0 || len(input.Functions) > 0) {
+		return fiber.NewError(fiber.StatusBadRequest, "guided_regex, guided_choice and guided_grammar can't be combined with tools or functions")
+	}
+
+	switch {
+	case input.GuidedRegex != "":
+		g, err := grammars.RegexGrammar(input.GuidedRegex)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid guided_regex: %s", err))
+		}
+		input.Grammar = g
+	case len(input.GuidedChoice) > 0:
+		g, err := grammars.ChoiceGrammar(input.GuidedChoice)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid guided_choice: %s", err))
+		}
+		input.Grammar = g
+	case input.GuidedGrammar != "":
+		input.Grammar = input.GuidedGrammar
+	}
+
+	cfg.GuidedRegex = input.GuidedRegex
+	cfg.GuidedChoice = input.GuidedChoice
+	cfg.GuidedGrammar = input.GuidedGrammar
+	return nil
+}
+
+// jsonResponseFormat returns true if the response_format of the request asks for JSON, which is
+// enforced with a grammar
+func jsonResponseFormat(responseFormat interface{}) bool {
+	switch rf := responseFormat.(type) {
+	case string:
+		return rf == "json_object" || rf == "json_schema"
+	case map[string]interface{}:
+		t, _ := rf["type"].(string)
+		return t == "json_object" || t == "json_schema"
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyGuidedDecoding(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input schema.OpenAIRequest
+		error string
+	}{
+		{
+			name:  "guided choice",
+			input: schema.OpenAIRequest{GuidedChoice: []string{"yes", "no"}},
+		},
+		{
+			name:  "guided regex with a text response format",
+			input: schema.OpenAIRequest{GuidedRegex: "[0-9]+", ResponseFormat: map[string]interface{}{"type": "text"}},
+		},
+		{
+			name:  "grammar with a json response format",
+			input: schema.OpenAIRequest{Grammar: "root ::= \"a\"", ResponseFormat: "json_object"},
+		},
+		{
+			name:  "guided regex and grammar",
+			input: schema.OpenAIRequest{GuidedRegex: "[0-9]+", Grammar: "root ::= \"a\""},
+			error: "only one of grammar, guided_regex, guided_choice and guided_grammar can be set",
+		},
+		{
+			name: "guided choice with a json schema",
+			input: schema.OpenAIRequest{GuidedChoice: []string{"yes", "no"}, ResponseFormat: map[string]interface{}{
+				"type": "json_schema", "json_schema": map[string]interface{}{"name": "answer"},
+			}},
+			error: "guided_regex, guided_choice and guided_grammar can't be combined with a json response_format",
+		},
+		{
+			name:  "guided grammar with tools",
+			input: schema.OpenAIRequest{GuidedGrammar: "root ::= \"a\"", Tools: []functions.Tool{{Type: "function", Function: functions.Function{Name: "search"}}}},
+			error: "guided_regex, guided_choice and guided_grammar can't be combined with tools or functions",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.ModelConfig{}
+			err := applyGuidedDecoding(cfg, &tc.input)
+			if tc.error != "" {
+				require.EqualError(t, err, tc.error)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, tc.input.Grammar)
+		})
+	}
+}
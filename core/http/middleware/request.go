@@ -129,14 +129,32 @@ func (re *RequestExtractor) SetModelAndConfig(initializer func() schema.LocalAIR
 			}
 		}
 
-		cfg, err := re.modelConfigLoader.LoadModelConfigFileByNameDefaultOptions(input.ModelName(nil), re.applicationConfig)
+		// Model routes (aliases) are served by one of their targets. The request keeps the
+		// name of the route, which is what the response reports.
+		modelName := input.ModelName(nil)
+		if modelName != "" {
+			target, err := re.resolveModelRoute(ctx, modelName)
+			if err != nil {
+				return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+			}
+			modelName = target
+		}
+
+		cfg, err := re.modelConfigLoader.LoadModelConfigFileByNameDefaultOptions(modelName, re.applicationConfig)
 
 		if err != nil {
 			log.Err(err)
-			log.Warn().Msgf("Model Configuration File not found for %q", input.ModelName(nil))
-		} else if cfg.Model == "" && input.ModelName(nil) != "" {
-			log.Debug().Str("input.ModelName", input.ModelName(nil)).Msg("config does not include model, using input")
-			cfg.Model = input.ModelName(nil)
+			log.Warn().Msgf("Model Configuration File not found for %q", modelName)
+		} else if cfg.Model == "" && modelName != "" {
+			log.Debug().Str("input.ModelName", modelName).Msg("config does not include model, using input")
+			cfg.Model = modelName
+		}
+
+		if err == nil {
+			cfg = re.resolveFallback(ctx, input, cfg)
+		}
+		if err := applyRequestTimeout(ctx, cfg); err != nil {
+			return err
 		}
 
 		ctx.Locals(CONTEXT_LOCALS_KEY_LOCALAI_REQUEST, input)
@@ -219,6 +237,10 @@ func mergeOpenAIRequestAndModelConfig(config *config.ModelConfig, input *schema.
 		config.RopeFreqScale = input.RopeFreqScale
 	}
 
+	if err := applyGuidedDecoding(config, input); err != nil {
+		return err
+	}
+
 	if input.Grammar != "" {
 		config.Grammar = input.Grammar
 	}
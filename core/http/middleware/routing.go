@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// resolveModelRoute returns the real model a virtual model name is routed to.
+// Names which are not routes are returned unchanged. It fails if no target of
+// the route can serve the request.
+func (re *RequestExtractor) resolveModelRoute(ctx *fiber.Ctx, modelName string) (string, error) {
+	route, exists := re.modelConfigLoader.GetModelRoute(modelName)
+	if !exists {
+		return modelName, nil
+	}
+
+	stickyKey := ""
+	if route.StickyHeader != "" {
+		stickyKey = ctx.Get(route.StickyHeader)
+	}
+
+	target, err := route.Resolve(stickyKey, re.isRouteTargetHealthy)
+	if err != nil {
+		log.Warn().Err(err).Str("route", modelName).Msg("unable to resolve model route")
+		return "", err
+	}
+
+	log.Debug().Str("route", modelName).Str("model", target).Msg("resolved model route")
+	return target, nil
+}
+
+// isRouteTargetHealthy uses the cached health of the backends, so that routing does
+// not wait for busy backends
+func (re *RequestExtractor) isRouteTargetHealthy(modelName string) bool {
+	if _, exists := re.modelConfigLoader.GetModelConfig(modelName); !exists && !re.modelLoader.ExistsInModelPath(modelName) {
+		return false
+	}
+
+	return re.modelLoader.IsHealthy(modelName)
+}
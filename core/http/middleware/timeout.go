@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// RequestTimeoutHeader sets the timeout of a single request. It accepts durations
+// (e.g. "1m30s") or seconds (e.g. "90")
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// applyRequestTimeout sets the timeout of the header in the configuration of the request. The timeout
+// of the model configuration, if any, caps it: requests can only shorten it.
+func applyRequestTimeout(ctx *fiber.Ctx, cfg *config.ModelConfig) error {
+	timeout := ctx.Get(RequestTimeoutHeader)
+	if timeout == "" {
+		return nil
+	}
+
+	d, err := config.ParseTimeout(timeout)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid %s header %q: %s", RequestTimeoutHeader, timeout, err))
+	}
+	if cfg == nil {
+		return nil
+	}
+
+	if limit := cfg.GetTimeout(); d > 0 && (limit == 0 || d < limit) {
+		cfg.Timeout = timeout
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRequestTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		header        string
+		configTimeout string
+		expectStatus  int
+		expectTimeout string
+	}{
+		{
+			name:          "without header",
+			configTimeout: "60s",
+			expectStatus:  200,
+			expectTimeout: "60s",
+		},
+		{
+			name:          "without timeout in the model configuration",
+			header:        "90",
+			expectStatus:  200,
+			expectTimeout: "90",
+		},
+		{
+			name:          "shorter than the model configuration",
+			header:        "30s",
+			configTimeout: "60s",
+			expectStatus:  200,
+			expectTimeout: "30s",
+		},
+		{
+			name:          "longer than the model configuration",
+			header:        "2m",
+			configTimeout: "60s",
+			expectStatus:  200,
+			expectTimeout: "60s",
+		},
+		{
+			name:          "invalid",
+			header:        "soon",
+			configTimeout: "60s",
+			expectStatus:  400,
+			expectTimeout: "60s",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.ModelConfig{Timeout: tc.configTimeout}
+
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return applyRequestTimeout(c, cfg)
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				req.Header.Set(RequestTimeoutHeader, tc.header)
+			}
+			resp, err := app.Test(req, -1)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectStatus, resp.StatusCode)
+			require.Equal(t, tc.expectTimeout, cfg.Timeout)
+		})
+	}
+}
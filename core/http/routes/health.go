@@ -1,13 +1,30 @@
 package routes
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/services"
+)
 
-func HealthRoutes(app *fiber.App) {
+func HealthRoutes(app *fiber.App, healthService *services.HealthService) {
 	// Service health checks
 	ok := func(c *fiber.Ctx) error {
 		return c.SendStatus(200)
 	}
 
+	// Ready once the startup jobs (model downloads and preloads) are done
+	// and the models loaded at startup are healthy
+	ready := func(c *fiber.Ctx) error {
+		if !healthService.Ready() {
+			return c.SendStatus(fiber.StatusServiceUnavailable)
+		}
+		return c.SendStatus(200)
+	}
+
+	details := func(c *fiber.Ctx) error {
+		return c.JSON(healthService.Details())
+	}
+
 	app.Get("/healthz", ok)
-	app.Get("/readyz", ok)
+	app.Get("/healthz/details", details)
+	app.Get("/readyz", ready)
 }
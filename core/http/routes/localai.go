@@ -18,7 +18,8 @@ func RegisterLocalAIRoutes(router *fiber.App,
 	cl *config.ModelConfigLoader,
 	ml *model.ModelLoader,
 	appConfig *config.ApplicationConfig,
-	galleryService *services.GalleryService) {
+	galleryService *services.GalleryService,
+	documentsService *services.DocumentsService) {
 
 	router.Get("/swagger/*", swagger.HandlerDefault) // default
 
@@ -83,10 +84,18 @@ func RegisterLocalAIRoutes(router *fiber.App,
 	router.Post("/v1/vad", vadChain...)
 
 	// Stores
-	router.Post("/stores/set", localai.StoresSetEndpoint(ml, appConfig))
-	router.Post("/stores/delete", localai.StoresDeleteEndpoint(ml, appConfig))
-	router.Post("/stores/get", localai.StoresGetEndpoint(ml, appConfig))
-	router.Post("/stores/find", localai.StoresFindEndpoint(ml, appConfig))
+	router.Post("/stores/set", localai.StoresSetEndpoint(cl, ml, appConfig))
+	router.Post("/stores/delete", localai.StoresDeleteEndpoint(cl, ml, appConfig))
+	router.Post("/stores/get", localai.StoresGetEndpoint(cl, ml, appConfig))
+	router.Post("/stores/find", localai.StoresFindEndpoint(cl, ml, appConfig))
+	router.Post("/stores/:name/documents", localai.StoresDocumentsEndpoint(cl, ml, appConfig, documentsService))
+	router.Get("/stores/jobs/:uuid", localai.StoresJobStatusEndpoint(documentsService))
+	router.Get("/stores/jobs", localai.StoresJobsEndpoint(documentsService))
+	router.Get("/stores", localai.StoresListEndpoint(cl, appConfig))
+	router.Get("/stores/:name", localai.StoresStatsEndpoint(cl, ml, appConfig))
+	router.Delete("/stores/:name", localai.StoresDropEndpoint(cl, ml, appConfig))
+	router.Get("/stores/:name/export", localai.StoresExportEndpoint(cl, ml, appConfig))
+	router.Post("/stores/:name/import", localai.StoresImportEndpoint(cl, ml, appConfig))
 
 	if !appConfig.DisableMetrics {
 		router.Get("/metrics", localai.LocalAIMetricsEndpoint())
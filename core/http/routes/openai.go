@@ -30,6 +30,12 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	}
 	app.Post("/v1/chat/completions", chatChain...)
 	app.Post("/chat/completions", chatChain...)
+	// renders the prompt without generating, as ?dry_run=true does
+	app.Post("/v1/chat/completions/render", chatChain...)
+	app.Post("/chat/completions/render", chatChain...)
+	// the MCP servers of the model configuration are run by the chat endpoints as server tools
+	app.Post("/mcp/v1/chat/completions", chatChain...)
+	app.Post("/mcp/chat/completions", chatChain...)
 
 	// edit
 	editChain := []fiber.Handler{
@@ -86,6 +92,29 @@ func RegisterOpenAIRoutes(app *fiber.App,
 		re.SetOpenAIRequest,
 		openai.ImageEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig()))
 
+	// files
+	app.Post("/v1/files", openai.UploadFilesEndpoint(application.FilesService()))
+	app.Post("/files", openai.UploadFilesEndpoint(application.FilesService()))
+	app.Get("/v1/files", openai.ListFilesEndpoint(application.FilesService()))
+	app.Get("/files", openai.ListFilesEndpoint(application.FilesService()))
+	app.Get("/v1/files/:file_id", openai.GetFilesEndpoint(application.FilesService()))
+	app.Get("/files/:file_id", openai.GetFilesEndpoint(application.FilesService()))
+	app.Delete("/v1/files/:file_id", openai.DeleteFilesEndpoint(application.FilesService()))
+	app.Delete("/files/:file_id", openai.DeleteFilesEndpoint(application.FilesService()))
+	app.Get("/v1/files/:file_id/content", openai.GetFilesContentsEndpoint(application.FilesService()))
+	app.Get("/files/:file_id/content", openai.GetFilesContentsEndpoint(application.FilesService()))
+
+	// vector stores
+	app.Post("/v1/vector_stores", openai.CreateVectorStoreEndpoint(application.ModelConfigLoader(), application.VectorStoresService(), application.FilesService()))
+	app.Get("/v1/vector_stores", openai.ListVectorStoresEndpoint(application.VectorStoresService()))
+	app.Get("/v1/vector_stores/:vector_store_id", openai.GetVectorStoreEndpoint(application.VectorStoresService()))
+	app.Delete("/v1/vector_stores/:vector_store_id", openai.DeleteVectorStoreEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig(), application.VectorStoresService()))
+	app.Post("/v1/vector_stores/:vector_store_id/files", openai.CreateVectorStoreFileEndpoint(application.VectorStoresService(), application.FilesService()))
+	app.Get("/v1/vector_stores/:vector_store_id/files", openai.ListVectorStoreFilesEndpoint(application.VectorStoresService()))
+	app.Get("/v1/vector_stores/:vector_store_id/files/:file_id", openai.GetVectorStoreFileEndpoint(application.VectorStoresService()))
+	app.Delete("/v1/vector_stores/:vector_store_id/files/:file_id", openai.DeleteVectorStoreFileEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig(), application.VectorStoresService()))
+	app.Post("/v1/vector_stores/:vector_store_id/search", openai.SearchVectorStoreEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig(), application.VectorStoresService()))
+
 	// List models
 	app.Get("/v1/models", openai.ListModelsEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig()))
 	app.Get("/models", openai.ListModelsEndpoint(application.ModelConfigLoader(), application.ModelLoader(), application.ApplicationConfig()))
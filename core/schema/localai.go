@@ -68,12 +68,19 @@ type VADResponse struct {
 
 type StoreCommon struct {
 	Backend string `json:"backend,omitempty" yaml:"backend,omitempty"`
+	// The namespace of the store the entries are in, the default one if empty
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 }
 type StoresSet struct {
 	Store string `json:"store,omitempty" yaml:"store,omitempty"`
 
 	Keys   [][]float32 `json:"keys" yaml:"keys"`
 	Values []string    `json:"values" yaml:"values"`
+	// The metadata of the values, JSON objects which finds can filter on
+	Metadata []map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	// Texts to set instead of keys and values, for stores bound to an embedding model. Each text is split
+	// into chunks, which are the values, and the metadata of the text is set on all its chunks
+	Texts []string `json:"texts,omitempty" yaml:"texts,omitempty"`
 	StoreCommon
 }
 
@@ -92,22 +99,72 @@ type StoresGet struct {
 }
 
 type StoresGetResponse struct {
-	Keys   [][]float32 `json:"keys" yaml:"keys"`
-	Values []string    `json:"values" yaml:"values"`
+	Keys     [][]float32              `json:"keys" yaml:"keys"`
+	Values   []string                 `json:"values" yaml:"values"`
+	Metadata []map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 }
 
 type StoresFind struct {
 	Store string `json:"store,omitempty" yaml:"store,omitempty"`
 
-	Key  []float32 `json:"key" yaml:"key"`
-	Topk int       `json:"topk" yaml:"topk"`
+	Key []float32 `json:"key" yaml:"key"`
+	// A text to find instead of the key, for stores bound to an embedding model
+	Query string `json:"query,omitempty" yaml:"query,omitempty"`
+	Topk  int    `json:"topk" yaml:"topk"`
+	// Only finds the entries whose metadata matches the filter, see pkg/store.Filter
+	Filter map[string]interface{} `json:"filter,omitempty" yaml:"filter,omitempty"`
 	StoreCommon
 }
 
 type StoresFindResponse struct {
-	Keys         [][]float32 `json:"keys" yaml:"keys"`
-	Values       []string    `json:"values" yaml:"values"`
-	Similarities []float32   `json:"similarities" yaml:"similarities"`
+	Keys         [][]float32              `json:"keys" yaml:"keys"`
+	Values       []string                 `json:"values" yaml:"values"`
+	Metadata     []map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Similarities []float32                `json:"similarities" yaml:"similarities"`
+}
+
+type StoreInfo struct {
+	Name string `json:"name" yaml:"name"`
+	// Whether the store has files under the models path, which stores without entries have not
+	Persisted bool `json:"persisted" yaml:"persisted"`
+	// Whether the store has a model configuration named after it
+	Configured     bool   `json:"configured" yaml:"configured"`
+	EmbeddingModel string `json:"embedding_model,omitempty" yaml:"embedding_model,omitempty"`
+}
+
+type StoresListResponse struct {
+	Stores []StoreInfo `json:"stores" yaml:"stores"`
+}
+
+type StoreNamespaceStats struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Entries   int64  `json:"entries" yaml:"entries"`
+	KeyLength int    `json:"key_length" yaml:"key_length"`
+	// An estimate of the memory used by the entries, in bytes
+	MemoryBytes int64 `json:"memory_bytes" yaml:"memory_bytes"`
+}
+
+type StoresStatsResponse struct {
+	Name         string                `json:"name" yaml:"name"`
+	Metric       string                `json:"metric" yaml:"metric"`
+	Quantization string                `json:"quantization" yaml:"quantization"`
+	Index        string                `json:"index" yaml:"index"`
+	Persisted    bool                  `json:"persisted" yaml:"persisted"`
+	Entries      int64                 `json:"entries" yaml:"entries"`
+	MemoryBytes  int64                 `json:"memory_bytes" yaml:"memory_bytes"`
+	Namespaces   []StoreNamespaceStats `json:"namespaces" yaml:"namespaces"`
+}
+
+// StoreEntry is an entry of a store as exported and imported, one JSON object per line
+type StoreEntry struct {
+	Namespace string                 `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Key       []float32              `json:"key" yaml:"key"`
+	Value     string                 `json:"value" yaml:"value"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+type StoresImportResponse struct {
+	Entries int `json:"entries" yaml:"entries"`
 }
 
 type NodeData struct {
@@ -130,7 +187,8 @@ type P2PNodesResponse struct {
 }
 
 type SysInfoModel struct {
-	ID string `json:"id"`
+	ID        string `json:"id"`
+	Suspended bool   `json:"suspended,omitempty"`
 }
 
 type SystemInformationResponse struct {
@@ -138,6 +196,31 @@ type SystemInformationResponse struct {
 	Models   []SysInfoModel `json:"loaded_models"`
 }
 
+// HealthDetailsResponse is the readiness breakdown served by /healthz/details
+type HealthDetailsResponse struct {
+	Ready  bool          `json:"ready"`
+	Jobs   []StartupJob  `json:"jobs"`
+	Models []ModelHealth `json:"models"`
+}
+
+// StartupJob is a task (e.g. preloading a model) that must complete before the instance is ready
+type StartupJob struct {
+	Name string `json:"name"`
+	// Required jobs must succeed for the instance to be ready
+	Required bool   `json:"required"`
+	State    string `json:"state"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ModelHealth struct {
+	Name string `json:"name"`
+	// Required models (loaded at startup) must be healthy for the instance to be ready
+	Required  bool       `json:"required"`
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+}
+
 type DetectionRequest struct {
 	BasicModelRequest
 	Image string `json:"image"`
@@ -28,9 +28,10 @@ type OpenAIUsage struct {
 }
 
 type Item struct {
-	Embedding []float32 `json:"embedding"`
-	Index     int       `json:"index"`
-	Object    string    `json:"object,omitempty"`
+	// Embedding is a []float32, or a string with the base64 encoding of its little-endian floats
+	Embedding interface{} `json:"embedding"`
+	Index     int         `json:"index"`
+	Object    string      `json:"object,omitempty"`
 
 	// Images
 	URL     string `json:"url,omitempty"`
@@ -44,6 +45,8 @@ type OpenAIResponse struct {
 	Model   string   `json:"model,omitempty"`
 	Choices []Choice `json:"choices,omitempty"`
 	Data    []Item   `json:"data,omitempty"`
+	// Citations are the documents retrieved for chat completions, see RAG
+	Citations []Document `json:"citations,omitempty"`
 
 	Usage OpenAIUsage `json:"usage"`
 }
@@ -95,6 +98,15 @@ type Message struct {
 	FunctionCall interface{} `json:"function_call,omitempty" yaml:"function_call,omitempty"`
 
 	ToolCalls []ToolCall `json:"tool_calls,omitempty" yaml:"tool_call,omitempty"`
+
+	// The tool call the message of a tool answers
+	ToolCallID string `json:"tool_call_id,omitempty" yaml:"tool_call_id,omitempty"`
+
+	// The refusal message, when the model output could not be returned
+	Refusal string `json:"refusal,omitempty" yaml:"refusal,omitempty"`
+
+	// The reasoning of the model, when separated from the content
+	ReasoningContent string `json:"reasoning_content,omitempty" yaml:"reasoning_content,omitempty"`
 }
 
 type ToolCall struct {
@@ -128,9 +140,9 @@ type JsonSchemaRequest struct {
 }
 
 type JsonSchema struct {
-	Name   string         `json:"name"`
-	Strict bool           `json:"strict"`
-	Schema functions.Item `json:"schema"`
+	Name   string                 `json:"name"`
+	Strict bool                   `json:"strict"`
+	Schema map[string]interface{} `json:"schema"`
 }
 
 type OpenAIRequest struct {
@@ -156,6 +168,11 @@ type OpenAIRequest struct {
 	Instruction string      `json:"instruction" yaml:"instruction"`
 	Input       interface{} `json:"input" yaml:"input"`
 
+	// Embeddings: the number of dimensions kept, for models trained with Matryoshka representation
+	// learning, and the encoding of the embeddings, either float (the default) or base64
+	Dimensions     int    `json:"dimensions,omitempty" yaml:"dimensions"`
+	EncodingFormat string `json:"encoding_format,omitempty" yaml:"encoding_format"`
+
 	Stop interface{} `json:"stop" yaml:"stop"`
 
 	// Messages is read only by chat/completion API calls
@@ -180,15 +197,46 @@ type OpenAIRequest struct {
 
 	JSONFunctionGrammarObject *functions.JSONFunctionStructure `json:"grammar_json_functions" yaml:"grammar_json_functions"`
 
+	// vLLM-style constraints on the LLM output: a regular expression to match, a list of
+	// choices or a grammar in GBNF. They are compiled to a grammar, or passed as is to the
+	// backends supporting them
+	GuidedRegex   string   `json:"guided_regex,omitempty" yaml:"guided_regex"`
+	GuidedChoice  []string `json:"guided_choice,omitempty" yaml:"guided_choice"`
+	GuidedGrammar string   `json:"guided_grammar,omitempty" yaml:"guided_grammar"`
+
 	Backend string `json:"backend" yaml:"backend"`
 
 	ModelBaseName string `json:"model_base_name" yaml:"model_base_name"`
 
 	ReasoningEffort string `json:"reasoning_effort" yaml:"reasoning_effort"`
 
+	// ServerTools selects by name the server tools of the model configuration run for the request.
+	// All of them are run if unset, and none if empty.
+	ServerTools []string `json:"server_tools,omitempty" yaml:"server_tools"`
+
+	// RAG overrides the fields of the RAG of the model configuration
+	RAG *RAG `json:"rag,omitempty" yaml:"rag"`
+	// Documents are retrieved from the store of the RAG, and given to the templates
+	Documents []Document `json:"-" yaml:"-"`
+
 	Metadata map[string]string `json:"metadata" yaml:"metadata"`
 }
 
+// ChatCompletionRenderResponse is what a chat completion request gives to the model, returned by
+// /v1/chat/completions/render without generating
+type ChatCompletionRenderResponse struct {
+	Model string `json:"model"`
+	// Prompt is empty when the backend applies the template of its tokenizer
+	Prompt    string   `json:"prompt"`
+	Grammar   string   `json:"grammar,omitempty"`
+	StopWords []string `json:"stop,omitempty"`
+	// Tokens is the number of tokens of the prompt, unset when the backend cannot tokenize it
+	Tokens     *int              `json:"tokens,omitempty"`
+	Parameters PredictionOptions `json:"parameters"`
+	// Warnings tell what the model would be given that the prompt leaves out, as rendering has no side effects
+	Warnings []string `json:"warnings,omitempty"`
+}
+
 type ModelsDataResponse struct {
 	Object string        `json:"object"`
 	Data   []OpenAIModel `json:"data"`
@@ -0,0 +1,57 @@
+package schema
+
+// RAG retrieves the values of a store closest to the last user message of chat completions. They are given to
+// the templates of the model as Documents, and returned as the citations of the response.
+type RAG struct {
+	// Store is searched with the embedding model it is bound to
+	Store     string `json:"store,omitempty" yaml:"store"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace"`
+	// TopK is the number of documents given to the model. Defaults to 3
+	TopK int `json:"top_k,omitempty" yaml:"top_k"`
+	// Filter only retrieves the values whose metadata matches it, see pkg/store.Filter
+	Filter map[string]interface{} `json:"filter,omitempty" yaml:"filter"`
+	// MinSimilarity drops the values less similar to the message
+	MinSimilarity float32 `json:"min_similarity,omitempty" yaml:"min_similarity"`
+	// Reranker is a rerank model ordering by relevance the Candidates values closest to the message, of which
+	// TopK are kept. Candidates defaults to 4 times TopK
+	Reranker   string `json:"reranker,omitempty" yaml:"reranker"`
+	Candidates int    `json:"candidates,omitempty" yaml:"candidates"`
+}
+
+// Override returns the RAG with the fields set in the other one replaced
+func (r RAG) Override(other *RAG) RAG {
+	if other == nil {
+		return r
+	}
+	if other.Store != "" {
+		r.Store = other.Store
+	}
+	if other.Namespace != "" {
+		r.Namespace = other.Namespace
+	}
+	if other.TopK != 0 {
+		r.TopK = other.TopK
+	}
+	if other.Filter != nil {
+		r.Filter = other.Filter
+	}
+	if other.MinSimilarity != 0 {
+		r.MinSimilarity = other.MinSimilarity
+	}
+	if other.Reranker != "" {
+		r.Reranker = other.Reranker
+	}
+	if other.Candidates != 0 {
+		r.Candidates = other.Candidates
+	}
+	return r
+}
+
+// Document is a value of a store retrieved for a request
+type Document struct {
+	Content    string                 `json:"content"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Similarity float32                `json:"similarity"`
+	// RelevanceScore is given by the reranker, if any
+	RelevanceScore *float32 `json:"relevance_score,omitempty"`
+}
@@ -0,0 +1,38 @@
+package schema
+
+// ServerTool is a tool run by LocalAI while serving chat completions, rather than by the client.
+// Built-in tools are "calculator" and "datetime"; "store" searches a vector store, "http"
+// calls a URL and "mcp" calls a tool of an MCP server. Tools are defined in the model configuration,
+// requests only select them by name.
+type ServerTool struct {
+	Type        string `json:"type" yaml:"type"`
+	Name        string `json:"name,omitempty" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description"`
+
+	// URL is called with the arguments, in the query string for GET requests and as a JSON body otherwise
+	URL     string            `json:"-" yaml:"url"`
+	Method  string            `json:"-" yaml:"method"`
+	Headers map[string]string `json:"-" yaml:"headers"`
+	// Parameters is the JSON schema of the arguments of http tools
+	Parameters map[string]interface{} `json:"-" yaml:"parameters"`
+
+	// Store is searched with the embeddings of the query computed by EmbeddingModel
+	Store          string `json:"store,omitempty" yaml:"store"`
+	EmbeddingModel string `json:"embedding_model,omitempty" yaml:"embedding_model"`
+	TopK           int    `json:"top_k,omitempty" yaml:"top_k"`
+
+	// MCPServer serves mcp tools, which are listed by the server rather than configured
+	MCPServer *MCPServer `json:"-" yaml:"-"`
+}
+
+// MCPServer is a Model Context Protocol server whose tools are run as server tools. It is started
+// with Command, and used over its standard input and output, or reached at URL with the streamable
+// HTTP transport.
+type MCPServer struct {
+	Name    string            `json:"name" yaml:"name"`
+	Command string            `json:"command,omitempty" yaml:"command"`
+	Args    []string          `json:"args,omitempty" yaml:"args"`
+	Env     map[string]string `json:"-" yaml:"env"`
+	URL     string            `json:"url,omitempty" yaml:"url"`
+	Headers map[string]string `json:"-" yaml:"headers"`
+}
@@ -0,0 +1,120 @@
+package schema
+
+// VectorStore is an OpenAI vector store, backed by a local store of the same ID
+type VectorStore struct {
+	ID           string                `json:"id"`
+	Object       string                `json:"object"`
+	CreatedAt    int64                 `json:"created_at"`
+	Name         string                `json:"name"`
+	UsageBytes   int64                 `json:"usage_bytes"`
+	FileCounts   VectorStoreFileCounts `json:"file_counts"`
+	Status       string                `json:"status"`
+	LastActiveAt int64                 `json:"last_active_at"`
+	Metadata     map[string]string     `json:"metadata,omitempty"`
+	// The model embedding the chunks of the files and the queries, a LocalAI extension
+	EmbeddingModel string `json:"embedding_model"`
+}
+
+type VectorStoreFileCounts struct {
+	InProgress int `json:"in_progress"`
+	Completed  int `json:"completed"`
+	Failed     int `json:"failed"`
+	Cancelled  int `json:"cancelled"`
+	Total      int `json:"total"`
+}
+
+// ChunkingStrategy splits the files in chunks of at most MaxChunkSizeTokens tokens, auto being 800 tokens
+// overlapping by 400
+type ChunkingStrategy struct {
+	Type   string          `json:"type"`
+	Static *StaticChunking `json:"static,omitempty"`
+}
+
+type StaticChunking struct {
+	MaxChunkSizeTokens int `json:"max_chunk_size_tokens"`
+	ChunkOverlapTokens int `json:"chunk_overlap_tokens"`
+}
+
+type VectorStoreCreateRequest struct {
+	Name             string            `json:"name"`
+	FileIDs          []string          `json:"file_ids"`
+	Metadata         map[string]string `json:"metadata"`
+	ChunkingStrategy *ChunkingStrategy `json:"chunking_strategy"`
+	// The model embedding the chunks of the files and the queries, the first embedding model configured if empty
+	EmbeddingModel string `json:"embedding_model"`
+}
+
+type VectorStoreList struct {
+	Object  string        `json:"object"`
+	Data    []VectorStore `json:"data"`
+	FirstID string        `json:"first_id,omitempty"`
+	LastID  string        `json:"last_id,omitempty"`
+	HasMore bool          `json:"has_more"`
+}
+
+// VectorStoreFile is a file attached to a vector store, whose chunks are set in the store once it is completed
+type VectorStoreFile struct {
+	ID               string                 `json:"id"`
+	Object           string                 `json:"object"`
+	CreatedAt        int64                  `json:"created_at"`
+	VectorStoreID    string                 `json:"vector_store_id"`
+	UsageBytes       int64                  `json:"usage_bytes"`
+	Status           string                 `json:"status"`
+	LastError        *VectorStoreFileError  `json:"last_error"`
+	Attributes       map[string]interface{} `json:"attributes,omitempty"`
+	ChunkingStrategy *ChunkingStrategy      `json:"chunking_strategy,omitempty"`
+}
+
+type VectorStoreFileError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type VectorStoreFileCreateRequest struct {
+	FileID           string                 `json:"file_id"`
+	Attributes       map[string]interface{} `json:"attributes"`
+	ChunkingStrategy *ChunkingStrategy      `json:"chunking_strategy"`
+}
+
+type VectorStoreFileList struct {
+	Object  string            `json:"object"`
+	Data    []VectorStoreFile `json:"data"`
+	FirstID string            `json:"first_id,omitempty"`
+	LastID  string            `json:"last_id,omitempty"`
+	HasMore bool              `json:"has_more"`
+}
+
+type VectorStoreSearchRequest struct {
+	// A string, or an array of strings which are searched together
+	Query          interface{}            `json:"query"`
+	MaxNumResults  int                    `json:"max_num_results"`
+	Filters        map[string]interface{} `json:"filters"`
+	RankingOptions *RankingOptions        `json:"ranking_options"`
+	RewriteQuery   bool                   `json:"rewrite_query"`
+}
+
+type RankingOptions struct {
+	Ranker         string  `json:"ranker,omitempty"`
+	ScoreThreshold float32 `json:"score_threshold"`
+}
+
+type VectorStoreSearchResult struct {
+	FileID     string                     `json:"file_id"`
+	Filename   string                     `json:"filename"`
+	Score      float32                    `json:"score"`
+	Attributes map[string]interface{}     `json:"attributes"`
+	Content    []VectorStoreSearchContent `json:"content"`
+}
+
+type VectorStoreSearchContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type VectorStoreSearchResponse struct {
+	Object      string                    `json:"object"`
+	SearchQuery []string                  `json:"search_query"`
+	Data        []VectorStoreSearchResult `json:"data"`
+	HasMore     bool                      `json:"has_more"`
+	NextPage    *string                   `json:"next_page"`
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// DocumentOp ingests a document into a store: its text is extracted and split into chunks, which are embedded and
+// set in the store, replacing the chunks of the document with the same ID
+type DocumentOp struct {
+	ID string
+
+	Store      string
+	Namespace  string
+	DocumentID string
+	FileName   string
+	Data       []byte
+	Metadata   map[string]interface{}
+	// ChunkSize and ChunkOverlap override the ones of the store
+	ChunkSize    int
+	ChunkOverlap int
+	// EmbeddingModel embeds the chunks of stores which are not bound to an embedding model
+	EmbeddingModel string
+}
+
+type DocumentOpStatus struct {
+	Store      string  `json:"store"`
+	DocumentID string  `json:"document_id"`
+	FileName   string  `json:"file_name"`
+	Error      error   `json:"error"`
+	Processed  bool    `json:"processed"`
+	Message    string  `json:"message"`
+	Progress   float64 `json:"progress"`
+	Chunks     int     `json:"chunks"`
+}
+
+// DocumentIngester runs a document operation, reporting its progress
+type DocumentIngester func(op *DocumentOp, progress func(*DocumentOpStatus)) error
+
+// DocumentsService ingests the documents sent to DocumentsChannel one at a time, tracking their status like
+// the gallery operations
+type DocumentsService struct {
+	appConfig *config.ApplicationConfig
+	sync.Mutex
+	DocumentsChannel chan DocumentOp
+
+	statuses map[string]*DocumentOpStatus
+}
+
+func NewDocumentsService(appConfig *config.ApplicationConfig) *DocumentsService {
+	return &DocumentsService{
+		appConfig: appConfig,
+		// the requests only wait once this many documents are queued
+		DocumentsChannel: make(chan DocumentOp, 64),
+		statuses:         make(map[string]*DocumentOpStatus),
+	}
+}
+
+func (d *DocumentsService) UpdateStatus(s string, op *DocumentOpStatus) {
+	d.Lock()
+	defer d.Unlock()
+	d.statuses[s] = op
+}
+
+func (d *DocumentsService) GetStatus(s string) *DocumentOpStatus {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.statuses[s]
+}
+
+func (d *DocumentsService) GetAllStatus() map[string]*DocumentOpStatus {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.statuses
+}
+
+func (d *DocumentsService) Start(c context.Context, ingest DocumentIngester) error {
+	go func() {
+		for {
+			select {
+			case <-c.Done():
+				return
+			case op := <-d.DocumentsChannel:
+				status := &DocumentOpStatus{Store: op.Store, DocumentID: op.DocumentID, FileName: op.FileName, Message: "processing"}
+				d.UpdateStatus(op.ID, status)
+
+				err := ingest(&op, func(s *DocumentOpStatus) {
+					d.UpdateStatus(op.ID, s)
+				})
+				if err != nil {
+					failed := *status
+					failed.Error, failed.Processed, failed.Message = err, true, "error: "+err.Error()
+					if d.appConfig.OpaqueErrors {
+						failed.Error, failed.Message = fmt.Errorf("an error occurred"), ""
+					}
+					d.UpdateStatus(op.ID, &failed)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
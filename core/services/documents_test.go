@@ -0,0 +1,52 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/services"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DocumentsService", func() {
+	var (
+		appConfig        *config.ApplicationConfig
+		documentsService *DocumentsService
+	)
+
+	BeforeEach(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		appConfig = config.NewApplicationConfig(config.WithContext(ctx))
+		documentsService = NewDocumentsService(appConfig)
+		Expect(documentsService.Start(ctx, func(op *DocumentOp, progress func(*DocumentOpStatus)) error {
+			if string(op.Data) == "" {
+				return errors.New("empty document")
+			}
+			progress(&DocumentOpStatus{Store: op.Store, DocumentID: op.DocumentID, Processed: true, Progress: 100, Chunks: 2})
+			return nil
+		})).To(Succeed())
+	})
+
+	It("tracks the status of the documents", func() {
+		documentsService.DocumentsChannel <- DocumentOp{ID: "job", Store: "docs", DocumentID: "manual", Data: []byte("text")}
+
+		Eventually(func() *DocumentOpStatus { return documentsService.GetStatus("job") }).Should(
+			And(Not(BeNil()), HaveField("Processed", BeTrue())))
+		Expect(documentsService.GetStatus("job").Chunks).To(Equal(2))
+		Expect(documentsService.GetAllStatus()).To(HaveKey("job"))
+	})
+
+	It("reports the errors of the documents", func() {
+		documentsService.DocumentsChannel <- DocumentOp{ID: "job", Store: "docs", DocumentID: "empty"}
+
+		Eventually(func() *DocumentOpStatus { return documentsService.GetStatus("job") }).Should(
+			And(Not(BeNil()), HaveField("Processed", BeTrue())))
+		status := documentsService.GetStatus("job")
+		Expect(status.Error).To(MatchError("empty document"))
+		Expect(status.DocumentID).To(Equal("empty"))
+	})
+})
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+const filesIndex = "files.json"
+
+// FilesService keeps the files uploaded with the OpenAI files API in the upload directory, along with an index of
+// their names and purposes
+type FilesService struct {
+	dir string
+	sync.Mutex
+	files map[string]schema.File
+}
+
+// NewFilesService loads the index of the files uploaded to dir
+func NewFilesService(dir string) (*FilesService, error) {
+	f := &FilesService{dir: dir, files: make(map[string]schema.File)}
+	if dir == "" {
+		return f, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, filesIndex))
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the index of the uploaded files: %w", err)
+	}
+	if err := json.Unmarshal(data, &f.files); err != nil {
+		return nil, fmt.Errorf("failed to parse the index of the uploaded files: %w", err)
+	}
+	return f, nil
+}
+
+func (f *FilesService) save() error {
+	data, err := json.Marshal(f.files)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(f.dir, filesIndex), data)
+}
+
+// Upload stores the data of a file
+func (f *FilesService) Upload(filename, purpose string, data []byte) (schema.File, error) {
+	if f.dir == "" {
+		return schema.File{}, fmt.Errorf("no upload directory is set")
+	}
+
+	file := schema.File{
+		ID:        "file-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Object:    "file",
+		Bytes:     int64(len(data)),
+		CreatedAt: time.Now().Unix(),
+		Filename:  filepath.Base(filename),
+		Purpose:   purpose,
+	}
+	if err := os.WriteFile(filepath.Join(f.dir, file.ID), data, 0600); err != nil {
+		return schema.File{}, fmt.Errorf("failed to store the file: %w", err)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.files[file.ID] = file
+	if err := f.save(); err != nil {
+		delete(f.files, file.ID)
+		os.Remove(filepath.Join(f.dir, file.ID))
+		return schema.File{}, err
+	}
+	return file, nil
+}
+
+func (f *FilesService) Get(id string) (schema.File, bool) {
+	f.Lock()
+	defer f.Unlock()
+	file, ok := f.files[id]
+	return file, ok
+}
+
+// List returns the files with the purpose, or all of them if empty, the most recent first
+func (f *FilesService) List(purpose string) []schema.File {
+	f.Lock()
+	defer f.Unlock()
+
+	files := []schema.File{}
+	for _, file := range f.files {
+		if purpose == "" || file.Purpose == purpose {
+			files = append(files, file)
+		}
+	}
+	slices.SortFunc(files, func(a, b schema.File) int {
+		if a.CreatedAt != b.CreatedAt {
+			return int(b.CreatedAt - a.CreatedAt)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return files
+}
+
+// Content returns the data of a file
+func (f *FilesService) Content(id string) ([]byte, error) {
+	if _, ok := f.Get(id); !ok {
+		return nil, fmt.Errorf("no file with ID %s", id)
+	}
+	return os.ReadFile(filepath.Join(f.dir, id))
+}
+
+// Delete deletes a file, returning false if it does not exist
+func (f *FilesService) Delete(id string) (bool, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if _, ok := f.files[id]; !ok {
+		return false, nil
+	}
+	delete(f.files, id)
+	if err := f.save(); err != nil {
+		return false, err
+	}
+	if err := os.Remove(filepath.Join(f.dir, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return true, nil
+}
+
+// writeFileAtomic replaces the file with the data, leaving it as it was if interrupted
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	HealthStatePending   = "pending"
+	HealthStateRunning   = "running"
+	HealthStateDone      = "done"
+	HealthStateFailed    = "failed"
+	HealthStateLoading   = "loading"
+	HealthStateHealthy   = "healthy"
+	HealthStateUnhealthy = "unhealthy"
+	HealthStateSuspended = "suspended"
+	HealthStateUnloaded  = "unloaded"
+)
+
+// HealthService tracks the startup jobs and periodically probes the loaded backends,
+// to tell whether the instance is ready to serve requests
+type HealthService struct {
+	modelLoader *model.ModelLoader
+	interval    time.Duration
+
+	sync.Mutex
+	jobs     []*schema.StartupJob
+	jobErrs  []error
+	jobsWg   sync.WaitGroup
+	required map[string]bool
+	probes   map[string]schema.ModelHealth
+}
+
+func NewHealthService(ml *model.ModelLoader, interval time.Duration) *HealthService {
+	return &HealthService{
+		modelLoader: ml,
+		interval:    interval,
+		required:    make(map[string]bool),
+		probes:      make(map[string]schema.ModelHealth),
+	}
+}
+
+// AddJob registers a startup job: the instance is not ready until it is completed.
+// If the job is required, the instance is not ready either if it fails.
+func (hs *HealthService) AddJob(name string, required bool) {
+	hs.Lock()
+	defer hs.Unlock()
+	hs.jobs = append(hs.jobs, &schema.StartupJob{Name: name, Required: required, State: HealthStatePending})
+	hs.jobsWg.Add(1)
+}
+
+// RunJob runs a job previously registered with AddJob, tracking its state
+func (hs *HealthService) RunJob(name string, fn func() error) error {
+	defer hs.jobsWg.Done()
+	hs.setJobState(name, HealthStateRunning, nil)
+
+	err := fn()
+	if err != nil {
+		hs.setJobState(name, HealthStateFailed, err)
+		return err
+	}
+
+	hs.setJobState(name, HealthStateDone, nil)
+	return nil
+}
+
+func (hs *HealthService) setJobState(name, state string, err error) {
+	hs.Lock()
+	defer hs.Unlock()
+	for _, j := range hs.jobs {
+		if j.Name != name {
+			continue
+		}
+		j.State = state
+		if err == nil {
+			continue
+		}
+		j.Error = err.Error()
+		if !j.Required {
+			log.Warn().Err(err).Str("job", name).Msg("startup job failed")
+			continue
+		}
+		log.Error().Err(err).Str("job", name).Msg("required startup job failed")
+		hs.jobErrs = append(hs.jobErrs, fmt.Errorf("%s: %w", name, err))
+	}
+}
+
+// WaitForJobs blocks until all the startup jobs are completed, returning the errors of the required ones
+func (hs *HealthService) WaitForJobs() error {
+	hs.jobsWg.Wait()
+	hs.Lock()
+	defer hs.Unlock()
+	return errors.Join(hs.jobErrs...)
+}
+
+// RequireModel marks a model as required: once loaded, it must stay healthy for the instance to be ready
+func (hs *HealthService) RequireModel(name string) {
+	hs.Lock()
+	defer hs.Unlock()
+	hs.required[name] = true
+}
+
+// Start probes the loaded backends every interval, until ctx is canceled
+func (hs *HealthService) Start(ctx context.Context) {
+	if hs.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(hs.interval)
+		defer ticker.Stop()
+		for {
+			hs.Probe()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Probe runs a health check against every loaded backend
+func (hs *HealthService) Probe() {
+	probes := make(map[string]schema.ModelHealth)
+	for _, m := range hs.modelLoader.ListLoadedModels() {
+		now := time.Now()
+		h := schema.ModelHealth{Name: m.ID, State: HealthStateHealthy, LastCheck: &now}
+		switch {
+		case !hs.modelLoader.CheckHealth(m.ID):
+			h.State = HealthStateUnhealthy
+			h.Error = "backend health check failed"
+			log.Warn().Str("model", m.ID).Msg("backend health check failed")
+		case hs.modelLoader.IsSuspended(m.ID):
+			h.State = HealthStateSuspended
+		}
+		probes[m.ID] = h
+	}
+
+	hs.Lock()
+	defer hs.Unlock()
+	hs.probes = probes
+}
+
+// Details returns the state of the startup jobs and of the models
+func (hs *HealthService) Details() schema.HealthDetailsResponse {
+	hs.Lock()
+	defer hs.Unlock()
+
+	res := schema.HealthDetailsResponse{Ready: true, Jobs: []schema.StartupJob{}, Models: []schema.ModelHealth{}}
+	for _, j := range hs.jobs {
+		res.Jobs = append(res.Jobs, *j)
+		switch j.State {
+		case HealthStateDone:
+		case HealthStateFailed:
+			if j.Required {
+				res.Ready = false
+			}
+		default:
+			res.Ready = false
+		}
+	}
+
+	for name := range hs.required {
+		h := hs.modelHealth(name)
+		h.Required = true
+		// Required models unloaded after startup (e.g. by the watchdog) are loaded again on demand
+		if h.State == HealthStateUnhealthy || h.State == HealthStateLoading {
+			res.Ready = false
+		}
+		res.Models = append(res.Models, h)
+	}
+
+	for name := range hs.probes {
+		if !hs.required[name] {
+			res.Models = append(res.Models, hs.modelHealth(name))
+		}
+	}
+
+	slices.SortFunc(res.Models, func(a, b schema.ModelHealth) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return res
+}
+
+// Ready reports whether the instance can serve requests
+func (hs *HealthService) Ready() bool {
+	return hs.Details().Ready
+}
+
+func (hs *HealthService) modelHealth(name string) schema.ModelHealth {
+	switch {
+	case hs.modelLoader.IsLoading(name):
+		return schema.ModelHealth{Name: name, State: HealthStateLoading}
+	case !hs.modelLoader.IsLoaded(name):
+		return schema.ModelHealth{Name: name, State: HealthStateUnloaded}
+	}
+
+	if h, ok := hs.probes[name]; ok {
+		return h
+	}
+
+	// Loaded since the last probe
+	return schema.ModelHealth{Name: name, State: HealthStateHealthy}
+}
@@ -0,0 +1,208 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mudler/LocalAI/core/schema"
+	. "github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/grpc/base"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/system"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthService", func() {
+	var (
+		modelLoader   *model.ModelLoader
+		healthService *HealthService
+	)
+
+	BeforeEach(func() {
+		systemState, err := system.GetSystemState(system.WithModelPath(GinkgoT().TempDir()))
+		Expect(err).ToNot(HaveOccurred())
+		modelLoader = model.NewModelLoader(systemState, false)
+		healthService = NewHealthService(modelLoader, 0)
+	})
+
+	jobState := func(name string) string {
+		for _, j := range healthService.Details().Jobs {
+			if j.Name == name {
+				return j.State
+			}
+		}
+		return ""
+	}
+
+	// loadFake loads a model served by an embedded fake backend
+	loadFake := func(name string, llm grpc.AIModel) {
+		grpc.Provide(name, llm)
+		_, err := modelLoader.Load(
+			model.WithBackendString("fake"),
+			model.WithExternalBackend("fake", name),
+			model.WithModel(name),
+			model.WithModelID(name),
+		)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	// loadUnreachable loads a model whose backend does not answer
+	loadUnreachable := func(name string) {
+		_, err := modelLoader.LoadModel(name, name, func(modelID, modelName, modelFile string) (*model.Model, error) {
+			// nothing listens on this address
+			return model.NewModel(modelID, "127.0.0.1:1", nil), nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	Context("startup jobs", func() {
+		It("is ready without jobs", func() {
+			Expect(healthService.Ready()).To(BeTrue())
+			Expect(healthService.WaitForJobs()).To(Succeed())
+		})
+
+		It("is not ready while jobs are pending or running", func() {
+			healthService.AddJob("download", true)
+			Expect(jobState("download")).To(Equal(HealthStatePending))
+			Expect(healthService.Ready()).To(BeFalse())
+
+			release := make(chan struct{})
+			go healthService.RunJob("download", func() error {
+				<-release
+				return nil
+			})
+			Eventually(func() string { return jobState("download") }).Should(Equal(HealthStateRunning))
+			Expect(healthService.Ready()).To(BeFalse())
+
+			close(release)
+			Expect(healthService.WaitForJobs()).To(Succeed())
+			Expect(jobState("download")).To(Equal(HealthStateDone))
+			Expect(healthService.Ready()).To(BeTrue())
+		})
+
+		It("is not ready when a required job fails", func() {
+			healthService.AddJob("load model", true)
+			Expect(healthService.RunJob("load model", func() error { return errors.New("no such model") })).ToNot(Succeed())
+
+			Expect(healthService.Details().Jobs).To(ConsistOf(schema.StartupJob{
+				Name: "load model", Required: true, State: HealthStateFailed, Error: "no such model",
+			}))
+			Expect(healthService.Ready()).To(BeFalse())
+			Expect(healthService.WaitForJobs()).To(MatchError(ContainSubstring("load model: no such model")))
+		})
+
+		It("stays ready when an optional job fails", func() {
+			healthService.AddJob("preload model files", false)
+			healthService.AddJob("load model", true)
+			Expect(healthService.RunJob("preload model files", func() error { return errors.New("download failed") })).ToNot(Succeed())
+			Expect(healthService.RunJob("load model", func() error { return nil })).To(Succeed())
+
+			Expect(jobState("preload model files")).To(Equal(HealthStateFailed))
+			Expect(healthService.Ready()).To(BeTrue())
+			Expect(healthService.WaitForJobs()).To(Succeed())
+		})
+	})
+
+	Context("backends", func() {
+		It("reports healthy backends", func() {
+			loadFake("healthy", &fakeBackend{})
+			healthService.RequireModel("healthy")
+			healthService.Probe()
+
+			details := healthService.Details()
+			Expect(details.Ready).To(BeTrue())
+			Expect(details.Models).To(HaveLen(1))
+			Expect(details.Models[0].Name).To(Equal("healthy"))
+			Expect(details.Models[0].Required).To(BeTrue())
+			Expect(details.Models[0].State).To(Equal(HealthStateHealthy))
+			Expect(details.Models[0].LastCheck).ToNot(BeNil())
+		})
+
+		It("reports busy backends as healthy without waiting for them", func() {
+			llm := &fakeBackend{started: make(chan struct{}), predicting: make(chan struct{})}
+			loadFake("busy", llm)
+			healthService.RequireModel("busy")
+
+			backend := modelLoader.CheckIsLoaded("busy").GRPC(false, nil)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := backend.Predict(context.Background(), &pb.PredictOptions{})
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			<-llm.started
+
+			healthService.Probe()
+			Expect(healthService.Details().Models[0].State).To(Equal(HealthStateHealthy))
+			Expect(healthService.Ready()).To(BeTrue())
+
+			close(llm.predicting)
+			<-done
+		})
+
+		It("is not ready when a required backend is unhealthy", func() {
+			loadUnreachable("broken")
+			healthService.RequireModel("broken")
+			healthService.Probe()
+
+			details := healthService.Details()
+			Expect(details.Ready).To(BeFalse())
+			Expect(details.Models[0].State).To(Equal(HealthStateUnhealthy))
+			Expect(details.Models[0].Error).ToNot(BeEmpty())
+		})
+
+		It("stays ready when a backend which is not required is unhealthy", func() {
+			loadUnreachable("broken")
+			healthService.Probe()
+
+			details := healthService.Details()
+			Expect(details.Ready).To(BeTrue())
+			Expect(details.Models).To(HaveLen(1))
+			Expect(details.Models[0].Required).To(BeFalse())
+			Expect(details.Models[0].State).To(Equal(HealthStateUnhealthy))
+		})
+
+		It("reports required models which are loading or unloaded", func() {
+			healthService.RequireModel("slow")
+			Expect(healthService.Details().Models[0].State).To(Equal(HealthStateUnloaded))
+
+			release := make(chan struct{})
+			go modelLoader.LoadModel("slow", "slow", func(modelID, modelName, modelFile string) (*model.Model, error) {
+				<-release
+				return model.NewModel(modelID, "127.0.0.1:1", nil), nil
+			})
+			Eventually(func() string { return healthService.Details().Models[0].State }).Should(Equal(HealthStateLoading))
+			Expect(healthService.Ready()).To(BeFalse())
+
+			close(release)
+			Eventually(func() string { return healthService.Details().Models[0].State }).Should(Equal(HealthStateHealthy))
+		})
+	})
+})
+
+type fakeBackend struct {
+	base.SingleThread
+	// started, if set, is closed when a prediction starts
+	started chan struct{}
+	// predicting, if set, blocks predictions until it is closed
+	predicting chan struct{}
+}
+
+func (b *fakeBackend) Load(*pb.ModelOptions) error {
+	return nil
+}
+
+func (b *fakeBackend) Predict(*pb.PredictOptions) (string, error) {
+	if b.started != nil {
+		close(b.started)
+	}
+	if b.predicting != nil {
+		<-b.predicting
+	}
+	return "", nil
+}
@@ -0,0 +1,42 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/system"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ListModels", func() {
+	It("lists the model routes once, after the configurations", func() {
+		modelPath := GinkgoT().TempDir()
+		for _, name := range []string{"llama3", "qwen"} {
+			Expect(os.WriteFile(filepath.Join(modelPath, name+".yaml"), []byte("name: "+name+"\nbackend: llama-cpp\n"), 0600)).To(Succeed())
+		}
+		routes := filepath.Join(GinkgoT().TempDir(), "routes.yaml")
+		Expect(os.WriteFile(routes, []byte(`
+- name: gpt-4o
+  targets:
+  - model: llama3
+- name: qwen
+  targets:
+  - model: llama3
+`), 0600)).To(Succeed())
+
+		bcl := config.NewModelConfigLoader(modelPath)
+		Expect(bcl.LoadModelConfigsFromPath(modelPath)).To(Succeed())
+		Expect(bcl.LoadModelRoutesFromFile(routes)).To(Succeed())
+
+		systemState, err := system.GetSystemState(system.WithModelPath(modelPath))
+		Expect(err).ToNot(HaveOccurred())
+		models, err := ListModels(bcl, model.NewModelLoader(systemState, false), config.NoFilterFn, SKIP_IF_CONFIGURED)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(ConsistOf("llama3", "qwen", "gpt-4o"))
+	})
+})
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	vectorStoresIndex = "vector_stores.json"
+
+	// the chunking of the auto strategy, in tokens
+	defaultChunkTokens        = 800
+	defaultChunkOverlapTokens = 400
+	// charactersPerToken converts the chunk sizes in tokens to the characters the texts are split by
+	charactersPerToken = 4
+
+	VectorStoreFileInProgress = "in_progress"
+	VectorStoreFileCompleted  = "completed"
+	VectorStoreFileFailed     = "failed"
+)
+
+var (
+	ErrVectorStoreNotFound     = errors.New("no such vector store")
+	ErrVectorStoreFileNotFound = errors.New("no such file in the vector store")
+)
+
+type vectorStoreRecord struct {
+	schema.VectorStore
+	ChunkingStrategy *schema.ChunkingStrategy          `json:"chunking_strategy,omitempty"`
+	Files            map[string]*vectorStoreFileRecord `json:"files"`
+}
+
+type vectorStoreFileRecord struct {
+	schema.VectorStoreFile
+	Filename string `json:"filename"`
+	// The ID of the document operation ingesting the file
+	JobID string `json:"job_id"`
+}
+
+// VectorStoresService keeps the OpenAI vector stores, each backed by the local store of the same ID whose chunks
+// are embedded with the embedding model of the vector store. The files attached to the vector stores are ingested
+// by the DocumentsService, their status follows the one of their document operation.
+type VectorStoresService struct {
+	path      string
+	documents *DocumentsService
+	sync.Mutex
+	stores map[string]*vectorStoreRecord
+}
+
+// NewVectorStoresService loads the vector stores kept along with the stores under the models path
+func NewVectorStoresService(appConfig *config.ApplicationConfig, documents *DocumentsService) (*VectorStoresService, error) {
+	s := &VectorStoresService{
+		path:      filepath.Join(appConfig.SystemState.Model.ModelsPath, "stores", vectorStoresIndex),
+		documents: documents,
+		stores:    make(map[string]*vectorStoreRecord),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the vector stores: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.stores); err != nil {
+		return nil, fmt.Errorf("failed to parse the vector stores: %w", err)
+	}
+	return s, nil
+}
+
+func (s *VectorStoresService) save() error {
+	data, err := json.Marshal(s.stores)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// ChunkingCharacters returns the size and the overlap of the chunks of the strategy in characters
+func ChunkingCharacters(c *schema.ChunkingStrategy) (int, int, error) {
+	if c == nil || c.Type == "" || c.Type == "auto" {
+		return defaultChunkTokens * charactersPerToken, defaultChunkOverlapTokens * charactersPerToken, nil
+	}
+	if c.Type != "static" || c.Static == nil {
+		return 0, 0, fmt.Errorf("the chunking strategy must be auto or static")
+	}
+
+	size, overlap := c.Static.MaxChunkSizeTokens, c.Static.ChunkOverlapTokens
+	if size < 100 || size > 4096 {
+		return 0, 0, fmt.Errorf("max_chunk_size_tokens must be between 100 and 4096")
+	}
+	if overlap < 0 || overlap > size/2 {
+		return 0, 0, fmt.Errorf("chunk_overlap_tokens must not exceed half of max_chunk_size_tokens")
+	}
+	return size * charactersPerToken, overlap * charactersPerToken, nil
+}
+
+func newID(prefix string) string {
+	return prefix + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// Create creates an empty vector store
+func (s *VectorStoresService) Create(name string, metadata map[string]string, embeddingModel string, chunking *schema.ChunkingStrategy) (schema.VectorStore, error) {
+	if _, _, err := ChunkingCharacters(chunking); err != nil {
+		return schema.VectorStore{}, err
+	}
+
+	now := time.Now().Unix()
+	rec := &vectorStoreRecord{
+		VectorStore: schema.VectorStore{
+			ID:             newID("vs_"),
+			Object:         "vector_store",
+			CreatedAt:      now,
+			Name:           name,
+			LastActiveAt:   now,
+			Metadata:       metadata,
+			EmbeddingModel: embeddingModel,
+		},
+		ChunkingStrategy: chunking,
+		Files:            make(map[string]*vectorStoreFileRecord),
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.stores[rec.ID] = rec
+	if err := s.save(); err != nil {
+		delete(s.stores, rec.ID)
+		return schema.VectorStore{}, err
+	}
+	return s.view(rec), nil
+}
+
+// refresh updates the status of the files from the one of their document operations, returning whether any changed
+func (s *VectorStoresService) refresh(rec *vectorStoreRecord) bool {
+	changed := false
+	for _, f := range rec.Files {
+		if f.Status != VectorStoreFileInProgress {
+			continue
+		}
+
+		status := s.documents.GetStatus(f.JobID)
+		switch {
+		case status == nil:
+			// the operations are not kept across restarts
+			f.Status = VectorStoreFileFailed
+			f.LastError = &schema.VectorStoreFileError{Code: "server_error", Message: "the ingestion of the file was interrupted"}
+		case !status.Processed:
+			continue
+		case status.Error != nil:
+			f.Status = VectorStoreFileFailed
+			f.LastError = &schema.VectorStoreFileError{Code: "server_error", Message: status.Error.Error()}
+		default:
+			f.Status = VectorStoreFileCompleted
+		}
+		changed = true
+	}
+	return changed
+}
+
+// view returns the vector store with the counts of its files
+func (s *VectorStoresService) view(rec *vectorStoreRecord) schema.VectorStore {
+	if s.refresh(rec) {
+		if err := s.save(); err != nil {
+			log.Warn().Err(err).Msg("failed to save the vector stores")
+		}
+	}
+
+	vs := rec.VectorStore
+	vs.UsageBytes = 0
+	vs.FileCounts = schema.VectorStoreFileCounts{Total: len(rec.Files)}
+	for _, f := range rec.Files {
+		switch f.Status {
+		case VectorStoreFileInProgress:
+			vs.FileCounts.InProgress++
+		case VectorStoreFileCompleted:
+			vs.FileCounts.Completed++
+			vs.UsageBytes += f.UsageBytes
+		case VectorStoreFileFailed:
+			vs.FileCounts.Failed++
+		}
+	}
+	vs.Status = "completed"
+	if vs.FileCounts.InProgress > 0 {
+		vs.Status = "in_progress"
+	}
+	return vs
+}
+
+func (s *VectorStoresService) Get(id string) (schema.VectorStore, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	rec, ok := s.stores[id]
+	if !ok {
+		return schema.VectorStore{}, ErrVectorStoreNotFound
+	}
+	return s.view(rec), nil
+}
+
+// List returns the vector stores, the most recent first
+func (s *VectorStoresService) List() []schema.VectorStore {
+	s.Lock()
+	defer s.Unlock()
+
+	stores := make([]schema.VectorStore, 0, len(s.stores))
+	for _, rec := range s.stores {
+		stores = append(stores, s.view(rec))
+	}
+	slices.SortFunc(stores, func(a, b schema.VectorStore) int {
+		if a.CreatedAt != b.CreatedAt {
+			return int(b.CreatedAt - a.CreatedAt)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return stores
+}
+
+// Touch marks the vector store as active
+func (s *VectorStoresService) Touch(id string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if rec, ok := s.stores[id]; ok {
+		rec.LastActiveAt = time.Now().Unix()
+	}
+}
+
+// Delete forgets the vector store, whose local store must be dropped
+func (s *VectorStoresService) Delete(id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	rec, ok := s.stores[id]
+	if !ok {
+		return ErrVectorStoreNotFound
+	}
+	delete(s.stores, id)
+	if err := s.save(); err != nil {
+		s.stores[id] = rec
+		return err
+	}
+	return nil
+}
+
+// AddFile attaches a file to the vector store, queuing its ingestion. The chunks of a file attached again replace the
+// previous ones.
+func (s *VectorStoresService) AddFile(id string, file schema.File, data []byte, attributes map[string]interface{}, chunking *schema.ChunkingStrategy) (schema.VectorStoreFile, error) {
+	s.Lock()
+
+	rec, ok := s.stores[id]
+	if !ok {
+		s.Unlock()
+		return schema.VectorStoreFile{}, ErrVectorStoreNotFound
+	}
+	if chunking == nil {
+		chunking = rec.ChunkingStrategy
+	}
+	size, overlap, err := ChunkingCharacters(chunking)
+	if err != nil {
+		s.Unlock()
+		return schema.VectorStoreFile{}, err
+	}
+
+	f := &vectorStoreFileRecord{
+		VectorStoreFile: schema.VectorStoreFile{
+			ID:               file.ID,
+			Object:           "vector_store.file",
+			CreatedAt:        time.Now().Unix(),
+			VectorStoreID:    id,
+			UsageBytes:       file.Bytes,
+			Status:           VectorStoreFileInProgress,
+			Attributes:       attributes,
+			ChunkingStrategy: chunking,
+		},
+		Filename: file.Filename,
+		JobID:    uuid.NewString(),
+	}
+	previous := rec.Files[file.ID]
+	rec.Files[file.ID] = f
+	rec.LastActiveAt = f.CreatedAt
+	if err := s.save(); err != nil {
+		if previous != nil {
+			rec.Files[file.ID] = previous
+		} else {
+			delete(rec.Files, file.ID)
+		}
+		s.Unlock()
+		return schema.VectorStoreFile{}, err
+	}
+
+	op := DocumentOp{
+		ID:             f.JobID,
+		Store:          id,
+		DocumentID:     file.ID,
+		FileName:       file.Filename,
+		Data:           data,
+		Metadata:       attributes,
+		ChunkSize:      size,
+		ChunkOverlap:   overlap,
+		EmbeddingModel: rec.EmbeddingModel,
+	}
+	s.documents.UpdateStatus(op.ID, &DocumentOpStatus{Store: op.Store, DocumentID: op.DocumentID, FileName: op.FileName, Message: "queued"})
+	s.Unlock()
+
+	s.documents.DocumentsChannel <- op
+	return f.VectorStoreFile, nil
+}
+
+func (s *VectorStoresService) GetFile(id, fileID string) (schema.VectorStoreFile, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	rec, ok := s.stores[id]
+	if !ok {
+		return schema.VectorStoreFile{}, ErrVectorStoreNotFound
+	}
+	f, ok := rec.Files[fileID]
+	if !ok {
+		return schema.VectorStoreFile{}, ErrVectorStoreFileNotFound
+	}
+	s.view(rec)
+	return f.VectorStoreFile, nil
+}
+
+// ListFiles returns the files of the vector store with the status, or all of them if empty, the most recent first
+func (s *VectorStoresService) ListFiles(id, status string) ([]schema.VectorStoreFile, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	rec, ok := s.stores[id]
+	if !ok {
+		return nil, ErrVectorStoreNotFound
+	}
+	s.view(rec)
+
+	files := []schema.VectorStoreFile{}
+	for _, f := range rec.Files {
+		if status == "" || f.Status == status {
+			files = append(files, f.VectorStoreFile)
+		}
+	}
+	slices.SortFunc(files, func(a, b schema.VectorStoreFile) int {
+		if a.CreatedAt != b.CreatedAt {
+			return int(b.CreatedAt - a.CreatedAt)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return files, nil
+}
+
+// DeleteFile detaches the file from the vector store, whose chunks must be deleted from the local store
+func (s *VectorStoresService) DeleteFile(id, fileID string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	rec, ok := s.stores[id]
+	if !ok {
+		return ErrVectorStoreNotFound
+	}
+	f, ok := rec.Files[fileID]
+	if !ok {
+		return ErrVectorStoreFileNotFound
+	}
+	delete(rec.Files, fileID)
+	if err := s.save(); err != nil {
+		rec.Files[fileID] = f
+		return err
+	}
+	return nil
+}
+
+// FileNames returns the names of the files of the vector store by ID
+func (s *VectorStoresService) FileNames(id string) map[string]string {
+	s.Lock()
+	defer s.Unlock()
+
+	names := map[string]string{}
+	if rec, ok := s.stores[id]; ok {
+		for fileID, f := range rec.Files {
+			names[fileID] = f.Filename
+		}
+	}
+	return names
+}
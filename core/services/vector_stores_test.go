@@ -0,0 +1,159 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	. "github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/system"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VectorStoresService", func() {
+	var (
+		appConfig           *config.ApplicationConfig
+		filesService        *FilesService
+		vectorStoresService *VectorStoresService
+
+		mu       sync.Mutex
+		ingested []DocumentOp
+	)
+
+	BeforeEach(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		state, err := system.GetSystemState(system.WithModelPath(GinkgoT().TempDir()))
+		Expect(err).ToNot(HaveOccurred())
+		appConfig = config.NewApplicationConfig(config.WithContext(ctx), config.WithSystemState(state))
+
+		ingested = nil
+		documentsService := NewDocumentsService(appConfig)
+		Expect(documentsService.Start(ctx, func(op *DocumentOp, progress func(*DocumentOpStatus)) error {
+			mu.Lock()
+			ingested = append(ingested, *op)
+			mu.Unlock()
+			if string(op.Data) == "" {
+				return errors.New("empty document")
+			}
+			progress(&DocumentOpStatus{Store: op.Store, DocumentID: op.DocumentID, Processed: true, Progress: 100})
+			return nil
+		})).To(Succeed())
+
+		filesService, err = NewFilesService(GinkgoT().TempDir())
+		Expect(err).ToNot(HaveOccurred())
+		vectorStoresService, err = NewVectorStoresService(appConfig, documentsService)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	upload := func(name, content string) schema.File {
+		file, err := filesService.Upload(name, "assistants", []byte(content))
+		Expect(err).ToNot(HaveOccurred())
+		return file
+	}
+
+	It("stores the uploaded files", func() {
+		file := upload("../manual.md", "# Manual")
+		Expect(file.ID).To(HavePrefix("file-"))
+		Expect(file.Filename).To(Equal("manual.md"))
+		Expect(file.Bytes).To(Equal(int64(8)))
+
+		data, err := filesService.Content(file.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("# Manual"))
+		Expect(filesService.List("assistants")).To(HaveLen(1))
+		Expect(filesService.List("batch")).To(BeEmpty())
+
+		deleted, err := filesService.Delete(file.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deleted).To(BeTrue())
+		_, err = filesService.Content(file.ID)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("ingests the files attached to the vector stores", func() {
+		vs, err := vectorStoresService.Create("docs", map[string]string{"team": "infra"}, "bert", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vs.ID).To(HavePrefix("vs_"))
+		Expect(vs.Status).To(Equal("completed"))
+
+		manual, empty := upload("manual.md", "# Manual"), upload("empty.txt", "")
+		_, err = vectorStoresService.AddFile(vs.ID, manual, []byte("# Manual"), map[string]interface{}{"lang": "en"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = vectorStoresService.AddFile(vs.ID, empty, nil, nil, &schema.ChunkingStrategy{
+			Type: "static", Static: &schema.StaticChunking{MaxChunkSizeTokens: 200, ChunkOverlapTokens: 50},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(func() schema.VectorStoreFileCounts {
+			vs, err := vectorStoresService.Get(vs.ID)
+			Expect(err).ToNot(HaveOccurred())
+			return vs.FileCounts
+		}).Should(Equal(schema.VectorStoreFileCounts{Completed: 1, Failed: 1, Total: 2}))
+
+		f, err := vectorStoresService.GetFile(vs.ID, empty.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.LastError.Message).To(Equal("empty document"))
+		completed, err := vectorStoresService.ListFiles(vs.ID, VectorStoreFileCompleted)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(completed).To(HaveLen(1))
+		Expect(completed[0].ID).To(Equal(manual.ID))
+
+		mu.Lock()
+		Expect(ingested).To(HaveLen(2))
+		Expect(ingested[0].Store).To(Equal(vs.ID))
+		Expect(ingested[0].EmbeddingModel).To(Equal("bert"))
+		Expect(ingested[0].Metadata).To(Equal(map[string]interface{}{"lang": "en"}))
+		Expect(ingested[0].ChunkSize).To(Equal(3200))
+		Expect(ingested[1].ChunkSize).To(Equal(800))
+		Expect(ingested[1].ChunkOverlap).To(Equal(200))
+		mu.Unlock()
+
+		vs, err = vectorStoresService.Get(vs.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vs.UsageBytes).To(Equal(int64(8)))
+
+		Expect(vectorStoresService.DeleteFile(vs.ID, manual.ID)).To(Succeed())
+		Expect(vectorStoresService.FileNames(vs.ID)).To(Equal(map[string]string{empty.ID: "empty.txt"}))
+		Expect(vectorStoresService.DeleteFile(vs.ID, manual.ID)).To(MatchError(ErrVectorStoreFileNotFound))
+	})
+
+	It("keeps the vector stores across restarts", func() {
+		vs, err := vectorStoresService.Create("docs", nil, "bert", nil)
+		Expect(err).ToNot(HaveOccurred())
+		file := upload("manual.md", "# Manual")
+		_, err = vectorStoresService.AddFile(vs.ID, file, []byte("# Manual"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(func() int {
+			vs, _ := vectorStoresService.Get(vs.ID)
+			return vs.FileCounts.Completed
+		}).Should(Equal(1))
+
+		// the files being ingested when LocalAI stops are failed
+		restarted, err := NewVectorStoresService(appConfig, NewDocumentsService(appConfig))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restarted.List()).To(HaveLen(1))
+		Expect(restarted.List()[0].FileCounts.Completed).To(Equal(1))
+
+		Expect(restarted.Delete(vs.ID)).To(Succeed())
+		_, err = restarted.Get(vs.ID)
+		Expect(err).To(MatchError(ErrVectorStoreNotFound))
+	})
+
+	It("converts the chunking strategies to characters", func() {
+		size, overlap, err := ChunkingCharacters(&schema.ChunkingStrategy{Type: "auto"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect([]int{size, overlap}).To(Equal([]int{3200, 1600}))
+
+		_, _, err = ChunkingCharacters(&schema.ChunkingStrategy{Type: "static", Static: &schema.StaticChunking{MaxChunkSizeTokens: 50}})
+		Expect(err).To(HaveOccurred())
+		_, _, err = ChunkingCharacters(&schema.ChunkingStrategy{Type: "static", Static: &schema.StaticChunking{MaxChunkSizeTokens: 200, ChunkOverlapTokens: 150}})
+		Expect(err).To(HaveOccurred())
+		_, _, err = ChunkingCharacters(&schema.ChunkingStrategy{Type: "semantic"})
+		Expect(err).To(HaveOccurred())
+	})
+})
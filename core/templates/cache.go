@@ -12,7 +12,6 @@ import (
 
 	"github.com/Masterminds/sprig/v3"
 
-	"github.com/nikolalohinski/gonja/v2"
 	"github.com/nikolalohinski/gonja/v2/exec"
 )
 
@@ -45,6 +44,20 @@ func (tc *templateCache) initializeTemplateMapKey(tt TemplateType) {
 func (tc *templateCache) existsInModelPath(s string) bool {
 	return utils.ExistsInPath(tc.templatesPath, s)
 }
+
+// source returns the content of the template, which is either a file in the model path or the template itself
+func (tc *templateCache) source(templateName string) string {
+	modelTemplateFile := fmt.Sprintf("%s.tmpl", templateName)
+	if utils.VerifyPath(modelTemplateFile, tc.templatesPath) != nil || !tc.existsInModelPath(modelTemplateFile) {
+		return templateName
+	}
+	d, err := os.ReadFile(filepath.Join(tc.templatesPath, modelTemplateFile))
+	if err != nil {
+		return templateName
+	}
+	return string(d)
+}
+
 func (tc *templateCache) loadTemplateIfExists(templateType TemplateType, templateName string) error {
 
 	// Check if the template was already loaded
@@ -120,7 +133,7 @@ func (tc *templateCache) loadJinjaTemplateIfExists(templateType TemplateType, te
 		dat = templateName
 	}
 
-	tmpl, err := gonja.FromString(dat)
+	tmpl, err := newJinjaTemplate(dat)
 	if err != nil {
 		return err
 	}
@@ -147,14 +160,7 @@ func (tc *templateCache) evaluateJinjaTemplate(templateType TemplateType, templa
 		return "", fmt.Errorf("failed loading a template for %s", templateNameOrContent)
 	}
 
-	var buf bytes.Buffer
-
-	data := exec.NewContext(in)
-
-	if err := m.Execute(&buf, data); err != nil {
-		return "", err
-	}
-	return buf.String(), nil
+	return executeJinjaTemplate(m, in)
 }
 
 func (tc *templateCache) evaluateTemplate(templateType TemplateType, templateNameOrContent string, in interface{}) (string, error) {
@@ -22,7 +22,11 @@ type PromptTemplateData struct {
 	Functions            []functions.Function
 	MessageIndex         int
 	ReasoningEffort      string
-	Metadata             map[string]string
+	// EnableThinking is false when the reasoning_effort of the request skips the reasoning
+	EnableThinking bool
+	Metadata       map[string]string
+	// Documents are retrieved from the store of the RAG of the model, if any
+	Documents []schema.Document
 }
 
 type ChatMessageTemplateData struct {
@@ -93,36 +97,56 @@ func (e *Evaluator) EvaluateTemplateForPrompt(templateType TemplateType, config
 	return e.cache.evaluateTemplate(templateType, template, in)
 }
 
+// ConsumesDocuments returns whether the chat templates of the model use the documents retrieved from its RAG.
+// The templates of the tokenizer, applied by the backends, never get them.
+func (e *Evaluator) ConsumesDocuments(config *config.ModelConfig) bool {
+	if config.TemplateConfig.UseTokenizerTemplate {
+		return false
+	}
+	if config.TemplateConfig.JinjaTemplate {
+		return strings.Contains(e.cache.source(config.TemplateConfig.ChatMessage), "documents")
+	}
+
+	chat := config.TemplateConfig.Chat
+	if chat == "" && e.cache.existsInModelPath(fmt.Sprintf("%s.tmpl", config.Model)) {
+		chat = config.Model
+	}
+	for _, template := range []string{chat, config.TemplateConfig.Functions} {
+		if template != "" && strings.Contains(e.cache.source(template), ".Documents") {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *Evaluator) evaluateTemplateForChatMessage(templateName string, messageData ChatMessageTemplateData) (string, error) {
 	return e.cache.evaluateTemplate(ChatMessageTemplate, templateName, messageData)
 }
 
-func (e *Evaluator) templateJinjaChat(templateName string, messageData []ChatMessageTemplateData, funcs []functions.Function) (string, error) {
-
-	conversation := make(map[string]interface{})
-	messages := make([]map[string]interface{}, len(messageData))
-
-	// convert from ChatMessageTemplateData to what the jinja template expects
-
-	for _, message := range messageData {
-		// TODO: this seems to cover minimum text templates. Can be expanded to cover more complex interactions
-		var data []byte
-		data, _ = json.Marshal(message.FunctionCall)
-		messages = append(messages, map[string]interface{}{
-			"role":      message.RoleName,
-			"content":   message.Content,
-			"tool_call": string(data),
-		})
+func (e *Evaluator) templateJinjaChat(config *config.ModelConfig, messages []schema.Message, funcs []functions.Function, documents []schema.Document, reasoningEffort string, enableThinking bool) (string, error) {
+	// the variables of transformers' apply_chat_template
+	conversation := map[string]interface{}{
+		"messages":              jinjaMessages(messages),
+		"add_generation_prompt": true,
+		"bos_token":             config.TemplateConfig.BOSToken,
+		"eos_token":             config.TemplateConfig.EOSToken,
+		"reasoning_effort":      reasoningEffort,
+		"enable_thinking":       enableThinking,
 	}
 
-	conversation["messages"] = messages
-
 	// if tools are detected, add these
 	if len(funcs) > 0 {
-		conversation["tools"] = funcs
+		conversation["tools"] = jinjaTools(funcs)
+	}
+	if len(documents) > 0 {
+		conversation["documents"] = jinjaDocuments(documents)
 	}
 
-	return e.cache.evaluateJinjaTemplate(ChatMessageTemplate, templateName, conversation)
+	res, err := e.cache.evaluateJinjaTemplate(ChatMessageTemplate, config.TemplateConfig.ChatMessage, conversation)
+	if err != nil {
+		return "", fmt.Errorf("failed to render the chat template of the model %q: %w", config.Name, err)
+	}
+	return res, nil
 }
 
 func (e *Evaluator) evaluateJinjaTemplateForPrompt(templateType TemplateType, templateName string, in PromptTemplateData) (string, error) {
@@ -131,36 +155,23 @@ func (e *Evaluator) evaluateJinjaTemplateForPrompt(templateType TemplateType, te
 
 	conversation["system_prompt"] = in.SystemPrompt
 	conversation["content"] = in.Input
+	conversation["reasoning_effort"] = in.ReasoningEffort
+	conversation["enable_thinking"] = in.EnableThinking
+	if len(in.Documents) > 0 {
+		conversation["documents"] = jinjaDocuments(in.Documents)
+	}
 
 	return e.cache.evaluateJinjaTemplate(templateType, templateName, conversation)
 }
 
-func (e *Evaluator) TemplateMessages(input schema.OpenAIRequest, messages []schema.Message, config *config.ModelConfig, funcs []functions.Function, shouldUseFn bool) string {
+// TemplateMessages renders the prompt of the messages. Only jinja templates fail, e.g. when the template
+// raises an exception for the conversation.
+func (e *Evaluator) TemplateMessages(input schema.OpenAIRequest, messages []schema.Message, config *config.ModelConfig, funcs []functions.Function, shouldUseFn bool) (string, error) {
+	budget, hasBudget := config.Reasoning.Budget(input.ReasoningEffort)
+	enableThinking := !hasBudget || budget > 0
 
 	if config.TemplateConfig.JinjaTemplate {
-		var messageData []ChatMessageTemplateData
-		for messageIndex, i := range messages {
-			fcall := i.FunctionCall
-			if len(i.ToolCalls) > 0 {
-				fcall = i.ToolCalls
-			}
-			messageData = append(messageData, ChatMessageTemplateData{
-				SystemPrompt: config.SystemPrompt,
-				Role:         config.Roles[i.Role],
-				RoleName:     i.Role,
-				Content:      i.StringContent,
-				FunctionCall: fcall,
-				FunctionName: i.Name,
-				LastMessage:  messageIndex == (len(messages) - 1),
-				Function:     config.Grammar != "" && (messageIndex == (len(messages) - 1)),
-				MessageIndex: messageIndex,
-			})
-		}
-
-		templatedInput, err := e.templateJinjaChat(config.TemplateConfig.ChatMessage, messageData, funcs)
-		if err == nil {
-			return templatedInput
-		}
+		return e.templateJinjaChat(config, messages, funcs, input.Documents, input.ReasoningEffort, enableThinking)
 	}
 
 	var predInput string
@@ -286,7 +297,9 @@ func (e *Evaluator) TemplateMessages(input schema.OpenAIRequest, messages []sche
 		Input:                predInput,
 		Functions:            funcs,
 		ReasoningEffort:      input.ReasoningEffort,
+		EnableThinking:       enableThinking,
 		Metadata:             input.Metadata,
+		Documents:            input.Documents,
 	})
 	if err == nil {
 		predInput = templatedInput
@@ -295,5 +308,5 @@ func (e *Evaluator) TemplateMessages(input schema.OpenAIRequest, messages []sche
 		log.Debug().Msgf("Template failed loading: %s", err.Error())
 	}
 
-	return predInput
+	return predInput, nil
 }
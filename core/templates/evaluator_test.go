@@ -219,7 +219,8 @@ var _ = Describe("Templates", func() {
 		for key := range chatMLTestMatch {
 			foo := chatMLTestMatch[key]
 			It("renders correctly `"+key+"`", func() {
-				templated := evaluator.TemplateMessages(schema.OpenAIRequest{}, foo["messages"].([]schema.Message), foo["config"].(*config.ModelConfig), foo["functions"].([]functions.Function), foo["shouldUseFn"].(bool))
+				templated, err := evaluator.TemplateMessages(schema.OpenAIRequest{}, foo["messages"].([]schema.Message), foo["config"].(*config.ModelConfig), foo["functions"].([]functions.Function), foo["shouldUseFn"].(bool))
+				Expect(err).ToNot(HaveOccurred())
 				Expect(templated).To(Equal(foo["expected"]), templated)
 			})
 		}
@@ -232,11 +233,34 @@ var _ = Describe("Templates", func() {
 		for key := range llama3TestMatch {
 			foo := llama3TestMatch[key]
 			It("renders correctly `"+key+"`", func() {
-				templated := evaluator.TemplateMessages(schema.OpenAIRequest{}, foo["messages"].([]schema.Message), foo["config"].(*config.ModelConfig), foo["functions"].([]functions.Function), foo["shouldUseFn"].(bool))
+				templated, err := evaluator.TemplateMessages(schema.OpenAIRequest{}, foo["messages"].([]schema.Message), foo["config"].(*config.ModelConfig), foo["functions"].([]functions.Function), foo["shouldUseFn"].(bool))
+				Expect(err).ToNot(HaveOccurred())
 				Expect(templated).To(Equal(foo["expected"]), templated)
 			})
 		}
 	})
+	It("gives the documents of the request to the chat template", func() {
+		evaluator := NewEvaluator("")
+		cfg := &config.ModelConfig{TemplateConfig: config.TemplateConfig{
+			Chat: "{{range .Documents}}{{.Content}} ({{.Metadata.source}})\n{{end}}{{.Input}}",
+		}}
+		input := schema.OpenAIRequest{Documents: []schema.Document{{Content: "Paris is the capital of France.", Metadata: map[string]interface{}{"source": "atlas"}}}}
+		templated, err := evaluator.TemplateMessages(input, []schema.Message{{Role: "user", Content: "What is the capital of France?", StringContent: "What is the capital of France?"}}, cfg, nil, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(templated).To(Equal("Paris is the capital of France. (atlas)\nWhat is the capital of France?"))
+	})
+	It("tells whether the templates of the model use the documents", func() {
+		evaluator := NewEvaluator("")
+		Expect(evaluator.ConsumesDocuments(&config.ModelConfig{TemplateConfig: config.TemplateConfig{
+			Chat: "{{range .Documents}}{{.Content}}\n{{end}}{{.Input}}",
+		}})).To(BeTrue())
+		Expect(evaluator.ConsumesDocuments(&config.ModelConfig{TemplateConfig: config.TemplateConfig{
+			ChatMessage: "{% for doc in documents %}{{ doc.text }}{% endfor %}", JinjaTemplate: true,
+		}})).To(BeTrue())
+		Expect(evaluator.ConsumesDocuments(&config.ModelConfig{TemplateConfig: config.TemplateConfig{Chat: "{{.Input}}"}})).To(BeFalse())
+		Expect(evaluator.ConsumesDocuments(&config.ModelConfig{TemplateConfig: config.TemplateConfig{ChatMessage: toolCallJinja, JinjaTemplate: true}})).To(BeFalse())
+		Expect(evaluator.ConsumesDocuments(&config.ModelConfig{TemplateConfig: config.TemplateConfig{UseTokenizerTemplate: true}})).To(BeFalse())
+	})
 	Context("chat message jinja", func() {
 		var evaluator *Evaluator
 		BeforeEach(func() {
@@ -245,7 +269,8 @@ var _ = Describe("Templates", func() {
 		for key := range jinjaTest {
 			foo := jinjaTest[key]
 			It("renders correctly `"+key+"`", func() {
-				templated := evaluator.TemplateMessages(schema.OpenAIRequest{}, foo["messages"].([]schema.Message), foo["config"].(*config.ModelConfig), foo["functions"].([]functions.Function), foo["shouldUseFn"].(bool))
+				templated, err := evaluator.TemplateMessages(schema.OpenAIRequest{}, foo["messages"].([]schema.Message), foo["config"].(*config.ModelConfig), foo["functions"].([]functions.Function), foo["shouldUseFn"].(bool))
+				Expect(err).ToNot(HaveOccurred())
 				Expect(templated).To(Equal(foo["expected"]), templated)
 			})
 		}
@@ -0,0 +1,1134 @@
+package templates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/builtins"
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// Chat templates of the Hugging Face hub are written for the environment of transformers' apply_chat_template:
+// blocks are trimmed, loops can be controlled, and a few globals are defined. The jinja environment mirrors it.
+
+// the blocks are trimmed by stripBlocks: gonja strips whole lines, and fails on some of the data following them
+var jinjaConfig = config.New()
+
+var jinjaEnvironment = &exec.Environment{
+	Context: gonja.DefaultContext.Inherit().Update(exec.NewContext(map[string]interface{}{
+		"strftime_now": strftimeNow,
+	})),
+	Filters: exec.NewFilterSet(map[string]exec.FilterFunction{}).Update(builtins.Filters).Update(exec.NewFilterSet(map[string]exec.FilterFunction{
+		"tojson":    filterToJSON,
+		"items":     filterItems,
+		sliceFilter: filterSlice,
+	})),
+	Tests: exec.NewTestSet(map[string]exec.TestFunction{}).Update(builtins.Tests).Update(exec.NewTestSet(map[string]exec.TestFunction{
+		"boolean": testBoolean,
+		"true":    testTrue,
+		"false":   testFalse,
+		"integer": testInteger,
+		"float":   testFloat,
+	})),
+	ControlStructures: exec.NewControlStructureSet(map[string]parser.ControlStructureParser{}).Update(builtins.ControlStructures).Update(exec.NewControlStructureSet(map[string]parser.ControlStructureParser{
+		"if":       ifParser,
+		"for":      forParser,
+		"break":    loopControlParser(errLoopBreak),
+		"continue": loopControlParser(errLoopContinue),
+	})),
+	Methods: exec.Methods{
+		Bool:  builtins.Methods.Bool,
+		Int:   builtins.Methods.Int,
+		Float: builtins.Methods.Float,
+		Str:   builtins.Methods.Str,
+		Dict:  dictMethods,
+		List:  builtins.Methods.List,
+	},
+}
+
+// TemplateError is an error raised by a template with raise_exception
+type TemplateError struct {
+	Message string
+}
+
+func (e *TemplateError) Error() string {
+	return e.Message
+}
+
+// newJinjaTemplate parses a jinja template
+func newJinjaTemplate(source string) (*exec.Template, error) {
+	rootID := fmt.Sprintf("root-%x", sha256.Sum256([]byte(source)))
+
+	loader, err := loaders.NewFileSystemLoader("")
+	if err != nil {
+		return nil, err
+	}
+	shiftedLoader, err := loaders.NewShiftedLoader(rootID, strings.NewReader(rewriteJinja(source)), loader)
+	if err != nil {
+		return nil, err
+	}
+	return exec.NewTemplate(rootID, jinjaConfig, shiftedLoader, jinjaEnvironment)
+}
+
+// executeJinjaTemplate renders the template, returning the exception the template raised, if any
+func executeJinjaTemplate(tmpl *exec.Template, in map[string]interface{}) (string, error) {
+	// the exception is recorded as gonja does not keep the errors of the functions it calls
+	var raised *TemplateError
+	data := make(map[string]interface{}, len(in)+1)
+	for k, v := range in {
+		data[k] = v
+	}
+	data["raise_exception"] = func(_ *exec.Evaluator, params *exec.VarArgs) (*exec.Value, error) {
+		raised = &TemplateError{}
+		if len(params.Args) > 0 {
+			raised.Message = params.Args[0].String()
+		}
+		return nil, raised
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, exec.NewContext(data)); err != nil {
+		if raised != nil {
+			return "", raised
+		}
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// strftimeNow formats the current time with the directives of Python's strftime
+func strftimeNow(_ *exec.Evaluator, params *exec.VarArgs) (*exec.Value, error) {
+	if len(params.Args) != 1 || !params.Args[0].IsString() {
+		return nil, errors.New("strftime_now expects a format")
+	}
+	return exec.AsValue(strftime(params.Args[0].String(), time.Now())), nil
+}
+
+var strftimeDirectives = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'H': "15",
+	'I': "03",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'S': "05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+}
+
+func strftime(format string, t time.Time) string {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch d := format[i]; d {
+		case '%':
+			sb.WriteByte('%')
+		case '-':
+			// %-d and friends, without padding
+			if i+1 < len(format) {
+				i++
+				sb.WriteString(strings.TrimLeft(strftime("%"+string(format[i]), t), "0"))
+			}
+		case 'j':
+			sb.WriteString(fmt.Sprintf("%03d", t.YearDay()))
+		default:
+			if layout, ok := strftimeDirectives[d]; ok {
+				sb.WriteString(t.Format(layout))
+			} else {
+				sb.WriteByte('%')
+				sb.WriteByte(d)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// filterToJSON serializes like Python's json.dumps, which transformers uses: objects keep the order of
+// their keys, and items are separated by ", " unless indented
+func filterToJSON(_ *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	p := params.Expect(0, []*exec.KwArg{{Name: "indent", Default: nil}, {Name: "ensure_ascii", Default: false}, {Name: "sort_keys", Default: false}})
+	if p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for 'tojson': %w", p))
+	}
+	indent := ""
+	if i := p.KwArgs["indent"]; !i.IsNil() {
+		if !i.IsInteger() {
+			return exec.AsValue(fmt.Errorf("expected an integer for 'indent', got %s", i.String()))
+		}
+		indent = strings.Repeat(" ", i.Integer())
+	}
+
+	var sb strings.Builder
+	if err := writeJSON(&sb, in, indent, "\n", p.KwArgs["sort_keys"].IsTrue()); err != nil {
+		return exec.AsValue(err)
+	}
+	return exec.AsSafeValue(sb.String())
+}
+
+func writeJSON(sb *strings.Builder, v *exec.Value, indent, newline string, sortKeys bool) error {
+	v = resolveValue(v)
+	itemSep, keySep := ", ", ": "
+	if indent != "" {
+		itemSep = ","
+	}
+	open := func(c byte, empty bool) {
+		sb.WriteByte(c)
+		if indent != "" && !empty {
+			sb.WriteString(newline + indent)
+		}
+	}
+	closing := func(c byte, empty bool) {
+		if indent != "" && !empty {
+			sb.WriteString(newline)
+		}
+		sb.WriteByte(c)
+	}
+	separate := func() {
+		sb.WriteString(itemSep)
+		if indent != "" {
+			sb.WriteString(newline + indent)
+		}
+	}
+
+	switch {
+	case v.IsNil():
+		sb.WriteString("null")
+	case v.IsBool():
+		sb.WriteString(strconv.FormatBool(v.Bool()))
+	case v.IsInteger():
+		sb.WriteString(strconv.Itoa(v.Integer()))
+	case v.IsFloat():
+		f := v.Float()
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eE") && f == float64(int64(f)) {
+			s += ".0"
+		}
+		sb.WriteString(s)
+	case v.IsString():
+		if err := writeJSONString(sb, v.String()); err != nil {
+			return err
+		}
+	case v.IsDict():
+		keys := dictKeys(v)
+		if sortKeys {
+			sort.Strings(keys)
+		}
+		open('{', len(keys) == 0)
+		for i, k := range keys {
+			if i > 0 {
+				separate()
+			}
+			if err := writeJSONString(sb, k); err != nil {
+				return err
+			}
+			sb.WriteString(keySep)
+			item, _ := v.GetItem(k)
+			if err := writeJSON(sb, item, indent, newline+indent, sortKeys); err != nil {
+				return err
+			}
+		}
+		closing('}', len(keys) == 0)
+	case v.IsList():
+		open('[', v.Len() == 0)
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				separate()
+			}
+			if err := writeJSON(sb, v.Index(i), indent, newline+indent, sortKeys); err != nil {
+				return err
+			}
+		}
+		closing(']', v.Len() == 0)
+	default:
+		return fmt.Errorf("unable to serialize %s to JSON", v.String())
+	}
+	return nil
+}
+
+// writeJSONString writes the string like ensure_ascii=False
+func writeJSONString(sb *strings.Builder, s string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	sb.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return nil
+}
+
+// resolveValue returns the value held by v: the items of the lists of templates are values
+func resolveValue(v *exec.Value) *exec.Value {
+	for v.Val.IsValid() && v.Val.Kind() == reflect.Ptr {
+		inner, ok := v.Val.Interface().(*exec.Value)
+		if !ok {
+			break
+		}
+		v = inner
+	}
+	return v
+}
+
+// filterItems returns the key and value pairs of a mapping, like dict.items()
+func filterItems(_ *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if !in.IsDict() {
+		return exec.AsValue(fmt.Errorf("items expects a mapping, got %s", in.String()))
+	}
+	return exec.AsValue(dictItems(in))
+}
+
+func dictItems(v *exec.Value) [][]interface{} {
+	items := [][]interface{}{}
+	for _, k := range dictKeys(v) {
+		item, _ := v.GetItem(k)
+		items = append(items, []interface{}{k, item.Interface()})
+	}
+	return items
+}
+
+// dictKeys returns the keys of a mapping, in the order of their definition for the objects of the
+// conversation, which are decoded from JSON to ordered dicts
+func dictKeys(v *exec.Value) []string {
+	keys := []string{}
+	for _, k := range v.Keys() {
+		keys = append(keys, k.String())
+	}
+	return keys
+}
+
+var dictMethods = exec.NewMethodSet[map[string]interface{}](map[string]exec.Method[map[string]interface{}]{
+	"keys": func(_ map[string]interface{}, self *exec.Value, arguments *exec.VarArgs) (interface{}, error) {
+		if err := arguments.Take(); err != nil {
+			return nil, exec.ErrInvalidCall(err)
+		}
+		return dictKeys(self), nil
+	},
+	"values": func(_ map[string]interface{}, self *exec.Value, arguments *exec.VarArgs) (interface{}, error) {
+		if err := arguments.Take(); err != nil {
+			return nil, exec.ErrInvalidCall(err)
+		}
+		values := []interface{}{}
+		for _, item := range dictItems(self) {
+			values = append(values, item[1])
+		}
+		return values, nil
+	},
+	"items": func(_ map[string]interface{}, self *exec.Value, arguments *exec.VarArgs) (interface{}, error) {
+		if err := arguments.Take(); err != nil {
+			return nil, exec.ErrInvalidCall(err)
+		}
+		return dictItems(self), nil
+	},
+	"get": func(self map[string]interface{}, _ *exec.Value, arguments *exec.VarArgs) (interface{}, error) {
+		var key string
+		var fallback interface{}
+		if err := arguments.Take(
+			exec.PositionalArgument("key", nil, exec.StringArgument(&key)),
+			exec.PositionalArgument("default", exec.AsValue(nil), exec.AnyArgument(&fallback)),
+		); err != nil {
+			return nil, exec.ErrInvalidCall(err)
+		}
+		if v, ok := self[key]; ok {
+			return v, nil
+		}
+		return fallback, nil
+	},
+})
+
+func testBoolean(_ *exec.Context, in *exec.Value, _ *exec.VarArgs) (bool, error) {
+	return in.IsBool(), nil
+}
+
+func testTrue(_ *exec.Context, in *exec.Value, _ *exec.VarArgs) (bool, error) {
+	return in.IsBool() && in.Bool(), nil
+}
+
+func testFalse(_ *exec.Context, in *exec.Value, _ *exec.VarArgs) (bool, error) {
+	return in.IsBool() && !in.Bool(), nil
+}
+
+func testInteger(_ *exec.Context, in *exec.Value, _ *exec.VarArgs) (bool, error) {
+	return in.IsInteger(), nil
+}
+
+func testFloat(_ *exec.Context, in *exec.Value, _ *exec.VarArgs) (bool, error) {
+	return in.IsFloat(), nil
+}
+
+var (
+	errLoopBreak    = errors.New("break outside of a loop")
+	errLoopContinue = errors.New("continue outside of a loop")
+)
+
+// loopControl is a break or continue statement
+type loopControl struct {
+	location *tokens.Token
+	err      error
+}
+
+func (c *loopControl) Position() *tokens.Token {
+	return c.location
+}
+
+func (c *loopControl) String() string {
+	return fmt.Sprintf("LoopControl(Line=%d Col=%d)", c.location.Line, c.location.Col)
+}
+
+func (c *loopControl) Execute(*exec.Renderer, *nodes.ControlStructureBlock) error {
+	return c.err
+}
+
+func loopControlParser(err error) parser.ControlStructureParser {
+	return func(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+		c := &loopControl{location: p.Current(), err: err}
+		if !args.End() {
+			return nil, args.Error("Arguments not allowed here.", nil)
+		}
+		return c, nil
+	}
+}
+
+// ifStatement is the if statement of gonja, whose blocks are in the scope of the statement like in
+// Jinja: variables set in the blocks are visible after the statement
+type ifStatement struct {
+	location   *tokens.Token
+	conditions []nodes.Expression
+	wrappers   []*nodes.Wrapper
+}
+
+func (s *ifStatement) Position() *tokens.Token {
+	return s.location
+}
+
+func (s *ifStatement) String() string {
+	return fmt.Sprintf("IfStatement(Line=%d Col=%d)", s.location.Line, s.location.Col)
+}
+
+func (s *ifStatement) Execute(r *exec.Renderer, _ *nodes.ControlStructureBlock) error {
+	for i, condition := range s.conditions {
+		result := r.Eval(condition)
+		if result.IsError() {
+			return result
+		}
+		if result.IsTrue() {
+			return nodes.Walk(r, s.wrappers[i])
+		}
+	}
+	if len(s.wrappers) > len(s.conditions) {
+		// else
+		return nodes.Walk(r, s.wrappers[len(s.conditions)])
+	}
+	return nil
+}
+
+func ifParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	s := &ifStatement{location: args.Current()}
+
+	condition, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	s.conditions = append(s.conditions, condition)
+	if !args.End() {
+		return nil, args.Error("If-condition is malformed.", nil)
+	}
+
+	for {
+		wrapper, tagArgs, err := p.WrapUntil("elif", "else", "endif")
+		if err != nil {
+			return nil, err
+		}
+		s.wrappers = append(s.wrappers, wrapper)
+
+		if wrapper.EndTag == "elif" {
+			condition, err := tagArgs.ParseExpression()
+			if err != nil {
+				return nil, err
+			}
+			s.conditions = append(s.conditions, condition)
+			if !tagArgs.End() {
+				return nil, tagArgs.Error("Elif-condition is malformed.", nil)
+			}
+		} else if !tagArgs.End() {
+			return nil, tagArgs.Error("Arguments not allowed here.", nil)
+		}
+
+		if wrapper.EndTag == "endif" {
+			return s, nil
+		}
+	}
+}
+
+// forLoop is the for statement of gonja, with break and continue
+type forLoop struct {
+	location    *tokens.Token
+	key         string
+	value       string
+	object      nodes.Expression
+	ifCondition nodes.Expression
+	body        *nodes.Wrapper
+	empty       *nodes.Wrapper
+}
+
+func (l *forLoop) Position() *tokens.Token {
+	return l.location
+}
+
+func (l *forLoop) String() string {
+	return fmt.Sprintf("ForLoop(Line=%d Col=%d)", l.location.Line, l.location.Col)
+}
+
+func (l *forLoop) Execute(r *exec.Renderer, _ *nodes.ControlStructureBlock) error {
+	obj := r.Eval(l.object)
+	if obj.IsError() {
+		return obj
+	}
+
+	type item struct {
+		key, value *exec.Value
+	}
+	bind := func(ctx *exec.Context, it item) {
+		ctx.Set(l.key, it.key)
+		if it.value != nil {
+			ctx.Set(l.value, it.value)
+		}
+	}
+
+	var items []item
+	obj.Iterate(func(_, _ int, key, value *exec.Value) bool {
+		it := item{key: key, value: value}
+		if l.value != "" && !key.IsString() && key.Len() == 2 {
+			// unpacking of pairs
+			it = item{key: key.Index(0), value: key.Index(1)}
+		}
+		if l.ifCondition != nil {
+			sub := r.Inherit()
+			bind(sub.Environment.Context, it)
+			if !sub.Eval(l.ifCondition).IsTrue() {
+				return true
+			}
+		}
+		items = append(items, it)
+		return true
+	}, func() {})
+
+	if len(items) == 0 && l.empty != nil {
+		return r.Inherit().ExecuteWrapper(l.empty)
+	}
+
+	for i, it := range items {
+		sub := r.Inherit()
+		ctx := sub.Environment.Context
+		bind(ctx, it)
+
+		loop := map[string]interface{}{
+			"index":     i + 1,
+			"index0":    i,
+			"revindex":  len(items) - i,
+			"revindex0": len(items) - i - 1,
+			"first":     i == 0,
+			"last":      i == len(items)-1,
+			"length":    len(items),
+			"previtem":  nil,
+			"nextitem":  nil,
+		}
+		if i > 0 {
+			loop["previtem"] = items[i-1].key
+		}
+		if i < len(items)-1 {
+			loop["nextitem"] = items[i+1].key
+		}
+		ctx.Set("loop", loop)
+
+		err := sub.ExecuteWrapper(l.body)
+		switch {
+		case errors.Is(err, errLoopBreak):
+			return nil
+		case errors.Is(err, errLoopContinue):
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+func forParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	l := &forLoop{location: p.Current()}
+
+	keyToken := args.Match(tokens.Name)
+	if keyToken == nil {
+		return nil, args.Error("Expected an key identifier as first argument for 'for'-tag", nil)
+	}
+	l.key = keyToken.Val
+	if args.Match(tokens.Comma) != nil {
+		valueToken := args.Match(tokens.Name)
+		if valueToken == nil {
+			return nil, args.Error("Value name must be an identifier.", nil)
+		}
+		l.value = valueToken.Val
+	}
+	if args.Match(tokens.In) == nil {
+		return nil, args.Error("Expected keyword 'in'.", nil)
+	}
+
+	object, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	l.object = object
+
+	if args.MatchName("if") != nil {
+		if l.ifCondition, err = args.ParseExpression(); err != nil {
+			return nil, err
+		}
+	}
+	if !args.End() {
+		return nil, args.Error("Malformed for-loop args.", nil)
+	}
+
+	wrapper, endargs, err := p.WrapUntil("else", "endfor")
+	if err != nil {
+		return nil, err
+	}
+	l.body = wrapper
+	if !endargs.End() {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	if wrapper.EndTag == "else" {
+		if l.empty, endargs, err = p.WrapUntil("endfor"); err != nil {
+			return nil, err
+		}
+		if !endargs.End() {
+			return nil, endargs.Error("Arguments not allowed here.", nil)
+		}
+	}
+	return l, nil
+}
+
+// Jinja binds filters tighter than operators, as in `'a' + x | trim`, but gonja applies them to whole
+// expressions, and it does not support the steps of slices, as in `messages[::-1]`. The sources are
+// rewritten before being parsed: filters are parenthesized with their operand, sliced values are
+// piped to the slice filter, and the whitespace starting the lines of blocks is removed.
+
+const sliceFilter = "jinja_slice"
+
+// jinjaKeywords are the names which cannot end an operand
+var jinjaKeywords = map[string]bool{
+	"if": true, "else": true, "elif": true, "for": true, "recursive": true, "set": true,
+	"import": true, "from": true, "as": true, "with": true,
+}
+
+// expressionTokens lexes the source, returning the tokens of its expressions and statements
+func expressionTokens(source string) []*tokens.Token {
+	var res []*tokens.Token
+	stream := tokens.Lex(source, jinjaConfig)
+	for !stream.End() {
+		t := stream.Next()
+		if t.Type == tokens.Error {
+			// the parser reports it
+			return nil
+		}
+		if t.Type != tokens.Whitespace {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+type insertion struct {
+	pos  int
+	text string
+}
+
+func applyInsertions(source string, insertions []insertion) string {
+	sort.SliceStable(insertions, func(i, j int) bool { return insertions[i].pos < insertions[j].pos })
+	var sb strings.Builder
+	last := 0
+	for _, i := range insertions {
+		sb.WriteString(source[last:i.pos])
+		sb.WriteString(i.text)
+		last = i.pos
+	}
+	sb.WriteString(source[last:])
+	return sb.String()
+}
+
+func tokenEnd(t *tokens.Token) int {
+	return t.Pos + len(t.Val)
+}
+
+// matching returns the index of the token closing or opening the bracket at i
+func matching(toks []*tokens.Token, i int) int {
+	pairs := map[tokens.Type]tokens.Type{
+		tokens.LeftParenthesis: tokens.RightParenthesis, tokens.LeftBracket: tokens.RightBracket, tokens.LeftBrace: tokens.RightBrace,
+		tokens.RightParenthesis: tokens.LeftParenthesis, tokens.RightBracket: tokens.LeftBracket, tokens.RightBrace: tokens.LeftBrace,
+	}
+	open, close := toks[i].Type, pairs[toks[i].Type]
+	step := 1
+	switch open {
+	case tokens.RightParenthesis, tokens.RightBracket, tokens.RightBrace:
+		step = -1
+	}
+	depth := 0
+	for j := i; j >= 0 && j < len(toks); j += step {
+		switch toks[j].Type {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// endsOperand tells whether the token at i ends an operand which can be followed by an attribute,
+// a subscript or a call
+func endsOperand(toks []*tokens.Token, i int) bool {
+	if i < 0 {
+		return false
+	}
+	switch toks[i].Type {
+	case tokens.Name:
+		return !jinjaKeywords[strings.TrimSpace(toks[i].Val)] && (i == 0 || toks[i-1].Type != tokens.BlockBegin)
+	case tokens.String, tokens.RightParenthesis, tokens.RightBracket:
+		return true
+	}
+	return false
+}
+
+// operandStart returns the index of the first token of the operand ending at i, or -1
+func operandStart(toks []*tokens.Token, i int) int {
+	for i >= 0 {
+		switch toks[i].Type {
+		case tokens.RightParenthesis, tokens.RightBracket, tokens.RightBrace:
+			i = matching(toks, i)
+			if i < 0 || toks[i].Type == tokens.LeftBrace || !endsOperand(toks, i-1) {
+				return i
+			}
+			i--
+		case tokens.Name:
+			if !endsOperand(toks, i) {
+				return -1
+			}
+			if i > 0 && toks[i-1].Type == tokens.Dot {
+				i -= 2
+				continue
+			}
+			return i
+		case tokens.String, tokens.Integer, tokens.Float:
+			return i
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// rewriteJinja rewrites the source of a template to be parsed by gonja like by Jinja
+func rewriteJinja(source string) string {
+	// Jinja drops the newline ending the templates
+	source = strings.TrimSuffix(source, "\n")
+	return bindFilters(rewriteSlices(spaceOperators(stripBlocks(source))))
+}
+
+// spaceOperators separates the operators from the parentheses following them, as in `not(x)`, which
+// gonja reads as calls
+func spaceOperators(source string) string {
+	var insertions []insertion
+	toks := expressionTokens(source)
+	for i, t := range toks {
+		if t.Type != tokens.Name || i+1 == len(toks) || (i > 0 && toks[i-1].Type == tokens.Dot) {
+			continue
+		}
+		switch strings.TrimSpace(t.Val) {
+		case "not", "and", "or", "in", "is":
+			if next := toks[i+1]; (next.Type == tokens.LeftParenthesis || next.Type == tokens.LeftBracket) && next.Pos == tokenEnd(t) {
+				insertions = append(insertions, insertion{next.Pos, " "})
+			}
+		}
+	}
+	return applyInsertions(source, insertions)
+}
+
+// stripBlocks removes the spaces and tabs between the start of a line and a block, and the newline following
+// a block, like lstrip_blocks and trim_blocks
+func stripBlocks(source string) string {
+	toks := expressionTokens(source)
+	var sb strings.Builder
+	last := 0
+	skip := func(from, to int) {
+		sb.WriteString(source[last:from])
+		last = to
+	}
+	for i, t := range toks {
+		switch {
+		case t.Type == tokens.BlockEnd && !strings.HasPrefix(t.Val, "+") && i+1 < len(toks) && toks[i+1].Type == tokens.Data:
+			data := toks[i+1]
+			if strings.HasPrefix(data.Val, "\r\n") {
+				skip(data.Pos, data.Pos+2)
+			} else if strings.HasPrefix(data.Val, "\n") {
+				skip(data.Pos, data.Pos+1)
+			}
+		case t.Type == tokens.BlockBegin && !strings.HasSuffix(t.Val, "+") && i > 0 && toks[i-1].Type == tokens.Data:
+			data := toks[i-1]
+			lineStart := strings.LastIndexByte(data.Val, '\n') + 1
+			if lineStart == 0 && data.Pos != 0 {
+				continue
+			}
+			if strings.Trim(data.Val[lineStart:], " \t") == "" {
+				skip(max(data.Pos+lineStart, last), t.Pos)
+			}
+		}
+	}
+	sb.WriteString(source[last:])
+	return sb.String()
+}
+
+// bindFilters parenthesizes the filters with their operand
+func bindFilters(source string) string {
+	toks := expressionTokens(source)
+	var insertions []insertion
+	chained := map[int]bool{}
+	for i, t := range toks {
+		if t.Type != tokens.Pipe || chained[i] {
+			continue
+		}
+		start := operandStart(toks, i-1)
+		if start < 0 {
+			continue
+		}
+		end := i
+		for end < len(toks) && toks[end].Type == tokens.Pipe && end+1 < len(toks) && toks[end+1].Type == tokens.Name {
+			chained[end] = true
+			end++
+			if end+1 < len(toks) && toks[end+1].Type == tokens.LeftParenthesis {
+				if end = matching(toks, end+1); end < 0 {
+					return source
+				}
+			}
+			end++
+		}
+		if end == i {
+			continue
+		}
+		insertions = append(insertions, insertion{toks[start].Pos, "("}, insertion{tokenEnd(toks[end-1]), ")"})
+	}
+	return applyInsertions(source, insertions)
+}
+
+// rewriteSlices pipes the values sliced with a step to the slice filter: `x[a:b:c]` is rewritten to
+// `x|jinja_slice(a, b, c)`
+func rewriteSlices(source string) string {
+	toks := expressionTokens(source)
+	var sb strings.Builder
+	last := 0
+	for i := 0; i < len(toks); i++ {
+		if toks[i].Type != tokens.LeftBracket || !endsOperand(toks, i-1) {
+			continue
+		}
+		end := matching(toks, i)
+		if end < 0 {
+			return source
+		}
+		var colons []int
+		for j := i + 1; j < end; j++ {
+			switch toks[j].Type {
+			case tokens.LeftParenthesis, tokens.LeftBracket, tokens.LeftBrace:
+				j = matching(toks, j)
+			case tokens.Colon:
+				colons = append(colons, j)
+			}
+		}
+		if len(colons) != 2 {
+			continue
+		}
+		bounds := []int{i, colons[0], colons[1], end}
+		args := make([]string, 3)
+		for k := range args {
+			args[k] = strings.TrimSpace(source[tokenEnd(toks[bounds[k]]):toks[bounds[k+1]].Pos])
+			if args[k] == "" {
+				args[k] = "none"
+			}
+		}
+		sb.WriteString(source[last:toks[i].Pos])
+		fmt.Fprintf(&sb, "|%s(%s)", sliceFilter, strings.Join(args, ", "))
+		last = tokenEnd(toks[end])
+	}
+	sb.WriteString(source[last:])
+	return sb.String()
+}
+
+// filterSlice slices lists and strings like Python
+func filterSlice(_ *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsError() {
+		return in
+	}
+	if !in.IsList() && !in.IsString() {
+		return exec.AsValue(fmt.Errorf("cannot slice %s", in.String()))
+	}
+	p := params.ExpectArgs(3)
+	if p.IsError() {
+		return exec.AsValue(fmt.Errorf("wrong signature for slicing: %w", p))
+	}
+	var bounds [3]*int
+	for k, arg := range p.Args {
+		if arg.IsNil() {
+			continue
+		}
+		if !arg.IsInteger() {
+			return exec.AsValue(fmt.Errorf("slice indices must be integers, got %s", arg.String()))
+		}
+		v := arg.Integer()
+		bounds[k] = &v
+	}
+
+	n, step := in.Len(), 1
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return exec.AsValue(errors.New("slice step cannot be zero"))
+	}
+	index := func(b *int, def int) int {
+		if b == nil {
+			return def
+		}
+		i := *b
+		if i < 0 {
+			i += n
+		}
+		lower, upper := 0, n
+		if step < 0 {
+			lower, upper = -1, n-1
+		}
+		return min(max(i, lower), upper)
+	}
+	start, stop := index(bounds[0], 0), index(bounds[1], n)
+	if step < 0 {
+		start, stop = index(bounds[0], n-1), index(bounds[1], -1)
+	}
+
+	var items []*exec.Value
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		items = append(items, resolveValue(in.Index(i)))
+	}
+	if in.IsString() {
+		var sb strings.Builder
+		for _, item := range items {
+			sb.WriteString(item.String())
+		}
+		return exec.AsValue(sb.String())
+	}
+	res := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		res = append(res, item.Interface())
+	}
+	return exec.AsValue(res)
+}
+
+// jinjaMessages converts the messages to the ones of transformers' chat templates. Tool calls are
+// of type function, and their arguments are decoded to objects.
+func jinjaMessages(messages []schema.Message) []interface{} {
+	res := make([]interface{}, 0, len(messages))
+	for _, m := range messages {
+		msg := exec.NewDict()
+		set := func(k string, v interface{}) {
+			msg.Pairs = append(msg.Pairs, &exec.Pair{Key: exec.AsValue(k), Value: exec.AsValue(v)})
+		}
+
+		toolCalls := []interface{}{}
+		for _, tc := range m.ToolCalls {
+			toolCalls = append(toolCalls, jinjaToolCall(tc.ID, tc.FunctionCall.Name, tc.FunctionCall.Arguments))
+		}
+		if len(m.ToolCalls) == 0 && m.FunctionCall != nil {
+			var fc schema.FunctionCall
+			if d, err := json.Marshal(m.FunctionCall); err == nil && json.Unmarshal(d, &fc) == nil {
+				toolCalls = append(toolCalls, jinjaToolCall("", fc.Name, fc.Arguments))
+			}
+		}
+
+		set("role", m.Role)
+		if m.StringContent == "" && len(toolCalls) > 0 {
+			set("content", nil)
+		} else {
+			set("content", m.StringContent)
+		}
+		if m.ReasoningContent != "" {
+			set("reasoning_content", m.ReasoningContent)
+		}
+		if m.Name != "" {
+			set("name", m.Name)
+		}
+		if len(toolCalls) > 0 {
+			set("tool_calls", toolCalls)
+		}
+		if m.ToolCallID != "" {
+			set("tool_call_id", m.ToolCallID)
+		}
+		res = append(res, msg)
+	}
+	return res
+}
+
+func jinjaToolCall(id, name, arguments string) *exec.Dict {
+	var args interface{} = arguments
+	if v, err := jinjaValue([]byte(arguments)); err == nil {
+		args = v
+	}
+
+	function := exec.NewDict()
+	function.Pairs = []*exec.Pair{
+		{Key: exec.AsValue("name"), Value: exec.AsValue(name)},
+		{Key: exec.AsValue("arguments"), Value: exec.AsValue(args)},
+	}
+	call := exec.NewDict()
+	if id != "" {
+		call.Pairs = append(call.Pairs, &exec.Pair{Key: exec.AsValue("id"), Value: exec.AsValue(id)})
+	}
+	call.Pairs = append(call.Pairs,
+		&exec.Pair{Key: exec.AsValue("type"), Value: exec.AsValue("function")},
+		&exec.Pair{Key: exec.AsValue("function"), Value: exec.AsValue(function)},
+	)
+	return call
+}
+
+// jinjaDocuments converts the documents to the documents of transformers' apply_chat_template, whose text is
+// their content. Their metadata, e.g. a title, is set on them as well.
+func jinjaDocuments(documents []schema.Document) []interface{} {
+	res := make([]interface{}, 0, len(documents))
+	for _, doc := range documents {
+		d := exec.NewDict()
+		if doc.Metadata != nil {
+			if data, err := json.Marshal(doc.Metadata); err == nil {
+				if v, err := jinjaValue(data); err == nil {
+					d = v.(*exec.Dict)
+				}
+			}
+		}
+		d.Pairs = append(d.Pairs, &exec.Pair{Key: exec.AsValue("text"), Value: exec.AsValue(doc.Content)})
+		res = append(res, d)
+	}
+	return res
+}
+
+// jinjaTools converts the functions to the tools of the OpenAI API, which chat templates expect
+func jinjaTools(funcs []functions.Function) []interface{} {
+	res := make([]interface{}, 0, len(funcs))
+	for _, f := range funcs {
+		function := exec.NewDict()
+		function.Pairs = append(function.Pairs, &exec.Pair{Key: exec.AsValue("name"), Value: exec.AsValue(f.Name)})
+		if f.Description != "" {
+			function.Pairs = append(function.Pairs, &exec.Pair{Key: exec.AsValue("description"), Value: exec.AsValue(f.Description)})
+		}
+		if f.Parameters != nil {
+			var params interface{} = f.Parameters
+			if d, err := json.Marshal(f.Parameters); err == nil {
+				if v, err := jinjaValue(d); err == nil {
+					params = v
+				}
+			}
+			function.Pairs = append(function.Pairs, &exec.Pair{Key: exec.AsValue("parameters"), Value: exec.AsValue(params)})
+		}
+
+		tool := exec.NewDict()
+		tool.Pairs = []*exec.Pair{
+			{Key: exec.AsValue("type"), Value: exec.AsValue("function")},
+			{Key: exec.AsValue("function"), Value: exec.AsValue(function)},
+		}
+		res = append(res, tool)
+	}
+	return res
+}
+
+// jinjaValue decodes JSON, keeping the order of the keys of objects
+func jinjaValue(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	v, err := decodeJinjaValue(d)
+	if err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+func decodeJinjaValue(d *json.Decoder) (interface{}, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		if t == '[' {
+			list := []interface{}{}
+			for d.More() {
+				v, err := decodeJinjaValue(d)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			_, err := d.Token()
+			return list, err
+		}
+		dict := exec.NewDict()
+		for d.More() {
+			k, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJinjaValue(d)
+			if err != nil {
+				return nil, err
+			}
+			dict.Pairs = append(dict.Pairs, &exec.Pair{Key: exec.AsValue(k), Value: exec.AsValue(v)})
+		}
+		_, err := d.Token()
+		return dict, err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return int(i), nil
+		}
+		return t.Float64()
+	default:
+		return t, nil
+	}
+}
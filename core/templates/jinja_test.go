@@ -0,0 +1,182 @@
+package templates_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	. "github.com/mudler/LocalAI/core/templates"
+	"github.com/mudler/LocalAI/pkg/functions"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type conversation struct {
+	messages  []schema.Message
+	functions []functions.Function
+}
+
+func message(role, content string) schema.Message {
+	return schema.Message{Role: role, Content: content, StringContent: content}
+}
+
+// conversations are rendered with the chat templates of testdata/chat_templates: each <model>.<conversation>.txt
+// file is the expected prompt, and each <model>.<conversation>.error file the exception the template raises
+var conversations = map[string]conversation{
+	"chat": {messages: []schema.Message{
+		message("system", "You are a helpful assistant."),
+		message("user", "Hello!"),
+		message("assistant", "Hi! How can I help you?"),
+		message("user", "What is the capital of France?"),
+	}},
+	"alternating": {messages: []schema.Message{
+		message("user", "Hello!"),
+		message("assistant", "Hi! How can I help you?"),
+		message("user", "What is the capital of France?"),
+	}},
+	"tools": {
+		messages: []schema.Message{
+			message("system", "You are a helpful assistant."),
+			message("user", "What is the weather in Rome?"),
+			{Role: "assistant", ToolCalls: []schema.ToolCall{{ID: "call_1", Type: "function", FunctionCall: schema.FunctionCall{Name: "get_weather", Arguments: `{"city": "Rome"}`}}}},
+			{Role: "tool", Name: "get_weather", ToolCallID: "call_1", Content: "sunny", StringContent: "sunny"},
+		},
+		functions: []functions.Function{{
+			Name:        "get_weather",
+			Description: "Returns the weather of a city",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+				"required":   []string{"city"},
+			},
+		}},
+	},
+	"reasoning": {messages: []schema.Message{
+		message("user", "What is 2+2?"),
+		message("assistant", "<think>\nSimple math.\n</think>\n\n4"),
+		message("user", "And 3+3?"),
+	}},
+}
+
+// specialTokens are the bos_token and eos_token of the models of the fixtures
+var specialTokens = map[string][2]string{
+	"llama3":   {"<|begin_of_text|>", "<|eot_id|>"},
+	"llama3.1": {"<|begin_of_text|>", "<|eot_id|>"},
+	"mistral":  {"<s>", "</s>"},
+	"gemma":    {"<bos>", "<eos>"},
+}
+
+func jinjaConfig(name, template string) *config.ModelConfig {
+	tokens := specialTokens[name]
+	return &config.ModelConfig{
+		Name: name,
+		TemplateConfig: config.TemplateConfig{
+			ChatMessage:   template,
+			JinjaTemplate: true,
+			BOSToken:      tokens[0],
+			EOSToken:      tokens[1],
+		},
+	}
+}
+
+var _ = Describe("Jinja chat templates", func() {
+	var evaluator *Evaluator
+	BeforeEach(func() {
+		evaluator = NewEvaluator("")
+	})
+
+	render := func(cfg *config.ModelConfig, c conversation) (string, error) {
+		return evaluator.TemplateMessages(schema.OpenAIRequest{}, c.messages, cfg, c.functions, len(c.functions) > 0)
+	}
+
+	fixtures, err := filepath.Glob("testdata/chat_templates/*.*.*")
+	if err != nil {
+		panic(err)
+	}
+	for _, fixture := range fixtures {
+		ext := filepath.Ext(fixture)
+		if ext != ".txt" && ext != ".error" {
+			continue
+		}
+		base := strings.TrimSuffix(filepath.Base(fixture), ext)
+		model, name := base[:strings.LastIndex(base, ".")], base[strings.LastIndex(base, ".")+1:]
+
+		It("renders the "+name+" conversation with the template of "+model, func() {
+			c, ok := conversations[name]
+			Expect(ok).To(BeTrue(), "unknown conversation %s", name)
+			template, err := os.ReadFile(filepath.Join("testdata/chat_templates", model+".jinja"))
+			Expect(err).ToNot(HaveOccurred())
+			expected, err := os.ReadFile(fixture)
+			Expect(err).ToNot(HaveOccurred())
+
+			prompt, err := render(jinjaConfig(model, string(template)), c)
+			if ext == ".error" {
+				Expect(err).To(MatchError(ContainSubstring(string(expected))))
+				Expect(err).To(MatchError(ContainSubstring(`model "` + model + `"`)))
+				var templateErr *TemplateError
+				Expect(errors.As(err, &templateErr)).To(BeTrue())
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(prompt).To(Equal(string(expected)))
+		})
+	}
+
+	It("defines strftime_now", func() {
+		prompt, err := render(jinjaConfig("dated", `{{ strftime_now("%d %b %Y") }}`), conversations["chat"])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prompt).To(BeElementOf(time.Now().Format("02 Jan 2006"), time.Now().Add(-time.Minute).Format("02 Jan 2006")))
+	})
+
+	It("controls loops", func() {
+		prompt, err := render(jinjaConfig("loops", `{% for m in messages %}{% if m.role == "system" %}{% continue %}{% endif %}{% if loop.index0 == 3 %}{% break %}{% endif %}{{ m.role }} {% endfor %}`), conversations["chat"])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prompt).To(Equal("user assistant "))
+	})
+
+	It("trims blocks like Jinja", func() {
+		prompt, err := render(jinjaConfig("blocks", "{% if true %}\n  {% for m in messages %}\n{{ m.role[0] }}{% endfor %}.\n  {%+ if true %}{% endif +%}\n{% endif %}"), conversations["alternating"])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prompt).To(Equal("uau.\n  \n"))
+	})
+
+	It("parses expressions like Jinja", func() {
+		prompt, err := render(jinjaConfig("expressions", `{{ 'a' + ' b ' | trim + 'c' }}|{{ messages|length - 1 }}|{{ [1, 2, 3][::-1] | join(',') }}|{{ "abcde"[1::2] }}|{% if not(messages[0].role == "user") %}system{% endif %}`), conversations["chat"])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prompt).To(Equal("abc|3|3,2,1|bd|system"))
+	})
+
+	It("serializes to JSON like Python", func() {
+		prompt, err := render(jinjaConfig("json", `{{ {"b": 1, "a": [1.5, true, none, "<é>"]} | tojson }}|{{ [] | tojson(indent=2) }}|{{ {"a": {"b": 1}} | tojson(indent=2) }}`), conversations["chat"])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prompt).To(Equal("{\"b\": 1, \"a\": [1.5, true, null, \"<é>\"]}|[]|{\n  \"a\": {\n    \"b\": 1\n  }\n}"))
+	})
+
+	It("iterates over the items of mappings", func() {
+		prompt, err := render(jinjaConfig("items", `{% for m in messages[2:3] %}{% for tc in m.tool_calls %}{% for k, v in tc.function.arguments.items() %}{{ k }}={{ v }}{% endfor %}{{ tc.function.get("missing", "none") }}{% endfor %}{% endfor %}`), conversations["tools"])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prompt).To(Equal("city=Romenone"))
+	})
+
+	It("gives the documents of the request to the template", func() {
+		cfg := jinjaConfig("documents", `{% for d in documents %}[{{ d.title }}] {{ d.text }}
+{% endfor %}{{ messages[-1].content }}`)
+		input := schema.OpenAIRequest{Documents: []schema.Document{
+			{Content: "Paris is the capital of France.", Metadata: map[string]interface{}{"title": "France"}},
+			{Content: "Rome is the capital of Italy.", Metadata: map[string]interface{}{"title": "Italy"}},
+		}}
+		prompt, err := evaluator.TemplateMessages(input, conversations["chat"].messages, cfg, nil, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prompt).To(Equal("[France] Paris is the capital of France.\n[Italy] Rome is the capital of Italy.\nWhat is the capital of France?"))
+	})
+
+	It("reports the syntax errors of templates", func() {
+		_, err := render(jinjaConfig("broken", `{% if %}`), conversations["chat"])
+		Expect(err).To(MatchError(ContainSubstring(`chat template of the model "broken"`)))
+	})
+})
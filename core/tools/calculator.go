@@ -0,0 +1,274 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var calculatorFunctions = map[string]func(args []float64) (float64, error){
+	"sqrt":  unary(math.Sqrt),
+	"abs":   unary(math.Abs),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"round": unary(math.Round),
+	"exp":   unary(math.Exp),
+	"ln":    unary(math.Log),
+	"log":   unary(math.Log10),
+	"sin":   unary(math.Sin),
+	"cos":   unary(math.Cos),
+	"tan":   unary(math.Tan),
+	"min": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("min expects at least 1 argument")
+		}
+		res := args[0]
+		for _, a := range args[1:] {
+			res = math.Min(res, a)
+		}
+		return res, nil
+	},
+	"max": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("max expects at least 1 argument")
+		}
+		res := args[0]
+		for _, a := range args[1:] {
+			res = math.Max(res, a)
+		}
+		return res, nil
+	},
+}
+
+var calculatorConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+func unary(f func(float64) float64) func(args []float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		return f(args[0]), nil
+	}
+}
+
+// Calculate evaluates an arithmetic expression with the + - * / % ^ operators, parentheses,
+// the pi and e constants and common math functions (e.g. sqrt, round, min, max)
+func Calculate(expression string) (float64, error) {
+	p := &calculatorParser{input: []rune(expression)}
+	v, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at offset %d", p.input[p.pos], p.pos)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("the result is not a finite number")
+	}
+	return v, nil
+}
+
+type calculatorParser struct {
+	input []rune
+	pos   int
+	depth int
+}
+
+// maxDepth bounds the nesting of expressions
+const maxDepth = 100
+
+func (p *calculatorParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *calculatorParser) peek() rune {
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// expression := term (("+" | "-") term)*
+func (p *calculatorParser) expression() (float64, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return 0, fmt.Errorf("the expression is nested too deeply")
+	}
+
+	v, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			r, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			v += r
+		case '-':
+			p.pos++
+			r, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			v -= r
+		default:
+			return v, nil
+		}
+	}
+}
+
+// term := unary (("*" | "/" | "%") unary)*
+func (p *calculatorParser) term() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return v, nil
+		}
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case op == '*':
+			v *= r
+		case r == 0:
+			return 0, fmt.Errorf("division by zero")
+		case op == '/':
+			v /= r
+		default:
+			v = math.Mod(v, r)
+		}
+	}
+}
+
+// unary := ("-" | "+") unary | power
+func (p *calculatorParser) unary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.unary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power := primary ("^" unary)?
+func (p *calculatorParser) power() (float64, error) {
+	v, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() == '^' {
+		p.pos++
+		e, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(v, e), nil
+	}
+	return v, nil
+}
+
+// primary := number | "(" expression ")" | constant | function "(" arguments ")"
+func (p *calculatorParser) primary() (float64, error) {
+	r := p.peek()
+	switch {
+	case r == '(':
+		p.pos++
+		v, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis at offset %d", p.pos)
+		}
+		p.pos++
+		return v, nil
+	case unicode.IsDigit(r) || r == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.' || p.input[p.pos] == '_') {
+			p.pos++
+		}
+		// exponent, e.g. 1e-3
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.input) && (p.input[end] == '-' || p.input[end] == '+') {
+				end++
+			}
+			if end < len(p.input) && unicode.IsDigit(p.input[end]) {
+				p.pos = end
+				for p.pos < len(p.input) && unicode.IsDigit(p.input[p.pos]) {
+					p.pos++
+				}
+			}
+		}
+		literal := strings.ReplaceAll(string(p.input[start:p.pos]), "_", "")
+		v, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", literal)
+		}
+		return v, nil
+	case unicode.IsLetter(r):
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos])) {
+			p.pos++
+		}
+		name := strings.ToLower(string(p.input[start:p.pos]))
+		if p.peek() != '(' {
+			if c, ok := calculatorConstants[name]; ok {
+				return c, nil
+			}
+			return 0, fmt.Errorf("unknown constant %q", name)
+		}
+		f, ok := calculatorFunctions[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown function %q", name)
+		}
+		p.pos++
+		var args []float64
+		for p.peek() != ')' {
+			if len(args) > 0 {
+				if p.peek() != ',' {
+					return 0, fmt.Errorf("expected ',' at offset %d", p.pos)
+				}
+				p.pos++
+			}
+			v, err := p.expression()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+		}
+		p.pos++
+		v, err := f(args)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", name, err)
+		}
+		return v, nil
+	case r == 0:
+		return 0, fmt.Errorf("unexpected end of the expression")
+	}
+	return 0, fmt.Errorf("unexpected %q at offset %d", r, p.pos)
+}
@@ -0,0 +1,21 @@
+package tools
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateTime returns the current date and time in the timezone (an IANA name, e.g. Europe/Rome),
+// or in UTC if empty
+func DateTime(now time.Time, timezone string) (string, error) {
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown timezone %q", timezone)
+		}
+	}
+	t := now.In(loc)
+	return fmt.Sprintf("%s (%s, %s)", t.Format(time.RFC3339), t.Weekday(), loc.String()), nil
+}
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// maxResponseSize bounds the size of the responses returned to the model
+const maxResponseSize = 64 * 1024
+
+// callHTTP calls the URL of the tool with the arguments: in the query string of GET requests,
+// and as a JSON body otherwise
+func callHTTP(ctx context.Context, client *http.Client, tool schema.ServerTool, arguments map[string]interface{}) (string, error) {
+	if tool.URL == "" {
+		return "", fmt.Errorf("http tools require a URL")
+	}
+	method := strings.ToUpper(tool.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	u, err := url.Parse(tool.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		q := u.Query()
+		for k, v := range arguments {
+			switch v := v.(type) {
+			case string:
+				q.Set(k, v)
+			default:
+				j, _ := json.Marshal(v)
+				q.Set(k, string(j))
+			}
+		}
+		u.RawQuery = q.Encode()
+	} else {
+		j, err := json.Marshal(arguments)
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewReader(j)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return "", err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range tool.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("%s returned %s: %s", tool.URL, resp.Status, string(data))
+	}
+	return string(data), nil
+}
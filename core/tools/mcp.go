@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/mcp"
+	"github.com/rs/zerolog/log"
+)
+
+const mcpConnectTimeout = 30 * time.Second
+
+// mcpSession is a connection to an MCP server, along with the tools it listed
+type mcpSession struct {
+	client *mcp.Client
+	tools  []mcp.Tool
+}
+
+// mcpKey identifies the sessions: the same server can be configured differently by several models
+func mcpKey(server schema.MCPServer) string {
+	d, _ := json.Marshal(struct {
+		schema.MCPServer
+		Env     map[string]string
+		Headers map[string]string
+	}{server, server.Env, server.Headers})
+	return string(d)
+}
+
+// MCPTools returns the tools of the MCP servers. The servers are started on first use, and their
+// tools listed once. The servers which cannot be reached are skipped.
+func (e *Executor) MCPTools(ctx context.Context, servers []schema.MCPServer) []schema.ServerTool {
+	var res []schema.ServerTool
+	for i := range servers {
+		server := &servers[i]
+		session, err := e.mcpSession(ctx, *server)
+		if err != nil {
+			log.Warn().Err(err).Str("server", server.Name).Msg("MCP server unavailable, skipping its tools")
+			continue
+		}
+		for _, t := range session.tools {
+			res = append(res, schema.ServerTool{
+				Type:        MCPTool,
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema,
+				MCPServer:   server,
+			})
+		}
+	}
+	return res
+}
+
+func (e *Executor) mcpSession(ctx context.Context, server schema.MCPServer) (*mcpSession, error) {
+	key := mcpKey(server)
+
+	e.mcpMu.Lock()
+	defer e.mcpMu.Unlock()
+	if s, ok := e.mcpSessions[key]; ok {
+		return s, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mcpConnectTimeout)
+	defer cancel()
+
+	var client *mcp.Client
+	switch {
+	case server.URL != "":
+		client = mcp.NewHTTPClient(server.URL, server.Headers, e.httpClient)
+	case server.Command != "":
+		var err error
+		client, err = mcp.NewStdioClient(server.Command, server.Args, server.Env)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("MCP server %q has neither a command nor a URL", server.Name)
+	}
+
+	s := &mcpSession{client: client}
+	if _, err := client.Initialize(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to initialize the MCP server %q: %w", server.Name, err)
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to list the tools of the MCP server %q: %w", server.Name, err)
+	}
+	s.tools = tools
+
+	log.Debug().Str("server", server.Name).Int("tools", len(tools)).Msg("connected to MCP server")
+	e.mcpSessions[key] = s
+	return s, nil
+}
+
+// dropMCPSession closes the session, so that the next call connects again
+func (e *Executor) dropMCPSession(server schema.MCPServer, s *mcpSession) {
+	key := mcpKey(server)
+
+	e.mcpMu.Lock()
+	defer e.mcpMu.Unlock()
+	if e.mcpSessions[key] == s {
+		delete(e.mcpSessions, key)
+	}
+	if err := s.client.Close(); err != nil {
+		log.Debug().Err(err).Str("server", server.Name).Msg("failed to close the MCP session")
+	}
+}
+
+func (e *Executor) callMCP(ctx context.Context, tool schema.ServerTool, arguments string) (string, error) {
+	if tool.MCPServer == nil {
+		return "", fmt.Errorf("mcp tools are listed by the MCP servers of the model configuration")
+	}
+
+	session, err := e.mcpSession(ctx, *tool.MCPServer)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := session.client.CallTool(ctx, tool.Name, json.RawMessage(arguments))
+	if err != nil {
+		var rpcErr *mcp.Error
+		if !errors.As(err, &rpcErr) && ctx.Err() == nil {
+			// the connection is broken
+			e.dropMCPSession(*tool.MCPServer, session)
+		}
+		return "", err
+	}
+	if res.IsError {
+		if text := res.Text(); text != "" {
+			return "", errors.New(text)
+		}
+		return "", fmt.Errorf("the tool %s failed", tool.Name)
+	}
+	return res.Text(), nil
+}
+
+// Close stops the MCP servers started by the executor
+func (e *Executor) Close() {
+	e.mcpMu.Lock()
+	defer e.mcpMu.Unlock()
+	for key, s := range e.mcpSessions {
+		if err := s.client.Close(); err != nil {
+			log.Debug().Err(err).Msg("failed to close the MCP session")
+		}
+		delete(e.mcpSessions, key)
+	}
+}
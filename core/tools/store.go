@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/store"
+)
+
+const defaultTopK = 3
+
+type storeResult struct {
+	Content    string  `json:"content"`
+	Similarity float32 `json:"similarity"`
+}
+
+// searchStore returns the values of the store closest to the embeddings of the query
+func (e *Executor) searchStore(ctx context.Context, tool schema.ServerTool, query string) (string, error) {
+	if tool.Store == "" || tool.EmbeddingModel == "" {
+		return "", fmt.Errorf("store tools require a store and an embedding model")
+	}
+
+	embeddingConfig, err := e.configLoader.LoadModelConfigFileByNameDefaultOptions(tool.EmbeddingModel, e.appConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to load the embedding model: %w", err)
+	}
+	embedFn, err := backend.ModelEmbedding(query, nil, e.modelLoader, *embeddingConfig, e.appConfig)
+	if err != nil {
+		return "", err
+	}
+	embeddings, err := embedFn()
+	if err != nil {
+		return "", err
+	}
+
+	topK := tool.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+
+	sb, err := backend.StoreBackend(e.modelLoader, e.configLoader, e.appConfig, tool.Store, "")
+	if err != nil {
+		return "", err
+	}
+	defer e.modelLoader.Close()
+
+	_, values, similarities, err := store.Find(ctx, sb, embeddings, topK)
+	if err != nil {
+		return "", err
+	}
+
+	results := make([]storeResult, len(values))
+	for i, v := range values {
+		results[i] = storeResult{Content: string(v), Similarity: similarities[i]}
+	}
+	j, err := json.Marshal(results)
+	if err != nil {
+		return "", err
+	}
+	return string(j), nil
+}
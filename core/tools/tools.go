@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+const (
+	CalculatorTool = "calculator"
+	DateTimeTool   = "datetime"
+	HTTPTool       = "http"
+	StoreTool      = "store"
+	MCPTool        = "mcp"
+)
+
+// DefaultMaxIterations is the default maximum number of generations of requests using server tools
+const DefaultMaxIterations = 5
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// Name returns the name of the function the model calls to run the tool
+func Name(tool schema.ServerTool) string {
+	if tool.Name != "" {
+		return tool.Name
+	}
+	return tool.Type
+}
+
+// Function returns the definition of the tool given to the model
+func Function(tool schema.ServerTool) (functions.Function, error) {
+	f := functions.Function{Name: Name(tool), Description: tool.Description}
+
+	switch tool.Type {
+	case CalculatorTool:
+		if f.Description == "" {
+			f.Description = "Evaluates an arithmetic expression, e.g. (2 + 3) * sqrt(16) / 2. Supports + - * / % ^, parentheses, pi, e and the sqrt, abs, floor, ceil, round, exp, ln, log, sin, cos, tan, min and max functions"
+		}
+		f.Parameters = objectSchema(map[string]interface{}{
+			"expression": map[string]interface{}{"type": "string", "description": "The expression to evaluate"},
+		}, "expression")
+	case DateTimeTool:
+		if f.Description == "" {
+			f.Description = "Returns the current date and time"
+		}
+		f.Parameters = objectSchema(map[string]interface{}{
+			"timezone": map[string]interface{}{"type": "string", "description": "IANA timezone, e.g. Europe/Rome. Defaults to UTC"},
+		})
+	case StoreTool:
+		if f.Description == "" {
+			f.Description = "Searches the knowledge base for the documents most relevant to the query"
+		}
+		f.Parameters = objectSchema(map[string]interface{}{
+			"query": map[string]interface{}{"type": "string", "description": "What to search for"},
+		}, "query")
+	case HTTPTool:
+		if f.Description == "" {
+			f.Description = fmt.Sprintf("Calls %s", tool.URL)
+		}
+		f.Parameters = tool.Parameters
+		if f.Parameters == nil {
+			f.Parameters = objectSchema(map[string]interface{}{})
+		}
+	case MCPTool:
+		f.Parameters = tool.Parameters
+		if f.Parameters == nil {
+			f.Parameters = objectSchema(map[string]interface{}{})
+		}
+	default:
+		return f, fmt.Errorf("unknown server tool type %q", tool.Type)
+	}
+	return f, nil
+}
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		r := make([]interface{}, len(required))
+		for i, v := range required {
+			r[i] = v
+		}
+		s["required"] = r
+	}
+	return s
+}
+
+// Executor runs the server tools called by the models
+type Executor struct {
+	modelLoader  *model.ModelLoader
+	configLoader *config.ModelConfigLoader
+	appConfig    *config.ApplicationConfig
+	httpClient   *http.Client
+	now          func() time.Time
+
+	mcpMu       sync.Mutex
+	mcpSessions map[string]*mcpSession
+}
+
+// NewExecutor returns an executor of the server tools. The MCP servers it starts are stopped
+// once the context of the application is canceled.
+func NewExecutor(ml *model.ModelLoader, cl *config.ModelConfigLoader, appConfig *config.ApplicationConfig) *Executor {
+	e := &Executor{
+		modelLoader:  ml,
+		configLoader: cl,
+		appConfig:    appConfig,
+		httpClient:   &http.Client{Timeout: defaultHTTPTimeout},
+		now:          time.Now,
+		mcpSessions:  make(map[string]*mcpSession),
+	}
+	if appConfig != nil && appConfig.Context != nil {
+		go func() {
+			<-appConfig.Context.Done()
+			e.Close()
+		}()
+	}
+	return e
+}
+
+// Run runs the tool with the arguments of the call, a JSON object. Errors of the tool are
+// returned as the result, so that the model can recover from them.
+func (e *Executor) Run(ctx context.Context, tool schema.ServerTool, arguments string) string {
+	res, err := e.run(ctx, tool, arguments)
+	if err != nil {
+		return fmt.Sprintf("Error: %s", err.Error())
+	}
+	return res
+}
+
+func (e *Executor) run(ctx context.Context, tool schema.ServerTool, arguments string) (string, error) {
+	args := map[string]interface{}{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	switch tool.Type {
+	case CalculatorTool:
+		expression, _ := args["expression"].(string)
+		v, err := Calculate(expression)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case DateTimeTool:
+		timezone, _ := args["timezone"].(string)
+		return DateTime(e.now(), timezone)
+	case StoreTool:
+		query, _ := args["query"].(string)
+		if query == "" {
+			return "", fmt.Errorf("the query is empty")
+		}
+		return e.searchStore(ctx, tool, query)
+	case HTTPTool:
+		return callHTTP(ctx, e.httpClient, tool, args)
+	case MCPTool:
+		return e.callMCP(ctx, tool, arguments)
+	}
+	return "", fmt.Errorf("unknown server tool type %q", tool.Type)
+}
+
+// Find returns the tool called by a function call, if any
+func Find(tools []schema.ServerTool, name string) (schema.ServerTool, bool) {
+	for _, t := range tools {
+		if Name(t) == name {
+			return t, true
+		}
+	}
+	return schema.ServerTool{}, false
+}
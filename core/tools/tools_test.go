@@ -0,0 +1,336 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	. "github.com/mudler/LocalAI/core/tools"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/grpc/base"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/system"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server tools", func() {
+	DescribeTable("Calculate() evaluates arithmetic expressions",
+		func(expression string, expected float64) {
+			v, err := Calculate(expression)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(BeNumerically("~", expected, 1e-9))
+		},
+		Entry("precedence", "1 + 2 * 3", 7.0),
+		Entry("parentheses", "(1 + 2) * 3", 9.0),
+		Entry("unary minus", "-2 ^ 2 + -(-3)", -1.0),
+		Entry("right associative power", "2 ^ 3 ^ 2", 512.0),
+		Entry("modulo and division", "17 % 5 / 4", 0.5),
+		Entry("decimals and exponents", "1.5e3 + .5 + 1_000", 2500.5),
+		Entry("functions", "sqrt(16) + max(1, 7, 3) + round(2.5) + abs(-1)", 15.0),
+		Entry("constants", "cos(pi) + ln(e)", 0.0),
+	)
+
+	DescribeTable("Calculate() rejects invalid expressions",
+		func(expression string) {
+			_, err := Calculate(expression)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("empty", ""),
+		Entry("unbalanced parentheses", "(1 + 2"),
+		Entry("trailing operator", "1 +"),
+		Entry("division by zero", "1 / (2 - 2)"),
+		Entry("unknown function", "os(1)"),
+		Entry("unknown constant", "x + 1"),
+		Entry("invalid result", "sqrt(-1)"),
+		Entry("trailing input", "1 2"),
+	)
+
+	It("DateTime() returns the date in the timezone", func() {
+		now := time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC)
+		s, err := DateTime(now, "Europe/Rome")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal("2024-03-02T00:30:00+01:00 (Saturday, Europe/Rome)"))
+
+		s, err = DateTime(now, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal("2024-03-01T23:30:00Z (Friday, UTC)"))
+
+		_, err = DateTime(now, "Mars/Olympus")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns the definitions of the tools", func() {
+		f, err := Function(schema.ServerTool{Type: CalculatorTool})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Name).To(Equal("calculator"))
+		Expect(f.Parameters["required"]).To(ConsistOf("expression"))
+
+		f, err = Function(schema.ServerTool{Type: HTTPTool, Name: "weather", URL: "http://localhost/weather"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Name).To(Equal("weather"))
+		Expect(f.Description).To(Equal("Calls http://localhost/weather"))
+
+		_, err = Function(schema.ServerTool{Type: "shell"})
+		Expect(err).To(HaveOccurred())
+
+		t, ok := Find([]schema.ServerTool{{Type: DateTimeTool}, {Type: HTTPTool, Name: "weather"}}, "weather")
+		Expect(ok).To(BeTrue())
+		Expect(t.Type).To(Equal(HTTPTool))
+		_, ok = Find([]schema.ServerTool{{Type: DateTimeTool}}, "calculator")
+		Expect(ok).To(BeFalse())
+	})
+
+	Context("Run()", func() {
+		var (
+			executor *Executor
+			server   *httptest.Server
+			requests []*http.Request
+			bodies   []string
+		)
+
+		BeforeEach(func() {
+			executor = NewExecutor(nil, nil, nil)
+			requests, bodies = nil, nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				requests = append(requests, r)
+				bodies = append(bodies, string(b))
+				if r.URL.Path == "/fail" {
+					w.WriteHeader(http.StatusNotFound)
+				}
+				w.Write([]byte("sunny"))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("runs the calculator", func() {
+			Expect(executor.Run(ctx(), schema.ServerTool{Type: CalculatorTool}, `{"expression": "6 * 7"}`)).To(Equal("42"))
+			Expect(executor.Run(ctx(), schema.ServerTool{Type: CalculatorTool}, `{"expression": "6 *"}`)).To(HavePrefix("Error: "))
+			Expect(executor.Run(ctx(), schema.ServerTool{Type: CalculatorTool}, `not json`)).To(HavePrefix("Error: invalid arguments"))
+		})
+
+		It("calls the URL of http tools with the arguments", func() {
+			tool := schema.ServerTool{Type: HTTPTool, URL: server.URL + "/weather?units=metric", Headers: map[string]string{"X-Key": "secret"}}
+			Expect(executor.Run(ctx(), tool, `{"city": "Rome", "days": 2}`)).To(Equal("sunny"))
+			Expect(requests[0].Method).To(Equal(http.MethodGet))
+			Expect(requests[0].URL.Query().Get("city")).To(Equal("Rome"))
+			Expect(requests[0].URL.Query().Get("days")).To(Equal("2"))
+			Expect(requests[0].URL.Query().Get("units")).To(Equal("metric"))
+			Expect(requests[0].Header.Get("X-Key")).To(Equal("secret"))
+
+			tool.Method = "post"
+			Expect(executor.Run(ctx(), tool, `{"city": "Rome"}`)).To(Equal("sunny"))
+			Expect(requests[1].Method).To(Equal(http.MethodPost))
+			body := map[string]interface{}{}
+			Expect(json.Unmarshal([]byte(bodies[1]), &body)).To(Succeed())
+			Expect(body).To(Equal(map[string]interface{}{"city": "Rome"}))
+		})
+
+		It("reports the errors of http tools", func() {
+			Expect(executor.Run(ctx(), schema.ServerTool{Type: HTTPTool, URL: server.URL + "/fail"}, `{}`)).To(HavePrefix("Error: "))
+			Expect(executor.Run(ctx(), schema.ServerTool{Type: HTTPTool}, `{}`)).To(Equal("Error: http tools require a URL"))
+		})
+	})
+
+	Context("MCP tools", func() {
+		var (
+			executor        *Executor
+			server          *httptest.Server
+			initializations int
+		)
+
+		BeforeEach(func() {
+			executor = NewExecutor(nil, nil, nil)
+			initializations = 0
+			// the server has a "weather" tool, and a "broken" one which always fails
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var msg struct {
+					ID     *int64 `json:"id"`
+					Method string `json:"method"`
+					Params struct {
+						Name      string            `json:"name"`
+						Arguments map[string]string `json:"arguments"`
+					} `json:"params"`
+				}
+				if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&msg) != nil {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				if msg.ID == nil {
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+
+				res := map[string]interface{}{"jsonrpc": "2.0", "id": *msg.ID}
+				switch msg.Method {
+				case "initialize":
+					initializations++
+					res["result"] = map[string]interface{}{
+						"protocolVersion": "2025-03-26",
+						"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+						"serverInfo":      map[string]string{"name": "stub", "version": "0.1.0"},
+					}
+				case "tools/list":
+					res["result"] = map[string]interface{}{"tools": []map[string]interface{}{
+						{
+							"name":        "weather",
+							"description": "Returns the weather of a city",
+							"inputSchema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"city": map[string]string{"type": "string"}}},
+						},
+						{"name": "broken", "inputSchema": map[string]interface{}{"type": "object"}},
+					}}
+				case "tools/call":
+					if msg.Params.Name == "weather" {
+						res["result"] = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": "sunny in " + msg.Params.Arguments["city"]}}}
+					} else {
+						res["result"] = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": "out of order"}}, "isError": true}
+					}
+				default:
+					res["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(res)
+			}))
+		})
+
+		AfterEach(func() {
+			executor.Close()
+			server.Close()
+		})
+
+		It("lists the tools of the servers once", func() {
+			servers := []schema.MCPServer{{Name: "stub", URL: server.URL}}
+			tools := executor.MCPTools(ctx(), servers)
+			Expect(tools).To(HaveLen(2))
+			Expect(tools[0].Type).To(Equal(MCPTool))
+			Expect(tools[0].Name).To(Equal("weather"))
+			Expect(tools[0].Description).To(Equal("Returns the weather of a city"))
+			Expect(tools[0].MCPServer.Name).To(Equal("stub"))
+
+			fn, err := Function(tools[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fn.Name).To(Equal("weather"))
+			Expect(fn.Parameters["properties"]).To(HaveKey("city"))
+
+			Expect(executor.MCPTools(ctx(), servers)).To(HaveLen(2))
+			Expect(initializations).To(Equal(1))
+		})
+
+		It("skips the servers which cannot be reached", func() {
+			closed := httptest.NewServer(http.NotFoundHandler())
+			closed.Close()
+			servers := []schema.MCPServer{{Name: "closed", URL: closed.URL}, {Name: "invalid"}, {Name: "stub", URL: server.URL}}
+			tools := executor.MCPTools(ctx(), servers)
+			Expect(tools).To(HaveLen(2))
+			Expect(tools[0].MCPServer.Name).To(Equal("stub"))
+		})
+
+		It("calls the tools through the servers", func() {
+			tools := executor.MCPTools(ctx(), []schema.MCPServer{{Name: "stub", URL: server.URL}})
+			Expect(tools).To(HaveLen(2))
+			Expect(executor.Run(ctx(), tools[0], `{"city": "Rome"}`)).To(Equal("sunny in Rome"))
+			Expect(executor.Run(ctx(), tools[1], `{}`)).To(Equal("Error: out of order"))
+			Expect(executor.Run(ctx(), schema.ServerTool{Type: MCPTool, Name: "weather"}, `{}`)).To(HavePrefix("Error: "))
+		})
+	})
+
+	Context("store tools", func() {
+		var (
+			executor *Executor
+			store    *fakeStore
+			tool     schema.ServerTool
+		)
+
+		BeforeEach(func() {
+			modelPath := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(modelPath, "embedder.yaml"), []byte("name: embedder\nbackend: fake-embeddings\nembeddings: true\nparameters:\n  model: embedder\n"), 0600)).To(Succeed())
+
+			systemState, err := system.GetSystemState(system.WithModelPath(modelPath))
+			Expect(err).ToNot(HaveOccurred())
+			appConfig := config.NewApplicationConfig(
+				config.WithSystemState(systemState),
+				config.WithExternalBackend("fake-embeddings", "tools-embedder"),
+			)
+			ml := model.NewModelLoader(systemState, false)
+
+			store = &fakeStore{}
+			grpc.Provide("tools-embedder", &fakeEmbedder{})
+			grpc.Provide("tools-store", store)
+			ml.SetExternalBackend(model.LocalStoreBackend, "tools-store")
+
+			executor = NewExecutor(ml, config.NewModelConfigLoader(modelPath), appConfig)
+			tool = schema.ServerTool{Type: StoreTool, Name: "search_docs", Store: "docs", EmbeddingModel: "embedder", TopK: 2}
+		})
+
+		It("searches the store with the embeddings of the query", func() {
+			result := executor.Run(ctx(), tool, `{"query": "refunds"}`)
+
+			var results []map[string]interface{}
+			Expect(json.Unmarshal([]byte(result), &results)).To(Succeed(), result)
+			Expect(results).To(HaveLen(2))
+			Expect(results[0]["content"]).To(Equal("Refunds are accepted within 30 days."))
+			Expect(results[0]["similarity"]).To(BeNumerically("~", 0.9, 1e-6))
+			Expect(results[1]["content"]).To(Equal("Shipping takes 2 days."))
+
+			Expect(store.find.Key.Floats).To(Equal([]float32{float32(len("refunds")), 1}))
+			Expect(store.find.TopK).To(BeEquivalentTo(2))
+		})
+
+		It("reports invalid queries and configurations", func() {
+			Expect(executor.Run(ctx(), tool, `{"query": ""}`)).To(Equal("Error: the query is empty"))
+
+			tool.EmbeddingModel = ""
+			Expect(executor.Run(ctx(), tool, `{"query": "refunds"}`)).To(Equal("Error: store tools require a store and an embedding model"))
+			Expect(store.find).To(BeNil())
+		})
+	})
+})
+
+// fakeEmbedder returns the length of the text and 1 as embeddings
+type fakeEmbedder struct {
+	base.SingleThread
+}
+
+func (f *fakeEmbedder) Load(*pb.ModelOptions) error {
+	return nil
+}
+
+func (f *fakeEmbedder) Embeddings(opts *pb.PredictOptions) ([]float32, error) {
+	return []float32{float32(len(opts.Embeddings)), 1}, nil
+}
+
+// fakeStore returns two documents, recording the search
+type fakeStore struct {
+	base.SingleThread
+	find *pb.StoresFindOptions
+}
+
+func (f *fakeStore) Load(*pb.ModelOptions) error {
+	return nil
+}
+
+func (f *fakeStore) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResult, error) {
+	f.find = opts
+	return pb.StoresFindResult{
+		Keys:         []*pb.StoresKey{{Floats: []float32{1, 0}}, {Floats: []float32{0, 1}}},
+		Values:       []*pb.StoresValue{{Bytes: []byte("Refunds are accepted within 30 days.")}, {Bytes: []byte("Shipping takes 2 days.")}},
+		Similarities: []float32{0.9, 0.5},
+	}, nil
+}
+
+func ctx() context.Context {
+	return context.Background()
+}
@@ -0,0 +1,68 @@
+package document
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupportedFormat is returned for documents which are not text, Markdown, HTML or PDF
+var ErrUnsupportedFormat = errors.New("unsupported document format, must be text, Markdown, HTML or PDF")
+
+var (
+	trailingSpaces = regexp.MustCompile(`[ \t]+\n`)
+	blankLines     = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+)
+
+// Extract returns the text of a document, which is plain text, Markdown, HTML or PDF. The format is given by
+// the extension of the name of the document, or else detected from its content.
+func Extract(name string, data []byte) (string, error) {
+	var text string
+	var err error
+
+	switch format(name, data) {
+	case "text":
+		if !utf8.Valid(data) {
+			return "", errors.New("the document is not valid UTF-8 text")
+		}
+		text = string(data)
+	case "html":
+		text, err = extractHTML(data)
+	case "pdf":
+		text, err = extractPDF(data)
+	default:
+		return "", ErrUnsupportedFormat
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// blank lines separate paragraphs, which only need one
+	text = trailingSpaces.ReplaceAllString(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n")), nil
+}
+
+func format(name string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".text", ".md", ".markdown":
+		return "text"
+	case ".html", ".htm", ".xhtml":
+		return "html"
+	case ".pdf":
+		return "pdf"
+	}
+
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	switch contentType {
+	case "text/plain":
+		return "text"
+	case "text/html", "text/xml":
+		return "html"
+	case "application/pdf":
+		return "pdf"
+	}
+	return ""
+}
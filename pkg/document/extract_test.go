@@ -0,0 +1,286 @@
+package document_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	. "github.com/mudler/LocalAI/pkg/document"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// pdfBuilder writes the objects of a PDF document, numbered from 1, followed by its page tree
+type pdfBuilder struct {
+	objects []string
+	pages   []string
+}
+
+func (b *pdfBuilder) add(object string) int {
+	b.objects = append(b.objects, object)
+	return len(b.objects)
+}
+
+func (b *pdfBuilder) stream(dict string, data []byte) int {
+	return b.add(fmt.Sprintf("<< /Length %d %s >>\nstream\n%s\nendstream", len(data), dict, data))
+}
+
+func (b *pdfBuilder) page(resources string, contents int) {
+	b.pages = append(b.pages, fmt.Sprintf("<< /Type /Page /Resources %s /Contents %d 0 R", resources, contents))
+}
+
+func (b *pdfBuilder) bytes() []byte {
+	root := len(b.objects) + 1
+	kids := []string{}
+	for i, page := range b.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", root+2+i))
+		b.objects = append(b.objects, fmt.Sprintf("%s /Parent %d 0 R >>", page, root+1))
+	}
+	objects := append(b.objects[:root-1:root-1],
+		fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", root+1),
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	objects = append(objects, b.objects[root-1:]...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	for i, object := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\n%%%%EOF\n", len(objects)+1, root)
+	return buf.Bytes()
+}
+
+func deflate(data []byte) []byte {
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write(data)
+	w.Close()
+	return z.Bytes()
+}
+
+// pdf builds a PDF document with a page showing each content stream, compressed or not, with the font F1
+func pdf(compress bool, contents ...string) []byte {
+	b := &pdfBuilder{}
+	font := b.add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	for _, content := range contents {
+		data, filter := []byte(content), ""
+		if compress {
+			data, filter = deflate(data), "/Filter /FlateDecode"
+		}
+		b.page(fmt.Sprintf("<< /Font << /F1 %d 0 R >> >>", font), b.stream(filter, data))
+	}
+	return b.bytes()
+}
+
+// identityFont adds a composite font of the Identity-H encoding, as written by word processors, with a ToUnicode
+// CMap or not
+func identityFont(b *pdfBuilder, toUnicode bool) int {
+	descendant := b.add("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /ABCDEE+Calibri /DW 500 /W [1 [250 500] 16 18 450] >>")
+	cmap := ""
+	if toUnicode {
+		cmap = fmt.Sprintf(" /ToUnicode %d 0 R", b.stream("/Filter /FlateDecode", deflate([]byte(`/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def
+/CMapName /Adobe-Identity-UCS def
+/CMapType 2 def
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+3 beginbfchar
+<0001> <0048>
+<0002> <0069>
+<0003> <00660069>
+endbfchar
+2 beginbfrange
+<0010> <0012> <0061>
+<0020> <0021> [<0021> <003F>]
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end`))))
+	}
+	return b.add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /ABCDEE+Calibri /Encoding /Identity-H /DescendantFonts [%d 0 R]%s >>", descendant, cmap))
+}
+
+var _ = Describe("Extract", func() {
+	It("returns the text of text and Markdown documents", func() {
+		text, err := Extract("notes.txt", []byte("first line\r\n\r\n\r\n\nsecond line\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("first line\n\nsecond line"))
+
+		text, err = Extract("README.md", []byte("# Title\n\nSome *text*."))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("# Title\n\nSome *text*."))
+
+		_, err = Extract("latin1.txt", []byte{'c', 'a', 'f', 0xe9})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns the visible text of HTML documents", func() {
+		text, err := Extract("page.html", []byte(`<!DOCTYPE html>
+<html>
+<head><title>The title</title><style>body { color: red; }</style></head>
+<body>
+  <h1>Heading</h1>
+  <p>A paragraph   with <b>bold</b>
+  text.</p>
+  <script>alert("hidden")</script>
+  <ul><li>one</li><li>two &amp; three</li></ul>
+</body>
+</html>`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("The title\n\nHeading\n\nA paragraph with bold text.\n\none\ntwo & three"))
+	})
+
+	It("returns the text shown by the pages of PDF documents", func() {
+		for _, compress := range []bool{true, false} {
+			text, err := Extract("doc.pdf", pdf(compress,
+				"BT /F1 12 Tf 72 712 Td (Hello, PDF!) Tj 0 -14 Td [(Second) -250 (line \\(escaped\\))] TJ ET",
+				"BT /F1 12 Tf 1 0 0 1 72 700 Tm <4E657874> Tj 1 0 0 1 72 686 Tm (page) Tj ET",
+			))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(text).To(Equal("Hello, PDF!\nSecond line (escaped)\n\nNext\npage"))
+		}
+	})
+
+	It("returns the text of the PDF documents of word processors", func() {
+		// the first page of the specification of XFLATE, written by Word, see testdata/README.md
+		data, err := os.ReadFile("testdata/xflate-format.pdf")
+		Expect(err).ToNot(HaveOccurred())
+		expected, err := os.ReadFile("testdata/xflate-format.txt")
+		Expect(err).ToNot(HaveOccurred())
+
+		text, err := Extract("xflate-format.pdf", data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal(strings.TrimSpace(string(expected))))
+	})
+
+	It("decodes the text with the ToUnicode CMaps of the fonts", func() {
+		b := &pdfBuilder{}
+		font := identityFont(b, true)
+		b.page(fmt.Sprintf("<< /Font << /F1 %d 0 R >> >>", font), b.stream("", []byte(
+			"BT /F1 12 Tf 72 700 Td <00010002> Tj [<0003> -500 <001000110012> <0020>] TJ ET",
+		)))
+
+		text, err := Extract("doc.pdf", b.bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("Hifi abc!"))
+	})
+
+	It("decodes the text with the encodings of the fonts", func() {
+		b := &pdfBuilder{}
+		encoding := b.add("<< /Type /Encoding /BaseEncoding /WinAnsiEncoding /Differences [1 /f_f /uni00E9 39 /quoteright] >>")
+		font := b.add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /ABCDEF+CMR10 /Encoding %d 0 R >>", encoding))
+		b.page(fmt.Sprintf("<< /Font << /F1 %d 0 R >> >>", font), b.stream("", []byte(
+			`BT /F1 10 Tf 72 700 Td (e\001ect caf\002 l'\002t\351) Tj ET`,
+		)))
+
+		text, err := Extract("doc.pdf", b.bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("effect café l’été"))
+	})
+
+	It("rejects the documents whose text is shown with fonts without encoding", func() {
+		b := &pdfBuilder{}
+		font := identityFont(b, false)
+		b.page(fmt.Sprintf("<< /Font << /F1 %d 0 R >> >>", font), b.stream("", []byte("BT /F1 12 Tf 72 700 Td <00010002> Tj ET")))
+
+		_, err := Extract("doc.pdf", b.bytes())
+		Expect(err).To(MatchError(ContainSubstring("the font ABCDEE+Calibri of the PDF document has no encoding")))
+
+		// the symbols of symbolic fonts without encoding are skipped
+		b = &pdfBuilder{}
+		text := b.add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+		descriptor := b.add("<< /Type /FontDescriptor /FontName /Wingdings /Flags 4 >>")
+		symbols := b.add(fmt.Sprintf("<< /Type /Font /Subtype /TrueType /BaseFont /Wingdings /FontDescriptor %d 0 R >>", descriptor))
+		b.page(fmt.Sprintf("<< /Font << /F1 %d 0 R /F2 %d 0 R >> >>", text, symbols), b.stream("", []byte(
+			"BT /F2 12 Tf 72 700 Td (l) Tj /F1 12 Tf 12 0 Td (The first item of the list) Tj ET",
+		)))
+
+		extracted, err := Extract("doc.pdf", b.bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(extracted).To(Equal("The first item of the list"))
+	})
+
+	It("decodes the streams with the filters and the predictors of PDF", func() {
+		// the rows of 4 bytes of the PNG Up predictor hold the differences with the row above
+		content := []byte("BT /F1 12 Tf 72 700 Td (Predicted) Tj ET")
+		for len(content)%4 != 0 {
+			content = append(content, ' ')
+		}
+		var predicted []byte
+		prev := make([]byte, 4)
+		for i := 0; i < len(content); i += 4 {
+			predicted = append(predicted, 2)
+			for j := 0; j < 4; j++ {
+				predicted = append(predicted, content[i+j]-prev[j])
+			}
+			prev = content[i : i+4]
+		}
+
+		a85 := make([]byte, ascii85.MaxEncodedLen(len("BT /F1 12 Tf 72 700 Td (ASCII85) Tj ET")))
+		a85 = a85[:ascii85.Encode(a85, []byte("BT /F1 12 Tf 72 700 Td (ASCII85) Tj ET"))]
+
+		b := &pdfBuilder{}
+		font := b.add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+		resources := fmt.Sprintf("<< /Font << /F1 %d 0 R >> >>", font)
+		b.page(resources, b.stream("/Filter [/ASCIIHexDecode /FlateDecode]",
+			[]byte(hex.EncodeToString(deflate([]byte("BT /F1 12 Tf 72 700 Td (Hexadecimal) Tj ET")))+">")))
+		b.page(resources, b.stream("/Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 4 >>", deflate(predicted)))
+		b.page(resources, b.stream("/Filter /A85", append(a85, "~>"...)))
+
+		text, err := Extract("doc.pdf", b.bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("Hexadecimal\n\nPredicted\n\nASCII85"))
+
+		b = &pdfBuilder{}
+		b.page("<< >>", b.stream("/Filter /JBIG2Decode", []byte("BT (Image) Tj ET")))
+		_, err = Extract("doc.pdf", b.bytes())
+		Expect(err).To(MatchError(ContainSubstring("unsupported filter JBIG2Decode")))
+	})
+
+	It("reads the objects of object streams", func() {
+		// the strings of the Unicode CMaps are in UTF-16
+		shown := ""
+		for _, c := range "Compressed objects" {
+			shown += fmt.Sprintf("%04X", c)
+		}
+		b := &pdfBuilder{}
+		content := b.stream("", []byte("BT /F1 12 Tf 72 700 Td <"+shown+"> Tj ET"))
+		header := "100 0 "
+		b.stream(fmt.Sprintf("/Type /ObjStm /N 1 /First %d /Filter /FlateDecode", len(header)),
+			deflate([]byte(header+"<< /Type /Font /Subtype /Type0 /BaseFont /Missing /Encoding /UniGB-UCS2-H >>")))
+		b.page("<< /Font << /F1 100 0 R >> >>", content)
+
+		text, err := Extract("doc.pdf", b.bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("Compressed objects"))
+	})
+
+	It("detects the format from the content", func() {
+		text, err := Extract("upload", []byte("<html><body><p>Hello</p></body></html>"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("Hello"))
+
+		text, err = Extract("upload", pdf(true, "BT (Hello) Tj ET"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal("Hello"))
+	})
+
+	It("rejects the documents whose text cannot be extracted", func() {
+		_, err := Extract("image.png", []byte("\x89PNG\r\n\x1a\n"))
+		Expect(err).To(MatchError(ErrUnsupportedFormat))
+
+		_, err = Extract("scan.pdf", pdf(true, "q 100 0 0 100 0 0 cm /Im1 Do Q"))
+		Expect(err).To(MatchError(ContainSubstring("no text")))
+
+		_, err = Extract("doc.pdf", []byte("not a pdf"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,109 @@
+package document
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// the elements whose text is not part of the document
+var hiddenElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+}
+
+// the elements which are separated from the text around them by a line
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Div: true,
+	atom.Dl: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.H1: true, atom.H2: true,
+	atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true, atom.Main: true,
+	atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true,
+	atom.Title: true, atom.Ul: true,
+}
+
+// the elements which start on a new line
+var lineElements = map[atom.Atom]bool{
+	atom.Br: true, atom.Dd: true, atom.Dt: true, atom.Li: true, atom.Tr: true,
+}
+
+// extractHTML returns the visible text of an HTML document, with a line for each block
+func extractHTML(data []byte) (string, error) {
+	z := html.NewTokenizer(bytes.NewReader(data))
+
+	var sb strings.Builder
+	hidden := 0
+	pre := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return "", err
+			}
+			lines := strings.Split(sb.String(), "\n")
+			for i, l := range lines {
+				lines[i] = strings.Trim(l, " ")
+			}
+			return strings.Join(lines, "\n"), nil
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			a := atom.Lookup(name)
+			if hiddenElements[a] && tt != html.SelfClosingTagToken {
+				if tt == html.StartTagToken {
+					hidden++
+				} else if hidden > 0 {
+					hidden--
+				}
+			}
+			if a == atom.Pre && tt != html.SelfClosingTagToken {
+				if tt == html.StartTagToken {
+					pre++
+				} else if pre > 0 {
+					pre--
+				}
+			}
+			switch {
+			case blockElements[a]:
+				sb.WriteString("\n\n")
+			case lineElements[a] && tt != html.EndTagToken:
+				sb.WriteString("\n")
+			case a == atom.Td || a == atom.Th:
+				sb.WriteString(" ")
+			}
+		case html.TextToken:
+			if hidden > 0 {
+				continue
+			}
+			text := string(z.Text())
+			if pre == 0 {
+				text = collapseSpaces(text)
+			}
+			sb.WriteString(text)
+		}
+	}
+}
+
+// collapseSpaces replaces the runs of whitespace with a space, as browsers do
+func collapseSpaces(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s == "" {
+			return ""
+		}
+		return " "
+	}
+	text := strings.Join(fields, " ")
+	if strings.TrimLeft(s, " \t\r\n\f") != s {
+		text = " " + text
+	}
+	if strings.TrimRight(s, " \t\r\n\f") != s {
+		text += " "
+	}
+	return text
+}
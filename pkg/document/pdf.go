@@ -0,0 +1,885 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxStreamSize bounds the size of the decoded streams of PDF documents
+const maxStreamSize = 64 << 20
+
+// maxSkippedShare is the share of the text of a PDF document shown with fonts without a usable encoding, e.g.
+// the symbols of math fonts, above which the document is rejected rather than missing much of its text
+const maxSkippedShare = 0.1
+
+// maxFormDepth bounds the nesting of the form XObjects drawn by the pages
+const maxFormDepth = 8
+
+var (
+	objectStart = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfEncrypt  = regexp.MustCompile(`/Encrypt\s`)
+)
+
+// extractPDF returns the text shown by the pages of a PDF document. The text is decoded with the ToUnicode CMaps
+// of the fonts, or else with their encodings. The text of the fonts with neither, e.g. CID fonts without a
+// ToUnicode CMap, is skipped rather than giving their glyph identifiers as text, and the documents with much of
+// their text shown with them are rejected.
+func extractPDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("the document is not a PDF")
+	}
+	if pdfEncrypt.Match(data) {
+		return "", errors.New("encrypted PDF documents are not supported")
+	}
+
+	doc := parsePDF(data)
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return "", errors.New("no pages found in the PDF document")
+	}
+
+	var sb strings.Builder
+	var decoded, skipped int
+	var skippedFont string
+	for _, page := range pages {
+		t, err := doc.pageText(page)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(t.sb.String())
+		sb.WriteString("\n\n")
+		decoded, skipped = decoded+t.decoded, skipped+t.skipped
+		if t.skippedFont != "" {
+			skippedFont = t.skippedFont
+		}
+	}
+	if float64(skipped) > maxSkippedShare*float64(decoded+skipped) {
+		return "", fmt.Errorf("the font %s of the PDF document has no encoding to extract its text", skippedFont)
+	}
+
+	text := sb.String()
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("no text found in the PDF document, it might be scanned")
+	}
+	return text, nil
+}
+
+// pdfDocument holds the objects of a PDF document by number, read from the document and its object streams
+// rather than from its cross-reference table, which is often broken
+type pdfDocument struct {
+	objects map[int]interface{}
+	// root is the reference of the catalog given by the last trailer
+	root  interface{}
+	fonts map[pdfRef]*pdfFont
+}
+
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+func parsePDF(data []byte) *pdfDocument {
+	doc := &pdfDocument{objects: map[int]interface{}{}, fonts: map[pdfRef]*pdfFont{}}
+
+	var objectStreams []*pdfStream
+	pos := 0
+	for {
+		loc := objectStart.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		p := &pdfLexer{data: data, pos: pos + loc[1], objects: true}
+		object, ok := p.object()
+		if !ok {
+			pos += loc[1]
+			continue
+		}
+		if dict, ok := object.(pdfDict); ok {
+			if stream, ok := p.stream(dict); ok {
+				switch dict["Type"] {
+				case pdfName("ObjStm"):
+					objectStreams = append(objectStreams, stream)
+				case pdfName("XRef"):
+					doc.root = dict["Root"]
+				}
+				object = stream
+			}
+		}
+		// the objects of incremental updates follow the ones they replace
+		doc.objects[num] = object
+		pos = p.pos
+	}
+
+	if i := bytes.LastIndex(data, []byte("trailer")); i >= 0 {
+		p := &pdfLexer{data: data, pos: i + len("trailer"), objects: true}
+		if trailer, ok := p.object(); ok {
+			if dict, ok := trailer.(pdfDict); ok && dict["Root"] != nil {
+				doc.root = dict["Root"]
+			}
+		}
+	}
+
+	for _, stream := range objectStreams {
+		doc.readObjectStream(stream)
+	}
+	return doc
+}
+
+// readObjectStream reads the objects compressed in an object stream, which do not replace the objects read from
+// the document
+func (doc *pdfDocument) readObjectStream(stream *pdfStream) {
+	data, err := doc.decode(stream)
+	if err != nil {
+		return
+	}
+	n, _ := doc.resolve(stream.dict["N"]).(float64)
+	first, _ := doc.resolve(stream.dict["First"]).(float64)
+	if first <= 0 || int(first) > len(data) {
+		return
+	}
+
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		num, ok1 := header.next()
+		offset, ok2 := header.next()
+		if !ok1 || !ok2 {
+			return
+		}
+		objNum, ok1 := num.(float64)
+		objOffset, ok2 := offset.(float64)
+		if !ok1 || !ok2 || int(first+objOffset) >= len(data) {
+			return
+		}
+		if _, exists := doc.objects[int(objNum)]; exists {
+			continue
+		}
+		p := &pdfLexer{data: data, pos: int(first + objOffset), objects: true}
+		if object, ok := p.object(); ok {
+			doc.objects[int(objNum)] = object
+		}
+	}
+}
+
+// resolve returns the object referenced by v, if it is a reference
+func (doc *pdfDocument) resolve(v interface{}) interface{} {
+	for i := 0; i < 16; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = doc.objects[ref.num]
+	}
+	return nil
+}
+
+func (doc *pdfDocument) dict(v interface{}) pdfDict {
+	switch v := doc.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+func (doc *pdfDocument) array(v interface{}) []interface{} {
+	switch v := doc.resolve(v).(type) {
+	case []interface{}:
+		return v
+	case nil:
+		return nil
+	default:
+		return []interface{}{v}
+	}
+}
+
+// pdfPage is a page with the resources it inherits from the page tree
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages returns the pages of the page tree of the catalog of the document, in order
+func (doc *pdfDocument) pages() []pdfPage {
+	catalog := doc.dict(doc.root)
+	if catalog["Type"] != pdfName("Catalog") {
+		// without a trailer, the catalog with the lowest number is the one of the original document
+		catalog = nil
+		lowest := -1
+		for num, object := range doc.objects {
+			if dict, ok := object.(pdfDict); ok && dict["Type"] == pdfName("Catalog") && (lowest < 0 || num < lowest) {
+				catalog, lowest = dict, num
+			}
+		}
+	}
+
+	var pages []pdfPage
+	visited := map[pdfRef]bool{}
+	var walk func(node pdfDict, resources pdfDict)
+	walk = func(node pdfDict, resources pdfDict) {
+		if r := doc.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		if node["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: node, resources: resources})
+			return
+		}
+		for _, kid := range doc.array(node["Kids"]) {
+			ref, isRef := kid.(pdfRef)
+			if !isRef || visited[ref] {
+				continue
+			}
+			visited[ref] = true
+			if kid := doc.dict(ref); kid != nil {
+				walk(kid, resources)
+			}
+		}
+	}
+	if pages := doc.dict(catalog["Pages"]); pages != nil {
+		walk(pages, nil)
+	}
+	return pages
+}
+
+// pageText returns the text shown by the content streams of a page
+func (doc *pdfDocument) pageText(page pdfPage) (*pdfText, error) {
+	var content []byte
+	for _, c := range doc.array(page.dict["Contents"]) {
+		stream, ok := doc.resolve(c).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := doc.decode(stream)
+		if err != nil {
+			return nil, err
+		}
+		content = append(append(content, data...), '\n')
+	}
+
+	t := newPDFText(doc)
+	t.show(content, page.resources, 0)
+	return t, nil
+}
+
+// pdfText accumulates the text shown by content streams. The position of the text is followed to break lines
+// where it moves vertically, and to separate words where it moves further than the width of the glyphs.
+type pdfText struct {
+	doc *pdfDocument
+	sb  strings.Builder
+
+	state pdfState
+	stack []pdfState
+	// tm and lm are the text matrix and the matrix of the start of its line
+	tm, lm matrix
+
+	shown      bool
+	endX, endY float64
+	// decoded and skipped count the codes shown with fonts with and without a usable encoding
+	decoded, skipped int
+	skippedFont      string
+}
+
+// pdfState is the part of the graphics state which positions the text
+type pdfState struct {
+	ctm                                     matrix
+	font                                    *pdfFont
+	size, charSpacing, wordSpacing, leading float64
+	scale                                   float64
+}
+
+// matrix is a transformation matrix [a b c d e f] of PDF
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translation(tx, ty float64) matrix {
+	return matrix{1, 0, 0, 1, tx, ty}
+}
+
+func newPDFText(doc *pdfDocument) *pdfText {
+	return &pdfText{doc: doc, state: pdfState{ctm: identity, scale: 1}, tm: identity, lm: identity}
+}
+
+func (t *pdfText) newLine() {
+	if t.sb.Len() > 0 && !strings.HasSuffix(t.sb.String(), "\n") {
+		t.sb.WriteString("\n")
+	}
+}
+
+func (t *pdfText) space() {
+	if t.sb.Len() > 0 && !strings.HasSuffix(t.sb.String(), " ") && !strings.HasSuffix(t.sb.String(), "\n") {
+		t.sb.WriteString(" ")
+	}
+}
+
+// write writes the text of a string, after a line or a space depending on where it starts from the end of the
+// text before, and moves the text matrix after it
+func (t *pdfText) write(s []byte) {
+	trm := t.tm.mul(t.state.ctm)
+	size := math.Abs(t.state.size * math.Hypot(trm[2], trm[3]))
+	if size == 0 {
+		size = 1
+	}
+
+	var text string
+	font := t.state.font
+	switch {
+	case font == nil:
+		text = decodeUnknownString(s)
+	case font.unusable:
+		t.skipped += font.codes(s)
+		t.skippedFont = font.name
+	default:
+		text = font.decode(s)
+		t.decoded += font.codes(s)
+	}
+
+	if text != "" {
+		if t.shown {
+			dx, dy := trm[4]-t.endX, trm[5]-t.endY
+			switch {
+			case math.Abs(dy) > size/2:
+				t.newLine()
+			case (dx > size*0.15 || dx < -size) && !strings.HasPrefix(text, " "):
+				t.space()
+			}
+		}
+		t.sb.WriteString(text)
+		t.shown = true
+	}
+
+	t.advance(t.glyphsWidth(s))
+	end := t.tm.mul(t.state.ctm)
+	t.endX, t.endY = end[4], end[5]
+}
+
+// glyphsWidth returns the horizontal displacement of the text space after showing a string
+func (t *pdfText) glyphsWidth(s []byte) float64 {
+	st := t.state
+	var width float64
+	if st.font == nil {
+		for _, c := range s {
+			width += 0.5*st.size + st.charSpacing
+			if c == ' ' {
+				width += st.wordSpacing
+			}
+		}
+		return width * st.scale
+	}
+
+	for len(s) > 0 {
+		n := st.font.codeLength(s)
+		code := codeValue(s[:n])
+		width += st.font.width(code)/1000*st.size + st.charSpacing
+		if n == 1 && code == ' ' {
+			width += st.wordSpacing
+		}
+		s = s[n:]
+	}
+	return width * st.scale
+}
+
+func (t *pdfText) advance(tx float64) {
+	t.tm = translation(tx, 0).mul(t.tm)
+}
+
+func (t *pdfText) moveLine(tx, ty float64) {
+	t.lm = translation(tx, ty).mul(t.lm)
+	t.tm = t.lm
+}
+
+func (t *pdfText) show(content []byte, resources pdfDict, depth int) {
+	fonts := t.doc.dict(resources["Font"])
+	xobjects := t.doc.dict(resources["XObject"])
+
+	var operands []interface{}
+	number := func(i int) float64 {
+		if i < len(operands) {
+			if f, ok := operands[i].(float64); ok {
+				return f
+			}
+		}
+		return 0
+	}
+
+	p := &pdfLexer{data: content}
+	for {
+		token, ok := p.next()
+		if !ok {
+			break
+		}
+		op, isOperator := token.(pdfOperator)
+		if !isOperator {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch op {
+		case "q":
+			t.stack = append(t.stack, t.state)
+		case "Q":
+			if len(t.stack) > 0 {
+				t.state = t.stack[len(t.stack)-1]
+				t.stack = t.stack[:len(t.stack)-1]
+			}
+		case "cm":
+			if len(operands) == 6 {
+				t.state.ctm = matrix{number(0), number(1), number(2), number(3), number(4), number(5)}.mul(t.state.ctm)
+			}
+		case "BT":
+			t.tm, t.lm = identity, identity
+		case "Tf":
+			if len(operands) == 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					t.state.font = t.doc.font(fonts[string(name)])
+				}
+				t.state.size = number(1)
+			}
+		case "Tc":
+			t.state.charSpacing = number(0)
+		case "Tw":
+			t.state.wordSpacing = number(0)
+		case "Tz":
+			t.state.scale = number(0) / 100
+		case "TL":
+			t.state.leading = number(0)
+		case "Td":
+			t.moveLine(number(0), number(1))
+		case "TD":
+			t.state.leading = -number(1)
+			t.moveLine(number(0), number(1))
+		case "Tm":
+			if len(operands) == 6 {
+				t.lm = matrix{number(0), number(1), number(2), number(3), number(4), number(5)}
+				t.tm = t.lm
+			}
+		case "T*":
+			t.moveLine(0, -t.state.leading)
+		case "Tj":
+			if s, ok := lastOperand[pdfString](operands); ok {
+				t.write(s)
+			}
+		case "'", "\"":
+			if op == "\"" && len(operands) == 3 {
+				t.state.wordSpacing, t.state.charSpacing = number(0), number(1)
+			}
+			t.moveLine(0, -t.state.leading)
+			if s, ok := lastOperand[pdfString](operands); ok {
+				t.write(s)
+			}
+		case "TJ":
+			if array, ok := lastOperand[[]interface{}](operands); ok {
+				for _, e := range array {
+					switch e := e.(type) {
+					case pdfString:
+						t.write(e)
+					case float64:
+						t.advance(-e / 1000 * t.state.size * t.state.scale)
+					}
+				}
+			}
+		case "Do":
+			if name, ok := lastOperand[pdfName](operands); ok && depth < maxFormDepth {
+				t.showForm(xobjects[string(name)], resources, depth)
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// showForm shows the text of a form XObject, which has its own resources or else the ones of the page
+func (t *pdfText) showForm(v interface{}, resources pdfDict, depth int) {
+	form, ok := t.doc.resolve(v).(*pdfStream)
+	if !ok || form.dict["Subtype"] != pdfName("Form") {
+		return
+	}
+	content, err := t.doc.decode(form)
+	if err != nil {
+		return
+	}
+	if r := t.doc.dict(form.dict["Resources"]); r != nil {
+		resources = r
+	}
+
+	state, tm, lm := t.state, t.tm, t.lm
+	if m := t.doc.array(form.dict["Matrix"]); len(m) == 6 {
+		var fm matrix
+		for i := range fm {
+			fm[i], _ = t.doc.resolve(m[i]).(float64)
+		}
+		t.state.ctm = fm.mul(t.state.ctm)
+	}
+	t.show(content, resources, depth+1)
+	t.state, t.tm, t.lm = state, tm, lm
+}
+
+// decodeUnknownString decodes a string shown without a font, in UTF-16 with a byte order mark or else in
+// Latin-1. Strings of control characters are glyph identifiers which cannot be mapped to text.
+func decodeUnknownString(b []byte) string {
+	if !bytes.HasPrefix(b, []byte{0xfe, 0xff}) {
+		for _, c := range b {
+			if c < 0x20 && !isPDFWhitespace(c) {
+				return ""
+			}
+		}
+	}
+	return decodePDFString(b)
+}
+
+// decodePDFString decodes a text string of the document, in UTF-16 with a byte order mark, or else in Latin-1
+func decodePDFString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		return decodeUTF16(b[2:])
+	}
+
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
+
+func lastOperand[T any](operands []interface{}) (T, bool) {
+	var zero T
+	if len(operands) == 0 {
+		return zero, false
+	}
+	v, ok := operands[len(operands)-1].(T)
+	return v, ok
+}
+
+// pdfOperator is an operator of a content stream, or a keyword of the document, the other tokens being operands:
+// strings, numbers, arrays, names, dictionaries and references
+type pdfOperator string
+
+type pdfName string
+
+// pdfString holds the bytes of a string, which are decoded by the font showing it
+type pdfString []byte
+
+type pdfDict map[string]interface{}
+
+type pdfRef struct {
+	num, gen int
+}
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+	// objects is set to read the references of the objects of the document, which content streams do not have
+	objects bool
+}
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// object returns the next object, which is not an operator
+func (p *pdfLexer) object() (interface{}, bool) {
+	token, ok := p.next()
+	if _, isOperator := token.(pdfOperator); !ok || isOperator {
+		return nil, false
+	}
+	return token, true
+}
+
+// stream returns the stream following the dictionary of a stream object
+func (p *pdfLexer) stream(dict pdfDict) (*pdfStream, bool) {
+	start := p.pos
+	for start < len(p.data) && isPDFWhitespace(p.data[start]) {
+		start++
+	}
+	if !bytes.HasPrefix(p.data[start:], []byte("stream")) {
+		return nil, false
+	}
+	start += len("stream")
+	if bytes.HasPrefix(p.data[start:], []byte("\r\n")) {
+		start += 2
+	} else if start < len(p.data) && (p.data[start] == '\n' || p.data[start] == '\r') {
+		start++
+	}
+
+	// the length is trusted if the stream ends there, it can also be the reference of an object yet to be read
+	if length, ok := dict["Length"].(float64); ok && length >= 0 && start+int(length) <= len(p.data) {
+		end := start + int(length)
+		rest := bytes.TrimLeft(p.data[end:], "\r\n \t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			p.pos = len(p.data) - len(rest) + len("endstream")
+			return &pdfStream{dict: dict, data: p.data[start:end]}, true
+		}
+	}
+
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil, false
+	}
+	p.pos = start + end + len("endstream")
+	data := bytes.TrimSuffix(p.data[start:start+end], []byte("\n"))
+	return &pdfStream{dict: dict, data: bytes.TrimSuffix(data, []byte("\r"))}, true
+}
+
+// next returns the next token, or false at the end of the stream
+func (p *pdfLexer) next() (interface{}, bool) {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case isPDFWhitespace(c):
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		case c == '(':
+			p.pos++
+			return p.literalString(), true
+		case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+			p.pos += 2
+			return p.dictionary(), true
+		case c == '<':
+			p.pos++
+			return p.hexString(), true
+		case c == '>' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '>':
+			p.pos += 2
+			return pdfOperator(">>"), true
+		case c == '[':
+			p.pos++
+			array := []interface{}{}
+			for {
+				token, ok := p.next()
+				if !ok || token == pdfOperator("]") {
+					return array, true
+				}
+				array = append(array, token)
+			}
+		case c == ']':
+			p.pos++
+			return pdfOperator("]"), true
+		case c == '/':
+			p.pos++
+			return pdfName(p.name()), true
+		case isPDFDelimiter(c):
+			p.pos++
+		default:
+			w := p.word()
+			if f, err := strconv.ParseFloat(w, 64); err == nil {
+				if p.objects {
+					if ref, ok := p.reference(f); ok {
+						return ref, true
+					}
+				}
+				return f, true
+			}
+			if w == "BI" {
+				p.skipInlineImage()
+				continue
+			}
+			return pdfOperator(w), true
+		}
+	}
+	return nil, false
+}
+
+// reference reads the rest of a reference "num gen R" starting with num, if any
+func (p *pdfLexer) reference(num float64) (pdfRef, bool) {
+	pos := p.pos
+	objects := p.objects
+	p.objects = false
+	defer func() { p.objects = objects }()
+
+	if gen, ok := p.next(); ok {
+		if g, ok := gen.(float64); ok {
+			if r, ok := p.next(); ok && r == pdfOperator("R") {
+				return pdfRef{num: int(num), gen: int(g)}, true
+			}
+		}
+	}
+	p.pos = pos
+	return pdfRef{}, false
+}
+
+func (p *pdfLexer) dictionary() pdfDict {
+	dict := pdfDict{}
+	for {
+		token, ok := p.next()
+		if !ok || token == pdfOperator(">>") {
+			return dict
+		}
+		key, isName := token.(pdfName)
+		if !isName {
+			continue
+		}
+		value, ok := p.next()
+		if !ok || value == pdfOperator(">>") {
+			return dict
+		}
+		dict[string(key)] = value
+	}
+}
+
+func (p *pdfLexer) word() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFWhitespace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// name returns a name, whose characters can be escaped in hexadecimal after a #
+func (p *pdfLexer) name() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFWhitespace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	name := p.data[start:p.pos]
+	if bytes.IndexByte(name, '#') < 0 {
+		return string(name)
+	}
+
+	var b []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if n, err := strconv.ParseUint(string(name[i+1:i+3]), 16, 8); err == nil {
+				b = append(b, byte(n))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, name[i])
+	}
+	return string(b)
+}
+
+// skipInlineImage skips the data of an inline image, up to the EI operator
+func (p *pdfLexer) skipInlineImage() {
+	end := bytes.Index(p.data[p.pos:], []byte("EI"))
+	for end >= 0 {
+		i := p.pos + end
+		if i+2 >= len(p.data) || isPDFWhitespace(p.data[i+2]) {
+			p.pos = i + 2
+			return
+		}
+		next := bytes.Index(p.data[i+2:], []byte("EI"))
+		if next < 0 {
+			break
+		}
+		end += 2 + next
+	}
+	p.pos = len(p.data)
+}
+
+func (p *pdfLexer) literalString() pdfString {
+	var b []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				continue
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						n = n*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+func (p *pdfLexer) hexString() pdfString {
+	var digits []byte
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		if c := p.data[p.pos]; !isPDFWhitespace(c) {
+			digits = append(digits, c)
+		}
+		p.pos++
+	}
+	p.pos++
+	return decodeHex(digits)
+}
+
+// decodeHex decodes hexadecimal digits, the last one being followed by a 0 if they are odd
+func decodeHex(digits []byte) []byte {
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		n, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return b
+		}
+		b = append(b, byte(n))
+	}
+	return b
+}
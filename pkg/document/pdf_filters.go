@@ -0,0 +1,273 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// decode returns the data of a stream, decoded by its filters
+func (doc *pdfDocument) decode(stream *pdfStream) ([]byte, error) {
+	filters := doc.array(stream.dict["Filter"])
+	params := doc.array(stream.dict["DecodeParms"])
+
+	data := stream.data
+	for i, f := range filters {
+		var p pdfDict
+		if i < len(params) {
+			p = doc.dict(params[i])
+		}
+
+		var err error
+		switch name, _ := doc.resolve(f).(pdfName); name {
+		case "FlateDecode", "Fl":
+			data, err = flateDecode(data)
+			if err == nil {
+				data, err = doc.unpredict(data, p)
+			}
+		case "LZWDecode", "LZW":
+			earlyChange := true
+			if e, ok := doc.resolve(p["EarlyChange"]).(float64); ok && e == 0 {
+				earlyChange = false
+			}
+			data, err = lzwDecode(data, earlyChange)
+			if err == nil {
+				data, err = doc.unpredict(data, p)
+			}
+		case "ASCIIHexDecode", "AHx":
+			data = asciiHexDecode(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		case "RunLengthDecode", "RL":
+			data = runLengthDecode(data)
+		default:
+			// the other filters are only used for images
+			err = fmt.Errorf("unsupported filter %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode a stream of the PDF document: %w", err)
+		}
+		if len(data) > maxStreamSize {
+			return nil, errors.New("a stream of the PDF document is too large")
+		}
+	}
+	return data, nil
+}
+
+func flateDecode(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(io.LimitReader(r, maxStreamSize+1))
+	// streams are often followed by an end of line counted in their length, which the decompression ignores,
+	// but an unexpected end of the data still leaves most of the text
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return content, nil
+}
+
+// unpredict reverts the predictor of the parameters of a Flate or LZW filter, which compresses the differences
+// between the bytes of rows rather than the bytes themselves
+func (doc *pdfDocument) unpredict(data []byte, params pdfDict) ([]byte, error) {
+	param := func(key string, def int) int {
+		if v, ok := doc.resolve(params[key]).(float64); ok {
+			return int(v)
+		}
+		return def
+	}
+	predictor := param("Predictor", 1)
+	if predictor == 1 {
+		return data, nil
+	}
+	bpp := (param("Colors", 1)*param("BitsPerComponent", 8) + 7) / 8
+	columns := param("Columns", 1)
+	rowSize := (param("Colors", 1)*param("BitsPerComponent", 8)*columns + 7) / 8
+	if bpp <= 0 || rowSize <= 0 {
+		return nil, errors.New("invalid predictor parameters")
+	}
+
+	if predictor == 2 {
+		// TIFF predictor, only supported for 8 bits components
+		if param("BitsPerComponent", 8) != 8 {
+			return nil, errors.New("unsupported TIFF predictor")
+		}
+		out := append([]byte{}, data...)
+		for row := 0; row+rowSize <= len(out); row += rowSize {
+			for i := row + bpp; i < row+rowSize; i++ {
+				out[i] += out[i-bpp]
+			}
+		}
+		return out, nil
+	}
+
+	// PNG predictors: every row starts with the type of its filter
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowSize)
+	for pos := 0; pos+1+rowSize <= len(data); pos += 1 + rowSize {
+		filter, row := data[pos], append([]byte{}, data[pos+1:pos+1+rowSize]...)
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("invalid PNG predictor %d", filter)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// lzwDecode decodes the LZW compression of PDF, whose codes grow one code early by default, unlike the one of
+// compress/lzw
+func lzwDecode(data []byte, earlyChange bool) ([]byte, error) {
+	const clear, eod = 256, 257
+
+	var out []byte
+	var table [][]byte
+	reset := func() {
+		table = table[:0]
+		for i := 0; i < 256; i++ {
+			table = append(table, []byte{byte(i)})
+		}
+		table = append(table, nil, nil)
+	}
+	reset()
+
+	width := 9
+	var bits, nbits uint
+	var prev []byte
+	for _, b := range data {
+		bits = bits<<8 | uint(b)
+		nbits += 8
+		for nbits >= uint(width) {
+			code := int(bits >> (nbits - uint(width)) & (1<<width - 1))
+			nbits -= uint(width)
+
+			switch {
+			case code == clear:
+				reset()
+				width, prev = 9, nil
+				continue
+			case code == eod:
+				return out, nil
+			case code < len(table) && table[code] != nil:
+				entry := table[code]
+				if prev != nil {
+					table = append(table, append(append([]byte{}, prev...), entry[0]))
+				}
+				out = append(out, entry...)
+				prev = entry
+			case code == len(table) && prev != nil:
+				entry := append(append([]byte{}, prev...), prev[0])
+				table = append(table, entry)
+				out = append(out, entry...)
+				prev = entry
+			default:
+				return nil, errors.New("invalid LZW code")
+			}
+			if len(out) > maxStreamSize {
+				return out, nil
+			}
+
+			next := len(table)
+			if earlyChange {
+				next++
+			}
+			switch {
+			case next >= 2048:
+				width = 12
+			case next >= 1024:
+				width = 11
+			case next >= 512:
+				width = 10
+			}
+		}
+	}
+	return out, nil
+}
+
+func asciiHexDecode(data []byte) []byte {
+	if i := bytes.IndexByte(data, '>'); i >= 0 {
+		data = data[:i]
+	}
+	var digits []byte
+	for _, c := range data {
+		if !isPDFWhitespace(c) {
+			digits = append(digits, c)
+		}
+	}
+	return decodeHex(digits)
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	out := make([]byte, 4*len(data)/5+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
+
+func runLengthDecode(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data) && len(out) <= maxStreamSize; {
+		n := int(data[i])
+		i++
+		switch {
+		case n == 128:
+			return out
+		case n < 128:
+			end := min(i+n+1, len(data))
+			out = append(out, data[i:end]...)
+			i = end
+		case i < len(data):
+			out = append(out, bytes.Repeat(data[i:i+1], 257-n)...)
+			i++
+		}
+	}
+	return out
+}
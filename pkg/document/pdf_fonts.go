@@ -0,0 +1,551 @@
+package document
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// pdfFont decodes the strings shown with a font to text, with its ToUnicode CMap, or else with its encoding
+type pdfFont struct {
+	name      string
+	toUnicode *pdfCMap
+	// composite fonts have codes of one or more bytes, given by their CMap, which are two bytes with the
+	// Identity and the Unicode CMaps
+	composite bool
+	twoBytes  bool
+	utf16     bool
+	// encoding maps the codes of simple fonts to text
+	encoding *[256]string
+	// unusable is set for the fonts whose codes cannot be mapped to text, e.g. glyph identifiers
+	unusable bool
+
+	// the widths of the glyphs of the codes, which position the text following them
+	widths       map[int]float64
+	widthRanges  []widthRange
+	defaultWidth float64
+}
+
+type widthRange struct {
+	first, last int
+	width       float64
+}
+
+// font returns the font of a font dictionary, or nil if there is none
+func (doc *pdfDocument) font(v interface{}) *pdfFont {
+	ref, isRef := v.(pdfRef)
+	if f, ok := doc.fonts[ref]; isRef && ok {
+		return f
+	}
+	dict := doc.dict(v)
+	if dict == nil {
+		return nil
+	}
+
+	f := doc.newFont(dict)
+	if isRef {
+		doc.fonts[ref] = f
+	}
+	return f
+}
+
+func (doc *pdfDocument) newFont(dict pdfDict) *pdfFont {
+	name, _ := doc.resolve(dict["BaseFont"]).(pdfName)
+	f := &pdfFont{name: string(name)}
+	if f.name == "" {
+		f.name = "without name"
+	}
+
+	if stream, ok := doc.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := doc.decode(stream); err == nil {
+			f.toUnicode = parseCMap(data)
+		}
+	}
+
+	if dict["Subtype"] == pdfName("Type0") {
+		f.composite = true
+		encoding, _ := doc.resolve(dict["Encoding"]).(pdfName)
+		switch {
+		case encoding == "Identity-H" || encoding == "Identity-V":
+			f.twoBytes = true
+		case strings.HasPrefix(string(encoding), "Uni") && (strings.Contains(string(encoding), "UCS2") || strings.Contains(string(encoding), "UTF16")):
+			f.twoBytes, f.utf16 = true, true
+		}
+		f.unusable = f.toUnicode == nil && !f.utf16
+		doc.cidWidths(f, encoding, doc.array(dict["DescendantFonts"]))
+		return f
+	}
+
+	f.encoding = doc.simpleEncoding(dict)
+	f.unusable = f.toUnicode == nil && f.encoding == nil
+	doc.simpleWidths(f, dict)
+	return f
+}
+
+// simpleWidths reads the widths of the codes of a simple font. The standard fonts may have none, their glyphs
+// are then given the average width of the letters.
+func (doc *pdfDocument) simpleWidths(f *pdfFont, dict pdfDict) {
+	f.widths = map[int]float64{}
+	f.defaultWidth = 500
+	if w, ok := doc.resolve(doc.dict(dict["FontDescriptor"])["MissingWidth"]).(float64); ok && w > 0 {
+		f.defaultWidth = w
+	}
+
+	// the glyphs of Type3 fonts are scaled by their font matrix rather than by a thousandth
+	scale := 1.0
+	if m := doc.array(dict["FontMatrix"]); dict["Subtype"] == pdfName("Type3") && len(m) == 6 {
+		if a, ok := doc.resolve(m[0]).(float64); ok {
+			scale = a * 1000
+		}
+	}
+
+	first, _ := doc.resolve(dict["FirstChar"]).(float64)
+	for i, w := range doc.array(dict["Widths"]) {
+		if w, ok := doc.resolve(w).(float64); ok {
+			f.widths[int(first)+i] = w * scale
+		}
+	}
+}
+
+// cidWidths reads the widths of the glyphs of the descendant CID font of a composite font, whose codes are their
+// identifiers with the Identity CMaps
+func (doc *pdfDocument) cidWidths(f *pdfFont, encoding pdfName, descendants []interface{}) {
+	f.widths = map[int]float64{}
+	f.defaultWidth = 1000
+	if len(descendants) == 0 {
+		return
+	}
+	descendant := doc.dict(descendants[0])
+	if w, ok := doc.resolve(descendant["DW"]).(float64); ok {
+		f.defaultWidth = w
+	}
+	if encoding != "Identity-H" && encoding != "Identity-V" {
+		return
+	}
+
+	w := doc.array(descendant["W"])
+	for i := 0; i+1 < len(w); {
+		first, ok := doc.resolve(w[i]).(float64)
+		if !ok {
+			return
+		}
+		switch next := doc.resolve(w[i+1]).(type) {
+		case []interface{}:
+			for j, width := range next {
+				if width, ok := doc.resolve(width).(float64); ok {
+					f.widths[int(first)+j] = width
+				}
+			}
+			i += 2
+		case float64:
+			if i+2 >= len(w) {
+				return
+			}
+			width, _ := doc.resolve(w[i+2]).(float64)
+			f.widthRanges = append(f.widthRanges, widthRange{first: int(first), last: int(next), width: width})
+			i += 3
+		default:
+			return
+		}
+	}
+}
+
+// simpleEncoding returns the text of the codes of a simple font, from its base encoding and the differences to
+// it. Symbolic fonts without encoding use the one of their font program, which has no text.
+func (doc *pdfDocument) simpleEncoding(dict pdfDict) *[256]string {
+	name, _ := doc.resolve(dict["BaseFont"]).(pdfName)
+	symbolic := doc.symbolic(dict)
+
+	var base *[256]string
+	if !symbolic {
+		base = &standardEncoding
+	}
+	var differences []interface{}
+	switch enc := doc.resolve(dict["Encoding"]).(type) {
+	case pdfName:
+		if e, ok := namedEncodings[string(enc)]; ok {
+			base = e
+		}
+	case pdfDict:
+		if name, ok := doc.resolve(enc["BaseEncoding"]).(pdfName); ok && namedEncodings[string(name)] != nil {
+			base = namedEncodings[string(name)]
+		}
+		differences = doc.array(enc["Differences"])
+	}
+
+	var encoding [256]string
+	if base != nil {
+		encoding = *base
+	}
+	code := 0
+	for _, d := range differences {
+		switch d := doc.resolve(d).(type) {
+		case float64:
+			code = int(d)
+		case pdfName:
+			if code >= 0 && code < 256 {
+				encoding[code] = glyphText(string(d))
+			}
+			code++
+		}
+	}
+
+	for _, text := range encoding {
+		if text != "" {
+			return &encoding
+		}
+	}
+	// the symbols of the standard fonts are not text, but do not prevent extracting the rest of the text
+	if name == "Symbol" || name == "ZapfDingbats" {
+		return &encoding
+	}
+	return nil
+}
+
+// symbolic returns whether a font has symbols, by its flags, or is a Type3 font, whose glyphs are drawings
+func (doc *pdfDocument) symbolic(dict pdfDict) bool {
+	if dict["Subtype"] == pdfName("Type3") {
+		return true
+	}
+	descriptor := doc.dict(dict["FontDescriptor"])
+	if flags, ok := doc.resolve(descriptor["Flags"]).(float64); ok {
+		return int(flags)&4 != 0
+	}
+	name, _ := doc.resolve(dict["BaseFont"]).(pdfName)
+	return name == "Symbol" || name == "ZapfDingbats"
+}
+
+// decode returns the text of a string shown with the font, skipping the codes without text
+func (f *pdfFont) decode(s []byte) string {
+	var sb strings.Builder
+	for len(s) > 0 {
+		n := f.codeLength(s)
+		code := s[:n]
+		s = s[n:]
+
+		text, ok := "", false
+		if f.toUnicode != nil {
+			text, ok = f.toUnicode.lookup(code)
+		}
+		switch {
+		case ok:
+		case f.utf16:
+			text = decodeUTF16(code)
+		case f.encoding != nil && n == 1:
+			text = f.encoding[code[0]]
+		}
+		for _, r := range text {
+			if !unicode.IsControl(r) || r == '\n' || r == '\t' {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// codes returns the number of codes of a string shown with the font
+func (f *pdfFont) codes(s []byte) int {
+	n := 0
+	for len(s) > 0 {
+		s = s[f.codeLength(s):]
+		n++
+	}
+	return n
+}
+
+// width returns the width of the glyph of a code, in thousandths of the size of the font
+func (f *pdfFont) width(code int) float64 {
+	if w, ok := f.widths[code]; ok {
+		return w
+	}
+	for _, r := range f.widthRanges {
+		if code >= r.first && code <= r.last {
+			return r.width
+		}
+	}
+	return f.defaultWidth
+}
+
+func (f *pdfFont) codeLength(s []byte) int {
+	switch {
+	case !f.composite:
+		return 1
+	case f.twoBytes:
+		return min(2, len(s))
+	case f.toUnicode != nil:
+		return f.toUnicode.codeLength(s)
+	}
+	return 1
+}
+
+// pdfCMap maps the codes of a font to text, as given by a ToUnicode CMap
+type pdfCMap struct {
+	codespaces []cmapRange
+	chars      map[string]string
+	ranges     []cmapRange
+}
+
+// cmapRange is a range of codes of the same length, mapped to text starting with dst, or to the texts of array.
+// The bytes of the codes of codespace ranges are each in the range of the bytes of lo and hi.
+type cmapRange struct {
+	lo, hi []byte
+	dst    []byte
+	array  []string
+}
+
+func (r cmapRange) contains(code []byte) bool {
+	if len(code) != len(r.lo) {
+		return false
+	}
+	for i, c := range code {
+		if c < r.lo[i] || c > r.hi[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCMap parses the mappings of a ToUnicode CMap, or returns nil if it has none
+func parseCMap(data []byte) *pdfCMap {
+	cmap := &pdfCMap{chars: map[string]string{}}
+
+	var operands []interface{}
+	p := &pdfLexer{data: data}
+	for {
+		token, ok := p.next()
+		if !ok {
+			break
+		}
+		op, isOperator := token.(pdfOperator)
+		if !isOperator {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(lo) == len(hi) && len(lo) > 0 {
+					cmap.codespaces = append(cmap.codespaces, cmapRange{lo: lo, hi: hi})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if src, ok := operands[i].(pdfString); ok {
+					switch dst := operands[i+1].(type) {
+					case pdfString:
+						cmap.chars[string(src)] = decodeUTF16(dst)
+					case pdfName:
+						cmap.chars[string(src)] = glyphText(string(dst))
+					}
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 {
+					continue
+				}
+				r := cmapRange{lo: lo, hi: hi}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					r.dst = dst
+				case []interface{}:
+					for _, e := range dst {
+						s, _ := e.(pdfString)
+						r.array = append(r.array, decodeUTF16(s))
+					}
+				default:
+					continue
+				}
+				cmap.ranges = append(cmap.ranges, r)
+			}
+		}
+		operands = operands[:0]
+	}
+
+	if len(cmap.chars) == 0 && len(cmap.ranges) == 0 {
+		return nil
+	}
+	return cmap
+}
+
+// lookup returns the text of a code, if it is mapped
+func (m *pdfCMap) lookup(code []byte) (string, bool) {
+	if text, ok := m.chars[string(code)]; ok {
+		return text, true
+	}
+	for _, r := range m.ranges {
+		if len(code) != len(r.lo) || bytes.Compare(code, r.lo) < 0 || bytes.Compare(code, r.hi) > 0 {
+			continue
+		}
+		offset := codeValue(code) - codeValue(r.lo)
+		if r.array != nil {
+			if offset < len(r.array) {
+				return r.array[offset], true
+			}
+			return "", false
+		}
+		// the last byte of the destination is incremented, spilling over the byte before in practice
+		dst := append([]byte{}, r.dst...)
+		if len(dst) >= 2 {
+			v := int(dst[len(dst)-2])<<8 | int(dst[len(dst)-1]) + offset
+			dst[len(dst)-2], dst[len(dst)-1] = byte(v>>8), byte(v)
+		} else if len(dst) == 1 {
+			dst[0] += byte(offset)
+		}
+		return decodeUTF16(dst), true
+	}
+	return "", false
+}
+
+// codeLength returns the length of the code at the start of s, from the codespace ranges of the CMap
+func (m *pdfCMap) codeLength(s []byte) int {
+	for _, r := range m.codespaces {
+		if len(s) >= len(r.lo) && r.contains(s[:len(r.lo)]) {
+			return len(r.lo)
+		}
+	}
+	return 1
+}
+
+func codeValue(code []byte) int {
+	v := 0
+	for _, c := range code {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+// glyphText returns the text of a glyph name of the Adobe Glyph List, or of the uniXXXX and uXXXX[XX] forms.
+// The suffixes of variants, e.g. a.sc, are ignored and the components of ligatures, e.g. f_f, are joined.
+func glyphText(name string) string {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if strings.Contains(name, "_") {
+		var sb strings.Builder
+		for _, component := range strings.Split(name, "_") {
+			sb.WriteString(glyphText(component))
+		}
+		return sb.String()
+	}
+
+	if len(name) == 1 && (name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z') {
+		return name
+	}
+	if text, ok := glyphNames[name]; ok {
+		return text
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		var sb strings.Builder
+		for i := 3; i < len(name); i += 4 {
+			r, err := strconv.ParseUint(name[i:i+4], 16, 16)
+			if err != nil {
+				return ""
+			}
+			sb.WriteRune(rune(r))
+		}
+		return sb.String()
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if r, err := strconv.ParseUint(name[1:], 16, 32); err == nil && r <= unicode.MaxRune {
+			return string(rune(r))
+		}
+	}
+	return ""
+}
+
+var (
+	standardEncoding = buildEncoding(map[byte]string{
+		0x27: "’", 0x60: "‘", 0xa1: "¡", 0xa2: "¢", 0xa3: "£", 0xa4: "⁄", 0xa5: "¥", 0xa6: "ƒ", 0xa7: "§",
+		0xa8: "¤", 0xa9: "'", 0xaa: "“", 0xab: "«", 0xac: "‹", 0xad: "›", 0xae: "fi", 0xaf: "fl", 0xb1: "–",
+		0xb2: "†", 0xb3: "‡", 0xb4: "·", 0xb6: "¶", 0xb7: "•", 0xb8: "‚", 0xb9: "„", 0xba: "”", 0xbb: "»",
+		0xbc: "…", 0xbd: "‰", 0xbf: "¿", 0xc1: "`", 0xc2: "´", 0xc3: "ˆ", 0xc4: "˜", 0xc5: "¯", 0xc6: "˘",
+		0xc7: "˙", 0xc8: "¨", 0xca: "˚", 0xcb: "¸", 0xcd: "˝", 0xce: "˛", 0xcf: "ˇ", 0xd0: "—", 0xe1: "Æ",
+		0xe3: "ª", 0xe8: "Ł", 0xe9: "Ø", 0xea: "Œ", 0xeb: "º", 0xf1: "æ", 0xf5: "ı", 0xf8: "ł", 0xf9: "ø",
+		0xfa: "œ", 0xfb: "ß",
+	}, "")
+	winAnsiEncoding  = buildEncoding(map[byte]string{0xa0: " ", 0xad: "-"}, "€\x00‚ƒ„…†‡ˆ‰Š‹Œ\x00Ž\x00\x00‘’“”•–—˜™š›œ\x00žŸ"+latin1High)
+	macRomanEncoding = buildEncoding(map[byte]string{0xca: " "}, "ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄¤‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔ\x00ÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ")
+	namedEncodings   = map[string]*[256]string{
+		"StandardEncoding":  &standardEncoding,
+		"WinAnsiEncoding":   &winAnsiEncoding,
+		"MacRomanEncoding":  &macRomanEncoding,
+		"MacExpertEncoding": &standardEncoding,
+	}
+)
+
+// latin1High holds the characters of Latin-1 from 0xa0
+var latin1High = func() string {
+	var sb strings.Builder
+	for r := rune(0xa0); r <= 0xff; r++ {
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}()
+
+// buildEncoding returns an encoding which is ASCII for the printable characters, with the characters of high
+// from 0x80, and the ones of overrides
+func buildEncoding(overrides map[byte]string, high string) [256]string {
+	var encoding [256]string
+	for c := 0x20; c < 0x7f; c++ {
+		encoding[c] = string(rune(c))
+	}
+	c := 0x80
+	for _, r := range high {
+		if r != 0 {
+			encoding[c] = string(r)
+		}
+		c++
+	}
+	for c, text := range overrides {
+		encoding[c] = text
+	}
+	return encoding
+}
+
+// glyphNames holds the text of the glyph names of the Latin fonts, mostly from the Adobe Glyph List, with the
+// ligatures given as their letters
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "parenleft": "(", "parenright": ")", "asterisk": "*",
+	"plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/", "zero": "0", "one": "1", "two": "2",
+	"three": "3", "four": "4", "five": "5", "six": "6", "seven": "7", "eight": "8", "nine": "9", "colon": ":",
+	"semicolon": ";", "less": "<", "equal": "=", "greater": ">", "question": "?", "at": "@", "bracketleft": "[",
+	"backslash": "\\", "bracketright": "]", "asciicircum": "^", "underscore": "_", "grave": "`", "quoteleft": "‘",
+	"braceleft": "{", "bar": "|", "braceright": "}", "asciitilde": "~", "exclamdown": "¡", "cent": "¢",
+	"sterling": "£", "fraction": "⁄", "yen": "¥", "florin": "ƒ", "section": "§", "currency": "¤",
+	"quotedblleft": "“", "guillemotleft": "«", "guilsinglleft": "‹", "guilsinglright": "›", "fi": "fi", "fl": "fl",
+	"ff": "ff", "ffi": "ffi", "ffl": "ffl", "endash": "–", "emdash": "—", "dagger": "†", "daggerdbl": "‡",
+	"periodcentered": "·", "paragraph": "¶", "bullet": "•", "quotesinglbase": "‚", "quotedblbase": "„",
+	"quotedblright": "”", "guillemotright": "»", "ellipsis": "…", "perthousand": "‰", "questiondown": "¿",
+	"acute": "´", "circumflex": "ˆ", "tilde": "˜", "macron": "¯", "breve": "˘", "dotaccent": "˙", "dieresis": "¨",
+	"ring": "˚", "cedilla": "¸", "hungarumlaut": "˝", "ogonek": "˛", "caron": "ˇ", "AE": "Æ", "ordfeminine": "ª",
+	"Lslash": "Ł", "Oslash": "Ø", "OE": "Œ", "ordmasculine": "º", "ae": "æ", "dotlessi": "ı", "dotlessj": "ȷ",
+	"lslash": "ł", "oslash": "ø", "oe": "œ", "germandbls": "ß", "Euro": "€", "trademark": "™",
+	"nbspace": " ", "brokenbar": "¦", "copyright": "©", "logicalnot": "¬", "registered": "®",
+	"degree": "°", "plusminus": "±", "twosuperior": "²", "threesuperior": "³", "mu": "µ", "onesuperior": "¹",
+	"onequarter": "¼", "onehalf": "½", "threequarters": "¾", "multiply": "×", "divide": "÷", "minus": "−",
+	"Agrave": "À", "Aacute": "Á", "Acircumflex": "Â", "Atilde": "Ã", "Adieresis": "Ä", "Aring": "Å",
+	"Ccedilla": "Ç", "Egrave": "È", "Eacute": "É", "Ecircumflex": "Ê", "Edieresis": "Ë", "Igrave": "Ì",
+	"Iacute": "Í", "Icircumflex": "Î", "Idieresis": "Ï", "Eth": "Ð", "Ntilde": "Ñ", "Ograve": "Ò", "Oacute": "Ó",
+	"Ocircumflex": "Ô", "Otilde": "Õ", "Odieresis": "Ö", "Ugrave": "Ù", "Uacute": "Ú", "Ucircumflex": "Û",
+	"Udieresis": "Ü", "Yacute": "Ý", "Thorn": "Þ", "agrave": "à", "aacute": "á", "acircumflex": "â",
+	"atilde": "ã", "adieresis": "ä", "aring": "å", "ccedilla": "ç", "egrave": "è", "eacute": "é",
+	"ecircumflex": "ê", "edieresis": "ë", "igrave": "ì", "iacute": "í", "icircumflex": "î", "idieresis": "ï",
+	"eth": "ð", "ntilde": "ñ", "ograve": "ò", "oacute": "ó", "ocircumflex": "ô", "otilde": "õ", "odieresis": "ö",
+	"ugrave": "ù", "uacute": "ú", "ucircumflex": "û", "udieresis": "ü", "yacute": "ý", "thorn": "þ",
+	"ydieresis": "ÿ", "Ydieresis": "Ÿ", "Scaron": "Š", "scaron": "š", "Zcaron": "Ž", "zcaron": "ž",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ",
+	"Phi": "Φ", "Psi": "Ψ", "Omega": "Ω", "alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ",
+	"epsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "iota": "ι", "kappa": "κ", "lambda": "λ", "nu": "ν",
+	"xi": "ξ", "pi": "π", "rho": "ρ", "sigma": "σ", "tau": "τ", "upsilon": "υ", "phi": "φ", "chi": "χ",
+	"psi": "ψ", "omega": "ω", "arrowleft": "←", "arrowright": "→", "arrowup": "↑", "arrowdown": "↓",
+	"infinity": "∞", "lessequal": "≤", "greaterequal": "≥", "notequal": "≠", "approxequal": "≈",
+	"visiblespace": "␣", "quotedblleftbase": "„", "perthousandzero": "‰",
+}
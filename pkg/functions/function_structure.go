@@ -30,6 +30,14 @@ func (j JSONFunctionStructure) Grammar(options ...func(*grammars.GrammarOption))
 	return converter.GrammarFromBytes(dat, options...)
 }
 
+// JSONSchemaGrammar converts a JSON schema (e.g. the one of a json_schema response format) to a grammar
+func JSONSchemaGrammar(schema map[string]interface{}, options ...func(*grammars.GrammarOption)) (string, error) {
+	grammarOpts := &grammars.GrammarOption{}
+	grammarOpts.Apply(options...)
+
+	return grammars.NewJSONSchemaConverter(grammarOpts.PropOrder).Grammar(schema, options...)
+}
+
 type SchemaConverter interface {
 	GrammarFromBytes([]byte, ...func(*grammars.GrammarOption)) (string, error)
 }
@@ -38,6 +46,12 @@ func NewSchemaConverter(opt grammars.GrammarOption) SchemaConverter {
 	switch {
 	case opt.SchemaType == grammars.LLama31Schema:
 		return grammars.NewLLama31SchemaConverter(opt.FunctionName)
+	case opt.SchemaType == grammars.HermesSchema,
+		opt.SchemaType == grammars.MistralSchema,
+		opt.SchemaType == grammars.Llama3JSONSchema,
+		opt.SchemaType == grammars.PythonicSchema,
+		opt.SchemaType == grammars.DeepSeekSchema:
+		return grammars.NewToolCallSchemaConverter(opt.SchemaType, opt.PropOrder)
 	}
 	return grammars.NewJSONSchemaConverter(opt.PropOrder)
 }
@@ -26,6 +26,7 @@ type FunctionName struct {
 type Argument struct {
 	Type       string                 `json:"type"`
 	Properties map[string]interface{} `json:"properties"`
+	Required   []string               `json:"required,omitempty"`
 }
 
 type Tool struct {
@@ -69,11 +70,23 @@ func (f Functions) ToJSONStructure(name, args string) JSONFunctionStructure {
 			js.Defs = defsD
 		}
 
+		var required []string
+		if r, ok := function.Parameters["required"].([]interface{}); ok {
+			for _, v := range r {
+				if s, ok := v.(string); ok {
+					required = append(required, s)
+				}
+			}
+		} else if r, ok := function.Parameters["required"].([]string); ok {
+			required = r
+		}
+
 		property := map[string]interface{}{}
 		property[nameKey] = FunctionName{Const: function.Name}
 		property[argsKey] = Argument{
 			Type:       "object",
 			Properties: prop,
+			Required:   required,
 		}
 		js.OneOf = append(js.OneOf, Item{
 			Type:       "object",
@@ -65,6 +65,28 @@ var _ = Describe("LocalAI grammar functions", func() {
 			Expect(fnName.Const).To(Equal("search"))
 			Expect(fnArgs.Properties["query"].(map[string]interface{})["type"]).To(Equal("string"))
 		})
+		It("keeps the required arguments", func() {
+			var functions Functions = []Function{
+				{
+					Name: "search",
+					Parameters: map[string]interface{}{
+						"properties": map[string]interface{}{
+							"query": map[string]interface{}{"type": "string"},
+							"limit": map[string]interface{}{"type": "integer"},
+						},
+						"required": []interface{}{"query"},
+					},
+				},
+			}
+
+			js := functions.ToJSONStructure("name", "arguments")
+			fnArgs := js.OneOf[0].Properties["arguments"].(Argument)
+			Expect(fnArgs.Required).To(Equal([]string{"query"}))
+
+			grammar, err := js.Grammar()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(grammar).To(ContainSubstring(`"{" space "\"query\"" space ":" space string ( "," space ( "\"limit\"" space ":" space integer ) )? "}" space`))
+		})
 	})
 	Context("Select()", func() {
 		It("selects one of the functions and returns a list containing only the selected one", func() {
@@ -22,6 +22,36 @@ var (
 			"\\" (["\\/bfnrt] | "u" [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F])
 		  )* space`,
 		"null": `"null" space`,
+		// generic JSON values, used for schemas without a type
+		"value":  `object | array | string | number | boolean | null`,
+		"object": `"{" space ( string ":" space value ( "," space string ":" space value )* )? "}" space`,
+		"array":  `"[" space ( value ( "," space value )* )? "]" space`,
+		// a single character inside a JSON string
+		"char": `[^"\\] | "\\" (["\\/bfnrt] | "u" [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F])`,
+	}
+
+	// PRIMITIVE_RULES_DEPS lists the rules referenced by the primitive rules
+	PRIMITIVE_RULES_DEPS = map[string][]string{
+		"value":  {"object", "array", "string", "number", "boolean", "null"},
+		"object": {"string", "value"},
+		"array":  {"value"},
+	}
+
+	// STRING_FORMAT_RULES are the rules for the supported "format" values of string schemas
+	STRING_FORMAT_RULES = map[string]string{
+		"date":      `[0-9] [0-9] [0-9] [0-9] "-" ( "0" [1-9] | "1" [0-2] ) "-" ( "0" [1-9] | [1-2] [0-9] | "3" [0-1] )`,
+		"time":      `( [01] [0-9] | "2" [0-3] ) ":" [0-5] [0-9] ":" [0-5] [0-9] ( "." [0-9]+ )? ( "Z" | ( "+" | "-" ) ( [01] [0-9] | "2" [0-3] ) ":" [0-5] [0-9] )`,
+		"date-time": `date "T" time`,
+		"uuid": `[0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] "-" ` +
+			`[0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] "-" ` +
+			`[0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] "-" ` +
+			`[0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] "-" ` +
+			`[0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F]`,
+		"email": `( [a-zA-Z0-9._%+] | "-" )+ "@" ( [a-zA-Z0-9] | "-" )+ ( "." ( [a-zA-Z0-9] | "-" )+ )* "." [a-zA-Z] [a-zA-Z]+`,
+	}
+
+	STRING_FORMAT_RULES_DEPS = map[string][]string{
+		"date-time": {"date", "time"},
 	}
 
 	INVALID_RULE_CHARS_RE     = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
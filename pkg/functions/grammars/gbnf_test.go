@@ -0,0 +1,423 @@
+package grammars_test
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A minimal GBNF recognizer, used to check that the generated grammars accept
+// (or reject) sample outputs.
+
+type gbnfNode interface {
+	// match returns the positions at which the node can end, when starting at pos
+	match(g *gbnfGrammar, input []rune, pos int) []int
+}
+
+type gbnfGrammar struct {
+	rules map[string]gbnfNode
+	memo  map[string]map[int][]int
+}
+
+var gbnfRuleRE = regexp.MustCompile(`(?m)^([a-zA-Z0-9-]+)\s*::=`)
+
+func parseGBNF(grammar string) (*gbnfGrammar, error) {
+	g := &gbnfGrammar{rules: map[string]gbnfNode{}}
+
+	locs := gbnfRuleRE.FindAllStringSubmatchIndex(grammar, -1)
+	for i, loc := range locs {
+		name := grammar[loc[2]:loc[3]]
+		end := len(grammar)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+
+		p := &gbnfParser{src: []rune(grammar[loc[1]:end])}
+		node, err := p.parseAlternatives()
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		p.skipSpace()
+		if p.pos != len(p.src) {
+			return nil, fmt.Errorf("rule %s: unexpected %q", name, string(p.src[p.pos:]))
+		}
+		if _, exists := g.rules[name]; exists {
+			return nil, fmt.Errorf("rule %s defined twice", name)
+		}
+		g.rules[name] = node
+	}
+
+	if _, exists := g.rules["root"]; !exists {
+		return nil, fmt.Errorf("no root rule")
+	}
+	return g, nil
+}
+
+// Matches reports whether the whole input is accepted by the grammar
+func (g *gbnfGrammar) Matches(input string) bool {
+	g.memo = map[string]map[int][]int{}
+	runes := []rune(input)
+	for _, end := range (gbnfRef{name: "root"}).match(g, runes, 0) {
+		if end == len(runes) {
+			return true
+		}
+	}
+	return false
+}
+
+type gbnfParser struct {
+	src []rune
+	pos int
+}
+
+func (p *gbnfParser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *gbnfParser) peek() rune {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *gbnfParser) parseAlternatives() (gbnfNode, error) {
+	var alternatives gbnfAlt
+	for {
+		seq, err := p.parseSequence()
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, seq)
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return alternatives, nil
+}
+
+func (p *gbnfParser) parseSequence() (gbnfNode, error) {
+	var seq gbnfSeq
+	for {
+		c := p.peek()
+		if c == 0 || c == '|' || c == ')' {
+			return seq, nil
+		}
+
+		item, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		item, err = p.parsePostfix(item)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, item)
+	}
+}
+
+func (p *gbnfParser) parsePrimary() (gbnfNode, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		p.pos++
+		var lit gbnfLiteral
+		for {
+			if p.pos >= len(p.src) {
+				return nil, fmt.Errorf("unterminated literal")
+			}
+			r := p.src[p.pos]
+			if r == '"' {
+				p.pos++
+				return lit, nil
+			}
+			r, err := p.parseChar()
+			if err != nil {
+				return nil, err
+			}
+			lit = append(lit, r)
+		}
+	case c == '[':
+		p.pos++
+		class := gbnfClass{}
+		if p.pos < len(p.src) && p.src[p.pos] == '^' {
+			class.negated = true
+			p.pos++
+		}
+		for {
+			if p.pos >= len(p.src) {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			if p.src[p.pos] == ']' {
+				p.pos++
+				return class, nil
+			}
+			lo, err := p.parseChar()
+			if err != nil {
+				return nil, err
+			}
+			hi := lo
+			if p.pos+1 < len(p.src) && p.src[p.pos] == '-' && p.src[p.pos+1] != ']' {
+				p.pos++
+				hi, err = p.parseChar()
+				if err != nil {
+					return nil, err
+				}
+			}
+			class.ranges = append(class.ranges, [2]rune{lo, hi})
+		}
+	case c == '(':
+		p.pos++
+		node, err := p.parseAlternatives()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	case c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.src) {
+			r := p.src[p.pos]
+			if r != '-' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+				break
+			}
+			p.pos++
+		}
+		return gbnfRef{name: string(p.src[start:p.pos])}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", string(p.src[p.pos:]))
+}
+
+func (p *gbnfParser) parseChar() (rune, error) {
+	r := p.src[p.pos]
+	p.pos++
+	if r != '\\' {
+		return r, nil
+	}
+	if p.pos >= len(p.src) {
+		return 0, fmt.Errorf("unterminated escape")
+	}
+
+	e := p.src[p.pos]
+	p.pos++
+	digits := 0
+	switch e {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case 'x':
+		digits = 2
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	default:
+		return e, nil
+	}
+
+	if p.pos+digits > len(p.src) {
+		return 0, fmt.Errorf("invalid escape")
+	}
+	v, err := strconv.ParseUint(string(p.src[p.pos:p.pos+digits]), 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	p.pos += digits
+	return rune(v), nil
+}
+
+func (p *gbnfParser) parsePostfix(item gbnfNode) (gbnfNode, error) {
+	for {
+		if p.pos >= len(p.src) {
+			return item, nil
+		}
+		// postfix operators follow the item immediately
+		switch p.src[p.pos] {
+		case '*':
+			item = gbnfRepeat{item: item, min: 0, max: -1}
+		case '+':
+			item = gbnfRepeat{item: item, min: 1, max: -1}
+		case '?':
+			item = gbnfRepeat{item: item, min: 0, max: 1}
+		case '{':
+			end := strings.IndexRune(string(p.src[p.pos:]), '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated repetition")
+			}
+			bounds := strings.SplitN(string(p.src[p.pos+1:p.pos+end]), ",", 2)
+			min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+			if err != nil {
+				return nil, err
+			}
+			max := min
+			if len(bounds) == 2 {
+				max = -1
+				if b := strings.TrimSpace(bounds[1]); b != "" {
+					if max, err = strconv.Atoi(b); err != nil {
+						return nil, err
+					}
+				}
+			}
+			item = gbnfRepeat{item: item, min: min, max: max}
+			p.pos += end
+		default:
+			return item, nil
+		}
+		p.pos++
+	}
+}
+
+type gbnfLiteral []rune
+
+func (l gbnfLiteral) match(_ *gbnfGrammar, input []rune, pos int) []int {
+	if pos+len(l) > len(input) {
+		return nil
+	}
+	for i, r := range l {
+		if input[pos+i] != r {
+			return nil
+		}
+	}
+	return []int{pos + len(l)}
+}
+
+type gbnfClass struct {
+	negated bool
+	ranges  [][2]rune
+}
+
+func (c gbnfClass) match(_ *gbnfGrammar, input []rune, pos int) []int {
+	if pos >= len(input) {
+		return nil
+	}
+	in := false
+	for _, r := range c.ranges {
+		if input[pos] >= r[0] && input[pos] <= r[1] {
+			in = true
+			break
+		}
+	}
+	if in == c.negated {
+		return nil
+	}
+	return []int{pos + 1}
+}
+
+type gbnfRef struct {
+	name string
+}
+
+func (r gbnfRef) match(g *gbnfGrammar, input []rune, pos int) []int {
+	if ends, ok := g.memo[r.name][pos]; ok {
+		return ends
+	}
+	rule, exists := g.rules[r.name]
+	if !exists {
+		panic("undefined rule " + r.name)
+	}
+	ends := rule.match(g, input, pos)
+	if g.memo[r.name] == nil {
+		g.memo[r.name] = map[int][]int{}
+	}
+	g.memo[r.name][pos] = ends
+	return ends
+}
+
+type gbnfSeq []gbnfNode
+
+func (s gbnfSeq) match(g *gbnfGrammar, input []rune, pos int) []int {
+	positions := []int{pos}
+	for _, item := range s {
+		var next []int
+		for _, p := range positions {
+			next = append(next, item.match(g, input, p)...)
+		}
+		positions = unique(next)
+		if len(positions) == 0 {
+			return nil
+		}
+	}
+	return positions
+}
+
+type gbnfAlt []gbnfNode
+
+func (a gbnfAlt) match(g *gbnfGrammar, input []rune, pos int) []int {
+	var ends []int
+	for _, alternative := range a {
+		ends = append(ends, alternative.match(g, input, pos)...)
+	}
+	return unique(ends)
+}
+
+type gbnfRepeat struct {
+	item     gbnfNode
+	min, max int
+}
+
+func (r gbnfRepeat) match(g *gbnfGrammar, input []rune, pos int) []int {
+	var ends []int
+	if r.min == 0 {
+		ends = append(ends, pos)
+	}
+
+	seen := map[int]bool{}
+	positions := []int{pos}
+	for count := 1; r.max < 0 || count <= r.max; count++ {
+		var next []int
+		for _, p := range positions {
+			for _, e := range r.item.match(g, input, p) {
+				// past the minimum, positions already reached add nothing new
+				if count > r.min && seen[e] {
+					continue
+				}
+				next = append(next, e)
+			}
+		}
+		positions = unique(next)
+		if len(positions) == 0 {
+			break
+		}
+		if count >= r.min {
+			for _, p := range positions {
+				seen[p] = true
+			}
+			ends = append(ends, positions...)
+		}
+	}
+	return unique(ends)
+}
+
+func unique(positions []int) []int {
+	sort.Ints(positions)
+	var res []int
+	for i, p := range positions {
+		if i == 0 || p != positions[i-1] {
+			res = append(res, p)
+		}
+	}
+	return res
+}
@@ -0,0 +1,33 @@
+package grammars
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegexGrammar returns a grammar matching the whole output against a regular expression
+// (RE2 syntax, as in Go). Anchors are implied.
+func RegexGrammar(pattern string) (string, error) {
+	expr, err := regexToExpression(pattern, false)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("root ::= %s", expr), nil
+}
+
+// ChoiceGrammar returns a grammar matching exactly one of the choices
+func ChoiceGrammar(choices []string) (string, error) {
+	if len(choices) == 0 {
+		return "", fmt.Errorf("no choices given")
+	}
+
+	alternatives := make([]string, 0, len(choices))
+	for _, c := range choices {
+		if c == "" {
+			alternatives = append(alternatives, `""`)
+			continue
+		}
+		alternatives = append(alternatives, `"`+escapeLiteral(c)+`"`)
+	}
+	return fmt.Sprintf("root ::= %s", strings.Join(alternatives, " | ")), nil
+}
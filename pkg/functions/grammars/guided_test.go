@@ -0,0 +1,102 @@
+package grammars_test
+
+import (
+	"regexp"
+
+	. "github.com/mudler/LocalAI/pkg/functions/grammars"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// allStrings returns all the strings made of the alphabet, up to maxLength characters
+func allStrings(alphabet []rune, maxLength int) []string {
+	res := []string{""}
+	prev := []string{""}
+	for l := 1; l <= maxLength; l++ {
+		var next []string
+		for _, p := range prev {
+			for _, r := range alphabet {
+				next = append(next, p+string(r))
+			}
+		}
+		res = append(res, next...)
+		prev = next
+	}
+	return res
+}
+
+var _ = Describe("Guided decoding grammars", func() {
+	DescribeTable("RegexGrammar() matches the same strings as the regular expression",
+		func(pattern string, alphabet string, maxLength int, samples []string) {
+			grammar, err := RegexGrammar(pattern)
+			Expect(err).ToNot(HaveOccurred())
+
+			g, err := parseGBNF(grammar)
+			Expect(err).ToNot(HaveOccurred(), grammar)
+
+			re := regexp.MustCompile(`^(?:` + pattern + `)$`)
+			inputs := append(allStrings([]rune(alphabet), maxLength), samples...)
+			for _, s := range inputs {
+				Expect(g.Matches(s)).To(Equal(re.MatchString(s)), "pattern %q, input %q, grammar:\n%s", pattern, s, grammar)
+			}
+		},
+		Entry("literals", `abc`, "abc", 4, nil),
+		Entry("alternatives", `ab|c|`, "abc", 3, nil),
+		Entry("optional", `ab?c`, "abc", 4, nil),
+		Entry("star", `a*b`, "ab", 5, nil),
+		Entry("plus", `(ab)+`, "ab", 6, nil),
+		Entry("bounded repetition", `a{2,4}`, "a", 6, nil),
+		Entry("exact repetition", `(ab){2}`, "ab", 5, nil),
+		Entry("unbounded repetition", `a{2,}b`, "ab", 5, nil),
+		Entry("optional repetition", `a{0,2}b`, "ab", 4, nil),
+		Entry("character classes", `[a-c][^a-c]`, "abcd-", 3, nil),
+		Entry("negated classes", `[^ab]+`, "abc\n", 3, nil),
+		Entry("escapes and perl classes", `\d+\.\d{1,2}`, "1.a", 5, []string{"12.34", "0.5", "1.234"}),
+		Entry("word and space classes", `\w+\s\w+`, "a_ 1\t", 4, []string{"hello world", "a\tb"}),
+		Entry("dot", `a.c`, "ac\n", 3, []string{"a€c", "a\nc"}),
+		Entry("dot matching newlines", `(?s)a.c`, "ac\n", 3, nil),
+		Entry("case insensitive", `(?i)ab`, "abAB", 3, nil),
+		Entry("anchors", `^a|b$`, "ab", 3, nil),
+		Entry("nested groups", `(a(b|c)*)?d`, "abcd", 4, nil),
+		Entry("non-capturing groups", `(?:ab|cd)+`, "abcd", 4, nil),
+		Entry("special characters", `"\\\[\]\(`, `"\[]( `, 4, []string{`"\[](`}),
+		Entry("empty", ``, "a", 2, nil),
+		Entry("emails",
+			`[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`, "", 0,
+			[]string{"john.doe@example.com", "a@b.co", "a@b.c", "@example.com", "john@", "john@example"},
+		),
+		Entry("dates",
+			`\d{4}-(0[1-9]|1[0-2])-(0[1-9]|[12]\d|3[01])`, "", 0,
+			[]string{"2024-01-31", "2024-12-01", "2024-13-01", "2024-00-10", "24-01-01", "2024-01-32"},
+		),
+		Entry("unicode",
+			`[à-ÿ]+ ✓`, "", 0,
+			[]string{"é ✓", "àÿ ✓", "a ✓", "é ✗"},
+		),
+	)
+
+	It("rejects unsupported regular expressions", func() {
+		_, err := RegexGrammar(`a\bb`)
+		Expect(err).To(HaveOccurred())
+		_, err = RegexGrammar(`a(`)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("ChoiceGrammar() matches exactly one of the choices", func() {
+		grammar, err := ChoiceGrammar([]string{"yes", "no", `"quoted"`, "multi\nline"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(Equal(`root ::= "yes" | "no" | "\"quoted\"" | "multi\nline"`))
+
+		g, err := parseGBNF(grammar)
+		Expect(err).ToNot(HaveOccurred())
+		for _, s := range []string{"yes", "no", `"quoted"`, "multi\nline"} {
+			Expect(g.Matches(s)).To(BeTrue(), s)
+		}
+		for _, s := range []string{"", "y", "yesno", "maybe", "quoted"} {
+			Expect(g.Matches(s)).To(BeFalse(), s)
+		}
+
+		_, err = ChoiceGrammar(nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
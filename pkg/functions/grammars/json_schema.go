@@ -5,6 +5,7 @@ package grammars
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"sort"
 	"strings"
 )
@@ -59,10 +60,13 @@ func (sc *JSONSchemaConverter) addRule(name, rule string) string {
 }
 
 func (sc *JSONSchemaConverter) visit(schema map[string]interface{}, name string, rootSchema map[string]interface{}) (string, error) {
-	st, existType := schema["type"]
+	schemaTypes, err := typesOf(schema)
+	if err != nil {
+		return "", err
+	}
 	var schemaType string
-	if existType {
-		schemaType = st.(string)
+	if len(schemaTypes) == 1 {
+		schemaType = schemaTypes[0]
 	}
 	ruleName := name
 	if name == "" {
@@ -70,6 +74,8 @@ func (sc *JSONSchemaConverter) visit(schema map[string]interface{}, name string,
 	}
 	_, oneOfExists := schema["oneOf"]
 	_, anyOfExists := schema["anyOf"]
+	_, propertiesExist := schema["properties"]
+	_, itemsExist := schema["items"]
 	if oneOfExists || anyOfExists {
 		var alternatives []string
 		oneOfSchemas, oneOfExists := schema["oneOf"].([]interface{})
@@ -101,6 +107,12 @@ func (sc *JSONSchemaConverter) visit(schema map[string]interface{}, name string,
 			return "", err
 		}
 		return sc.visit(referencedSchema, name, rootSchema)
+	} else if allOf, exists := schema["allOf"].([]interface{}); exists {
+		merged, err := sc.mergeAllOf(schema, allOf, rootSchema)
+		if err != nil {
+			return "", err
+		}
+		return sc.visit(merged, name, rootSchema)
 	} else if constVal, exists := schema["const"]; exists {
 		literal, err := sc.formatLiteral((constVal))
 		if err != nil {
@@ -118,70 +130,465 @@ func (sc *JSONSchemaConverter) visit(schema map[string]interface{}, name string,
 		}
 		rule := strings.Join(enumRules, " | ")
 		return sc.addRule(ruleName, rule), nil
-	} else if properties, exists := schema["properties"].(map[string]interface{}); schemaType == "object" && exists {
-		propOrder := sc.propOrder
-		var propPairs []struct {
+	} else if len(schemaTypes) > 1 {
+		// e.g. "type": ["string", "null"]
+		var alternatives []string
+		for _, t := range schemaTypes {
+			altSchema := make(map[string]interface{}, len(schema))
+			for k, v := range schema {
+				altSchema[k] = v
+			}
+			altSchema["type"] = t
+			alternative, err := sc.visit(altSchema, fmt.Sprintf("%s-%s", ruleName, t), rootSchema)
+			if err != nil {
+				return "", err
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		return sc.addRule(ruleName, strings.Join(alternatives, " | ")), nil
+	} else if schemaType == "object" || (schemaType == "" && propertiesExist) {
+		return sc.visitObject(schema, ruleName, rootSchema)
+	} else if schemaType == "array" || (schemaType == "" && itemsExist) {
+		return sc.visitArray(schema, ruleName, rootSchema)
+	} else if schemaType == "string" && hasStringConstraints(schema) {
+		return sc.visitString(schema, ruleName)
+	} else if (schemaType == "integer" || schemaType == "number") && hasNumericBounds(schema) {
+		return sc.visitNumber(schema, ruleName, schemaType == "integer")
+	} else if schemaType == "" {
+		// any JSON value
+		value := sc.addPrimitive("value")
+		if ruleName == "root" {
+			return sc.addRule(ruleName, value), nil
+		}
+		return value, nil
+	} else {
+		primitiveRule, exists := PRIMITIVE_RULES[schemaType]
+		if !exists {
+			return "", fmt.Errorf("unrecognized schema: %v", schema)
+		}
+		if ruleName == "root" {
+			schemaType = "root"
+		}
+		return sc.addRule(schemaType, primitiveRule), nil
+	}
+}
+
+// addPrimitive adds a primitive rule, along with the rules it references
+func (sc *JSONSchemaConverter) addPrimitive(name string) string {
+	if _, exists := sc.rules[name]; exists {
+		return name
+	}
+	sc.rules[name] = PRIMITIVE_RULES[name]
+	for _, dep := range PRIMITIVE_RULES_DEPS[name] {
+		sc.addPrimitive(dep)
+	}
+	return name
+}
+
+// visitObject handles object schemas. Properties listed in "required" are mandatory and the others
+// optional. If "required" is not set, all the properties are mandatory. Additional properties are
+// allowed only if "additionalProperties" is set to true or to a schema.
+func (sc *JSONSchemaConverter) visitObject(schema map[string]interface{}, ruleName string, rootSchema map[string]interface{}) (string, error) {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	propOrder := sc.propOrder
+	var propPairs []struct {
+		propName   string
+		propSchema map[string]interface{}
+	}
+
+	for propName, propSchema := range properties {
+		ps, ok := propSchema.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("invalid schema for property %q: %v", propName, propSchema)
+		}
+		propPairs = append(propPairs, struct {
 			propName   string
 			propSchema map[string]interface{}
+		}{propName: propName, propSchema: ps})
+	}
+
+	sort.Slice(propPairs, func(i, j int) bool {
+		iOrder := propOrder[propPairs[i].propName]
+		jOrder := propOrder[propPairs[j].propName]
+		if iOrder != 0 && jOrder != 0 {
+			return iOrder < jOrder
+		}
+		return propPairs[i].propName < propPairs[j].propName
+	})
+
+	requiredList, hasRequired := schema["required"].([]interface{})
+	required := map[string]bool{}
+	for _, r := range requiredList {
+		if s, ok := r.(string); ok {
+			required[s] = true
+		}
+	}
+
+	var requiredKVs, optionalKVs []string
+	for _, propPair := range propPairs {
+		propName := propPair.propName
+		propRuleName, err := sc.visit(propPair.propSchema, fmt.Sprintf("%s-%s", ruleName, propName), rootSchema)
+		if err != nil {
+			return "", err
+		}
+		lPropName, err := sc.formatLiteral(propName)
+		if err != nil {
+			return "", err
+		}
+
+		kv := fmt.Sprintf(`%s space ":" space %s`, lPropName, propRuleName)
+		if !hasRequired || required[propName] {
+			requiredKVs = append(requiredKVs, kv)
+		} else {
+			optionalKVs = append(optionalKVs, kv)
+		}
+	}
+
+	additional, hasAdditional := schema["additionalProperties"]
+	if !hasAdditional && len(properties) == 0 {
+		// {"type": "object"} matches any object
+		additional = true
+	}
+
+	additionalKV := ""
+	switch additional := additional.(type) {
+	case bool:
+		if additional {
+			additionalKV = fmt.Sprintf(`%s ":" space %s`, sc.addPrimitive("string"), sc.addPrimitive("value"))
+		}
+	case map[string]interface{}:
+		valueRule, err := sc.visit(additional, fmt.Sprintf("%s-additional", ruleName), rootSchema)
+		if err != nil {
+			return "", err
+		}
+		additionalKV = fmt.Sprintf(`%s ":" space %s`, sc.addPrimitive("string"), valueRule)
+	}
+
+	var rule strings.Builder
+	rule.WriteString(`"{" space`)
+
+	if len(requiredKVs) > 0 {
+		rule.WriteString(" ")
+		rule.WriteString(strings.Join(requiredKVs, ` "," space `))
+	}
+
+	if len(optionalKVs) > 0 || additionalKV != "" {
+		// optional properties may be omitted, but must appear in order
+		var alternatives []string
+		for i := range optionalKVs {
+			alternatives = append(alternatives, sc.optionalProperties(ruleName, optionalKVs[i:], additionalKV))
+		}
+		if additionalKV != "" {
+			alternatives = append(alternatives, fmt.Sprintf(`%s ( "," space %s )*`, additionalKV, additionalKV))
 		}
 
-		for propName, propSchema := range properties {
-			propPairs = append(propPairs, struct {
-				propName   string
-				propSchema map[string]interface{}
-			}{propName: propName, propSchema: propSchema.(map[string]interface{})})
+		if len(requiredKVs) > 0 {
+			rule.WriteString(fmt.Sprintf(` ( "," space ( %s ) )?`, strings.Join(alternatives, " | ")))
+		} else {
+			rule.WriteString(fmt.Sprintf(` ( %s )?`, strings.Join(alternatives, " | ")))
 		}
+	}
 
-		sort.Slice(propPairs, func(i, j int) bool {
-			iOrder := propOrder[propPairs[i].propName]
-			jOrder := propOrder[propPairs[j].propName]
-			if iOrder != 0 && jOrder != 0 {
-				return iOrder < jOrder
+	rule.WriteString(` "}" space`)
+	return sc.addRule(ruleName, rule.String()), nil
+}
+
+// optionalProperties returns an expression matching the first property of kvs followed by
+// any subset of the others, in order, and by any number of additional properties
+func (sc *JSONSchemaConverter) optionalProperties(ruleName string, kvs []string, additionalKV string) string {
+	res := kvs[0]
+	rest := ""
+	if len(kvs) > 1 {
+		rest = sc.addRule(fmt.Sprintf("%s-rest", ruleName), sc.optionalPropertiesRest(kvs[1:], additionalKV))
+	} else if additionalKV != "" {
+		rest = fmt.Sprintf(`( "," space %s )*`, additionalKV)
+	}
+
+	if rest != "" {
+		res += " " + rest
+	}
+	return res
+}
+
+func (sc *JSONSchemaConverter) optionalPropertiesRest(kvs []string, additionalKV string) string {
+	res := fmt.Sprintf(`( "," space %s )?`, kvs[0])
+	if len(kvs) > 1 {
+		res += " " + sc.optionalPropertiesRest(kvs[1:], additionalKV)
+	} else if additionalKV != "" {
+		res += fmt.Sprintf(` ( "," space %s )*`, additionalKV)
+	}
+	return res
+}
+
+// visitArray handles array schemas, with optional minItems and maxItems
+func (sc *JSONSchemaConverter) visitArray(schema map[string]interface{}, ruleName string, rootSchema map[string]interface{}) (string, error) {
+	itemRuleName := sc.addPrimitive("value")
+	if items, exists := schema["items"].(map[string]interface{}); exists {
+		var err error
+		itemRuleName, err = sc.visit(items, fmt.Sprintf("%s-item", ruleName), rootSchema)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	minItems, _ := intValue(schema["minItems"])
+	maxItems := -1
+	if m, ok := intValue(schema["maxItems"]); ok {
+		maxItems = m
+	}
+
+	items := repetition(itemRuleName, minItems, maxItems, `"," space`)
+	if items != "" {
+		items += " "
+	}
+	rule := fmt.Sprintf(`"[" space %s"]" space`, items)
+	return sc.addRule(ruleName, rule), nil
+}
+
+func hasStringConstraints(schema map[string]interface{}) bool {
+	for _, k := range []string{"minLength", "maxLength", "pattern", "format"} {
+		if _, exists := schema[k]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// visitString handles string schemas with a pattern, a format or length bounds
+func (sc *JSONSchemaConverter) visitString(schema map[string]interface{}, ruleName string) (string, error) {
+	if pattern, ok := schema["pattern"].(string); ok {
+		expr, err := regexToExpression(pattern, true)
+		if err != nil {
+			return "", err
+		}
+		return sc.addRule(ruleName, fmt.Sprintf(`"\"" %s "\"" space`, group(expr))), nil
+	}
+
+	if format, ok := schema["format"].(string); ok {
+		if _, supported := STRING_FORMAT_RULES[format]; supported {
+			sc.addFormatRule(format)
+			if ruleName != "root" {
+				ruleName = format + "-string"
 			}
-			return propPairs[i].propName < propPairs[j].propName
-		})
+			return sc.addRule(ruleName, fmt.Sprintf(`"\"" %s "\"" space`, format)), nil
+		}
+	}
+
+	minLength, _ := intValue(schema["minLength"])
+	maxLength := -1
+	if m, ok := intValue(schema["maxLength"]); ok {
+		maxLength = m
+	}
+	if minLength == 0 && maxLength < 0 {
+		// unknown format only
+		if ruleName == "root" {
+			return sc.addRule(ruleName, PRIMITIVE_RULES["string"]), nil
+		}
+		return sc.addPrimitive("string"), nil
+	}
 
-		var rule strings.Builder
-		rule.WriteString(`"{" space`)
+	chars := repetition(sc.addPrimitive("char"), minLength, maxLength, "")
+	return sc.addRule(ruleName, fmt.Sprintf(`"\"" %s "\"" space`, chars)), nil
+}
 
-		for i, propPair := range propPairs {
-			propName := propPair.propName
-			propSchema := propPair.propSchema
-			propRuleName, err := sc.visit(propSchema, fmt.Sprintf("%s-%s", ruleName, propName), rootSchema)
-			if err != nil {
-				return "", err
+func (sc *JSONSchemaConverter) addFormatRule(format string) {
+	for _, dep := range STRING_FORMAT_RULES_DEPS[format] {
+		sc.addFormatRule(dep)
+	}
+	sc.rules[format] = STRING_FORMAT_RULES[format]
+}
+
+func hasNumericBounds(schema map[string]interface{}) bool {
+	for _, k := range []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum"} {
+		if _, ok := floatValue(schema[k]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// visitNumber handles integer and number schemas with bounds. For numbers, bounds are
+// enforced on the integer part, so values between a non-integer bound and the closest
+// integer are not generated.
+func (sc *JSONSchemaConverter) visitNumber(schema map[string]interface{}, ruleName string, integer bool) (string, error) {
+	var lo, hi *int64
+	if v, ok := floatValue(schema["minimum"]); ok {
+		l := int64(math.Ceil(v))
+		lo = &l
+	}
+	if v, ok := floatValue(schema["exclusiveMinimum"]); ok {
+		l := int64(math.Floor(v)) + 1
+		if lo == nil || l > *lo {
+			lo = &l
+		}
+	}
+	if v, ok := floatValue(schema["maximum"]); ok {
+		h := int64(math.Floor(v))
+		hi = &h
+	}
+	if v, ok := floatValue(schema["exclusiveMaximum"]); ok {
+		h := int64(math.Ceil(v)) - 1
+		if hi == nil || h < *hi {
+			hi = &h
+		}
+	}
+	if lo != nil && hi != nil && *lo > *hi {
+		return "", fmt.Errorf("no integer satisfies the bounds of %q", ruleName)
+	}
+
+	alternatives := []string{group(integerRange(lo, hi))}
+
+	if !integer {
+		fraction := `"." [0-9]+`
+
+		// non-negative values with a fraction: the integer part must be lower than the maximum
+		nonNegLo := int64(0)
+		if lo != nil && *lo > 0 {
+			nonNegLo = *lo
+		}
+		var nonNegHi *int64
+		if hi != nil {
+			h := *hi - 1
+			nonNegHi = &h
+		}
+		if nonNegHi == nil || *nonNegHi >= nonNegLo {
+			alternatives = append(alternatives, group(integerRange(&nonNegLo, nonNegHi))+" "+fraction)
+		}
+
+		// negative values with a fraction: the integer part must be greater than the minimum
+		if lo == nil || *lo < 0 {
+			negHi := int64(-1)
+			if hi != nil && *hi < negHi {
+				negHi = *hi
 			}
-			lPropName, err := sc.formatLiteral(propName)
-			if err != nil {
-				return "", err
+			var negLo *int64
+			if lo != nil {
+				l := *lo + 1
+				negLo = &l
+			}
+			if negLo == nil || *negLo <= negHi {
+				alternatives = append(alternatives, group(integerRange(negLo, &negHi))+" "+fraction)
 			}
-			if i > 0 {
-				rule.WriteString(` "," space`)
+			// between -1 and 0
+			if (lo == nil || *lo <= -1) && (hi == nil || *hi >= 0) {
+				alternatives = append(alternatives, `"-0" `+fraction)
 			}
+		}
+	}
 
-			rule.WriteString(fmt.Sprintf(` %s space ":" space %s`, lPropName, propRuleName))
+	return sc.addRule(ruleName, fmt.Sprintf("( %s ) space", strings.Join(alternatives, " | "))), nil
+}
+
+// mergeAllOf merges the schemas listed in allOf (and the rest of the schema) into a single one:
+// properties and required properties are combined, other keywords are overridden in order
+func (sc *JSONSchemaConverter) mergeAllOf(schema map[string]interface{}, allOf []interface{}, rootSchema map[string]interface{}) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+	properties := map[string]interface{}{}
+	var required []interface{}
+	hasRequired := false
+
+	merge := func(s map[string]interface{}) {
+		for k, v := range s {
+			switch k {
+			case "allOf":
+			case "properties":
+				if props, ok := v.(map[string]interface{}); ok {
+					for name, p := range props {
+						properties[name] = p
+					}
+				}
+			case "required":
+				if r, ok := v.([]interface{}); ok {
+					hasRequired = true
+					required = append(required, r...)
+				}
+			default:
+				merged[k] = v
+			}
 		}
+	}
 
-		rule.WriteString(` "}" space`)
-		return sc.addRule(ruleName, rule.String()), nil
-	} else if items, exists := schema["items"].(map[string]interface{}); schemaType == "array" && exists {
-		itemRuleName, err := sc.visit(items, fmt.Sprintf("%s-item", ruleName), rootSchema)
-		if err != nil {
-			return "", err
+	for _, component := range allOf {
+		s, ok := component.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid allOf schema: %v", component)
 		}
-		rule := fmt.Sprintf(`"[" space (%s ("," space %s)*)? "]" space`, itemRuleName, itemRuleName)
-		return sc.addRule(ruleName, rule), nil
-	} else {
-		primitiveRule, exists := PRIMITIVE_RULES[schemaType]
-		if !exists {
-			return "", fmt.Errorf("unrecognized schema: %v", schema)
+		if ref, exists := s["$ref"].(string); exists {
+			resolved, err := sc.resolveReference(ref, rootSchema)
+			if err != nil {
+				return nil, err
+			}
+			s = resolved
 		}
-		if ruleName == "root" {
-			schemaType = "root"
+		if nested, exists := s["allOf"].([]interface{}); exists {
+			resolved, err := sc.mergeAllOf(s, nested, rootSchema)
+			if err != nil {
+				return nil, err
+			}
+			s = resolved
 		}
-		return sc.addRule(schemaType, primitiveRule), nil
+		merge(s)
+	}
+	merge(schema)
+
+	if len(properties) > 0 {
+		merged["properties"] = properties
+	}
+	if hasRequired {
+		merged["required"] = required
+	}
+	return merged, nil
+}
+
+// typesOf returns the types allowed by a schema, which can be a single type or a list
+func typesOf(schema map[string]interface{}) ([]string, error) {
+	switch t := schema["type"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{t}, nil
+	case []interface{}:
+		var types []string
+		for _, tt := range t {
+			s, ok := tt.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid type: %v", schema["type"])
+			}
+			types = append(types, s)
+		}
+		return types, nil
+	case []string:
+		return t, nil
+	}
+	return nil, fmt.Errorf("invalid type: %v", schema["type"])
+}
+
+func floatValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
 	}
+	return 0, false
 }
+
+func intValue(v interface{}) (int, bool) {
+	f, ok := floatValue(v)
+	if !ok || f < 0 {
+		return 0, false
+	}
+	return int(f), true
+}
+
 func (sc *JSONSchemaConverter) resolveReference(ref string, rootSchema map[string]interface{}) (map[string]interface{}, error) {
 	if !strings.HasPrefix(ref, "#/$defs/") {
 		return nil, fmt.Errorf("invalid reference format: %s", ref)
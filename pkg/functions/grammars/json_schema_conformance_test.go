@@ -0,0 +1,148 @@
+package grammars_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/functions/grammars"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON schema grammar conformance", func() {
+	DescribeTable("accepts the outputs valid against the schema",
+		func(schema string, valid []string, invalid []string) {
+			grammar, err := NewJSONSchemaConverter("").GrammarFromBytes([]byte(schema))
+			Expect(err).ToNot(HaveOccurred())
+
+			g, err := parseGBNF(grammar)
+			Expect(err).ToNot(HaveOccurred(), grammar)
+
+			for _, v := range valid {
+				Expect(g.Matches(v)).To(BeTrue(), "expected %q to be accepted by:\n%s", v, grammar)
+			}
+			for _, v := range invalid {
+				Expect(g.Matches(v)).To(BeFalse(), "expected %q to be rejected by:\n%s", v, grammar)
+			}
+		},
+		Entry("all properties are mandatory without required",
+			`{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}}`,
+			[]string{`{"a": "x", "b": 1}`, `{"a":"x","b":-12}`},
+			[]string{`{"a": "x"}`, `{"b": 1}`, `{}`, `{"b": 1, "a": "x"}`},
+		),
+		// required properties come first, followed by the optional ones
+		Entry("required and optional properties",
+			`{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}, "c": {"type": "boolean"}}, "required": ["b"]}`,
+			[]string{`{"b": 1}`, `{"b": 1, "a": "x"}`, `{"b": 1, "a": "x", "c": true}`, `{"b": 1, "c": false}`},
+			[]string{`{}`, `{"a": "x"}`, `{"a": "x", "c": true}`, `{"b": 1, "d": 2}`, `{"b": 1, "c": true, "a": "x"}`},
+		),
+		Entry("only optional properties",
+			`{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}, "required": []}`,
+			[]string{`{}`, `{"a": "x"}`, `{"b": 1}`, `{"a": "x", "b": 1}`},
+			[]string{`{"c": 1}`, `{"a": 1}`, `{,}`},
+		),
+		Entry("additional properties",
+			`{"type": "object", "properties": {"a": {"type": "string"}}, "required": ["a"], "additionalProperties": true}`,
+			[]string{`{"a": "x"}`, `{"a": "x", "c": [1, {"d": null}]}`, `{"a": "x", "c": 1, "d": "e"}`},
+			[]string{`{}`, `{"c": 1}`},
+		),
+		Entry("additional properties with a schema",
+			`{"type": "object", "properties": {"a": {"type": "string"}}, "required": [], "additionalProperties": {"type": "integer"}}`,
+			[]string{`{}`, `{"a": "x"}`, `{"a": "x", "b": 1, "c": 2}`, `{"b": 1}`},
+			[]string{`{"a": "x", "b": "y"}`, `{"b": true}`},
+		),
+		Entry("objects without properties",
+			`{"type": "object"}`,
+			[]string{`{}`, `{"a": 1}`, `{"a": {"b": [true, "c"]}}`},
+			[]string{`[]`, `{"a"}`},
+		),
+		Entry("allOf",
+			`{"allOf": [{"$ref": "#/$defs/base"}, {"type": "object", "properties": {"b": {"type": "integer"}}, "required": ["b"]}],
+			  "$defs": {"base": {"type": "object", "properties": {"a": {"type": "string"}}, "required": ["a"]}}}`,
+			[]string{`{"a": "x", "b": 1}`},
+			[]string{`{"a": "x"}`, `{"b": 1}`},
+		),
+		Entry("minItems and maxItems",
+			`{"type": "array", "items": {"type": "integer"}, "minItems": 1, "maxItems": 3}`,
+			[]string{`[1]`, `[1, 2]`, `[1, 2, 3]`},
+			[]string{`[]`, `[1, 2, 3, 4]`, `["a"]`},
+		),
+		Entry("minItems only",
+			`{"type": "array", "items": {"type": "boolean"}, "minItems": 2}`,
+			[]string{`[true, false]`, `[true, false, true, true]`},
+			[]string{`[]`, `[true]`},
+		),
+		Entry("arrays without items",
+			`{"type": "array"}`,
+			[]string{`[]`, `[1, "a", {"b": null}]`},
+			[]string{`{}`},
+		),
+		Entry("minLength and maxLength",
+			`{"type": "string", "minLength": 2, "maxLength": 4}`,
+			[]string{`"ab"`, `"abcd"`, `"a\nb"`, `"ét"`},
+			[]string{`""`, `"a"`, `"abcde"`},
+		),
+		Entry("pattern",
+			`{"type": "string", "pattern": "^[A-Z]{2}-[0-9]+$"}`,
+			[]string{`"AB-1"`, `"XY-123"`},
+			[]string{`"A-1"`, `"ab-1"`, `"AB-"`, `"AB-1x"`},
+		),
+		Entry("pattern with characters escaped in JSON",
+			`{"type": "string", "pattern": "^a\"b\\\\c$"}`,
+			[]string{`"a\"b\\c"`},
+			[]string{`"a"b\c"`, `"abc"`},
+		),
+		Entry("date format",
+			`{"type": "string", "format": "date"}`,
+			[]string{`"2024-02-29"`, `"1999-12-31"`},
+			[]string{`"2024-13-01"`, `"2024-1-1"`, `"yesterday"`},
+		),
+		Entry("date-time format",
+			`{"type": "string", "format": "date-time"}`,
+			[]string{`"2024-02-29T12:30:00Z"`, `"2024-02-29T23:59:59.123+02:00"`},
+			[]string{`"2024-02-29"`, `"2024-02-29T24:00:00Z"`, `"2024-02-29T12:30:00"`},
+		),
+		Entry("uuid format",
+			`{"type": "string", "format": "uuid"}`,
+			[]string{`"123e4567-e89b-12d3-a456-426614174000"`},
+			[]string{`"123e4567e89b12d3a456426614174000"`, `"123e4567-e89b-12d3-a456-42661417400g"`},
+		),
+		Entry("email format",
+			`{"type": "string", "format": "email"}`,
+			[]string{`"john.doe@example.com"`, `"a+b@mail.example.org"`},
+			[]string{`"john"`, `"john@example"`, `"@example.com"`},
+		),
+		Entry("integer bounds",
+			`{"type": "integer", "minimum": -5, "maximum": 120}`,
+			[]string{`-5`, `-1`, `0`, `7`, `99`, `100`, `120`},
+			[]string{`-6`, `121`, `200`, `007`, `-0`, `1.5`},
+		),
+		Entry("exclusive integer bounds",
+			`{"type": "integer", "exclusiveMinimum": 0, "exclusiveMaximum": 10}`,
+			[]string{`1`, `9`},
+			[]string{`0`, `10`, `-1`},
+		),
+		Entry("integer lower bound only",
+			`{"type": "integer", "minimum": 18}`,
+			[]string{`18`, `19`, `99`, `100`, `123456`},
+			[]string{`17`, `0`, `-18`},
+		),
+		Entry("number bounds",
+			`{"type": "number", "minimum": -1.5, "maximum": 2}`,
+			[]string{`-1`, `-0.5`, `0`, `1.25`, `2`},
+			[]string{`-2`, `2.5`, `3`, `1e3`},
+		),
+		Entry("nested bounded values",
+			`{"type": "object", "properties": {"age": {"type": "integer", "minimum": 0, "maximum": 150}, "tags": {"type": "array", "items": {"type": "string", "maxLength": 3}, "maxItems": 2}}, "required": ["age"]}`,
+			[]string{`{"age": 42}`, `{"age": 0, "tags": ["a", "bcd"]}`},
+			[]string{`{"age": 151}`, `{"age": 1, "tags": ["abcd"]}`, `{"age": 1, "tags": ["a", "b", "c"]}`},
+		),
+		Entry("type arrays",
+			`{"type": ["string", "null"]}`,
+			[]string{`"a"`, `null`},
+			[]string{`1`},
+		),
+		Entry("untyped values",
+			`{"type": "object", "properties": {"data": {}}}`,
+			[]string{`{"data": 1}`, `{"data": "x"}`, `{"data": [1, {"a": null}]}`},
+			[]string{`{"data": }`},
+		),
+	)
+})
@@ -0,0 +1,187 @@
+package grammars
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// group wraps an expression in parentheses, unless it is a single term
+func group(expr string) string {
+	if !strings.ContainsAny(expr, " |") {
+		return expr
+	}
+	return "( " + expr + " )"
+}
+
+// repetition returns an expression matching item between min and max times
+// (max < 0 means unbounded), with separator (if any) between the items
+func repetition(item string, min, max int, separator string) string {
+	if max >= 0 && max < min {
+		max = min
+	}
+
+	next := group(item)
+	if separator != "" {
+		next = group(separator + " " + item)
+	}
+
+	if min == 0 {
+		if max == 0 {
+			return ""
+		}
+		rest := repetitionTail(next, 0, decrement(max))
+		if rest == "" {
+			return "(" + item + ")?"
+		}
+		return "(" + item + " " + rest + ")?"
+	}
+
+	parts := []string{group(item)}
+	for i := 1; i < min; i++ {
+		parts = append(parts, next)
+	}
+	if tail := repetitionTail(next, min, max); tail != "" {
+		parts = append(parts, tail)
+	}
+	return strings.Join(parts, " ")
+}
+
+// repetitionTail returns the optional repetitions of next after the first count ones
+func repetitionTail(next string, count, max int) string {
+	if max < 0 {
+		return "(" + strings.TrimSuffix(strings.TrimPrefix(next, "( "), " )") + ")*"
+	}
+
+	tail := ""
+	for i := count; i < max; i++ {
+		if tail == "" {
+			tail = "(" + next + ")?"
+		} else {
+			tail = "(" + next + " " + tail + ")?"
+		}
+	}
+	return tail
+}
+
+func decrement(max int) int {
+	if max < 0 {
+		return max
+	}
+	return max - 1
+}
+
+// integerRange returns an expression matching the integers between min and max.
+// Nil bounds are unbounded.
+func integerRange(min, max *int64) string {
+	var alternatives []string
+
+	// non-negative values
+	if max == nil || *max >= 0 {
+		var lo int64
+		if min != nil && *min > 0 {
+			lo = *min
+		}
+		alternatives = append(alternatives, uintRange(lo, max)...)
+	}
+
+	// negative values, as "-" followed by their magnitude
+	if min == nil || *min < 0 {
+		lo := int64(1)
+		if max != nil && *max < 0 {
+			lo = -*max
+		}
+		var hi *int64
+		if min != nil {
+			h := -*min
+			hi = &h
+		}
+		if neg := uintRange(lo, hi); len(neg) > 0 {
+			alternatives = append(alternatives, `"-" `+group(strings.Join(neg, " | ")))
+		}
+	}
+
+	return strings.Join(alternatives, " | ")
+}
+
+// uintRange returns the alternatives matching the non-negative integers between lo and hi
+// (nil for unbounded), without leading zeros
+func uintRange(lo int64, hi *int64) []string {
+	if hi != nil && *hi < lo {
+		return nil
+	}
+
+	var alternatives []string
+	loDigits := len(strconv.FormatInt(lo, 10))
+	maxDigits := 19
+	if hi != nil {
+		maxDigits = len(strconv.FormatInt(*hi, 10))
+	}
+
+	for digits := loDigits; digits <= maxDigits; digits++ {
+		from := int64(0)
+		if digits > 1 {
+			from = int64(math.Pow10(digits - 1))
+		}
+		if lo > from {
+			from = lo
+		}
+
+		if hi == nil && digits > loDigits {
+			// any number with more digits than the lower bound
+			alternatives = append(alternatives, "[1-9]"+strings.Repeat(" [0-9]", digits-1)+" [0-9]*")
+			break
+		}
+
+		to := int64(math.Pow10(digits)) - 1
+		if digits == 19 {
+			to = math.MaxInt64
+		}
+		if hi != nil && *hi < to {
+			to = *hi
+		}
+
+		alternatives = append(alternatives, sameLengthRange(strconv.FormatInt(from, 10), strconv.FormatInt(to, 10)))
+	}
+
+	return alternatives
+}
+
+// sameLengthRange returns an expression matching the numbers between lo and hi,
+// which have the same number of digits
+func sameLengthRange(lo, hi string) string {
+	if lo == hi {
+		return fmt.Sprintf(`"%s"`, lo)
+	}
+
+	if len(lo) == 1 {
+		return digitRange(lo[0], hi[0])
+	}
+
+	if lo[0] == hi[0] {
+		return fmt.Sprintf(`"%c" %s`, lo[0], group(sameLengthRange(lo[1:], hi[1:])))
+	}
+
+	if strings.Trim(lo[1:], "0") == "" && strings.Trim(hi[1:], "9") == "" {
+		return digitRange(lo[0], hi[0]) + strings.Repeat(" [0-9]", len(lo)-1)
+	}
+
+	alternatives := []string{
+		fmt.Sprintf(`"%c" %s`, lo[0], group(sameLengthRange(lo[1:], strings.Repeat("9", len(lo)-1)))),
+	}
+	if hi[0]-lo[0] > 1 {
+		alternatives = append(alternatives, digitRange(lo[0]+1, hi[0]-1)+strings.Repeat(" [0-9]", len(lo)-1))
+	}
+	alternatives = append(alternatives,
+		fmt.Sprintf(`"%c" %s`, hi[0], group(sameLengthRange(strings.Repeat("0", len(hi)-1), hi[1:]))))
+
+	return strings.Join(alternatives, " | ")
+}
+
+func digitRange(lo, hi byte) string {
+	if lo == hi {
+		return fmt.Sprintf(`"%c"`, lo)
+	}
+	return fmt.Sprintf("[%c-%c]", lo, hi)
+}
@@ -0,0 +1,320 @@
+package grammars
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// regexToExpression converts a regular expression to a grammar expression matching
+// the whole input. If jsonString is set, the expression matches the regular expression
+// inside a JSON string, i.e. with quotes, backslashes and control characters escaped.
+func regexToExpression(pattern string, jsonString bool) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	rc := regexConverter{jsonString: jsonString}
+	expr, err := rc.convert(stripAnchors(re.Simplify()), true)
+	if err != nil {
+		return "", fmt.Errorf("unsupported pattern %q: %w", pattern, err)
+	}
+	if expr == "" {
+		expr = `""`
+	}
+	return expr, nil
+}
+
+// stripAnchors removes the ^ and $ anchors around the expression (and its alternatives):
+// patterns always match the whole input
+func stripAnchors(re *syntax.Regexp) *syntax.Regexp {
+	isAnchor := func(r *syntax.Regexp) bool {
+		switch r.Op {
+		case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+			return true
+		}
+		return false
+	}
+
+	if isAnchor(re) {
+		return &syntax.Regexp{Op: syntax.OpEmptyMatch}
+	}
+	switch re.Op {
+	case syntax.OpAlternate, syntax.OpCapture:
+		// each alternative spans the whole input
+		stripped := *re
+		stripped.Sub = make([]*syntax.Regexp, len(re.Sub))
+		for i, sub := range re.Sub {
+			stripped.Sub[i] = stripAnchors(sub)
+		}
+		return &stripped
+	case syntax.OpConcat:
+	default:
+		return re
+	}
+
+	subs := re.Sub
+	for len(subs) > 0 && isAnchor(subs[0]) {
+		subs = subs[1:]
+	}
+	for len(subs) > 0 && isAnchor(subs[len(subs)-1]) {
+		subs = subs[:len(subs)-1]
+	}
+
+	stripped := *re
+	stripped.Sub = subs
+	return &stripped
+}
+
+type regexConverter struct {
+	jsonString bool
+}
+
+func (rc regexConverter) convert(re *syntax.Regexp, top bool) (string, error) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return "", nil
+	case syntax.OpNoMatch:
+		return "", fmt.Errorf("expression never matches")
+	case syntax.OpLiteral:
+		var parts []string
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && unicode.ToLower(r) != unicode.ToUpper(r) {
+				cls, err := rc.charClass([]rune{unicode.ToLower(r), unicode.ToLower(r), unicode.ToUpper(r), unicode.ToUpper(r)})
+				if err != nil {
+					return "", err
+				}
+				parts = append(parts, cls)
+				continue
+			}
+			parts = append(parts, rc.literal(r))
+		}
+		return strings.Join(parts, " "), nil
+	case syntax.OpCharClass:
+		return rc.charClass(re.Rune)
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		if rc.jsonString {
+			return rc.charClass([]rune{0, unicode.MaxRune})
+		}
+		if re.Op == syntax.OpAnyCharNotNL {
+			return `[^\n]`, nil
+		}
+		return `( [^\n] | "\n" )`, nil
+	case syntax.OpCapture:
+		sub, err := rc.convert(re.Sub[0], false)
+		if err != nil {
+			return "", err
+		}
+		return group(sub), nil
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		sub, err := rc.convert(re.Sub[0], false)
+		if err != nil {
+			return "", err
+		}
+		if sub == "" {
+			return "", nil
+		}
+		op := map[syntax.Op]string{syntax.OpStar: "*", syntax.OpPlus: "+", syntax.OpQuest: "?"}[re.Op]
+		return "(" + sub + ")" + op, nil
+	case syntax.OpRepeat:
+		sub, err := rc.convert(re.Sub[0], false)
+		if err != nil {
+			return "", err
+		}
+		return repetition(sub, re.Min, re.Max, ""), nil
+	case syntax.OpConcat:
+		var parts []string
+		for _, sub := range re.Sub {
+			part, err := rc.convert(sub, false)
+			if err != nil {
+				return "", err
+			}
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, " "), nil
+	case syntax.OpAlternate:
+		var alternatives []string
+		for _, sub := range re.Sub {
+			alternative, err := rc.convert(sub, false)
+			if err != nil {
+				return "", err
+			}
+			if alternative == "" {
+				alternative = `""`
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		if top {
+			return strings.Join(alternatives, " | "), nil
+		}
+		return "( " + strings.Join(alternatives, " | ") + " )", nil
+	}
+
+	return "", fmt.Errorf("%s is not supported", re.Op)
+}
+
+// literal returns the expression matching a single character
+func (rc regexConverter) literal(r rune) string {
+	if rc.jsonString {
+		if escaped, ok := jsonEscapes[r]; ok {
+			return `"` + escapeLiteral(escaped) + `"`
+		}
+		if r < 0x20 {
+			return `"` + escapeLiteral(fmt.Sprintf(`\u%04x`, r)) + `"`
+		}
+	}
+	return `"` + escapeLiteral(string(r)) + `"`
+}
+
+// jsonEscapeRule matches any escape sequence of JSON strings
+const jsonEscapeRule = `"\\" (["\\/bfnrt] | "u" [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F])`
+
+var jsonEscapes = map[rune]string{
+	'"':  `\"`,
+	'\\': `\\`,
+	'\n': `\n`,
+	'\r': `\r`,
+	'\t': `\t`,
+	'\b': `\b`,
+	'\f': `\f`,
+}
+
+func escapeLiteral(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// charClass returns the expression matching the given ranges (pairs of lower and upper bounds)
+func (rc regexConverter) charClass(ranges []rune) (string, error) {
+	var escapes []string
+	if rc.jsonString {
+		// quotes, backslashes and control characters must be escaped inside JSON strings
+		var allowed []rune
+		anyEscape := ranges[0] == 0 && ranges[1] >= 0x1f
+		if anyEscape {
+			escapes = append(escapes, jsonEscapeRule)
+		}
+		for i := 0; i < len(ranges); i += 2 {
+			lo, hi := ranges[i], ranges[i+1]
+			if !anyEscape {
+				for r := lo; r <= hi && r < 0x20; r++ {
+					escapes = append(escapes, rc.literal(r))
+				}
+				for _, r := range []rune{'"', '\\'} {
+					if lo <= r && r <= hi {
+						escapes = append(escapes, rc.literal(r))
+					}
+				}
+			}
+			allowed = append(allowed, subtractRunes(max(lo, 0x20), hi, '"', '\\')...)
+		}
+		ranges = allowed
+	}
+
+	var alternatives []string
+	if len(ranges) > 0 {
+		alternatives = append(alternatives, formatCharClass(ranges))
+	}
+	alternatives = append(alternatives, escapes...)
+
+	switch len(alternatives) {
+	case 0:
+		return "", fmt.Errorf("empty character class")
+	case 1:
+		return alternatives[0], nil
+	}
+	return "( " + strings.Join(alternatives, " | ") + " )", nil
+}
+
+// subtractRunes returns the ranges covering lo-hi, except the excluded runes (sorted)
+func subtractRunes(lo, hi rune, excluded ...rune) []rune {
+	var res []rune
+	for _, e := range excluded {
+		if e < lo || e > hi {
+			continue
+		}
+		if e > lo {
+			res = append(res, lo, e-1)
+		}
+		lo = e + 1
+	}
+	if lo <= hi {
+		res = append(res, lo, hi)
+	}
+	return res
+}
+
+func formatCharClass(ranges []rune) string {
+	negated := false
+	if ranges[len(ranges)-1] == unicode.MaxRune {
+		// express classes like [^"] as such, instead of listing the whole unicode range
+		var complement []rune
+		next := rune(0)
+		for i := 0; i < len(ranges); i += 2 {
+			if ranges[i] > next {
+				complement = append(complement, next, ranges[i]-1)
+			}
+			next = ranges[i+1] + 1
+		}
+		if len(complement) > 0 {
+			negated = true
+			ranges = complement
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("[")
+	if negated {
+		b.WriteString("^")
+	}
+	for i := 0; i < len(ranges); i += 2 {
+		b.WriteString(escapeClassRune(ranges[i]))
+		if ranges[i+1] != ranges[i] {
+			b.WriteString("-")
+			b.WriteString(escapeClassRune(ranges[i+1]))
+		}
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func escapeClassRune(r rune) string {
+	switch {
+	case r == '\\' || r == ']' || r == '[' || r == '"':
+		return `\` + string(r)
+	case r == '\n':
+		return `\n`
+	case r == '\r':
+		return `\r`
+	case r == '\t':
+		return `\t`
+	case r == '-' || r == '^' || r < 0x20 || r == 0x7f:
+		return fmt.Sprintf(`\x%02X`, r)
+	case r > 0xffff:
+		return fmt.Sprintf(`\U%08X`, r)
+	case r > 0x7f && !unicode.IsPrint(r):
+		return fmt.Sprintf(`\u%04X`, r)
+	}
+	return string(r)
+}
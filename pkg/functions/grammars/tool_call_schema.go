@@ -0,0 +1,245 @@
+package grammars
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ToolCallSchemaConverter generates grammars for the native tool call formats of model families
+// (see the *Schema types). The arguments are generated as JSON, like with JSONSchemaConverter.
+type ToolCallSchemaConverter struct {
+	schemaType SchemaConverterType
+	json       *JSONSchemaConverter
+}
+
+func NewToolCallSchemaConverter(schemaType SchemaConverterType, propOrder string) *ToolCallSchemaConverter {
+	return &ToolCallSchemaConverter{
+		schemaType: schemaType,
+		json:       NewJSONSchemaConverter(propOrder),
+	}
+}
+
+type toolCallFunction struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+// functions returns the functions of a schema generated by functions.JSONFunctionStructure:
+// alternatives of objects with a function name (a const) and its arguments
+func (sc *ToolCallSchemaConverter) functions(schema map[string]interface{}) ([]toolCallFunction, error) {
+	alternatives, ok := schema["oneOf"].([]interface{})
+	if !ok {
+		alternatives, ok = schema["anyOf"].([]interface{})
+	}
+	if !ok {
+		alternatives = []interface{}{schema}
+	}
+
+	var res []toolCallFunction
+	for _, a := range alternatives {
+		alternative, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid function schema: %v", a)
+		}
+		properties, _ := alternative["properties"].(map[string]interface{})
+
+		f := toolCallFunction{}
+		for _, p := range properties {
+			property, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if name, ok := property["const"].(string); ok {
+				f.name = name
+			} else {
+				f.arguments = property
+			}
+		}
+		if f.name == "" {
+			return nil, fmt.Errorf("no function name found in the schema: %v", alternative)
+		}
+		if f.arguments == nil {
+			f.arguments = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+func (sc *ToolCallSchemaConverter) Grammar(schema map[string]interface{}, options ...func(*GrammarOption)) (string, error) {
+	grammarOpts := &GrammarOption{}
+	grammarOpts.Apply(options...)
+
+	funcs, err := sc.functions(schema)
+	if err != nil {
+		return "", err
+	}
+
+	sc.json.addRule("freestring", PRIMITIVE_RULES["freestring"])
+	rules := sc.json.rules
+
+	var calls []string
+	for i, f := range funcs {
+		call, err := sc.call(f, fmt.Sprintf("call-%d", i), schema)
+		if err != nil {
+			return "", err
+		}
+		calls = append(calls, call)
+	}
+	call := sc.json.addRule("call", strings.Join(calls, " | "))
+
+	// Parallel calls are part of the format, rather than a JSON array
+	parallel := grammarOpts.MaybeArray
+	switch sc.schemaType {
+	case HermesSchema:
+		rules["root"] = repeatCalls(`"<tool_call>\n" `+call+` "\n</tool_call>"`, `"\n"`, parallel)
+	case MistralSchema:
+		rules["root"] = `"[TOOL_CALLS]" space "[" space ` + repeatCalls(call, `"," space`, parallel) + ` "]"`
+	case Llama3JSONSchema:
+		rules["root"] = `"<|python_tag|>"? ` + repeatCalls(call, `";" space`, parallel)
+	case PythonicSchema:
+		rules["root"] = `"[" ` + repeatCalls(call, `"," space`, parallel) + ` "]"`
+	case DeepSeekSchema:
+		rules["root"] = `"<｜tool▁calls▁begin｜>" ` + repeatCalls(`"<｜tool▁call▁begin｜>function<｜tool▁sep｜>" `+call+` "<｜tool▁call▁end｜>"`, `"\n"?`, parallel) + ` "<｜tool▁calls▁end｜>"`
+	default:
+		return "", fmt.Errorf("unsupported tool call format: %s", sc.schemaType)
+	}
+
+	return rules.ToGrammar(append(options, func(o *GrammarOption) { o.MaybeArray = false })...), nil
+}
+
+func repeatCalls(call, separator string, parallel bool) string {
+	if !parallel {
+		return call
+	}
+	return fmt.Sprintf("%s ( %s %s )*", call, separator, call)
+}
+
+// call returns the rule for a single call of the function
+func (sc *ToolCallSchemaConverter) call(f toolCallFunction, ruleName string, rootSchema map[string]interface{}) (string, error) {
+	name, err := sc.json.formatLiteral(f.name)
+	if err != nil {
+		return "", err
+	}
+
+	if sc.schemaType == PythonicSchema {
+		arguments, err := sc.pythonArguments(f, ruleName, rootSchema)
+		if err != nil {
+			return "", err
+		}
+		// the function name is not quoted
+		rule := fmt.Sprintf(`"%s("`, escapeLiteral(f.name))
+		if arguments != "" {
+			rule += " " + arguments
+		}
+		return sc.json.addRule(ruleName, rule+` ")"`), nil
+	}
+
+	arguments, err := sc.json.visit(f.arguments, ruleName+"-arguments", rootSchema)
+	if err != nil {
+		return "", err
+	}
+
+	var rule string
+	switch sc.schemaType {
+	case Llama3JSONSchema:
+		rule = fmt.Sprintf(`"{" space "\"name\"" space ":" space %s space "," space "\"parameters\"" space ":" space %s "}" space`, name, arguments)
+	case DeepSeekSchema:
+		rule = fmt.Sprintf("\"%s\\n```json\\n\" %s \"\\n```\"", escapeLiteral(f.name), arguments)
+	default:
+		rule = fmt.Sprintf(`"{" space "\"name\"" space ":" space %s space "," space "\"arguments\"" space ":" space %s "}" space`, name, arguments)
+	}
+	return sc.json.addRule(ruleName, rule), nil
+}
+
+// pythonArguments returns the expression for keyword arguments, e.g. city="Paris", days=3.
+// Values are JSON, except for booleans and null (True, False and None).
+func (sc *ToolCallSchemaConverter) pythonArguments(f toolCallFunction, ruleName string, rootSchema map[string]interface{}) (string, error) {
+	properties, _ := f.arguments["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	requiredList, hasRequired := f.arguments["required"].([]interface{})
+	required := map[string]bool{}
+	for _, r := range requiredList {
+		if s, ok := r.(string); ok {
+			required[s] = true
+		}
+	}
+
+	var mandatory, optional []string
+	for _, name := range names {
+		propSchema, ok := properties[name].(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("invalid schema for argument %q: %v", name, properties[name])
+		}
+		value, err := sc.json.visit(propSchema, fmt.Sprintf("%s-%s", ruleName, name), rootSchema)
+		if err != nil {
+			return "", err
+		}
+		kv := fmt.Sprintf(`"%s=" %s`, escapeLiteral(name), value)
+		if !hasRequired || required[name] {
+			mandatory = append(mandatory, kv)
+		} else {
+			optional = append(optional, kv)
+		}
+	}
+
+	if _, ok := sc.json.rules["boolean"]; ok {
+		sc.json.rules["boolean"] = `("True" | "False") space`
+	}
+	if _, ok := sc.json.rules["null"]; ok {
+		sc.json.rules["null"] = `"None" space`
+	}
+
+	return orderedArguments(mandatory, optional, `"," space`), nil
+}
+
+// orderedArguments returns an expression matching the mandatory items followed by any subset
+// of the optional ones, in order, with separator between them
+func orderedArguments(mandatory, optional []string, separator string) string {
+	// tail matches any subset of optional[i:], each one preceded by the separator
+	tail := func(i int) string {
+		var parts []string
+		for _, o := range optional[i:] {
+			parts = append(parts, fmt.Sprintf("( %s %s )?", separator, o))
+		}
+		return strings.Join(parts, " ")
+	}
+
+	if len(mandatory) > 0 {
+		expr := strings.Join(mandatory, " "+separator+" ")
+		if t := tail(0); t != "" {
+			expr += " " + t
+		}
+		return expr
+	}
+
+	if len(optional) == 0 {
+		return ""
+	}
+
+	var alternatives []string
+	for i, o := range optional {
+		alternative := o
+		if t := tail(i + 1); t != "" {
+			alternative += " " + t
+		}
+		alternatives = append(alternatives, alternative)
+	}
+	return "( " + strings.Join(alternatives, " | ") + " )?"
+}
+
+func (sc *ToolCallSchemaConverter) GrammarFromBytes(b []byte, options ...func(*GrammarOption)) (string, error) {
+	var schema map[string]interface{}
+	err := json.Unmarshal(b, &schema)
+	if err != nil {
+		return "", err
+	}
+	return sc.Grammar(schema, options...)
+}
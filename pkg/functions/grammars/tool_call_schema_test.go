@@ -0,0 +1,131 @@
+package grammars_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/functions/grammars"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const testToolCallFunctions = `{
+	"oneOf": [
+		{
+			"type": "object",
+			"properties": {
+				"name": {"const": "get_weather"},
+				"arguments": {
+					"type": "object",
+					"properties": {
+						"city": {"type": "string"},
+						"days": {"type": "integer"},
+						"metric": {"type": "boolean"}
+					},
+					"required": ["city"]
+				}
+			}
+		},
+		{
+			"type": "object",
+			"properties": {
+				"name": {"const": "get_time"},
+				"arguments": {"type": "object", "properties": {}}
+			}
+		}
+	]
+}`
+
+var _ = Describe("Tool call grammars", func() {
+	DescribeTable("accepts the tool calls in the format of the model",
+		func(schemaType SchemaConverterType, parallel bool, valid []string, invalid []string) {
+			options := []func(*GrammarOption){}
+			if parallel {
+				options = append(options, EnableMaybeArray)
+			}
+			grammar, err := NewToolCallSchemaConverter(schemaType, "").GrammarFromBytes([]byte(testToolCallFunctions), options...)
+			Expect(err).ToNot(HaveOccurred())
+
+			g, err := parseGBNF(grammar)
+			Expect(err).ToNot(HaveOccurred(), grammar)
+
+			for _, v := range valid {
+				Expect(g.Matches(v)).To(BeTrue(), "expected %q to be accepted by:\n%s", v, grammar)
+			}
+			for _, v := range invalid {
+				Expect(g.Matches(v)).To(BeFalse(), "expected %q to be rejected by:\n%s", v, grammar)
+			}
+		},
+		Entry("hermes", HermesSchema, false,
+			[]string{
+				"<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call>",
+				"<tool_call>\n{\"name\": \"get_time\", \"arguments\": {}}\n</tool_call>",
+			},
+			[]string{
+				"{\"name\": \"get_time\", \"arguments\": {}}",
+				"<tool_call>\n{\"name\": \"get_date\", \"arguments\": {}}\n</tool_call>",
+				"<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {}}\n</tool_call>",
+				"<tool_call>\n{\"name\": \"get_time\", \"arguments\": {}}\n</tool_call>\n<tool_call>\n{\"name\": \"get_time\", \"arguments\": {}}\n</tool_call>",
+			},
+		),
+		Entry("hermes with parallel calls", HermesSchema, true,
+			[]string{
+				"<tool_call>\n{\"name\": \"get_time\", \"arguments\": {}}\n</tool_call>",
+				"<tool_call>\n{\"name\": \"get_time\", \"arguments\": {}}\n</tool_call>\n<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\", \"days\": 2}}\n</tool_call>",
+			},
+			[]string{
+				"[{\"name\": \"get_time\", \"arguments\": {}}]",
+			},
+		),
+		Entry("mistral", MistralSchema, true,
+			[]string{
+				`[TOOL_CALLS] [{"name": "get_time", "arguments": {}}]`,
+				`[TOOL_CALLS][{"name": "get_weather", "arguments": {"city": "Paris", "metric": true}}, {"name": "get_time", "arguments": {}}]`,
+			},
+			[]string{
+				`[{"name": "get_time", "arguments": {}}]`,
+				`[TOOL_CALLS] {"name": "get_time", "arguments": {}}`,
+			},
+		),
+		Entry("llama3_json", Llama3JSONSchema, true,
+			[]string{
+				`{"name": "get_time", "parameters": {}}`,
+				`<|python_tag|>{"name": "get_weather", "parameters": {"city": "Paris"}}`,
+				`{"name": "get_time", "parameters": {}}; {"name": "get_time", "parameters": {}}`,
+			},
+			[]string{
+				`{"name": "get_time", "arguments": {}}`,
+			},
+		),
+		Entry("pythonic", PythonicSchema, true,
+			[]string{
+				`[get_time()]`,
+				`[get_weather(city="Paris")]`,
+				`[get_weather(city="Paris", days=3, metric=True), get_time()]`,
+				`[get_weather(city="Paris", metric=False)]`,
+			},
+			[]string{
+				`[get_weather()]`,
+				`[get_weather(city="Paris", metric=true)]`,
+				`[get_weather(days=3, city="Paris")]`,
+				`get_time()`,
+			},
+		),
+		Entry("deepseek", DeepSeekSchema, false,
+			[]string{
+				"<｜tool▁calls▁begin｜><｜tool▁call▁begin｜>function<｜tool▁sep｜>get_weather\n```json\n{\"city\": \"Paris\"}\n```<｜tool▁call▁end｜><｜tool▁calls▁end｜>",
+			},
+			[]string{
+				"<｜tool▁calls▁begin｜><｜tool▁call▁begin｜>function<｜tool▁sep｜>get_weather\n{\"city\": \"Paris\"}<｜tool▁call▁end｜><｜tool▁calls▁end｜>",
+				"<｜tool▁calls▁begin｜><｜tool▁call▁begin｜>function<｜tool▁sep｜>get_time\n```json\n{}\n```<｜tool▁call▁end｜><｜tool▁call▁begin｜>function<｜tool▁sep｜>get_time\n```json\n{}\n```<｜tool▁call▁end｜><｜tool▁calls▁end｜>",
+			},
+		),
+	)
+
+	It("selects the converter from the type name", func() {
+		Expect(NewType("hermes")).To(Equal(HermesSchema))
+		Expect(NewType("qwen")).To(Equal(HermesSchema))
+		Expect(NewType("mistral")).To(Equal(MistralSchema))
+		Expect(NewType("llama3_json")).To(Equal(Llama3JSONSchema))
+		Expect(NewType("pythonic")).To(Equal(PythonicSchema))
+		Expect(NewType("deepseek")).To(Equal(DeepSeekSchema))
+		Expect(NewType("unknown")).To(Equal(JSONSchema))
+	})
+})
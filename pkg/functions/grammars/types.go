@@ -5,11 +5,27 @@ type SchemaConverterType int
 const (
 	JSONSchema SchemaConverterType = iota
 	LLama31Schema
+	// HermesSchema is the format of Hermes and Qwen models: <tool_call>{"name": ..., "arguments": ...}</tool_call>
+	HermesSchema
+	// MistralSchema is the format of Mistral models: [TOOL_CALLS] [{"name": ..., "arguments": ...}]
+	MistralSchema
+	// Llama3JSONSchema is the JSON format of Llama 3.x models: {"name": ..., "parameters": ...}
+	Llama3JSONSchema
+	// PythonicSchema is the format of Llama 3.2+/4 pythonic models: [func(arg=value)]
+	PythonicSchema
+	// DeepSeekSchema is the format of DeepSeek V3/R1 models, using their tool call tokens
+	DeepSeekSchema
 )
 
 const (
-	LlamaType string = "llama3.1"
-	JSONType  string = "json"
+	LlamaType      string = "llama3.1"
+	JSONType       string = "json"
+	HermesType     string = "hermes"
+	QwenType       string = "qwen"
+	MistralType    string = "mistral"
+	Llama3JSONType string = "llama3_json"
+	PythonicType   string = "pythonic"
+	DeepSeekType   string = "deepseek"
 )
 
 func (s SchemaConverterType) String() string {
@@ -18,6 +34,16 @@ func (s SchemaConverterType) String() string {
 		return JSONType
 	case LLama31Schema:
 		return LlamaType
+	case HermesSchema:
+		return HermesType
+	case MistralSchema:
+		return MistralType
+	case Llama3JSONSchema:
+		return Llama3JSONType
+	case PythonicSchema:
+		return PythonicType
+	case DeepSeekSchema:
+		return DeepSeekType
 	}
 	return "unknown"
 }
@@ -28,6 +54,16 @@ func NewType(t string) SchemaConverterType {
 		return JSONSchema
 	case LlamaType:
 		return LLama31Schema
+	case HermesType, QwenType:
+		return HermesSchema
+	case MistralType:
+		return MistralSchema
+	case Llama3JSONType:
+		return Llama3JSONSchema
+	case PythonicType:
+		return PythonicSchema
+	case DeepSeekType:
+		return DeepSeekSchema
 	}
 	return JSONSchema
 }
@@ -0,0 +1,422 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SchemaValidationError lists the reasons why a document does not match a JSON schema
+type SchemaValidationError struct {
+	Errors []string
+}
+
+func (e *SchemaValidationError) Error() string {
+	return strings.Join(e.Errors, "; ")
+}
+
+var uuidRE = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidateJSONSchema checks that document is a JSON document matching schema. It supports the same
+// keywords as the grammar converter. If the document does not match, a *SchemaValidationError is returned.
+func ValidateJSONSchema(schema map[string]interface{}, document string) error {
+	dec := json.NewDecoder(strings.NewReader(document))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return &SchemaValidationError{Errors: []string{fmt.Sprintf("invalid JSON: %s", err.Error())}}
+	}
+	if dec.More() {
+		return &SchemaValidationError{Errors: []string{"invalid JSON: unexpected content after the document"}}
+	}
+
+	v := &schemaValidator{root: schema}
+	v.validate(schema, value, "$")
+	if len(v.errors) > 0 {
+		return &SchemaValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	root   map[string]interface{}
+	errors []string
+}
+
+func (v *schemaValidator) errorf(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+// matches reports whether value matches schema, without recording errors
+func (v *schemaValidator) matches(schema map[string]interface{}, value interface{}, path string) bool {
+	sub := &schemaValidator{root: v.root}
+	sub.validate(schema, value, path)
+	return len(sub.errors) == 0
+}
+
+func (v *schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolveReference(ref)
+		if err != nil {
+			v.errorf(path, "%s", err.Error())
+			return
+		}
+		v.validate(resolved, value, path)
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		// as in the grammar converter, the schemas are merged so that the properties of all
+		// of them are allowed in objects
+		v.validate(v.mergeAllOf(schema, allOf), value, path)
+		return
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		if v.countMatches(anyOf, value, path) == 0 {
+			v.errorf(path, "does not match any of the allowed schemas")
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if n := v.countMatches(oneOf, value, path); n != 1 {
+			v.errorf(path, "must match exactly one of the allowed schemas, matches %d", n)
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !jsonEqual(c, value) {
+		v.errorf(path, "must be %s", marshal(c))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.errorf(path, "must be one of %s", marshal(enum))
+		}
+	}
+
+	if !v.validateType(schema, value, path) {
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, value, path)
+	case []interface{}:
+		v.validateArray(schema, value, path)
+	case string:
+		v.validateString(schema, value, path)
+	case json.Number:
+		v.validateNumber(schema, value, path)
+	}
+}
+
+func (v *schemaValidator) countMatches(schemas []interface{}, value interface{}, path string) int {
+	n := 0
+	for _, s := range schemas {
+		if s, ok := s.(map[string]interface{}); ok && v.matches(s, value, path) {
+			n++
+		}
+	}
+	return n
+}
+
+func (v *schemaValidator) validateType(schema map[string]interface{}, value interface{}, path string) bool {
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, tt := range t {
+			if s, ok := tt.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		return true
+	}
+
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	v.errorf(path, "must be of type %s, got %s", strings.Join(types, " or "), actual)
+	return false
+}
+
+// validateObject has the same defaults as the grammar converter: if "required" is not set all the
+// properties are mandatory, and additional properties are allowed only if "additionalProperties"
+// is set to true or to a schema, or if the schema has no properties.
+func (v *schemaValidator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	var required []string
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, r := range list {
+			if name, ok := r.(string); ok {
+				required = append(required, name)
+			}
+		}
+	} else {
+		for name := range properties {
+			required = append(required, name)
+		}
+		sort.Strings(required)
+	}
+	for _, name := range required {
+		if _, exists := value[name]; !exists {
+			v.errorf(path, "missing required property %q", name)
+		}
+	}
+
+	additional, hasAdditional := schema["additionalProperties"]
+	if !hasAdditional && len(properties) == 0 {
+		// {"type": "object"} matches any object
+		additional = true
+	}
+
+	keys := make([]string, 0, len(value))
+	for k := range value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		propPath := path + "." + k
+		if propSchema, ok := properties[k].(map[string]interface{}); ok {
+			v.validate(propSchema, value[k], propPath)
+			continue
+		}
+
+		switch additional := additional.(type) {
+		case map[string]interface{}:
+			v.validate(additional, value[k], propPath)
+		case bool:
+			if additional {
+				continue
+			}
+			v.errorf(path, "unexpected property %q", k)
+		default:
+			v.errorf(path, "unexpected property %q", k)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, value []interface{}, path string) {
+	if min, ok := numberKeyword(schema, "minItems"); ok && float64(len(value)) < min {
+		v.errorf(path, "must have at least %v items", min)
+	}
+	if max, ok := numberKeyword(schema, "maxItems"); ok && float64(len(value)) > max {
+		v.errorf(path, "must have at most %v items", max)
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]interface{}, value string, path string) {
+	length := float64(utf8.RuneCountInString(value))
+	if min, ok := numberKeyword(schema, "minLength"); ok && length < min {
+		v.errorf(path, "must be at least %v characters long", min)
+	}
+	if max, ok := numberKeyword(schema, "maxLength"); ok && length > max {
+		v.errorf(path, "must be at most %v characters long", max)
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.errorf(path, "invalid pattern %q: %s", pattern, err.Error())
+		} else if !re.MatchString(value) {
+			v.errorf(path, "must match the pattern %q", pattern)
+		}
+	}
+
+	if format, ok := schema["format"].(string); ok && !validFormat(format, value) {
+		v.errorf(path, "must be a valid %s", format)
+	}
+}
+
+func validFormat(format, value string) bool {
+	var err error
+	switch format {
+	case "date":
+		_, err = time.Parse(time.DateOnly, value)
+	case "time":
+		_, err = time.Parse("15:04:05Z07:00", value)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+	case "uuid":
+		return uuidRE.MatchString(value)
+	case "email":
+		var addr *mail.Address
+		addr, err = mail.ParseAddress(value)
+		if err == nil && addr.Address != value {
+			return false
+		}
+	}
+	return err == nil
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]interface{}, value json.Number, path string) {
+	f, err := value.Float64()
+	if err != nil {
+		v.errorf(path, "invalid number %s", value)
+		return
+	}
+
+	if min, ok := numberKeyword(schema, "minimum"); ok && f < min {
+		v.errorf(path, "must be greater than or equal to %v", min)
+	}
+	if max, ok := numberKeyword(schema, "maximum"); ok && f > max {
+		v.errorf(path, "must be less than or equal to %v", max)
+	}
+	if min, ok := numberKeyword(schema, "exclusiveMinimum"); ok && f <= min {
+		v.errorf(path, "must be greater than %v", min)
+	}
+	if max, ok := numberKeyword(schema, "exclusiveMaximum"); ok && f >= max {
+		v.errorf(path, "must be less than %v", max)
+	}
+}
+
+// mergeAllOf merges the schemas listed in allOf (and the rest of the schema) into a single one, like
+// the grammar converter: properties and required properties are combined, other keywords are
+// overridden in order
+func (v *schemaValidator) mergeAllOf(schema map[string]interface{}, allOf []interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	properties := map[string]interface{}{}
+	var required []interface{}
+	hasRequired := false
+
+	merge := func(s map[string]interface{}) {
+		for k, val := range s {
+			switch k {
+			case "allOf":
+			case "properties":
+				if props, ok := val.(map[string]interface{}); ok {
+					for name, p := range props {
+						properties[name] = p
+					}
+				}
+			case "required":
+				if r, ok := val.([]interface{}); ok {
+					hasRequired = true
+					required = append(required, r...)
+				}
+			default:
+				merged[k] = val
+			}
+		}
+	}
+
+	for _, component := range allOf {
+		s, ok := component.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if ref, ok := s["$ref"].(string); ok {
+			if resolved, err := v.resolveReference(ref); err == nil {
+				s = resolved
+			}
+		}
+		if nested, ok := s["allOf"].([]interface{}); ok {
+			s = v.mergeAllOf(s, nested)
+		}
+		merge(s)
+	}
+	merge(schema)
+
+	if len(properties) > 0 {
+		merged["properties"] = properties
+	}
+	if hasRequired {
+		merged["required"] = required
+	}
+	return merged
+}
+
+func (v *schemaValidator) resolveReference(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#/$defs/") {
+		return nil, fmt.Errorf("invalid reference format: %s", ref)
+	}
+	definitions, _ := v.root["$defs"].(map[string]interface{})
+	def, ok := definitions[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("definition not found: %s", ref)
+	}
+	return def, nil
+}
+
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if f, err := value.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func numberKeyword(schema map[string]interface{}, key string) (float64, bool) {
+	switch n := schema[key].(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// jsonEqual compares two values as JSON documents
+func jsonEqual(a, b interface{}) bool {
+	normalize := func(v interface{}) interface{} {
+		var res interface{}
+		if err := json.Unmarshal([]byte(marshal(v)), &res); err != nil {
+			return v
+		}
+		return res
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func marshal(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
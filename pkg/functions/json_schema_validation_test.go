@@ -0,0 +1,102 @@
+package functions_test
+
+import (
+	"encoding/json"
+
+	. "github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateJSONSchema()", func() {
+	DescribeTable("validates documents against the schema",
+		func(schema string, valid []string, invalid []string) {
+			s := map[string]interface{}{}
+			Expect(json.Unmarshal([]byte(schema), &s)).To(Succeed())
+
+			for _, v := range valid {
+				Expect(ValidateJSONSchema(s, v)).To(Succeed(), v)
+			}
+			for _, v := range invalid {
+				err := ValidateJSONSchema(s, v)
+				Expect(err).To(HaveOccurred(), v)
+				Expect(err).To(BeAssignableToTypeOf(&SchemaValidationError{}))
+			}
+		},
+		Entry("invalid JSON",
+			`{"type": "object"}`,
+			[]string{`{}`, ` {"a": 1} `},
+			[]string{`{`, `{} {}`, `not json`},
+		),
+		Entry("types",
+			`{"type": ["integer", "null"]}`,
+			[]string{`1`, `2.0`, `null`},
+			[]string{`1.5`, `"1"`, `true`},
+		),
+		Entry("required and additional properties",
+			`{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}, "required": ["a"], "additionalProperties": false}`,
+			[]string{`{"a": "x"}`, `{"b": 1, "a": "x"}`},
+			[]string{`{"b": 1}`, `{"a": "x", "c": 1}`, `{"a": 1}`},
+		),
+		Entry("all the properties are required and no other is allowed by default",
+			`{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}}`,
+			[]string{`{"a": "x", "b": 1}`},
+			[]string{`{"a": "x"}`, `{"a": "x", "b": 1, "c": true}`},
+		),
+		Entry("allOf combines the properties",
+			`{"allOf": [{"type": "object", "properties": {"a": {"type": "string"}}}, {"properties": {"b": {"type": "integer"}}, "required": ["b"]}]}`,
+			[]string{`{"b": 1}`, `{"a": "x", "b": 1}`},
+			[]string{`{"a": "x"}`, `{"b": 1, "c": true}`},
+		),
+		Entry("additional properties with a schema",
+			`{"type": "object", "additionalProperties": {"type": "boolean"}}`,
+			[]string{`{}`, `{"a": true}`},
+			[]string{`{"a": 1}`},
+		),
+		Entry("arrays",
+			`{"type": "array", "items": {"enum": ["a", "b"]}, "minItems": 1, "maxItems": 2}`,
+			[]string{`["a"]`, `["a", "b"]`},
+			[]string{`[]`, `["a", "b", "a"]`, `["c"]`},
+		),
+		Entry("strings",
+			`{"type": "string", "minLength": 2, "maxLength": 5, "pattern": "^[a-z]+$"}`,
+			[]string{`"ab"`, `"abcde"`},
+			[]string{`"a"`, `"abcdef"`, `"AB"`},
+		),
+		Entry("formats",
+			`{"type": "object", "properties": {"d": {"type": "string", "format": "date-time"}, "e": {"type": "string", "format": "email"}, "u": {"type": "string", "format": "uuid"}}}`,
+			[]string{`{"d": "2024-02-29T12:00:00Z", "e": "john@example.com", "u": "123e4567-e89b-12d3-a456-426614174000"}`},
+			[]string{`{"d": "tomorrow"}`, `{"e": "john"}`, `{"u": "123"}`},
+		),
+		Entry("numbers",
+			`{"type": "number", "minimum": 0, "exclusiveMaximum": 10}`,
+			[]string{`0`, `9.99`},
+			[]string{`-1`, `10`},
+		),
+		Entry("const, anyOf, oneOf and allOf",
+			`{"allOf": [{"$ref": "#/$defs/kind"}, {"oneOf": [{"properties": {"v": {"type": "integer"}}, "additionalProperties": true}, {"properties": {"v": {"type": "string"}}, "additionalProperties": true}]}],
+			  "$defs": {"kind": {"type": "object", "properties": {"kind": {"const": "x"}}, "required": ["kind"], "additionalProperties": true}}}`,
+			[]string{`{"kind": "x", "v": 1}`, `{"kind": "x", "v": "a"}`},
+			[]string{`{"kind": "y", "v": 1}`, `{"v": 1}`, `{"kind": "x"}`},
+		),
+	)
+
+	It("reports the location of the errors", func() {
+		s := map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"items": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "integer"},
+				},
+			},
+			"required": []interface{}{"name"},
+		}
+		err := ValidateJSONSchema(s, `{"items": [1, "two"]}`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*SchemaValidationError).Errors).To(ConsistOf(
+			`$: missing required property "name"`,
+			`$.items[1]: must be of type integer, got string`,
+		))
+	})
+})
@@ -101,6 +101,10 @@ type FunctionsConfig struct {
 	// This might be useful for certain models trained with the function name as the first token.
 	FunctionNameKey      string `yaml:"function_name_key"`
 	FunctionArgumentsKey string `yaml:"function_arguments_key"`
+
+	// Parser selects the tool call format of the model, used both to parse the calls and to generate the grammar.
+	// available: hermes, qwen, mistral, llama3_json, pythonic, deepseek, and auto to guess it from the chat template of GGUF models
+	Parser string `yaml:"parser"`
 }
 
 type ReplaceResult struct {
@@ -136,6 +140,8 @@ func (g FunctionsConfig) GrammarOptions() []func(o *grammars.GrammarOption) {
 
 	if g.GrammarConfig.SchemaType != "" {
 		opts = append(opts, grammars.WithSchemaType(grammars.NewType(g.GrammarConfig.SchemaType)))
+	} else if _, ok := toolCallParsers[g.Parser]; ok {
+		opts = append(opts, grammars.WithSchemaType(grammars.NewType(g.Parser)))
 	}
 
 	if g.FunctionNameKey != "" {
@@ -174,6 +180,11 @@ func ParseTextContent(llmresult string, functionConfig FunctionsConfig) string {
 		}
 	}
 
+	if parser, ok := toolCallParsers[functionConfig.Parser]; ok {
+		_, text := parser(llmresult)
+		return text
+	}
+
 	return ""
 }
 
@@ -230,6 +241,15 @@ func ParseFunctionCall(llmresult string, functionConfig FunctionsConfig) []FuncC
 	}
 	log.Debug().Msgf("LLM result(function cleanup): %s", llmresult)
 
+	if parser, ok := toolCallParsers[functionConfig.Parser]; ok {
+		results, _ := parser(llmresult)
+		log.Debug().Str("parser", functionConfig.Parser).Msgf("Function calls: %+v", results)
+		if results == nil {
+			results = []FuncCallResults{}
+		}
+		return results
+	}
+
 	functionNameKey := defaultFunctionNameKey
 	functionArgumentsKey := defaultFunctionArgumentsKey
 	if functionConfig.FunctionNameKey != "" {
@@ -0,0 +1,390 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mudler/LocalAI/pkg/functions/grammars"
+)
+
+// AutoParser guesses the tool call format from the chat template of GGUF models.
+// The calls are parsed as if no parser was set if the format cannot be guessed.
+const AutoParser = "auto"
+
+// toolCallParser extracts the tool calls of a model specific format from the LLM result,
+// along with the text that is not part of any call
+type toolCallParser func(llmresult string) ([]FuncCallResults, string)
+
+var toolCallParsers = map[string]toolCallParser{
+	grammars.HermesType:     parseHermesToolCalls,
+	grammars.QwenType:       parseHermesToolCalls,
+	grammars.MistralType:    parseMistralToolCalls,
+	grammars.Llama3JSONType: parseLlama3JSONToolCalls,
+	grammars.PythonicType:   parsePythonicToolCalls,
+	grammars.DeepSeekType:   parseDeepSeekToolCalls,
+}
+
+// toolCallFromJSON returns the call of an object like {"name": ..., "arguments": ...}
+func toolCallFromJSON(obj map[string]any, argumentsKeys ...string) (FuncCallResults, bool) {
+	name, ok := obj["name"].(string)
+	if !ok || name == "" {
+		return FuncCallResults{}, false
+	}
+
+	var args any = map[string]any{}
+	for _, k := range argumentsKeys {
+		if a, ok := obj[k]; ok {
+			args = a
+			break
+		}
+	}
+	// some models return the arguments already stringified
+	if s, ok := args.(string); ok {
+		return FuncCallResults{Name: name, Arguments: s}, true
+	}
+	d, err := json.Marshal(args)
+	if err != nil {
+		return FuncCallResults{}, false
+	}
+	return FuncCallResults{Name: name, Arguments: string(d)}, true
+}
+
+var hermesToolCallRE = regexp.MustCompile(`(?s)<tool_call>(.*?)(?:</tool_call>|$)`)
+
+// parseHermesToolCalls parses <tool_call>{"name": ..., "arguments": ...}</tool_call>.
+// The closing tag is optional at the end of the result, as models often stop before it.
+func parseHermesToolCalls(llmresult string) ([]FuncCallResults, string) {
+	var results []FuncCallResults
+	for _, m := range hermesToolCallRE.FindAllStringSubmatch(llmresult, -1) {
+		objs, _ := ParseJSON(strings.TrimSpace(m[1]))
+		for _, obj := range objs {
+			if call, ok := toolCallFromJSON(obj, "arguments", "parameters"); ok {
+				results = append(results, call)
+			}
+		}
+	}
+	return results, strings.TrimSpace(hermesToolCallRE.ReplaceAllString(llmresult, ""))
+}
+
+var mistralArgsRE = regexp.MustCompile(`^\s*([\w.-]+)\s*\[ARGS\]`)
+
+// parseMistralToolCalls parses [TOOL_CALLS] [{"name": ..., "arguments": ...}, ...], and the
+// format of newer models, [TOOL_CALLS]name[ARGS]{...}
+func parseMistralToolCalls(llmresult string) ([]FuncCallResults, string) {
+	idx := strings.Index(llmresult, "[TOOL_CALLS]")
+	if idx < 0 {
+		return nil, strings.TrimSpace(llmresult)
+	}
+	text := strings.TrimSpace(llmresult[:idx])
+
+	var results []FuncCallResults
+	for _, segment := range strings.Split(llmresult[idx:], "[TOOL_CALLS]")[1:] {
+		if m := mistralArgsRE.FindStringSubmatch(segment); m != nil {
+			dec := json.NewDecoder(strings.NewReader(segment[len(m[0]):]))
+			var args any
+			if err := dec.Decode(&args); err != nil {
+				continue
+			}
+			d, _ := json.Marshal(args)
+			results = append(results, FuncCallResults{Name: m[1], Arguments: string(d)})
+			continue
+		}
+
+		var calls []map[string]any
+		dec := json.NewDecoder(strings.NewReader(strings.TrimSpace(segment)))
+		if err := dec.Decode(&calls); err != nil {
+			// a single object, or calls which are not in an array
+			calls, _ = ParseJSON(segment)
+		}
+		for _, obj := range calls {
+			if call, ok := toolCallFromJSON(obj, "arguments", "parameters"); ok {
+				results = append(results, call)
+			}
+		}
+	}
+	return results, text
+}
+
+// parseLlama3JSONToolCalls parses {"name": ..., "parameters": ...}, optionally after <|python_tag|>.
+// Multiple calls are separated by semicolons.
+func parseLlama3JSONToolCalls(llmresult string) ([]FuncCallResults, string) {
+	text := ""
+	if idx := strings.Index(llmresult, "<|python_tag|>"); idx >= 0 {
+		text = llmresult[:idx]
+		llmresult = llmresult[idx+len("<|python_tag|>"):]
+	}
+
+	objs, _ := ParseJSON(llmresult)
+	var results []FuncCallResults
+	for _, obj := range objs {
+		if call, ok := toolCallFromJSON(obj, "parameters", "arguments"); ok {
+			results = append(results, call)
+		}
+	}
+	if len(results) > 0 {
+		return results, strings.TrimSpace(text)
+	}
+	return nil, strings.TrimSpace(llmresult)
+}
+
+var deepSeekToolCallRE = regexp.MustCompile("(?s)<｜tool▁call▁begin｜>(?:function<｜tool▁sep｜>)?([^\n<]+?)(?:<｜tool▁sep｜>|\n)\\s*(?:```json)?\\s*(.*?)\\s*(?:```)?\\s*<｜tool▁call▁end｜>")
+
+var deepSeekToolCallsRE = regexp.MustCompile(`(?s)<｜tool▁calls▁begin｜>.*?(?:<｜tool▁calls▁end｜>|$)`)
+
+// parseDeepSeekToolCalls parses the calls between the tool call tokens of DeepSeek models, with the
+// arguments in a JSON code block (V3 and R1) or directly after the separator (V3.1)
+func parseDeepSeekToolCalls(llmresult string) ([]FuncCallResults, string) {
+	var results []FuncCallResults
+	for _, m := range deepSeekToolCallRE.FindAllStringSubmatch(llmresult, -1) {
+		var args any
+		if err := json.Unmarshal([]byte(m[2]), &args); err != nil {
+			continue
+		}
+		d, _ := json.Marshal(args)
+		results = append(results, FuncCallResults{Name: strings.TrimSpace(m[1]), Arguments: string(d)})
+	}
+	return results, strings.TrimSpace(deepSeekToolCallsRE.ReplaceAllString(llmresult, ""))
+}
+
+var pythonicToolCallsRE = regexp.MustCompile(`(?s)\[\s*[A-Za-z_][\w.]*\s*\(.*\)\s*\]`)
+
+// parsePythonicToolCalls parses a list of python function calls with keyword arguments,
+// e.g. [get_weather(city="Paris", days=3), get_time()]
+func parsePythonicToolCalls(llmresult string) ([]FuncCallResults, string) {
+	loc := pythonicToolCallsRE.FindStringIndex(llmresult)
+	if loc == nil {
+		return nil, strings.TrimSpace(llmresult)
+	}
+
+	p := &pythonParser{input: []rune(llmresult[loc[0]:loc[1]])}
+	calls, err := p.calls()
+	if err != nil {
+		return nil, strings.TrimSpace(llmresult)
+	}
+
+	var results []FuncCallResults
+	for _, c := range calls {
+		d, err := json.Marshal(c.arguments)
+		if err != nil {
+			continue
+		}
+		results = append(results, FuncCallResults{Name: c.name, Arguments: string(d)})
+	}
+	return results, strings.TrimSpace(llmresult[:loc[0]] + llmresult[loc[1]:])
+}
+
+type pythonCall struct {
+	name      string
+	arguments map[string]any
+}
+
+// pythonParser parses python calls whose arguments are literals (strings, numbers, booleans,
+// None, lists and dicts)
+type pythonParser struct {
+	input []rune
+	pos   int
+}
+
+func (p *pythonParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *pythonParser) consume(r rune) bool {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == r {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *pythonParser) expect(r rune) error {
+	if !p.consume(r) {
+		return fmt.Errorf("expected %q at offset %d", r, p.pos)
+	}
+	return nil
+}
+
+func (p *pythonParser) identifier() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) {
+		r := p.input[p.pos]
+		if r != '_' && r != '.' && !unicode.IsLetter(r) && !(p.pos > start && unicode.IsDigit(r)) {
+			break
+		}
+		p.pos++
+	}
+	return string(p.input[start:p.pos])
+}
+
+func (p *pythonParser) calls() ([]pythonCall, error) {
+	if err := p.expect('['); err != nil {
+		return nil, err
+	}
+	var calls []pythonCall
+	for !p.consume(']') {
+		if len(calls) > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		name := p.identifier()
+		if name == "" {
+			return nil, fmt.Errorf("expected a function name at offset %d", p.pos)
+		}
+		if err := p.expect('('); err != nil {
+			return nil, err
+		}
+		args := map[string]any{}
+		for !p.consume(')') {
+			if len(args) > 0 {
+				if err := p.expect(','); err != nil {
+					return nil, err
+				}
+				// trailing comma
+				if p.consume(')') {
+					break
+				}
+			}
+			key := p.identifier()
+			if key == "" {
+				return nil, fmt.Errorf("expected an argument name at offset %d", p.pos)
+			}
+			if err := p.expect('='); err != nil {
+				return nil, err
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			args[key] = value
+		}
+		calls = append(calls, pythonCall{name: name, arguments: args})
+	}
+	return calls, nil
+}
+
+func (p *pythonParser) value() (any, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of input")
+	}
+
+	switch r := p.input[p.pos]; {
+	case r == '"' || r == '\'':
+		return p.string()
+	case r == '[' || r == '(':
+		p.pos++
+		closing := ']'
+		if r == '(' {
+			closing = ')'
+		}
+		list := []any{}
+		for !p.consume(closing) {
+			if len(list) > 0 {
+				if err := p.expect(','); err != nil {
+					return nil, err
+				}
+				if p.consume(closing) {
+					break
+				}
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case r == '{':
+		p.pos++
+		dict := map[string]any{}
+		for !p.consume('}') {
+			if len(dict) > 0 {
+				if err := p.expect(','); err != nil {
+					return nil, err
+				}
+				if p.consume('}') {
+					break
+				}
+			}
+			k, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			dict[fmt.Sprint(k)] = v
+		}
+		return dict, nil
+	case r == '-' || r == '+' || r == '.' || unicode.IsDigit(r):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.input) && strings.ContainsRune("0123456789.eE+-_", p.input[p.pos]) {
+			p.pos++
+		}
+		literal := strings.ReplaceAll(string(p.input[start:p.pos]), "_", "")
+		if i, err := strconv.ParseInt(literal, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", literal)
+		}
+		return f, nil
+	}
+
+	switch id := p.identifier(); id {
+	case "True", "true":
+		return true, nil
+	case "False", "false":
+		return false, nil
+	case "None", "null":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %q at offset %d", id, p.pos)
+	}
+}
+
+func (p *pythonParser) string() (string, error) {
+	quote := p.input[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		r := p.input[p.pos]
+		p.pos++
+		switch {
+		case r == quote:
+			return b.String(), nil
+		case r == '\\' && p.pos < len(p.input):
+			e := p.input[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case 'r':
+				b.WriteRune('\r')
+			default:
+				b.WriteRune(e)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
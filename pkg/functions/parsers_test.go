@@ -0,0 +1,89 @@
+package functions_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tool call parsers", func() {
+	DescribeTable("parses the tool calls in the format of the model",
+		func(parser string, input string, expected []FuncCallResults, text string) {
+			functionConfig := FunctionsConfig{Parser: parser}
+
+			results := ParseFunctionCall(input, functionConfig)
+			Expect(results).To(Equal(expected))
+			Expect(ParseTextContent(input, functionConfig)).To(Equal(text))
+		},
+		Entry("hermes", "hermes",
+			"Let me check.\n<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call>\n<tool_call>\n{\"name\": \"get_time\", \"arguments\": {}}\n</tool_call>",
+			[]FuncCallResults{{Name: "get_weather", Arguments: `{"city":"Paris"}`}, {Name: "get_time", Arguments: `{}`}},
+			"Let me check.",
+		),
+		Entry("qwen without the closing tag", "qwen",
+			"<tool_call>\n{\"name\": \"get_time\", \"arguments\": {\"tz\": \"UTC\"}}",
+			[]FuncCallResults{{Name: "get_time", Arguments: `{"tz":"UTC"}`}},
+			"",
+		),
+		Entry("hermes without tool calls", "hermes",
+			"The weather is sunny.",
+			[]FuncCallResults{},
+			"The weather is sunny.",
+		),
+		Entry("mistral", "mistral",
+			`[TOOL_CALLS] [{"name": "get_weather", "arguments": {"city": "Paris"}}, {"name": "get_time", "arguments": {}}]`,
+			[]FuncCallResults{{Name: "get_weather", Arguments: `{"city":"Paris"}`}, {Name: "get_time", Arguments: `{}`}},
+			"",
+		),
+		Entry("mistral with [ARGS]", "mistral",
+			`[TOOL_CALLS]get_weather[ARGS]{"city": "Paris"}[TOOL_CALLS]get_time[ARGS]{}`,
+			[]FuncCallResults{{Name: "get_weather", Arguments: `{"city":"Paris"}`}, {Name: "get_time", Arguments: `{}`}},
+			"",
+		),
+		Entry("llama3_json", "llama3_json",
+			`<|python_tag|>{"name": "get_weather", "parameters": {"city": "Paris"}}; {"name": "get_time", "parameters": {}}`,
+			[]FuncCallResults{{Name: "get_weather", Arguments: `{"city":"Paris"}`}, {Name: "get_time", Arguments: `{}`}},
+			"",
+		),
+		Entry("llama3_json with stringified arguments", "llama3_json",
+			`{"name": "get_weather", "arguments": "{\"city\": \"Paris\"}"}`,
+			[]FuncCallResults{{Name: "get_weather", Arguments: `{"city": "Paris"}`}},
+			"",
+		),
+		Entry("pythonic", "pythonic",
+			`[get_weather(city="Paris", days=3, metric=True, tags=['a', "b"], extra={"x": None}), get_time()]`,
+			[]FuncCallResults{
+				{Name: "get_weather", Arguments: `{"city":"Paris","days":3,"extra":{"x":null},"metric":true,"tags":["a","b"]}`},
+				{Name: "get_time", Arguments: `{}`},
+			},
+			"",
+		),
+		Entry("pythonic with text", "pythonic",
+			"I'll look it up.\n[get_weather(city='New \\'York\\'')]",
+			[]FuncCallResults{{Name: "get_weather", Arguments: `{"city":"New 'York'"}`}},
+			"I'll look it up.",
+		),
+		Entry("deepseek", "deepseek",
+			"<｜tool▁calls▁begin｜><｜tool▁call▁begin｜>function<｜tool▁sep｜>get_weather\n```json\n{\"city\": \"Paris\"}\n```<｜tool▁call▁end｜>\n<｜tool▁call▁begin｜>function<｜tool▁sep｜>get_time\n```json\n{}\n```<｜tool▁call▁end｜><｜tool▁calls▁end｜>",
+			[]FuncCallResults{{Name: "get_weather", Arguments: `{"city":"Paris"}`}, {Name: "get_time", Arguments: `{}`}},
+			"",
+		),
+		Entry("deepseek v3.1", "deepseek",
+			"Sure.<｜tool▁calls▁begin｜><｜tool▁call▁begin｜>get_weather<｜tool▁sep｜>{\"city\": \"Paris\"}<｜tool▁call▁end｜><｜tool▁calls▁end｜>",
+			[]FuncCallResults{{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+			"Sure.",
+		),
+	)
+
+	It("uses the grammar of the parser", func() {
+		functionConfig := FunctionsConfig{Parser: "hermes"}
+		grammar, err := JSONFunctionStructure{
+			OneOf: []Item{{Type: "object", Properties: map[string]interface{}{
+				"name":      FunctionName{Const: "get_time"},
+				"arguments": Argument{Type: "object", Properties: map[string]interface{}{}},
+			}}},
+		}.Grammar(functionConfig.GrammarOptions()...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(ContainSubstring(`"<tool_call>\n"`))
+	})
+})
@@ -8,6 +8,8 @@ import (
 
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	gopsutil "github.com/shirou/gopsutil/v3/process"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Base is a base class for all backends to implement
@@ -49,6 +51,11 @@ func (llm *Base) Embeddings(opts *pb.PredictOptions) ([]float32, error) {
 	return []float32{}, fmt.Errorf("unimplemented")
 }
 
+// EmbeddingsBatch returns an Unimplemented status, for the clients to embed the inputs one at a time instead
+func (llm *Base) EmbeddingsBatch(opts *pb.PredictOptions) ([][]float32, error) {
+	return nil, status.Error(codes.Unimplemented, "unimplemented")
+}
+
 func (llm *Base) GenerateImage(*pb.GenerateImageRequest) error {
 	return fmt.Errorf("unimplemented")
 }
@@ -100,6 +107,22 @@ func (llm *Base) StoresFind(*pb.StoresFindOptions) (pb.StoresFindResult, error)
 	return pb.StoresFindResult{}, fmt.Errorf("unimplemented")
 }
 
+func (llm *Base) StoresStats(*pb.StoresStatsOptions) (pb.StoresStatsResult, error) {
+	return pb.StoresStatsResult{}, fmt.Errorf("unimplemented")
+}
+
+func (llm *Base) StoresDrop(*pb.StoresDropOptions) error {
+	return fmt.Errorf("unimplemented")
+}
+
+func (llm *Base) StoresExport(*pb.StoresExportOptions) (pb.StoresExportResult, error) {
+	return pb.StoresExportResult{}, fmt.Errorf("unimplemented")
+}
+
+func (llm *Base) Unload() error {
+	return fmt.Errorf("unimplemented")
+}
+
 func (llm *Base) VAD(*pb.VADRequest) (pb.VADResponse, error) {
 	return pb.VADResponse{}, fmt.Errorf("unimplemented")
 }
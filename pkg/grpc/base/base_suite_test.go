@@ -0,0 +1,13 @@
+package base_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBase(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LocalAI base backend test")
+}
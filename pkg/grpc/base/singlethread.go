@@ -33,7 +33,7 @@ func (llm *SingleThread) Busy() bool {
 	if r {
 		llm.backendBusy.Unlock()
 	}
-	return r
+	return !r
 }
 
 // backends may wish to call this to capture the gopsutil info, then enhance with additional memory usage details?
@@ -0,0 +1,36 @@
+package base_test
+
+import (
+	"github.com/mudler/LocalAI/pkg/grpc/base"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SingleThread", func() {
+	It("is ready while no request is served", func() {
+		llm := &base.SingleThread{}
+		Expect(llm.Busy()).To(BeFalse())
+
+		status, err := llm.Status()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status.State).To(Equal(pb.StatusResponse_READY))
+
+		// checking does not keep the backend locked
+		Expect(llm.Busy()).To(BeFalse())
+	})
+
+	It("is busy while a request is served", func() {
+		llm := &base.SingleThread{}
+		llm.Lock()
+		Expect(llm.Busy()).To(BeTrue())
+
+		status, err := llm.Status()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status.State).To(Equal(pb.StatusResponse_BUSY))
+
+		llm.Unlock()
+		Expect(llm.Busy()).To(BeFalse())
+	})
+})
@@ -27,6 +27,10 @@ func (e *embedBackend) Embeddings(ctx context.Context, in *pb.PredictOptions, op
 	return e.s.Embedding(ctx, in)
 }
 
+func (e *embedBackend) EmbeddingsBatch(ctx context.Context, in *pb.PredictOptions, opts ...grpc.CallOption) (*pb.EmbeddingBatchResult, error) {
+	return e.s.EmbeddingBatch(ctx, in)
+}
+
 func (e *embedBackend) Predict(ctx context.Context, in *pb.PredictOptions, opts ...grpc.CallOption) (*pb.Reply, error) {
 	return e.s.Predict(ctx, in)
 }
@@ -75,6 +79,10 @@ func (e *embedBackend) Status(ctx context.Context) (*pb.StatusResponse, error) {
 	return e.s.Status(ctx, &pb.HealthMessage{})
 }
 
+func (e *embedBackend) Unload(ctx context.Context, opts ...grpc.CallOption) (*pb.Result, error) {
+	return e.s.Unload(ctx, &pb.UnloadRequest{})
+}
+
 func (e *embedBackend) StoresSet(ctx context.Context, in *pb.StoresSetOptions, opts ...grpc.CallOption) (*pb.Result, error) {
 	return e.s.StoresSet(ctx, in)
 }
@@ -91,6 +99,18 @@ func (e *embedBackend) StoresFind(ctx context.Context, in *pb.StoresFindOptions,
 	return e.s.StoresFind(ctx, in)
 }
 
+func (e *embedBackend) StoresStats(ctx context.Context, in *pb.StoresStatsOptions, opts ...grpc.CallOption) (*pb.StoresStatsResult, error) {
+	return e.s.StoresStats(ctx, in)
+}
+
+func (e *embedBackend) StoresDrop(ctx context.Context, in *pb.StoresDropOptions, opts ...grpc.CallOption) (*pb.Result, error) {
+	return e.s.StoresDrop(ctx, in)
+}
+
+func (e *embedBackend) StoresExport(ctx context.Context, in *pb.StoresExportOptions, opts ...grpc.CallOption) (*pb.StoresExportResult, error) {
+	return e.s.StoresExport(ctx, in)
+}
+
 func (e *embedBackend) Rerank(ctx context.Context, in *pb.RerankRequest, opts ...grpc.CallOption) (*pb.RerankResult, error) {
 	return e.s.Rerank(ctx, in)
 }
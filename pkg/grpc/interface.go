@@ -13,6 +13,7 @@ type AIModel interface {
 	PredictStream(*pb.PredictOptions, chan string) error
 	Load(*pb.ModelOptions) error
 	Embeddings(*pb.PredictOptions) ([]float32, error)
+	EmbeddingsBatch(*pb.PredictOptions) ([][]float32, error)
 	GenerateImage(*pb.GenerateImageRequest) error
 	GenerateVideo(*pb.GenerateVideoRequest) error
 	Detect(*pb.DetectOptions) (pb.DetectResponse, error)
@@ -21,11 +22,15 @@ type AIModel interface {
 	SoundGeneration(*pb.SoundGenerationRequest) error
 	TokenizeString(*pb.PredictOptions) (pb.TokenizationResponse, error)
 	Status() (pb.StatusResponse, error)
+	Unload() error
 
 	StoresSet(*pb.StoresSetOptions) error
 	StoresDelete(*pb.StoresDeleteOptions) error
 	StoresGet(*pb.StoresGetOptions) (pb.StoresGetResult, error)
 	StoresFind(*pb.StoresFindOptions) (pb.StoresFindResult, error)
+	StoresStats(*pb.StoresStatsOptions) (pb.StoresStatsResult, error)
+	StoresDrop(*pb.StoresDropOptions) error
+	StoresExport(*pb.StoresExportOptions) (pb.StoresExportResult, error)
 
 	VAD(*pb.VADRequest) (pb.VADResponse, error)
 }
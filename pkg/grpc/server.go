@@ -42,6 +42,23 @@ func (s *server) Embedding(ctx context.Context, in *pb.PredictOptions) (*pb.Embe
 	return &pb.EmbeddingResult{Embeddings: embeds}, nil
 }
 
+func (s *server) EmbeddingBatch(ctx context.Context, in *pb.PredictOptions) (*pb.EmbeddingBatchResult, error) {
+	if s.llm.Locking() {
+		s.llm.Lock()
+		defer s.llm.Unlock()
+	}
+	embeds, err := s.llm.EmbeddingsBatch(in)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.EmbeddingBatchResult{Embeddings: make([]*pb.EmbeddingResult, len(embeds))}
+	for i, e := range embeds {
+		res.Embeddings[i] = &pb.EmbeddingResult{Embeddings: e}
+	}
+	return res, nil
+}
+
 func (s *server) LoadModel(ctx context.Context, in *pb.ModelOptions) (*pb.Result, error) {
 	if s.llm.Locking() {
 		s.llm.Lock()
@@ -203,6 +220,18 @@ func (s *server) Status(ctx context.Context, in *pb.HealthMessage) (*pb.StatusRe
 	return &res, nil
 }
 
+func (s *server) Unload(ctx context.Context, in *pb.UnloadRequest) (*pb.Result, error) {
+	if s.llm.Locking() {
+		s.llm.Lock()
+		defer s.llm.Unlock()
+	}
+	err := s.llm.Unload()
+	if err != nil {
+		return &pb.Result{Message: fmt.Sprintf("Error unloading model: %s", err.Error()), Success: false}, err
+	}
+	return &pb.Result{Message: "Model unloaded", Success: true}, nil
+}
+
 func (s *server) StoresSet(ctx context.Context, in *pb.StoresSetOptions) (*pb.Result, error) {
 	if s.llm.Locking() {
 		s.llm.Lock()
@@ -251,6 +280,42 @@ func (s *server) StoresFind(ctx context.Context, in *pb.StoresFindOptions) (*pb.
 	return &res, nil
 }
 
+func (s *server) StoresStats(ctx context.Context, in *pb.StoresStatsOptions) (*pb.StoresStatsResult, error) {
+	if s.llm.Locking() {
+		s.llm.Lock()
+		defer s.llm.Unlock()
+	}
+	res, err := s.llm.StoresStats(in)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (s *server) StoresDrop(ctx context.Context, in *pb.StoresDropOptions) (*pb.Result, error) {
+	if s.llm.Locking() {
+		s.llm.Lock()
+		defer s.llm.Unlock()
+	}
+	err := s.llm.StoresDrop(in)
+	if err != nil {
+		return &pb.Result{Message: fmt.Sprintf("Error dropping store: %s", err.Error()), Success: false}, err
+	}
+	return &pb.Result{Message: "Dropped store", Success: true}, nil
+}
+
+func (s *server) StoresExport(ctx context.Context, in *pb.StoresExportOptions) (*pb.StoresExportResult, error) {
+	if s.llm.Locking() {
+		s.llm.Lock()
+		defer s.llm.Unlock()
+	}
+	res, err := s.llm.StoresExport(in)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
 func (s *server) VAD(ctx context.Context, in *pb.VADRequest) (*pb.VADResponse, error) {
 	if s.llm.Locking() {
 		s.llm.Lock()
@@ -0,0 +1,198 @@
+// Package mcp is a client of the Model Context Protocol (https://modelcontextprotocol.io),
+// to use the tools of MCP servers over stdio or the streamable HTTP transport
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// ProtocolVersion is the version of the protocol requested to the servers
+const ProtocolVersion = "2025-03-26"
+
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *int64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error returned by the server
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// transport sends the messages to the server
+type transport interface {
+	// call sends a request and waits for its response
+	call(ctx context.Context, req request) (response, error)
+	// notify sends a notification, which has no response
+	notify(ctx context.Context, req request) error
+	Close() error
+}
+
+// Tool is a tool of the server
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// Content is an item of the result of a tool
+type Content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Resource *struct {
+		URI  string `json:"uri"`
+		Text string `json:"text,omitempty"`
+	} `json:"resource,omitempty"`
+}
+
+// CallToolResult is the result of a tool call
+type CallToolResult struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// Text returns the result as text. Binary content is replaced by its type.
+func (r *CallToolResult) Text() string {
+	var parts []string
+	for _, c := range r.Content {
+		switch {
+		case c.Type == "text":
+			parts = append(parts, c.Text)
+		case c.Resource != nil && c.Resource.Text != "":
+			parts = append(parts, c.Resource.Text)
+		case c.Resource != nil:
+			parts = append(parts, fmt.Sprintf("[resource %s]", c.Resource.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s %s]", c.Type, c.MimeType))
+		}
+	}
+	if len(parts) == 0 && r.StructuredContent != nil {
+		d, err := json.Marshal(r.StructuredContent)
+		if err == nil {
+			return string(d)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ServerInfo describes the server, as returned by Initialize
+type ServerInfo struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	ServerInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"serverInfo"`
+	Instructions string `json:"instructions,omitempty"`
+}
+
+// Client is a session with an MCP server. It is safe for concurrent use.
+type Client struct {
+	transport transport
+	nextID    atomic.Int64
+}
+
+func newClient(t transport) *Client {
+	return &Client{transport: t}
+}
+
+func (c *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := c.nextID.Add(1)
+	res, err := c.transport.call(ctx, request{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(res.Result, result); err != nil {
+		return fmt.Errorf("invalid result of %s: %w", method, err)
+	}
+	return nil
+}
+
+// Initialize starts the session. It must be called before any other method.
+func (c *Client) Initialize(ctx context.Context) (*ServerInfo, error) {
+	info := &ServerInfo{}
+	err := c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "LocalAI", "version": "1.0.0"},
+	}, info)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.transport.notify(ctx, request{JSONRPC: "2.0", Method: "notifications/initialized"}); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListTools returns all the tools of the server
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor,omitempty"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool calls the tool with the arguments, a JSON object
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	result := &CallToolResult{}
+	err := c.call(ctx, "tools/call", map[string]interface{}{
+		"name":      name,
+		"arguments": arguments,
+	}, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Close ends the session, stopping the server if it was started by the client
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
@@ -0,0 +1,213 @@
+package mcp_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/mudler/LocalAI/pkg/mcp"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type stubMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// stubServer is an MCP server with an "add" tool, listed over two pages along with "echo"
+type stubServer struct {
+	initialized bool
+}
+
+func (s *stubServer) handle(msg stubMessage) interface{} {
+	if msg.ID == nil {
+		if msg.Method == "notifications/initialized" {
+			s.initialized = true
+		}
+		return nil
+	}
+
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": *msg.ID}
+	switch msg.Method {
+	case "initialize":
+		res["result"] = map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "stub", "version": "0.1.0"},
+		}
+	case "tools/list":
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		if params.Cursor == "" {
+			res["result"] = map[string]interface{}{
+				"tools": []map[string]interface{}{{
+					"name":        "add",
+					"description": "Adds two numbers",
+					"inputSchema": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"a": map[string]string{"type": "number"}, "b": map[string]string{"type": "number"}},
+						"required":   []string{"a", "b"},
+					},
+				}},
+				"nextCursor": "2",
+			}
+		} else {
+			res["result"] = map[string]interface{}{
+				"tools": []map[string]interface{}{{"name": "echo", "inputSchema": map[string]interface{}{"type": "object"}}},
+			}
+		}
+	case "tools/call":
+		var params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		switch params.Name {
+		case "add":
+			a, _ := params.Arguments["a"].(float64)
+			b, _ := params.Arguments["b"].(float64)
+			res["result"] = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": fmt.Sprint(a + b)}}}
+		default:
+			res["result"] = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": "unknown tool"}}, "isError": true}
+		}
+	default:
+		res["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	return res
+}
+
+// serveStream serves the messages of the reader, like a stdio server
+func (s *stubServer) serveStream(r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var msg stubMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Method == "tools/call" {
+			// requests and notifications of the server are sent before the response
+			fmt.Fprintln(w, `{"jsonrpc": "2.0", "method": "notifications/message", "params": {}}`)
+			fmt.Fprintln(w, `{"jsonrpc": "2.0", "id": 1000, "method": "ping"}`)
+		}
+		if res := s.handle(msg); res != nil {
+			d, _ := json.Marshal(res)
+			fmt.Fprintln(w, string(d))
+		}
+	}
+}
+
+var _ = Describe("MCP client", func() {
+	testClient := func(client *Client) {
+		ctx := context.Background()
+
+		info, err := client.Initialize(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.ServerInfo.Name).To(Equal("stub"))
+
+		tools, err := client.ListTools(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tools).To(HaveLen(2))
+		Expect(tools[0].Name).To(Equal("add"))
+		Expect(tools[0].Description).To(Equal("Adds two numbers"))
+		Expect(tools[0].InputSchema["required"]).To(ConsistOf("a", "b"))
+		Expect(tools[1].Name).To(Equal("echo"))
+
+		res, err := client.CallTool(ctx, "add", json.RawMessage(`{"a": 2, "b": 40}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.IsError).To(BeFalse())
+		Expect(res.Text()).To(Equal("42"))
+
+		res, err = client.CallTool(ctx, "echo", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.IsError).To(BeTrue())
+	}
+
+	It("uses the tools of servers over a stream", func() {
+		server := &stubServer{}
+		clientReader, serverWriter := io.Pipe()
+		serverReader, clientWriter := io.Pipe()
+		go func() {
+			server.serveStream(serverReader, serverWriter)
+			serverWriter.Close()
+		}()
+
+		client := NewClient(clientReader, clientWriter)
+		testClient(client)
+		Expect(server.initialized).To(BeTrue())
+
+		// calls fail once the server has closed the stream
+		Expect(client.Close()).To(Succeed())
+		Eventually(func() error {
+			_, err := client.ListTools(context.Background())
+			return err
+		}).Should(MatchError(ContainSubstring("closed the connection")))
+	})
+
+	It("uses the tools of servers over streamable HTTP", func() {
+		server := &stubServer{}
+		var sessions, deleted []string
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer secret"))
+			if r.Method == http.MethodDelete {
+				deleted = append(deleted, r.Header.Get("Mcp-Session-Id"))
+				return
+			}
+
+			var msg stubMessage
+			Expect(json.NewDecoder(r.Body).Decode(&msg)).To(Succeed())
+			if msg.Method == "initialize" {
+				w.Header().Set("Mcp-Session-Id", "session-1")
+			} else {
+				sessions = append(sessions, r.Header.Get("Mcp-Session-Id"))
+			}
+
+			res := server.handle(msg)
+			if res == nil {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			d, _ := json.Marshal(res)
+			if msg.Method == "tools/call" {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\": \"2.0\", \"method\": \"notifications/progress\"}\n\n")
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", d)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(d)
+		}))
+		defer httpServer.Close()
+
+		client := NewHTTPClient(httpServer.URL, map[string]string{"Authorization": "Bearer secret"}, nil)
+		testClient(client)
+		Expect(server.initialized).To(BeTrue())
+		Expect(sessions).ToNot(BeEmpty())
+		for _, s := range sessions {
+			Expect(s).To(Equal("session-1"))
+		}
+
+		Expect(client.Close()).To(Succeed())
+		Expect(deleted).To(Equal([]string{"session-1"}))
+	})
+
+	It("returns the errors of the server", func() {
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "error": {"code": -32602, "message": "unsupported protocol version"}}`))
+		}))
+		defer httpServer.Close()
+
+		_, err := NewHTTPClient(httpServer.URL, nil, nil).Initialize(context.Background())
+		Expect(err).To(MatchError("mcp error -32602: unsupported protocol version"))
+	})
+})
@@ -0,0 +1,157 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const sessionHeader = "Mcp-Session-Id"
+
+// httpTransport is the streamable HTTP transport: every message is POSTed to the endpoint of the
+// server, which replies with JSON or with a stream of server-sent events
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// NewHTTPClient returns a client of the server at the url, using the streamable HTTP transport.
+// The headers are sent with every request, e.g. for authentication.
+func NewHTTPClient(url string, headers map[string]string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return newClient(&httpTransport{url: url, headers: headers, client: client})
+}
+
+func (t *httpTransport) post(ctx context.Context, req request) (*http.Response, error) {
+	d, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(d))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	httpReq.Header.Set("Mcp-Protocol-Version", ProtocolVersion)
+	for k, v := range t.headers {
+		httpReq.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		httpReq.Header.Set(sessionHeader, t.sessionID)
+	}
+	t.mu.Unlock()
+
+	res, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("the MCP server returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	if id := res.Header.Get(sessionHeader); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	return res, nil
+}
+
+func (t *httpTransport) call(ctx context.Context, req request) (response, error) {
+	res, err := t.post(ctx, req)
+	if err != nil {
+		return response{}, err
+	}
+	defer res.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var r response
+		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+			return response{}, fmt.Errorf("invalid response of the MCP server: %w", err)
+		}
+		return r, nil
+	}
+
+	// the response is one of the events, after any notification of the server
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if d, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(d, " "))
+			}
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		var r response
+		err := json.Unmarshal([]byte(strings.Join(data, "\n")), &r)
+		data = nil
+		if err == nil && r.ID != nil && *r.ID == *req.ID && r.Method == "" {
+			return r, nil
+		}
+	}
+	if len(data) > 0 {
+		var r response
+		if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &r); err == nil && r.ID != nil && *r.ID == *req.ID {
+			return r, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return response{}, err
+	}
+	return response{}, fmt.Errorf("the MCP server did not respond to %s", req.Method)
+}
+
+func (t *httpTransport) notify(ctx context.Context, req request) error {
+	res, err := t.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, res.Body)
+	return res.Body.Close()
+}
+
+// Close terminates the session on the server
+func (t *httpTransport) Close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(sessionHeader, sessionID)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
@@ -0,0 +1,177 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// streamTransport exchanges newline delimited messages over a stream, like the standard
+// input and output of a server process
+type streamTransport struct {
+	w      io.WriteCloser
+	closer func() error
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]chan response
+	err     error
+	done    chan struct{}
+}
+
+func newStreamTransport(r io.Reader, w io.WriteCloser, closer func() error) *streamTransport {
+	t := &streamTransport{
+		w:       w,
+		closer:  closer,
+		pending: map[int64]chan response{},
+		done:    make(chan struct{}),
+	}
+	go t.read(r)
+	return t
+}
+
+// NewClient returns a client exchanging messages with a server over a stream
+func NewClient(r io.Reader, w io.WriteCloser) *Client {
+	return newClient(newStreamTransport(r, w, nil))
+}
+
+// NewStdioClient starts the server command, and returns a client using its standard input and output.
+// The process is stopped by Close.
+func NewStdioClient(command string, args []string, env map[string]string) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	cmd.Stderr = log.Logger.With().Str("mcp", command).Logger()
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the MCP server %q: %w", command, err)
+	}
+
+	return newClient(newStreamTransport(stdout, stdin, func() error {
+		if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+		cmd.Wait()
+		return nil
+	})), nil
+}
+
+func (t *streamTransport) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var res response
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			log.Debug().Err(err).Str("message", scanner.Text()).Msg("ignoring invalid MCP message")
+			continue
+		}
+		switch {
+		case res.ID != nil && res.Method != "":
+			// answered asynchronously, as the server may be blocked writing to us
+			go t.answerRequest(res)
+		case res.ID != nil:
+			t.mu.Lock()
+			ch, ok := t.pending[*res.ID]
+			delete(t.pending, *res.ID)
+			t.mu.Unlock()
+			if ok {
+				ch <- res
+			}
+		}
+		// notifications of the server are ignored
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	t.mu.Lock()
+	t.err = fmt.Errorf("the MCP server closed the connection: %w", err)
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// answerRequest answers the requests of the server: only pings are supported
+func (t *streamTransport) answerRequest(req response) {
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if req.Method == "ping" {
+		res["result"] = map[string]interface{}{}
+	} else {
+		res["error"] = Error{Code: -32601, Message: "method not found"}
+	}
+	if err := t.write(res); err != nil {
+		log.Debug().Err(err).Msg("failed to answer the MCP server")
+	}
+}
+
+func (t *streamTransport) write(msg interface{}) error {
+	d, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.w.Write(append(d, '\n'))
+	return err
+}
+
+func (t *streamTransport) call(ctx context.Context, req request) (response, error) {
+	ch := make(chan response, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return response{}, t.err
+	}
+	t.pending[*req.ID] = ch
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, *req.ID)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(req); err != nil {
+		return response{}, err
+	}
+
+	select {
+	case res := <-ch:
+		return res, nil
+	case <-t.done:
+		return response{}, t.err
+	case <-ctx.Done():
+		return response{}, ctx.Err()
+	}
+}
+
+func (t *streamTransport) notify(ctx context.Context, req request) error {
+	return t.write(req)
+}
+
+func (t *streamTransport) Close() error {
+	err := t.w.Close()
+	if t.closer != nil {
+		return t.closer()
+	}
+	return err
+}
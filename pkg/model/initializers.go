@@ -149,6 +149,8 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 			return nil, fmt.Errorf("could not load model (no success): %s", res.Message)
 		}
 
+		client.loadOptions = &options
+
 		return client, nil
 	}
 }
@@ -22,9 +22,12 @@ import (
 type ModelLoader struct {
 	ModelPath        string
 	mu               sync.Mutex
+	loadMu           sync.Mutex // serializes the loads of the models, which do not hold mu
 	singletonLock    sync.Mutex
 	singletonMode    bool
 	models           map[string]*Model
+	loading          map[string]*modelLoad
+	backgroundLoads  map[string]*modelLoad
 	wd               *WatchDog
 	externalBackends map[string]string
 }
@@ -33,6 +36,8 @@ func NewModelLoader(system *system.SystemState, singleActiveBackend bool) *Model
 	nml := &ModelLoader{
 		ModelPath:        system.Model.ModelsPath,
 		models:           make(map[string]*Model),
+		loading:          make(map[string]*modelLoad),
+		backgroundLoads:  make(map[string]*modelLoad),
 		singletonMode:    singleActiveBackend,
 		externalBackends: make(map[string]string),
 	}
@@ -68,7 +73,9 @@ func (ml *ModelLoader) GetExternalBackend(name string) string {
 
 func (ml *ModelLoader) GetAllExternalBackends(o *Options) map[string]string {
 	backends := make(map[string]string)
+	ml.mu.Lock()
 	maps.Copy(backends, ml.externalBackends)
+	ml.mu.Unlock()
 	if o != nil {
 		maps.Copy(backends, o.externalBackends)
 	}
@@ -147,18 +154,57 @@ func (ml *ModelLoader) ListLoadedModels() []*Model {
 	return models
 }
 
+// modelLoad tracks a model which is currently being loaded, so that
+// concurrent callers can wait for the same load instead of starting a new one
+type modelLoad struct {
+	done chan struct{}
+	err  error
+}
+
 func (ml *ModelLoader) LoadModel(modelID, modelName string, loader func(string, string, string) (*Model, error)) (*Model, error) {
 	// Check if we already have a loaded model
 	if model := ml.CheckIsLoaded(modelID); model != nil {
 		return model, nil
 	}
 
+	// If the model is already being loaded, wait for it. The lock is not held
+	// while loading, so that the loaded models can still be served in the meantime,
+	// but the loads of different models still happen one at a time.
+	ml.mu.Lock()
+	if l, ok := ml.loading[modelID]; ok {
+		ml.mu.Unlock()
+		log.Debug().Msgf("Model %s is already loading, waiting for it", modelID)
+		<-l.done
+		if l.err != nil {
+			return nil, l.err
+		}
+		return ml.LoadModel(modelID, modelName, loader)
+	}
+	l := &modelLoad{done: make(chan struct{})}
+	ml.loading[modelID] = l
+	ml.mu.Unlock()
+
+	ml.loadMu.Lock()
+	model, err := ml.loadModel(modelID, modelName, loader)
+	ml.loadMu.Unlock()
+
+	ml.mu.Lock()
+	if err == nil {
+		ml.models[modelID] = model
+	}
+	l.err = err
+	delete(ml.loading, modelID)
+	ml.mu.Unlock()
+	close(l.done)
+
+	return model, err
+}
+
+func (ml *ModelLoader) loadModel(modelID, modelName string, loader func(string, string, string) (*Model, error)) (*Model, error) {
 	// Load the model and keep it in memory for later use
 	modelFile := filepath.Join(ml.ModelPath, modelName)
 	log.Debug().Msgf("Loading model in memory from file: %s", modelFile)
 
-	ml.mu.Lock()
-	defer ml.mu.Unlock()
 	model, err := loader(modelID, modelName, modelFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load model with internal loader: %s", err)
@@ -168,11 +214,177 @@ func (ml *ModelLoader) LoadModel(modelID, modelName string, loader func(string,
 		return nil, fmt.Errorf("loader didn't return a model")
 	}
 
-	ml.models[modelID] = model
-
 	return model, nil
 }
 
+// BackgroundLoad loads the model in the background. If the model is already being loaded in the
+// background, the load in progress is joined instead of starting a new one. The returned channel
+// is closed once the load completes; failures are logged.
+func (ml *ModelLoader) BackgroundLoad(opts ...Option) <-chan struct{} {
+	modelID := NewOptions(opts...).modelID
+
+	ml.mu.Lock()
+	if l, ok := ml.backgroundLoads[modelID]; ok {
+		ml.mu.Unlock()
+		return l.done
+	}
+	l := &modelLoad{done: make(chan struct{})}
+	ml.backgroundLoads[modelID] = l
+	ml.mu.Unlock()
+
+	go func() {
+		_, err := ml.Load(opts...)
+		if err != nil {
+			log.Error().Err(err).Str("model", modelID).Msg("failed loading model in the background")
+		}
+
+		ml.mu.Lock()
+		l.err = err
+		delete(ml.backgroundLoads, modelID)
+		ml.mu.Unlock()
+		close(l.done)
+	}()
+
+	return l.done
+}
+
+// IsLoaded returns true if the model is resident in memory. Unlike
+// CheckIsLoaded it does not health-check the backend, so it is cheap
+// enough to be called on every request.
+func (ml *ModelLoader) IsLoaded(modelID string) bool {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	_, ok := ml.models[modelID]
+	return ok
+}
+
+// healthCheckMaxAge is how long the result of a health check is reused by IsHealthy
+const healthCheckMaxAge = 10 * time.Second
+
+// IsHealthy returns false if the model is loaded but its backend failed its last
+// health check. It does not wait for the backend: when the last check is older than
+// healthCheckMaxAge, a new one is started in the background. Models which are not
+// loaded are considered healthy, as they are loaded on demand.
+func (ml *ModelLoader) IsHealthy(modelID string) bool {
+	ml.mu.Lock()
+	m, ok := ml.models[modelID]
+	ml.mu.Unlock()
+	if !ok {
+		return true
+	}
+
+	healthy, check := m.cachedHealth(healthCheckMaxAge)
+	if check {
+		go ml.checkHealth(m)
+	}
+	return healthy
+}
+
+// CheckHealth runs a health check against the backend of the model, and returns
+// its result. Models which are not loaded are considered healthy.
+func (ml *ModelLoader) CheckHealth(modelID string) bool {
+	ml.mu.Lock()
+	m, ok := ml.models[modelID]
+	ml.mu.Unlock()
+	if !ok {
+		return true
+	}
+	return ml.checkHealth(m)
+}
+
+func (ml *ModelLoader) checkHealth(m *Model) bool {
+	alive := ml.healthCheck(m)
+	m.setHealth(alive)
+	return alive
+}
+
+func (ml *ModelLoader) healthCheck(m *Model) bool {
+	client := m.GRPC(false, ml.wd)
+	// A busy backend is serving requests: don't wait for it to be free
+	if client.IsBusy() {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	alive, err := client.HealthCheck(ctx)
+	if !alive {
+		log.Debug().Err(err).Msgf("Model %s is not healthy", m.ID)
+	}
+	return alive
+}
+
+// IsLoading returns true if the model is currently being loaded
+func (ml *ModelLoader) IsLoading(modelID string) bool {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	_, ok := ml.loading[modelID]
+	return ok
+}
+
+// suspendTimeout bounds the time the backend takes to unload the model
+const suspendTimeout = 30 * time.Second
+
+// SuspendModel frees the memory used by a model while keeping its backend running,
+// so that it can be resumed faster than a full reload. It fails with ErrModelInUse
+// if the backend is serving requests, and with another error if the backend does
+// not support it.
+func (ml *ModelLoader) SuspendModel(modelName string) error {
+	ml.mu.Lock()
+	m, ok := ml.models[modelName]
+	ml.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("model %s not found", modelName)
+	}
+
+	// requests resuming the model wait for the suspension to complete
+	m.resumeMu.Lock()
+	defer m.resumeMu.Unlock()
+
+	if m.IsSuspended() {
+		return nil
+	}
+
+	if m.loadOptions == nil {
+		return fmt.Errorf("model %s cannot be resumed", modelName)
+	}
+
+	client := m.GRPC(false, ml.wd)
+	if m.inUse() || client.IsBusy() {
+		return fmt.Errorf("model %s: %w", modelName, ErrModelInUse)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), suspendTimeout)
+	defer cancel()
+	res, err := client.Unload(ctx)
+	if err != nil {
+		return fmt.Errorf("could not suspend model %s: %w", modelName, err)
+	}
+	if !res.Success {
+		return fmt.Errorf("could not suspend model %s (no success): %s", modelName, res.Message)
+	}
+
+	m.setSuspended(true)
+	log.Debug().Msgf("Model %s suspended", modelName)
+
+	return nil
+}
+
+// IsSuspended returns true if the model is loaded but suspended
+func (ml *ModelLoader) IsSuspended(modelName string) bool {
+	ml.mu.Lock()
+	m, ok := ml.models[modelName]
+	ml.mu.Unlock()
+	return ok && m.IsSuspended()
+}
+
+func (ml *ModelLoader) resumeModel(m *Model) error {
+	m.resumeMu.Lock()
+	defer m.resumeMu.Unlock()
+	return m.resume()
+}
+
 func (ml *ModelLoader) ShutdownModel(modelName string) error {
 	ml.mu.Lock()
 	defer ml.mu.Unlock()
@@ -180,7 +392,26 @@ func (ml *ModelLoader) ShutdownModel(modelName string) error {
 	return ml.deleteProcess(modelName)
 }
 
+// CheckIsLoaded returns the model if it is loaded and its backend is alive.
+// Suspended models are resumed before being returned.
 func (ml *ModelLoader) CheckIsLoaded(s string) *Model {
+	m := ml.checkIsLoaded(s)
+	if m == nil || !m.IsSuspended() {
+		return m
+	}
+
+	if err := ml.resumeModel(m); err != nil {
+		log.Error().Err(err).Str("model", s).Msg("failed resuming suspended model, it will be loaded again")
+		if err := ml.ShutdownModel(s); err != nil {
+			log.Error().Err(err).Str("model", s).Msg("error stopping process")
+		}
+		return nil
+	}
+
+	return m
+}
+
+func (ml *ModelLoader) checkIsLoaded(s string) *Model {
 	ml.mu.Lock()
 	defer ml.mu.Unlock()
 	m, ok := ml.models[s]
@@ -1,10 +1,15 @@
 package model_test
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/grpc/base"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/system"
 	. "github.com/onsi/ginkgo/v2"
@@ -90,6 +95,108 @@ var _ = Describe("ModelLoader", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(model).To(BeNil())
 		})
+
+		It("should share a single load between concurrent callers", func() {
+			mockModel = model.NewModel("foo", "test.model", nil)
+			release := make(chan struct{})
+			var calls atomic.Int32
+
+			mockLoader := func(modelID, modelName, modelFile string) (*model.Model, error) {
+				calls.Add(1)
+				<-release
+				return mockModel, nil
+			}
+
+			results := make(chan *model.Model, 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					defer GinkgoRecover()
+					m, err := modelLoader.LoadModel("foo", "test.model", mockLoader)
+					Expect(err).ToNot(HaveOccurred())
+					results <- m
+				}()
+			}
+
+			Eventually(func() bool { return modelLoader.IsLoading("foo") }).Should(BeTrue())
+			Expect(modelLoader.IsLoaded("foo")).To(BeFalse())
+			// other models can be looked up while "foo" is loading
+			Expect(modelLoader.CheckIsLoaded("bar")).To(BeNil())
+
+			close(release)
+			Eventually(results).Should(Receive(Equal(mockModel)))
+			Eventually(results).Should(Receive(Equal(mockModel)))
+			Expect(calls.Load()).To(Equal(int32(1)))
+			Expect(modelLoader.IsLoading("foo")).To(BeFalse())
+			Expect(modelLoader.IsLoaded("foo")).To(BeTrue())
+		})
+
+		It("should load different models one at a time", func() {
+			release := make(chan struct{})
+			var loading, maxLoading atomic.Int32
+
+			mockLoader := func(modelID, modelName, modelFile string) (*model.Model, error) {
+				n := loading.Add(1)
+				defer loading.Add(-1)
+				if n > maxLoading.Load() {
+					maxLoading.Store(n)
+				}
+				<-release
+				return model.NewModel(modelID, modelName, nil), nil
+			}
+
+			done := make(chan string, 2)
+			for _, id := range []string{"foo", "bar"} {
+				go func() {
+					defer GinkgoRecover()
+					_, err := modelLoader.LoadModel(id, "test.model", mockLoader)
+					Expect(err).ToNot(HaveOccurred())
+					done <- id
+				}()
+			}
+
+			Eventually(func() bool { return modelLoader.IsLoading("foo") && modelLoader.IsLoading("bar") }).Should(BeTrue())
+			Consistently(loading.Load).Should(Equal(int32(1)))
+
+			close(release)
+			Eventually(done).Should(Receive())
+			Eventually(done).Should(Receive())
+			Expect(maxLoading.Load()).To(Equal(int32(1)))
+			Expect(modelLoader.IsLoaded("foo")).To(BeTrue())
+			Expect(modelLoader.IsLoaded("bar")).To(BeTrue())
+		})
+	})
+
+	Context("IsHealthy", func() {
+		It("should report the last health check without waiting for the backend", func() {
+			mockLoader := func(modelID, modelName, modelFile string) (*model.Model, error) {
+				// nothing listens on this address
+				return model.NewModel("foo", "127.0.0.1:1", nil), nil
+			}
+			_, err := modelLoader.LoadModel("foo", "test.model", mockLoader)
+			Expect(err).ToNot(HaveOccurred())
+
+			// the backend was not checked yet
+			Expect(modelLoader.IsHealthy("foo")).To(BeTrue())
+			Eventually(func() bool { return modelLoader.IsHealthy("foo") }, "10s").Should(BeFalse())
+			Expect(modelLoader.CheckHealth("foo")).To(BeFalse())
+
+			// models which are not loaded are loaded on demand
+			Expect(modelLoader.IsHealthy("bar")).To(BeTrue())
+			Expect(modelLoader.CheckHealth("bar")).To(BeTrue())
+		})
+
+		It("should report healthy backends", func() {
+			grpc.Provide("healthy", &loadableBackend{})
+			_, err := modelLoader.Load(
+				model.WithBackendString("fake"),
+				model.WithExternalBackend("fake", "healthy"),
+				model.WithModel("healthy"),
+				model.WithModelID("healthy"),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(modelLoader.CheckHealth("healthy")).To(BeTrue())
+			Expect(modelLoader.IsHealthy("healthy")).To(BeTrue())
+		})
 	})
 
 	Context("ShutdownModel", func() {
@@ -106,4 +213,115 @@ var _ = Describe("ModelLoader", func() {
 			Expect(modelLoader.CheckIsLoaded("foo")).To(BeNil())
 		})
 	})
+
+	Context("SuspendModel", func() {
+		load := func(name string, llm grpc.AIModel) error {
+			grpc.Provide(name, llm)
+			_, err := modelLoader.Load(
+				model.WithBackendString("fake"),
+				model.WithExternalBackend("fake", name),
+				model.WithModel(name),
+				model.WithModelID(name),
+			)
+			return err
+		}
+
+		It("should suspend and resume backends which support it", func() {
+			llm := &suspendableBackend{}
+			Expect(load("suspendable", llm)).To(Succeed())
+			Expect(llm.loads).To(Equal(1))
+
+			Expect(modelLoader.SuspendModel("suspendable")).To(Succeed())
+			Expect(llm.unloads).To(Equal(1))
+			Expect(modelLoader.IsSuspended("suspendable")).To(BeTrue())
+			Expect(modelLoader.IsLoaded("suspendable")).To(BeTrue())
+
+			// using the model again resumes it
+			Expect(modelLoader.CheckIsLoaded("suspendable")).ToNot(BeNil())
+			Expect(llm.loads).To(Equal(2))
+			Expect(modelLoader.IsSuspended("suspendable")).To(BeFalse())
+		})
+
+		It("should resume suspended models when their backend is used", func() {
+			llm := &suspendableBackend{}
+			Expect(load("resumed-on-use", llm)).To(Succeed())
+			backend := modelLoader.ListLoadedModels()[0].GRPC(false, nil)
+
+			Expect(modelLoader.SuspendModel("resumed-on-use")).To(Succeed())
+			_, err := backend.Predict(context.Background(), &pb.PredictOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(llm.loads).To(Equal(2))
+			Expect(llm.predictions).To(Equal(1))
+			Expect(modelLoader.IsSuspended("resumed-on-use")).To(BeFalse())
+		})
+
+		It("should not suspend models with calls in flight", func() {
+			llm := &suspendableBackend{started: make(chan struct{}), predicting: make(chan struct{})}
+			Expect(load("in-use", llm)).To(Succeed())
+			backend := modelLoader.ListLoadedModels()[0].GRPC(false, nil)
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := backend.Predict(context.Background(), &pb.PredictOptions{})
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			<-llm.started
+
+			Expect(modelLoader.SuspendModel("in-use")).To(MatchError(model.ErrModelInUse))
+			Expect(llm.unloads).To(Equal(0))
+
+			close(llm.predicting)
+			<-done
+			Expect(modelLoader.SuspendModel("in-use")).To(Succeed())
+			Expect(llm.unloads).To(Equal(1))
+		})
+
+		It("should fail for backends which do not support it", func() {
+			Expect(load("not-suspendable", &loadableBackend{})).To(Succeed())
+			Expect(modelLoader.SuspendModel("not-suspendable")).ToNot(Succeed())
+			Expect(modelLoader.IsSuspended("not-suspendable")).To(BeFalse())
+		})
+
+		It("should fail for models which are not loaded", func() {
+			Expect(modelLoader.SuspendModel("foo")).ToNot(Succeed())
+		})
+	})
 })
+
+type loadableBackend struct {
+	base.SingleThread
+	loads int
+}
+
+func (b *loadableBackend) Load(*pb.ModelOptions) error {
+	b.loads++
+	return nil
+}
+
+type suspendableBackend struct {
+	loadableBackend
+	unloads     int
+	predictions int
+	// started, if set, is closed when a prediction starts
+	started chan struct{}
+	// predicting, if set, blocks predictions until it is closed
+	predicting chan struct{}
+}
+
+func (b *suspendableBackend) Predict(*pb.PredictOptions) (string, error) {
+	if b.started != nil {
+		close(b.started)
+	}
+	if b.predicting != nil {
+		<-b.predicting
+	}
+	b.predictions++
+	return "", nil
+}
+
+func (b *suspendableBackend) Unload() error {
+	b.unloads++
+	return nil
+}
@@ -2,8 +2,10 @@ package model
 
 import (
 	"sync"
+	"time"
 
 	grpc "github.com/mudler/LocalAI/pkg/grpc"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	process "github.com/mudler/go-processmanager"
 )
 
@@ -13,6 +15,19 @@ type Model struct {
 	client  grpc.Backend
 	process *process.Process
 	sync.Mutex
+
+	// loadOptions are the options the model was loaded with,
+	// used to load it again after it has been suspended
+	loadOptions *pb.ModelOptions
+	suspended   bool
+	resumeMu    sync.Mutex
+	// inFlight counts the calls running on the backend, see resumingBackend
+	inFlight int
+
+	// result of the last health check of the backend, see ModelLoader.IsHealthy
+	unhealthy      bool
+	healthCheckAt  time.Time
+	healthChecking bool
 }
 
 func NewModel(ID, address string, process *process.Process) *Model {
@@ -27,18 +42,45 @@ func (m *Model) Process() *process.Process {
 	return m.process
 }
 
-func (m *Model) GRPC(parallel bool, wd *WatchDog) grpc.Backend {
-	if m.client != nil {
-		return m.client
-	}
+// IsSuspended returns true if the model has been unloaded from its backend,
+// which is still running
+func (m *Model) IsSuspended() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.suspended
+}
+
+func (m *Model) setSuspended(s bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.suspended = s
+}
 
-	enableWD := false
-	if wd != nil {
-		enableWD = true
+// cachedHealth returns the result of the last health check, and whether a new check
+// should be started because it is older than maxAge. Only one caller is asked to check.
+func (m *Model) cachedHealth(maxAge time.Duration) (healthy bool, check bool) {
+	m.Lock()
+	defer m.Unlock()
+	check = !m.healthChecking && time.Since(m.healthCheckAt) > maxAge
+	if check {
+		m.healthChecking = true
 	}
+	return !m.unhealthy, check
+}
+
+func (m *Model) setHealth(healthy bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.unhealthy = !healthy
+	m.healthCheckAt = time.Now()
+	m.healthChecking = false
+}
 
+func (m *Model) GRPC(parallel bool, wd *WatchDog) grpc.Backend {
 	m.Lock()
 	defer m.Unlock()
-	m.client = grpc.NewClient(m.address, parallel, wd, enableWD)
-	return m.client
+	if m.client == nil {
+		m.client = grpc.NewClient(m.address, parallel, wd, wd != nil)
+	}
+	return &resumingBackend{Backend: m.client, model: m}
 }
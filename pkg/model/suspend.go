@@ -0,0 +1,167 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	grpc "github.com/mudler/LocalAI/pkg/grpc"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	ggrpc "google.golang.org/grpc"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrModelInUse is returned when suspending a model which is serving requests
+var ErrModelInUse = errors.New("model is in use")
+
+// resume loads the model again in its backend if it is suspended. resumeMu must be held.
+func (m *Model) resume() error {
+	// another caller might have resumed it in the meantime
+	if !m.IsSuspended() {
+		return nil
+	}
+
+	log.Debug().Msgf("Resuming suspended model %s", m.ID)
+	m.Lock()
+	client := m.client
+	m.Unlock()
+	res, err := client.LoadModel(context.Background(), m.loadOptions)
+	if err != nil {
+		return fmt.Errorf("could not resume model: %w", err)
+	}
+	if !res.Success {
+		return fmt.Errorf("could not resume model (no success): %s", res.Message)
+	}
+
+	m.setSuspended(false)
+	return nil
+}
+
+// acquire resumes the model if needed, and keeps it from being suspended until release is called
+func (m *Model) acquire() error {
+	m.resumeMu.Lock()
+	defer m.resumeMu.Unlock()
+	if err := m.resume(); err != nil {
+		return err
+	}
+
+	m.Lock()
+	m.inFlight++
+	m.Unlock()
+	return nil
+}
+
+func (m *Model) release() {
+	m.Lock()
+	m.inFlight--
+	m.Unlock()
+}
+
+func (m *Model) inUse() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.inFlight > 0
+}
+
+// resumingBackend resumes a suspended model before the calls which need the model
+// to be loaded, so that the watchdog cannot suspend it between the time a request
+// gets the backend and the time it uses it
+type resumingBackend struct {
+	grpc.Backend
+	model *Model
+}
+
+func use[T any](b *resumingBackend, call func() (T, error)) (T, error) {
+	if err := b.model.acquire(); err != nil {
+		var zero T
+		return zero, err
+	}
+	defer b.model.release()
+	return call()
+}
+
+func (b *resumingBackend) Embeddings(ctx context.Context, in *pb.PredictOptions, opts ...ggrpc.CallOption) (*pb.EmbeddingResult, error) {
+	return use(b, func() (*pb.EmbeddingResult, error) { return b.Backend.Embeddings(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) EmbeddingsBatch(ctx context.Context, in *pb.PredictOptions, opts ...ggrpc.CallOption) (*pb.EmbeddingBatchResult, error) {
+	return use(b, func() (*pb.EmbeddingBatchResult, error) { return b.Backend.EmbeddingsBatch(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) PredictStream(ctx context.Context, in *pb.PredictOptions, f func(reply *pb.Reply), opts ...ggrpc.CallOption) error {
+	_, err := use(b, func() (struct{}, error) { return struct{}{}, b.Backend.PredictStream(ctx, in, f, opts...) })
+	return err
+}
+
+func (b *resumingBackend) Predict(ctx context.Context, in *pb.PredictOptions, opts ...ggrpc.CallOption) (*pb.Reply, error) {
+	return use(b, func() (*pb.Reply, error) { return b.Backend.Predict(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) GenerateImage(ctx context.Context, in *pb.GenerateImageRequest, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	return use(b, func() (*pb.Result, error) { return b.Backend.GenerateImage(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) GenerateVideo(ctx context.Context, in *pb.GenerateVideoRequest, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	return use(b, func() (*pb.Result, error) { return b.Backend.GenerateVideo(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) TTS(ctx context.Context, in *pb.TTSRequest, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	return use(b, func() (*pb.Result, error) { return b.Backend.TTS(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) SoundGeneration(ctx context.Context, in *pb.SoundGenerationRequest, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	return use(b, func() (*pb.Result, error) { return b.Backend.SoundGeneration(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) Detect(ctx context.Context, in *pb.DetectOptions, opts ...ggrpc.CallOption) (*pb.DetectResponse, error) {
+	return use(b, func() (*pb.DetectResponse, error) { return b.Backend.Detect(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) AudioTranscription(ctx context.Context, in *pb.TranscriptRequest, opts ...ggrpc.CallOption) (*pb.TranscriptResult, error) {
+	return use(b, func() (*pb.TranscriptResult, error) { return b.Backend.AudioTranscription(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) TokenizeString(ctx context.Context, in *pb.PredictOptions, opts ...ggrpc.CallOption) (*pb.TokenizationResponse, error) {
+	return use(b, func() (*pb.TokenizationResponse, error) { return b.Backend.TokenizeString(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) StoresSet(ctx context.Context, in *pb.StoresSetOptions, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	return use(b, func() (*pb.Result, error) { return b.Backend.StoresSet(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) StoresDelete(ctx context.Context, in *pb.StoresDeleteOptions, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	return use(b, func() (*pb.Result, error) { return b.Backend.StoresDelete(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) StoresGet(ctx context.Context, in *pb.StoresGetOptions, opts ...ggrpc.CallOption) (*pb.StoresGetResult, error) {
+	return use(b, func() (*pb.StoresGetResult, error) { return b.Backend.StoresGet(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) StoresFind(ctx context.Context, in *pb.StoresFindOptions, opts ...ggrpc.CallOption) (*pb.StoresFindResult, error) {
+	return use(b, func() (*pb.StoresFindResult, error) { return b.Backend.StoresFind(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) StoresStats(ctx context.Context, in *pb.StoresStatsOptions, opts ...ggrpc.CallOption) (*pb.StoresStatsResult, error) {
+	return use(b, func() (*pb.StoresStatsResult, error) { return b.Backend.StoresStats(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) StoresDrop(ctx context.Context, in *pb.StoresDropOptions, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	return use(b, func() (*pb.Result, error) { return b.Backend.StoresDrop(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) StoresExport(ctx context.Context, in *pb.StoresExportOptions, opts ...ggrpc.CallOption) (*pb.StoresExportResult, error) {
+	return use(b, func() (*pb.StoresExportResult, error) { return b.Backend.StoresExport(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) Rerank(ctx context.Context, in *pb.RerankRequest, opts ...ggrpc.CallOption) (*pb.RerankResult, error) {
+	return use(b, func() (*pb.RerankResult, error) { return b.Backend.Rerank(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) GetTokenMetrics(ctx context.Context, in *pb.MetricsRequest, opts ...ggrpc.CallOption) (*pb.MetricsResponse, error) {
+	return use(b, func() (*pb.MetricsResponse, error) { return b.Backend.GetTokenMetrics(ctx, in, opts...) })
+}
+
+func (b *resumingBackend) VAD(ctx context.Context, in *pb.VADRequest, opts ...ggrpc.CallOption) (*pb.VADResponse, error) {
+	return use(b, func() (*pb.VADResponse, error) { return b.Backend.VAD(ctx, in, opts...) })
+}
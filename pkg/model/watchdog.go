@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"sync"
 	"time"
 
@@ -13,7 +14,9 @@ import (
 // watchdog that will keep track of the state of each backend (busy or not)
 // and for how much time it has been busy.
 // If a backend is busy for too long, the watchdog will kill the process and
-// force a reload of the model
+// force a reload of the model.
+// If a backend is idle for too long, the watchdog either kills the process, or
+// suspends it if idleSuspend is set and the backend supports it
 // The watchdog runs as a separate go routine,
 // and the GRPC client talks to it via a channel to send status updates
 type WatchDog struct {
@@ -26,14 +29,15 @@ type WatchDog struct {
 	pm                   ProcessManager
 	stop                 chan bool
 
-	busyCheck, idleCheck bool
+	busyCheck, idleCheck, idleSuspend bool
 }
 
 type ProcessManager interface {
 	ShutdownModel(modelName string) error
+	SuspendModel(modelName string) error
 }
 
-func NewWatchDog(pm ProcessManager, timeoutBusy, timeoutIdle time.Duration, busy, idle bool) *WatchDog {
+func NewWatchDog(pm ProcessManager, timeoutBusy, timeoutIdle time.Duration, busy, idle, idleSuspend bool) *WatchDog {
 	return &WatchDog{
 		timeout:         timeoutBusy,
 		idletimeout:     timeoutIdle,
@@ -43,6 +47,7 @@ func NewWatchDog(pm ProcessManager, timeoutBusy, timeoutIdle time.Duration, busy
 		addressMap:      make(map[string]*process.Process),
 		busyCheck:       busy,
 		idleCheck:       idle,
+		idleSuspend:     idleSuspend,
 		addressModelMap: make(map[string]string),
 	}
 }
@@ -102,27 +107,73 @@ func (wd *WatchDog) Run() {
 	}
 }
 
+// idleModel is a model idle for too long, with the time it became idle at
+type idleModel struct {
+	address, model string
+	resolved       bool
+	since          time.Time
+}
+
 func (wd *WatchDog) checkIdle() {
 	wd.Lock()
-	defer wd.Unlock()
 	log.Debug().Msg("[WatchDog] Watchdog checks for idle connections")
+	var idle []idleModel
 	for address, t := range wd.idleTime {
 		log.Debug().Msgf("[WatchDog] %s: idle connection", address)
 		if time.Since(t) > wd.idletimeout {
-			log.Warn().Msgf("[WatchDog] Address %s is idle for too long, killing it", address)
 			model, ok := wd.addressModelMap[address]
-			if ok {
-				if err := wd.pm.ShutdownModel(model); err != nil {
-					log.Error().Err(err).Str("model", model).Msg("[watchdog] error shutting down model")
-				}
-				log.Debug().Msgf("[WatchDog] model shut down: %s", address)
-				delete(wd.idleTime, address)
-				delete(wd.addressModelMap, address)
-				delete(wd.addressMap, address)
-			} else {
-				log.Warn().Msgf("[WatchDog] Address %s unresolvable", address)
+			idle = append(idle, idleModel{address: address, model: model, resolved: ok, since: t})
+		}
+	}
+	wd.Unlock()
+
+	// Suspending a model can take as long as the backend takes to unload it, so it is done without holding
+	// the lock, which would block the requests marking their models busy or idle meanwhile
+	suspended := make([]error, len(idle))
+	for i, m := range idle {
+		if m.resolved && wd.idleSuspend {
+			suspended[i] = wd.pm.SuspendModel(m.model)
+		}
+	}
+
+	wd.Lock()
+	defer wd.Unlock()
+	for i, m := range idle {
+		if t, ok := wd.idleTime[m.address]; !ok || !t.Equal(m.since) {
+			// the model was used while it was being suspended
+			continue
+		}
+
+		address, model := m.address, m.model
+		if m.resolved && wd.idleSuspend {
+			// The backend stays up, so we keep tracking its address:
+			// it is marked idle again once it is resumed and used
+			err := suspended[i]
+			if err == nil {
+				log.Info().Msgf("[WatchDog] Model %s is idle for too long, suspended it", model)
 				delete(wd.idleTime, address)
+				continue
+			}
+			if errors.Is(err, ErrModelInUse) {
+				// a request is about to use it: it is not idle anymore
+				log.Debug().Str("model", model).Msg("[WatchDog] model is in use, not suspending it")
+				wd.idleTime[address] = time.Now()
+				continue
+			}
+			log.Debug().Err(err).Str("model", model).Msg("[WatchDog] unable to suspend model, shutting it down")
+		}
+		log.Warn().Msgf("[WatchDog] Address %s is idle for too long, killing it", address)
+		if m.resolved {
+			if err := wd.pm.ShutdownModel(model); err != nil {
+				log.Error().Err(err).Str("model", model).Msg("[watchdog] error shutting down model")
 			}
+			log.Debug().Msgf("[WatchDog] model shut down: %s", address)
+			delete(wd.idleTime, address)
+			delete(wd.addressModelMap, address)
+			delete(wd.addressMap, address)
+		} else {
+			log.Warn().Msgf("[WatchDog] Address %s unresolvable", address)
+			delete(wd.idleTime, address)
 		}
 	}
 }
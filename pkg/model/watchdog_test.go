@@ -0,0 +1,78 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// blockingProcessManager signals suspending when it starts suspending a model, and suspends it once release
+// is closed
+type blockingProcessManager struct {
+	mu         sync.Mutex
+	suspended  []string
+	shutdown   []string
+	suspending chan struct{}
+	release    chan struct{}
+}
+
+func (pm *blockingProcessManager) SuspendModel(modelName string) error {
+	pm.suspending <- struct{}{}
+	<-pm.release
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.suspended = append(pm.suspended, modelName)
+	return nil
+}
+
+func (pm *blockingProcessManager) ShutdownModel(modelName string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.shutdown = append(pm.shutdown, modelName)
+	return nil
+}
+
+var _ = Describe("WatchDog", func() {
+	var (
+		pm *blockingProcessManager
+		wd *WatchDog
+	)
+
+	BeforeEach(func() {
+		pm = &blockingProcessManager{suspending: make(chan struct{}, 1), release: make(chan struct{})}
+		wd = NewWatchDog(pm, time.Minute, time.Millisecond, false, true, true)
+		wd.AddAddressModelMap("127.0.0.1:1", "used")
+		wd.UnMark("127.0.0.1:1")
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	It("does not block the requests while suspending the idle models", func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			wd.checkIdle()
+		}()
+
+		// the model is used while the watchdog suspends it
+		Eventually(pm.suspending).Should(Receive())
+		marked := make(chan struct{})
+		go func() {
+			defer close(marked)
+			wd.Mark("127.0.0.1:1")
+		}()
+		Eventually(marked).Should(BeClosed())
+		Consistently(done).ShouldNot(BeClosed())
+
+		close(pm.release)
+		Eventually(done).Should(BeClosed())
+		Expect(pm.suspended).To(Equal([]string{"used"}))
+		Expect(pm.shutdown).To(BeEmpty())
+
+		wd.Lock()
+		defer wd.Unlock()
+		Expect(wd.idleTime).To(BeEmpty())
+		Expect(wd.timetable).To(HaveKey("127.0.0.1:1"))
+	})
+})
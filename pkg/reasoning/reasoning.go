@@ -0,0 +1,155 @@
+package reasoning
+
+import (
+	"strings"
+)
+
+// Extract splits the output of a model in its reasoning, delimited by startTag and endTag, and the content of the reply.
+// If open is true the output starts inside the reasoning, as the prompt already ends with startTag.
+// An end tag without a start tag is also considered to close reasoning started by the prompt.
+func Extract(output, startTag, endTag string, open bool) (string, string) {
+	if startTag == "" || endTag == "" {
+		return "", output
+	}
+
+	if !open {
+		end := strings.Index(output, endTag)
+		start := strings.Index(output, startTag)
+		open = end >= 0 && (start < 0 || end < start)
+		if !open && start < 0 {
+			// no reasoning: the output is left as it is
+			return "", output
+		}
+	}
+
+	var reasoning, content []string
+	rest := output
+	for rest != "" {
+		if open {
+			idx := strings.Index(rest, endTag)
+			if idx < 0 {
+				// the reasoning was not closed, e.g. the generation was truncated
+				reasoning = append(reasoning, strings.TrimSpace(rest))
+				break
+			}
+			reasoning = append(reasoning, strings.TrimSpace(rest[:idx]))
+			rest = rest[idx+len(endTag):]
+			open = false
+			continue
+		}
+
+		idx := strings.Index(rest, startTag)
+		if idx < 0 {
+			content = append(content, rest)
+			break
+		}
+		content = append(content, rest[:idx])
+		rest = rest[idx+len(startTag):]
+		open = true
+	}
+
+	return strings.Join(nonEmpty(reasoning), "\n\n"), strings.TrimSpace(strings.Join(content, ""))
+}
+
+// IsOpen reports whether output ends inside a reasoning block
+func IsOpen(output, startTag, endTag string, open bool) bool {
+	if startTag == "" || endTag == "" {
+		return false
+	}
+	start := strings.LastIndex(output, startTag)
+	end := strings.LastIndex(output, endTag)
+	if start < 0 && end < 0 {
+		return open
+	}
+	return start > end
+}
+
+func nonEmpty(s []string) []string {
+	var res []string
+	for _, v := range s {
+		if v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Parser splits streamed tokens between reasoning and content. Tags split across
+// tokens are held back until they can be told apart from the text.
+type Parser struct {
+	startTag, endTag string
+	inReasoning      bool
+	// trim is set at the beginning of a block, to drop the whitespace following the tags
+	trim   bool
+	buffer string
+}
+
+// NewParser returns a parser for reasoning delimited by startTag and endTag.
+// If open is true the output starts inside the reasoning.
+func NewParser(startTag, endTag string, open bool) *Parser {
+	return &Parser{startTag: startTag, endTag: endTag, inReasoning: open, trim: true}
+}
+
+// Feed processes a token, returning the reasoning and the content that can be sent
+func (p *Parser) Feed(token string) (string, string) {
+	if p.startTag == "" || p.endTag == "" {
+		return "", token
+	}
+
+	p.buffer += token
+	var reasoning, content strings.Builder
+	for {
+		tag := p.startTag
+		out := &content
+		if p.inReasoning {
+			tag = p.endTag
+			out = &reasoning
+		}
+
+		if idx := strings.Index(p.buffer, tag); idx >= 0 {
+			p.write(out, p.buffer[:idx])
+			p.buffer = p.buffer[idx+len(tag):]
+			p.inReasoning = !p.inReasoning
+			p.trim = true
+			continue
+		}
+
+		// keep what might be the beginning of the tag
+		keep := partialSuffix(p.buffer, tag)
+		p.write(out, p.buffer[:len(p.buffer)-keep])
+		p.buffer = p.buffer[len(p.buffer)-keep:]
+		return reasoning.String(), content.String()
+	}
+}
+
+// Flush returns what is left once the generation is over
+func (p *Parser) Flush() (string, string) {
+	var out strings.Builder
+	p.write(&out, p.buffer)
+	p.buffer = ""
+	if p.inReasoning {
+		return out.String(), ""
+	}
+	return "", out.String()
+}
+
+func (p *Parser) write(out *strings.Builder, s string) {
+	if p.trim {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return
+		}
+		p.trim = false
+	}
+	out.WriteString(s)
+}
+
+// partialSuffix returns the length of the longest suffix of s which is a prefix of tag
+func partialSuffix(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
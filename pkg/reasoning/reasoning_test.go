@@ -0,0 +1,66 @@
+package reasoning_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/LocalAI/pkg/reasoning"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reasoning", func() {
+	DescribeTable("Extract() splits the reasoning from the content",
+		func(output string, open bool, expectedReasoning, expectedContent string) {
+			reasoning, content := Extract(output, "<think>", "</think>", open)
+			Expect(reasoning).To(Equal(expectedReasoning))
+			Expect(content).To(Equal(expectedContent))
+		},
+		Entry("without reasoning", "Hello!", false, "", "Hello!"),
+		Entry("without reasoning, keeping the whitespace", "\n  Hello!\n", false, "", "\n  Hello!\n"),
+		Entry("with reasoning", "<think>\nThe user greets me.\n</think>\n\nHello!", false, "The user greets me.", "Hello!"),
+		Entry("with reasoning started by the prompt", "The user greets me.\n</think>\n\nHello!", true, "The user greets me.", "Hello!"),
+		Entry("with a closing tag only", "The user greets me.</think>Hello!", false, "The user greets me.", "Hello!"),
+		Entry("with truncated reasoning", "<think>The user", false, "The user", ""),
+		Entry("with empty reasoning", "<think>\n\n</think>\n\nHello!", false, "", "Hello!"),
+		Entry("with multiple blocks", "<think>a</think>Hello<think>b</think> world", false, "a\n\nb", "Hello world"),
+	)
+
+	It("IsOpen() reports unterminated reasoning", func() {
+		Expect(IsOpen("<think>The user", "<think>", "</think>", false)).To(BeTrue())
+		Expect(IsOpen("<think>a</think>Hello", "<think>", "</think>", false)).To(BeFalse())
+		Expect(IsOpen("The user", "<think>", "</think>", true)).To(BeTrue())
+		Expect(IsOpen("The user</think>Hello", "<think>", "</think>", true)).To(BeFalse())
+		Expect(IsOpen("Hello", "<think>", "</think>", false)).To(BeFalse())
+	})
+
+	DescribeTable("Parser splits streamed tokens",
+		func(tokens []string, open bool, expectedReasoning, expectedContent string) {
+			p := NewParser("<think>", "</think>", open)
+			var reasoning, content strings.Builder
+			for _, t := range tokens {
+				r, c := p.Feed(t)
+				reasoning.WriteString(r)
+				content.WriteString(c)
+			}
+			r, c := p.Flush()
+			reasoning.WriteString(r)
+			content.WriteString(c)
+
+			Expect(reasoning.String()).To(Equal(expectedReasoning))
+			Expect(content.String()).To(Equal(expectedContent))
+		},
+		Entry("without reasoning", []string{"Hel", "lo", "!"}, false, "", "Hello!"),
+		Entry("with tags in single tokens", []string{"<think>", "\n", "Hmm", ".", "\n", "</think>", "\n\n", "Hi"}, false, "Hmm.\n", "Hi"),
+		Entry("with tags split across tokens", []string{"<th", "ink>Hmm</", "thi", "nk>Hi"}, false, "Hmm", "Hi"),
+		Entry("with reasoning started by the prompt", []string{"Hmm", "</think>", "Hi"}, true, "Hmm", "Hi"),
+		Entry("with text looking like a tag", []string{"a <", "b", "> c"}, false, "", "a <b> c"),
+		Entry("with truncated reasoning", []string{"<think>", "Hmm </thi"}, false, "Hmm </thi", ""),
+	)
+
+	It("returns the tokens as content without tags", func() {
+		p := NewParser("", "", false)
+		r, c := p.Feed("<think>")
+		Expect(r).To(BeEmpty())
+		Expect(c).To(Equal("<think>"))
+	})
+})
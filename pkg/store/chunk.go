@@ -0,0 +1,57 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SplitText splits a text into chunks of at most size characters, consecutive chunks sharing about overlap
+// characters. Chunks end and start at whitespace when possible so that words are not cut. A size of 0 or
+// less keeps the text whole.
+func SplitText(text string, size, overlap int) []string {
+	r := []rune(strings.TrimSpace(text))
+	if len(r) == 0 {
+		return nil
+	}
+	if size <= 0 || len(r) <= size {
+		return []string{string(r)}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	chunks := []string{}
+	for start := 0; start < len(r); {
+		end := start + size
+		if end >= len(r) {
+			chunks = append(chunks, string(r[start:]))
+			break
+		}
+
+		// end the chunk before the last whitespace, unless it would leave no more than the overlap
+		for i := end; i > start+overlap; i-- {
+			if unicode.IsSpace(r[i]) {
+				end = i
+				break
+			}
+		}
+		if chunk := strings.TrimSpace(string(r[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+
+		// start the next chunk at the beginning of a word of the overlap
+		next := end - overlap
+		for next < end && !unicode.IsSpace(r[next-1]) {
+			next++
+		}
+		if next == end {
+			next = end - overlap
+		}
+		for next < len(r) && unicode.IsSpace(r[next]) {
+			next++
+		}
+		start = next
+	}
+
+	return chunks
+}
@@ -0,0 +1,43 @@
+package store_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/LocalAI/pkg/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SplitText", func() {
+	It("keeps short texts whole", func() {
+		Expect(SplitText("  the quick brown fox ", 100, 10)).To(Equal([]string{"the quick brown fox"}))
+		Expect(SplitText("the quick brown fox", 0, 0)).To(Equal([]string{"the quick brown fox"}))
+		Expect(SplitText(" \n ", 10, 0)).To(BeEmpty())
+	})
+
+	It("splits texts at whitespace", func() {
+		Expect(SplitText("the quick brown fox jumps over the lazy dog", 16, 0)).To(Equal([]string{
+			"the quick brown",
+			"fox jumps over",
+			"the lazy dog",
+		}))
+	})
+
+	It("repeats the end of the chunks at the start of the next ones", func() {
+		chunks := SplitText("the quick brown fox jumps over the lazy dog", 16, 6)
+		Expect(chunks).To(Equal([]string{
+			"the quick brown",
+			"brown fox jumps",
+			"jumps over the",
+			"the lazy dog",
+		}))
+	})
+
+	It("cuts words longer than the chunks", func() {
+		chunks := SplitText(strings.Repeat("a", 25), 10, 2)
+		Expect(chunks).To(Equal([]string{"aaaaaaaaaa", "aaaaaaaaaa", "aaaaaaaaa"}))
+		for _, c := range SplitText(strings.Repeat("ab ", 100), 7, 3) {
+			Expect(len(c)).To(BeNumerically("<=", 7))
+		}
+	})
+})
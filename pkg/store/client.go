@@ -10,9 +10,51 @@ import (
 
 // Wrapper for the GRPC client so that simple use cases are handled without verbosity
 
+type options struct {
+	namespace string
+	metadata  [][]byte
+	filter    []byte
+}
+
+type Option func(*options)
+
+// WithNamespace operates on a namespace of the store instead of the default one
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithMetadata sets the metadata of the keys which are set, metadata[i] being the JSON object of keys[i]
+func WithMetadata(metadata [][]byte) Option {
+	return func(o *options) {
+		o.metadata = metadata
+	}
+}
+
+// WithFilter only finds or deletes the keys whose metadata matches the JSON filter, see Filter
+func WithFilter(filter []byte) Option {
+	return func(o *options) {
+		o.filter = filter
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
 // SetCols sets multiple key-value pairs in the store
 // It's in columnar format so that keys[i] is associated with values[i]
-func SetCols(ctx context.Context, c grpc.Backend, keys [][]float32, values [][]byte) error {
+func SetCols(ctx context.Context, c grpc.Backend, keys [][]float32, values [][]byte, opts ...Option) error {
+	o := newOptions(opts)
+	if o.metadata != nil && len(o.metadata) != len(keys) {
+		return fmt.Errorf("len(keys) = %d, len(metadata) = %d", len(keys), len(o.metadata))
+	}
+
 	protoKeys := make([]*proto.StoresKey, len(keys))
 	for i, k := range keys {
 		protoKeys[i] = &proto.StoresKey{
@@ -24,10 +66,14 @@ func SetCols(ctx context.Context, c grpc.Backend, keys [][]float32, values [][]b
 		protoValues[i] = &proto.StoresValue{
 			Bytes: v,
 		}
+		if o.metadata != nil {
+			protoValues[i].Metadata = o.metadata[i]
+		}
 	}
 	setOpts := &proto.StoresSetOptions{
-		Keys:   protoKeys,
-		Values: protoValues,
+		Keys:      protoKeys,
+		Values:    protoValues,
+		Namespace: o.namespace,
 	}
 
 	res, err := c.StoresSet(ctx, setOpts)
@@ -44,13 +90,15 @@ func SetCols(ctx context.Context, c grpc.Backend, keys [][]float32, values [][]b
 
 // SetSingle sets a single key-value pair in the store
 // Don't call this in a tight loop, instead use SetCols
-func SetSingle(ctx context.Context, c grpc.Backend, key []float32, value []byte) error {
-	return SetCols(ctx, c, [][]float32{key}, [][]byte{value})
+func SetSingle(ctx context.Context, c grpc.Backend, key []float32, value []byte, opts ...Option) error {
+	return SetCols(ctx, c, [][]float32{key}, [][]byte{value}, opts...)
 }
 
 // DeleteCols deletes multiple key-value pairs from the store
 // It's in columnar format so that keys[i] is associated with values[i]
-func DeleteCols(ctx context.Context, c grpc.Backend, keys [][]float32) error {
+func DeleteCols(ctx context.Context, c grpc.Backend, keys [][]float32, opts ...Option) error {
+	o := newOptions(opts)
+
 	protoKeys := make([]*proto.StoresKey, len(keys))
 	for i, k := range keys {
 		protoKeys[i] = &proto.StoresKey{
@@ -58,7 +106,8 @@ func DeleteCols(ctx context.Context, c grpc.Backend, keys [][]float32) error {
 		}
 	}
 	deleteOpts := &proto.StoresDeleteOptions{
-		Keys: protoKeys,
+		Keys:      protoKeys,
+		Namespace: o.namespace,
 	}
 
 	res, err := c.StoresDelete(ctx, deleteOpts)
@@ -73,17 +122,43 @@ func DeleteCols(ctx context.Context, c grpc.Backend, keys [][]float32) error {
 	return fmt.Errorf("failed to delete keys: %v", res.Message)
 }
 
+// DeleteFiltered deletes the key-value pairs whose metadata matches the JSON filter, see Filter
+func DeleteFiltered(ctx context.Context, c grpc.Backend, filter []byte, opts ...Option) error {
+	o := newOptions(opts)
+
+	res, err := c.StoresDelete(ctx, &proto.StoresDeleteOptions{
+		Namespace: o.namespace,
+		Filter:    filter,
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.Success {
+		return nil
+	}
+
+	return fmt.Errorf("failed to delete keys: %v", res.Message)
+}
+
 // DeleteSingle deletes a single key-value pair from the store
 // Don't call this in a tight loop, instead use DeleteCols
-func DeleteSingle(ctx context.Context, c grpc.Backend, key []float32) error {
-	return DeleteCols(ctx, c, [][]float32{key})
+func DeleteSingle(ctx context.Context, c grpc.Backend, key []float32, opts ...Option) error {
+	return DeleteCols(ctx, c, [][]float32{key}, opts...)
 }
 
 // GetCols gets multiple key-value pairs from the store
 // It's in columnar format so that keys[i] is associated with values[i]
 // Be warned the keys are sorted and will be returned in a different order than they were input
 // There is no guarantee as to how the keys are sorted
-func GetCols(ctx context.Context, c grpc.Backend, keys [][]float32) ([][]float32, [][]byte, error) {
+func GetCols(ctx context.Context, c grpc.Backend, keys [][]float32, opts ...Option) ([][]float32, [][]byte, error) {
+	ks, vs, _, err := GetColsWithMetadata(ctx, c, keys, opts...)
+	return ks, vs, err
+}
+
+// GetColsWithMetadata is GetCols also returning the metadata of the keys, which is nil for the keys without
+func GetColsWithMetadata(ctx context.Context, c grpc.Backend, keys [][]float32, opts ...Option) ([][]float32, [][]byte, [][]byte, error) {
+	o := newOptions(opts)
 	protoKeys := make([]*proto.StoresKey, len(keys))
 	for i, k := range keys {
 		protoKeys[i] = &proto.StoresKey{
@@ -91,12 +166,13 @@ func GetCols(ctx context.Context, c grpc.Backend, keys [][]float32) ([][]float32
 		}
 	}
 	getOpts := &proto.StoresGetOptions{
-		Keys: protoKeys,
+		Keys:      protoKeys,
+		Namespace: o.namespace,
 	}
 
 	res, err := c.StoresGet(ctx, getOpts)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	ks := make([][]float32, len(res.Keys))
@@ -104,17 +180,19 @@ func GetCols(ctx context.Context, c grpc.Backend, keys [][]float32) ([][]float32
 		ks[i] = k.Floats
 	}
 	vs := make([][]byte, len(res.Values))
+	ms := make([][]byte, len(res.Values))
 	for i, v := range res.Values {
 		vs[i] = v.Bytes
+		ms[i] = v.Metadata
 	}
 
-	return ks, vs, nil
+	return ks, vs, ms, nil
 }
 
 // GetSingle gets a single key-value pair from the store
 // Don't call this in a tight loop, instead use GetCols
-func GetSingle(ctx context.Context, c grpc.Backend, key []float32) ([]byte, error) {
-	_, values, err := GetCols(ctx, c, [][]float32{key})
+func GetSingle(ctx context.Context, c grpc.Backend, key []float32, opts ...Option) ([]byte, error) {
+	_, values, err := GetCols(ctx, c, [][]float32{key}, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -127,21 +205,81 @@ func GetSingle(ctx context.Context, c grpc.Backend, key []float32) ([]byte, erro
 }
 
 // Find similar keys to the given key. Returns the keys, values, and similarities
-func Find(ctx context.Context, c grpc.Backend, key []float32, topk int) ([][]float32, [][]byte, []float32, error) {
+func Find(ctx context.Context, c grpc.Backend, key []float32, topk int, opts ...Option) ([][]float32, [][]byte, []float32, error) {
+	ks, vs, _, similarities, err := FindWithMetadata(ctx, c, key, topk, opts...)
+	return ks, vs, similarities, err
+}
+
+// FindWithMetadata is Find also returning the metadata of the keys, which is nil for the keys without
+func FindWithMetadata(ctx context.Context, c grpc.Backend, key []float32, topk int, opts ...Option) ([][]float32, [][]byte, [][]byte, []float32, error) {
+	o := newOptions(opts)
 	findOpts := &proto.StoresFindOptions{
 		Key: &proto.StoresKey{
 			Floats: key,
 		},
-		TopK: int32(topk),
+		TopK:      int32(topk),
+		Namespace: o.namespace,
+		Filter:    o.filter,
 	}
 
 	res, err := c.StoresFind(ctx, findOpts)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+
+	ks := make([][]float32, len(res.Keys))
+	vs := make([][]byte, len(res.Values))
+	ms := make([][]byte, len(res.Values))
+
+	for i, k := range res.Keys {
+		ks[i] = k.Floats
+	}
+
+	for i, v := range res.Values {
+		vs[i] = v.Bytes
+		ms[i] = v.Metadata
+	}
+
+	return ks, vs, ms, res.Similarities, nil
+}
+
+// Stats returns the entries, key length and memory use of each namespace of the store, with the options it was
+// loaded with
+func Stats(ctx context.Context, c grpc.Backend) (*proto.StoresStatsResult, error) {
+	return c.StoresStats(ctx, &proto.StoresStatsOptions{})
+}
+
+// Drop deletes all the entries of the store, in all its namespaces, along with its persisted files
+func Drop(ctx context.Context, c grpc.Backend) error {
+	res, err := c.StoresDrop(ctx, &proto.StoresDropOptions{})
+	if err != nil {
+		return err
+	}
+
+	if res.Success {
+		return nil
+	}
+
+	return fmt.Errorf("failed to drop the store: %v", res.Message)
+}
+
+// Export returns at most limit entries of the store from the offset-th one, all of them when limit is 0, with
+// the number of entries of the namespace. The entries are in a stable order as long as the store is not changed.
+func Export(ctx context.Context, c grpc.Backend, offset int64, limit int, opts ...Option) ([][]float32, [][]byte, [][]byte, int64, error) {
+	o := newOptions(opts)
+
+	res, err := c.StoresExport(ctx, &proto.StoresExportOptions{
+		Namespace: o.namespace,
+		Offset:    offset,
+		Limit:     int32(limit),
+	})
+	if err != nil {
+		return nil, nil, nil, 0, err
 	}
 
 	ks := make([][]float32, len(res.Keys))
 	vs := make([][]byte, len(res.Values))
+	ms := make([][]byte, len(res.Values))
 
 	for i, k := range res.Keys {
 		ks[i] = k.Floats
@@ -149,7 +287,8 @@ func Find(ctx context.Context, c grpc.Backend, key []float32, topk int) ([][]flo
 
 	for i, v := range res.Values {
 		vs[i] = v.Bytes
+		ms[i] = v.Metadata
 	}
 
-	return ks, vs, res.Similarities, nil
+	return ks, vs, ms, res.Total, nil
 }
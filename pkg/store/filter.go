@@ -0,0 +1,267 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Filter is a condition on the metadata of the entries of a store. Filters are JSON objects in the syntax
+// of MongoDB queries: every field of the object must match, either by being equal to the value or by
+// satisfying its operators. For example
+//
+//	{"author": "alice", "year": {"$gte": 2020, "$lt": 2024}, "tags": {"$in": ["go", "ai"]}}
+//
+// The operators are $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin and $exists, and conditions are combined
+// with $and, $or and $not. Nested fields are named with dots, and fields holding arrays match if one of
+// their elements does.
+type Filter struct {
+	match condition
+}
+
+type condition func(metadata map[string]any) bool
+
+// ParseFilter parses a filter, which is nil if empty
+func ParseFilter(filter []byte) (*Filter, error) {
+	if len(filter) == 0 {
+		return nil, nil
+	}
+
+	var f map[string]any
+	if err := json.Unmarshal(filter, &f); err != nil {
+		return nil, fmt.Errorf("the filter must be a JSON object: %w", err)
+	}
+	if f == nil {
+		return nil, nil
+	}
+
+	match, err := compileFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{match: match}, nil
+}
+
+// Match reports whether the metadata satisfies the filter. A nil filter matches everything.
+func (f *Filter) Match(metadata map[string]any) bool {
+	return f == nil || f.match(metadata)
+}
+
+func compileFilter(f map[string]any) (condition, error) {
+	conditions := make([]condition, 0, len(f))
+
+	for k, v := range f {
+		switch k {
+		case "$and", "$or":
+			filters, ok := v.([]any)
+			if !ok || len(filters) == 0 {
+				return nil, fmt.Errorf("%s must be a non-empty array of filters", k)
+			}
+			subConditions := make([]condition, len(filters))
+			for i, sub := range filters {
+				subFilter, ok := sub.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("%s must be a non-empty array of filters", k)
+				}
+				c, err := compileFilter(subFilter)
+				if err != nil {
+					return nil, err
+				}
+				subConditions[i] = c
+			}
+			if k == "$and" {
+				conditions = append(conditions, all(subConditions))
+			} else {
+				conditions = append(conditions, anyOf(subConditions))
+			}
+		case "$not":
+			subFilter, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("$not must be a filter")
+			}
+			c, err := compileFilter(subFilter)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, func(metadata map[string]any) bool { return !c(metadata) })
+		default:
+			if strings.HasPrefix(k, "$") {
+				return nil, fmt.Errorf("unknown operator %s", k)
+			}
+			c, err := compileField(k, v)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, c)
+		}
+	}
+
+	return all(conditions), nil
+}
+
+func all(conditions []condition) condition {
+	return func(metadata map[string]any) bool {
+		for _, c := range conditions {
+			if !c(metadata) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func anyOf(conditions []condition) condition {
+	return func(metadata map[string]any) bool {
+		for _, c := range conditions {
+			if c(metadata) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// compileField compiles the condition on a field, which is either a value or an object of operators
+func compileField(path string, v any) (condition, error) {
+	operators, ok := v.(map[string]any)
+	if !ok || !hasOperators(operators) {
+		return fieldCondition(path, func(value any, exists bool) bool {
+			return exists && matchesAny(value, func(e any) bool { return reflect.DeepEqual(e, v) })
+		}), nil
+	}
+
+	conditions := make([]condition, 0, len(operators))
+	for op, operand := range operators {
+		if !strings.HasPrefix(op, "$") {
+			return nil, fmt.Errorf("the field %s mixes operators and values", path)
+		}
+
+		var match func(value any, exists bool) bool
+		switch op {
+		case "$eq", "$ne":
+			eq := func(value any, exists bool) bool {
+				return exists && matchesAny(value, func(e any) bool { return reflect.DeepEqual(e, operand) })
+			}
+			match = eq
+			if op == "$ne" {
+				match = func(value any, exists bool) bool { return !eq(value, exists) }
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			switch operand.(type) {
+			case float64, string:
+			default:
+				return nil, fmt.Errorf("%s of the field %s must be a number or a string", op, path)
+			}
+			accept := map[string]func(int) bool{
+				"$gt":  func(c int) bool { return c > 0 },
+				"$gte": func(c int) bool { return c >= 0 },
+				"$lt":  func(c int) bool { return c < 0 },
+				"$lte": func(c int) bool { return c <= 0 },
+			}[op]
+			match = func(value any, exists bool) bool {
+				return exists && matchesAny(value, func(e any) bool {
+					c, ok := compare(e, operand)
+					return ok && accept(c)
+				})
+			}
+		case "$in", "$nin":
+			values, ok := operand.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s of the field %s must be an array", op, path)
+			}
+			in := func(value any, exists bool) bool {
+				return exists && matchesAny(value, func(e any) bool {
+					for _, v := range values {
+						if reflect.DeepEqual(e, v) {
+							return true
+						}
+					}
+					return false
+				})
+			}
+			match = in
+			if op == "$nin" {
+				match = func(value any, exists bool) bool { return !in(value, exists) }
+			}
+		case "$exists":
+			want, ok := operand.(bool)
+			if !ok {
+				return nil, fmt.Errorf("$exists of the field %s must be a boolean", path)
+			}
+			match = func(_ any, exists bool) bool { return exists == want }
+		default:
+			return nil, fmt.Errorf("unknown operator %s", op)
+		}
+
+		conditions = append(conditions, fieldCondition(path, match))
+	}
+
+	return all(conditions), nil
+}
+
+func hasOperators(object map[string]any) bool {
+	for k := range object {
+		if strings.HasPrefix(k, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldCondition(path string, match func(value any, exists bool) bool) condition {
+	fields := strings.Split(path, ".")
+	return func(metadata map[string]any) bool {
+		value, exists := lookup(metadata, fields)
+		return match(value, exists)
+	}
+}
+
+func lookup(metadata map[string]any, fields []string) (any, bool) {
+	var value any = metadata
+	for _, f := range fields {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[f]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// matchesAny applies the match to the value and, if it is an array, to its elements
+func matchesAny(value any, match func(any) bool) bool {
+	if match(value) {
+		return true
+	}
+	if elements, ok := value.([]any); ok {
+		for _, e := range elements {
+			if match(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compare orders two numbers or two strings, returning false for other values
+func compare(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			if a < b {
+				return -1, true
+			} else if a > b {
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package store_test
+
+import (
+	"encoding/json"
+
+	. "github.com/mudler/LocalAI/pkg/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filter", func() {
+	metadata := func(s string) map[string]any {
+		var m map[string]any
+		Expect(json.Unmarshal([]byte(s), &m)).To(Succeed())
+		return m
+	}
+
+	doc := `{"author": "alice", "year": 2021, "tags": ["go", "ai"], "source": {"kind": "pdf", "pages": 12}, "draft": false}`
+
+	DescribeTable("matches the metadata",
+		func(filter string, expected bool) {
+			f, err := ParseFilter([]byte(filter))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Match(metadata(doc))).To(Equal(expected))
+		},
+		Entry("equal value", `{"author": "alice"}`, true),
+		Entry("different value", `{"author": "bob"}`, false),
+		Entry("all the fields", `{"author": "alice", "year": 2020}`, false),
+		Entry("$eq", `{"draft": {"$eq": false}}`, true),
+		Entry("$ne", `{"author": {"$ne": "bob"}}`, true),
+		Entry("$ne of a missing field", `{"missing": {"$ne": "bob"}}`, true),
+		Entry("range", `{"year": {"$gte": 2020, "$lt": 2022}}`, true),
+		Entry("out of the range", `{"year": {"$gt": 2021}}`, false),
+		Entry("string range", `{"author": {"$lte": "b"}}`, true),
+		Entry("range of another type", `{"author": {"$gt": 1}}`, false),
+		Entry("$in", `{"author": {"$in": ["bob", "alice"]}}`, true),
+		Entry("$nin", `{"author": {"$nin": ["bob", "alice"]}}`, false),
+		Entry("element of an array", `{"tags": "ai"}`, true),
+		Entry("$in an array", `{"tags": {"$in": ["rust", "go"]}}`, true),
+		Entry("whole array", `{"tags": ["go", "ai"]}`, true),
+		Entry("nested field", `{"source.kind": "pdf", "source.pages": {"$gt": 10}}`, true),
+		Entry("missing nested field", `{"source.kind.name": "pdf"}`, false),
+		Entry("$exists", `{"source": {"$exists": true}, "missing": {"$exists": false}}`, true),
+		Entry("$or", `{"$or": [{"author": "bob"}, {"year": 2021}]}`, true),
+		Entry("$and", `{"$and": [{"author": "alice"}, {"year": 2020}]}`, false),
+		Entry("$not", `{"$not": {"author": "bob"}}`, true),
+		Entry("empty filter", `{}`, true),
+	)
+
+	It("matches everything without a filter", func() {
+		f, err := ParseFilter(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f).To(BeNil())
+		Expect(f.Match(nil)).To(BeTrue())
+	})
+
+	It("does not match entries without metadata", func() {
+		f, err := ParseFilter([]byte(`{"author": "alice"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Match(nil)).To(BeFalse())
+	})
+
+	DescribeTable("rejects invalid filters",
+		func(filter string) {
+			_, err := ParseFilter([]byte(filter))
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("not an object", `["author"]`),
+		Entry("unknown operator", `{"year": {"$near": 2020}}`),
+		Entry("unknown logical operator", `{"$xor": [{"year": 2020}]}`),
+		Entry("mixed operators and values", `{"source": {"$exists": true, "kind": "pdf"}}`),
+		Entry("$in without an array", `{"author": {"$in": "alice"}}`),
+		Entry("range of an object", `{"year": {"$gt": {}}}`),
+		Entry("empty $or", `{"$or": []}`),
+	)
+})